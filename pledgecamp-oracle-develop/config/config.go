@@ -0,0 +1,280 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/connect"
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/cron"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/jwt"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
+	"github.com/pledgecamp/pledgecamp-oracle/ratelimit"
+)
+
+// secretKeys - settings that may be given as <KEY>_FILE and whose values never appear in the logs
+var secretKeys = []string{
+	"APP_AUTH_ACCESS_TOKEN",
+	"BACKEND_AUTH_ACCESS_TOKEN",
+	"NODESERVER_AUTH_ACCESS_TOKEN",
+	"NODESERVER_CALLBACK_SECRET",
+	"DB_PASS",
+}
+
+// App - how the Oracle is reached and how long its requests and jobs may take. Domain is the base URL
+// Nodeserver posts callbacks to
+type App struct {
+	Domain             string
+	Port               string
+	AuthAccessToken    string
+	CorsAllowedOrigins string
+	EnvMode            string
+	LogLevel           string
+	IdempotencyTTL     time.Duration
+	IdempotencyLease   time.Duration
+	ReadinessTimeout   time.Duration
+	ShutdownTimeout    time.Duration
+}
+
+// Backend - location and credentials of the main Pledgecamp API
+type Backend struct {
+	URL         string
+	AccessToken string
+}
+
+// Jobs - schedules of the jobs the job worker runs from the jobs table. The worker looks for due jobs every
+// PollInterval and holds a job for LeaseTTL while it runs. A failed run is retried after RetryBaseDelay,
+// doubled on each failure up to RetryMaxDelay, unless the job is due again sooner
+type Jobs struct {
+	Milestone      cron.Schedule
+	Recovery       cron.Schedule
+	Cancellation   cron.Schedule
+	Interest       cron.Schedule
+	Reconciliation cron.Schedule
+	Idempotency    cron.Schedule
+	// InterestAmount - interest posted for the CampShares holders on each run of the interest job, none when 0
+	InterestAmount int
+	PollInterval   time.Duration
+	LeaseTTL       time.Duration
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// ReconcileAfter - age of a pending activity before the reconciliation job asks Nodeserver about it,
+	// ActivityTimeout - age of a pending activity Nodeserver did not settle before it times out
+	ReconcileAfter  time.Duration
+	ActivityTimeout time.Duration
+}
+
+// CampShares - contract parameters of CampShares
+type CampShares struct {
+	UnstakePeriod time.Duration
+}
+
+// Outbox - schedule of the outbox dispatcher
+type Outbox struct {
+	Interval    time.Duration
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	MaxAttempts int
+	BatchSize   int
+}
+
+// Leader - election of the one Oracle instance that runs the scheduler jobs. InstanceID names this instance
+// in the lease, which it renews every third of LeaseTTL
+type Leader struct {
+	InstanceID string
+	LeaseTTL   time.Duration
+}
+
+// Config - every setting of the Oracle, loaded and checked at startup by Load
+type Config struct {
+	App        App
+	Backend    Backend
+	Database   connect.Config
+	NodeServer nodeserver.Config
+	JWT        jwt.Config
+	RateLimit  ratelimit.Config
+	Outbox     Outbox
+	Leader     Leader
+	Jobs       Jobs
+	CampShares CampShares
+}
+
+// Load - settings from the environment, over those of the YAML file at CONFIG_FILE when it is set. Secrets
+// may be given as <KEY>_FILE instead. Every missing or invalid setting is reported in the error
+func Load() (Config, error) {
+	s := &source{values: map[string]string{}}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		s.readFile(path)
+	}
+	s.readEnv(os.Environ())
+	s.readSecretFiles()
+
+	config := s.config()
+	if len(s.problems) > 0 {
+		sort.Strings(s.problems)
+		return config, errs.New(errs.Validation, "Invalid configuration: %s", strings.Join(s.problems, "; "))
+	}
+	return config, nil
+}
+
+// config - typed settings of s, recording a problem for each one that is missing or out of range
+func (s *source) config() Config {
+	return Config{
+		App: App{
+			Domain:             s.url("APP_DOMAIN", ""),
+			Port:               s.port("APP_PORT"),
+			AuthAccessToken:    s.str("APP_AUTH_ACCESS_TOKEN", ""),
+			CorsAllowedOrigins: s.required("CORS_ALLOWED_ORIGINS"),
+			EnvMode:            s.required("ENV_MODE"),
+			LogLevel:           s.oneOf("LOG_LEVEL", "info", "debug", "info", "warn", "error"),
+			IdempotencyTTL:     s.milliseconds("IDEMPOTENCY_KEY_TTL", 24*time.Hour, time.Millisecond),
+			IdempotencyLease:   s.milliseconds("IDEMPOTENCY_KEY_LEASE", time.Minute, time.Second),
+			ReadinessTimeout:   s.milliseconds("READINESS_TIMEOUT", 2*time.Second, time.Millisecond),
+			ShutdownTimeout:    s.milliseconds("SHUTDOWN_TIMEOUT", 30*time.Second, 0),
+		},
+		Backend: Backend{
+			URL:         s.url("BACKEND_URL", ""),
+			AccessToken: s.required("BACKEND_AUTH_ACCESS_TOKEN"),
+		},
+		Database: s.database(),
+		NodeServer: nodeserver.Config{
+			URL:              s.url("NODESERVER_URL", ""),
+			AccessToken:      s.required("NODESERVER_AUTH_ACCESS_TOKEN"),
+			MonitorURI:       s.str("NODESERVER_MONITOR_URI", "/monitor"),
+			Timeout:          s.milliseconds("NODESERVER_TIMEOUT", 10*time.Second, time.Millisecond),
+			MaxAttempts:      s.number("NODESERVER_RETRY_MAX_ATTEMPTS", 3, 0),
+			RetryBaseDelay:   s.milliseconds("NODESERVER_RETRY_BASE_DELAY", 200*time.Millisecond, time.Millisecond),
+			RetryMaxDelay:    s.milliseconds("NODESERVER_RETRY_MAX_DELAY", 5*time.Second, time.Millisecond),
+			BreakerThreshold: s.number("NODESERVER_BREAKER_THRESHOLD", 5, 0),
+			BreakerCooldown:  s.milliseconds("NODESERVER_BREAKER_COOLDOWN", 30*time.Second, time.Millisecond),
+			CallbackSecret:   s.str("NODESERVER_CALLBACK_SECRET", ""),
+			CallbackWindow:   s.milliseconds("NODESERVER_CALLBACK_WINDOW", 5*time.Minute, time.Second),
+		},
+		JWT: jwt.Config{
+			JWKSFile:       s.str("JWT_JWKS_FILE", ""),
+			ReloadInterval: s.milliseconds("JWT_JWKS_RELOAD_INTERVAL", time.Minute, 0),
+			Issuer:         s.str("JWT_ISSUER", ""),
+			Audience:       s.str("JWT_AUDIENCE", ""),
+			Leeway:         s.milliseconds("JWT_LEEWAY", 30*time.Second, 0),
+		},
+		RateLimit: s.rateLimit(),
+		Outbox: Outbox{
+			Interval:    s.milliseconds("OUTBOX_INTERVAL", time.Second, time.Millisecond),
+			BaseDelay:   s.milliseconds("OUTBOX_RETRY_BASE_DELAY", time.Second, time.Millisecond),
+			MaxDelay:    s.milliseconds("OUTBOX_RETRY_MAX_DELAY", 10*time.Minute, time.Millisecond),
+			MaxAttempts: s.number("OUTBOX_MAX_ATTEMPTS", 10, 1),
+			BatchSize:   100,
+		},
+		Leader: Leader{
+			InstanceID: s.str("LEADER_INSTANCE_ID", instanceID()),
+			LeaseTTL:   s.milliseconds("LEADER_LEASE_TTL", 15*time.Second, time.Second),
+		},
+		Jobs: Jobs{
+			Milestone:       s.schedule("JOBS_MILESTONE_SCHEDULE", "INTERVALS_CHECK_MILESTONE", -1),
+			Recovery:        s.schedule("JOBS_RECOVERY_SCHEDULE", "INTERVALS_FUND_RECOVERY", -1),
+			Cancellation:    s.schedule("JOBS_CANCELLATION_SCHEDULE", "INTERVALS_CANCEL_PROJECT", 5*time.Minute),
+			Interest:        s.cronSchedule("JOBS_INTEREST_SCHEDULE", "@daily"),
+			Reconciliation:  s.cronSchedule("JOBS_RECONCILIATION_SCHEDULE", "*/5 * * * *"),
+			Idempotency:     s.cronSchedule("JOBS_IDEMPOTENCY_SCHEDULE", "@hourly"),
+			InterestAmount:  s.number("JOBS_INTEREST_AMOUNT", 0, 0),
+			PollInterval:    s.milliseconds("JOBS_POLL_INTERVAL", time.Second, 100*time.Millisecond),
+			LeaseTTL:        s.milliseconds("JOBS_LEASE_TTL", 10*time.Minute, time.Second),
+			RetryBaseDelay:  s.milliseconds("JOBS_RETRY_BASE_DELAY", 10*time.Second, time.Second),
+			RetryMaxDelay:   s.milliseconds("JOBS_RETRY_MAX_DELAY", 10*time.Minute, time.Second),
+			ReconcileAfter:  s.milliseconds("JOBS_RECONCILE_AFTER", 10*time.Minute, time.Second),
+			ActivityTimeout: s.milliseconds("JOBS_ACTIVITY_TIMEOUT", time.Hour, time.Second),
+		},
+		CampShares: CampShares{
+			UnstakePeriod: s.duration("CS_UNSTAKE_PERIOD", time.Second, -1, 0),
+		},
+	}
+}
+
+// database - the database of DB_DRIVER, a Postgres server unless it is sqlite, then the file at DB_FILE
+func (s *source) database() connect.Config {
+	config := connect.Config{
+		Driver: s.oneOf("DB_DRIVER", connect.DriverPostgres, connect.DriverPostgres, connect.DriverSQLite),
+		Pool:   s.pool(),
+	}
+	if config.Driver == connect.DriverSQLite {
+		config.File = s.required("DB_FILE")
+		return config
+	}
+	config.Host = s.required("DB_HOST")
+	config.Port = s.port("DB_PORT")
+	config.Name = s.required("DB_NAME")
+	config.User = s.required("DB_USER")
+	config.Password = s.required("DB_PASS")
+	config.SSLMode = s.oneOf("DB_SSL_MODE", "disable", "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	return config
+}
+
+// pool - sizing and timeouts of the shared pool from DB_POOL_* and DB_QUERY_TIMEOUT
+func (s *source) pool() connect.PoolConfig {
+	pool := connect.PoolConfig{
+		MaxOpenConns:    s.number("DB_POOL_MAX_OPEN", 10, 1),
+		MaxIdleConns:    s.number("DB_POOL_MAX_IDLE", 5, 0),
+		ConnMaxIdleTime: s.milliseconds("DB_POOL_IDLE_TIMEOUT", 5*time.Minute, 0),
+		ConnMaxLifetime: s.milliseconds("DB_POOL_MAX_LIFETIME", 30*time.Minute, 0),
+		QueryTimeout:    s.milliseconds("DB_QUERY_TIMEOUT", 5*time.Second, time.Millisecond),
+	}
+	if pool.MaxIdleConns > pool.MaxOpenConns {
+		s.problem("DB_POOL_MAX_IDLE must not be above DB_POOL_MAX_OPEN, got %d and %d", pool.MaxIdleConns, pool.MaxOpenConns)
+	}
+	return pool
+}
+
+// rateLimit - limits from RATE_LIMIT_<GROUP>_PER_MINUTE and RATE_LIMIT_<GROUP>_BURST of every route group.
+// A rate of 0 disables the limit of a group and a burst of 0 defaults to the rate
+func (s *source) rateLimit() ratelimit.Config {
+	config := ratelimit.Config{Limits: map[constants.RouteGroup]ratelimit.Limit{}}
+	for _, group := range constants.RouteGroups {
+		prefix := "RATE_LIMIT_" + strings.ToUpper(string(group))
+		fallback := ratelimit.DefaultLimits[group]
+		limit := ratelimit.Limit{
+			PerMinute: s.number(prefix+"_PER_MINUTE", fallback.PerMinute, 0),
+			Burst:     s.number(prefix+"_BURST", fallback.Burst, 0),
+		}
+		if limit.Burst == 0 {
+			limit.Burst = limit.PerMinute
+		}
+		if limit.PerMinute > 0 && limit.Burst > 0 {
+			config.Limits[group] = limit
+		}
+	}
+	return config
+}
+
+// instanceID - default name of this instance, unique among the replicas on the same or different hosts
+func instanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "oracle"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// isSecret - whether key is one of the secretKeys
+func isSecret(key string) bool {
+	for _, secret := range secretKeys {
+		if key == secret {
+			return true
+		}
+	}
+	return false
+}
+
+// Secrets - values of the configured tokens and secrets, to be redacted from the logs
+func (c Config) Secrets() []string {
+	return []string{
+		c.App.AuthAccessToken,
+		c.Backend.AccessToken,
+		c.NodeServer.AccessToken,
+		c.NodeServer.CallbackSecret,
+		c.Database.Password,
+	}
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/cron"
+	"gopkg.in/yaml.v2"
+)
+
+// source - raw settings keyed by env name, and the problems found while reading them
+type source struct {
+	values   map[string]string
+	problems []string
+}
+
+// problem - record a setting that cannot be used, reported by Load with every other one
+func (s *source) problem(format string, args ...interface{}) {
+	s.problems = append(s.problems, fmt.Sprintf(format, args...))
+}
+
+// readFile - settings of a YAML file. Nested keys are joined with "_", so `nodeserver: {timeout: 10000}` sets
+// NODESERVER_TIMEOUT
+func (s *source) readFile(path string) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		s.problem("CONFIG_FILE could not be read: %v", err)
+		return
+	}
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(content, &settings); err != nil {
+		s.problem("CONFIG_FILE is not valid YAML: %v", err)
+		return
+	}
+	s.flatten("", settings)
+}
+
+// flatten - add the scalar settings under prefix, mappings are walked and lists rejected
+func (s *source) flatten(prefix string, settings map[string]interface{}) {
+	for key, value := range settings {
+		name := strings.ToUpper(prefix + key)
+		switch value := value.(type) {
+		case nil:
+			s.values[name] = ""
+		case map[interface{}]interface{}:
+			nested := map[string]interface{}{}
+			for nestedKey, nestedValue := range value {
+				nested[fmt.Sprint(nestedKey)] = nestedValue
+			}
+			s.flatten(name+"_", nested)
+		case []interface{}:
+			s.problem("%s in CONFIG_FILE must be a single value", name)
+		default:
+			s.values[name] = fmt.Sprint(value)
+		}
+	}
+}
+
+// readEnv - settings of the environment, which take precedence over the file
+func (s *source) readEnv(environ []string) {
+	for _, variable := range environ {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) == 2 {
+			s.values[parts[0]] = parts[1]
+		}
+	}
+}
+
+// readSecretFiles - settings given as <KEY>_FILE, the path of a file holding the value as mounted by Docker
+// and Kubernetes secrets. Trailing newlines of the file are dropped
+func (s *source) readSecretFiles() {
+	for key, path := range s.values {
+		if !strings.HasSuffix(key, "_FILE") || path == "" || !isSecret(strings.TrimSuffix(key, "_FILE")) {
+			continue
+		}
+		name := strings.TrimSuffix(key, "_FILE")
+		if s.values[name] != "" {
+			s.problem("Only one of %s and %s may be set", name, key)
+			continue
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			s.problem("%s could not be read: %v", key, err)
+			continue
+		}
+		s.values[name] = strings.TrimRight(string(content), "\r\n")
+	}
+}
+
+// str - value of key, fallback when it is not set
+func (s *source) str(key string, fallback string) string {
+	if value := strings.TrimSpace(s.values[key]); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// required - value of key, a problem when it is not set
+func (s *source) required(key string) string {
+	value := s.str(key, "")
+	if value == "" {
+		s.problem("%s is required", key)
+	}
+	return value
+}
+
+// oneOf - value of key, which must be one of allowed
+func (s *source) oneOf(key string, fallback string, allowed ...string) string {
+	value := s.str(key, fallback)
+	for _, option := range allowed {
+		if strings.EqualFold(value, option) {
+			return option
+		}
+	}
+	s.problem("%s must be one of %s, got %q", key, strings.Join(allowed, ", "), value)
+	return fallback
+}
+
+// url - value of key, which must be an absolute http or https URL. An empty fallback makes it required
+func (s *source) url(key string, fallback string) string {
+	value := s.str(key, fallback)
+	if value == "" {
+		return s.required(key)
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		s.problem("%s must be an http or https URL, got %q", key, value)
+	}
+	return strings.TrimSuffix(value, "/")
+}
+
+// port - value of key, which must be a TCP port
+func (s *source) port(key string) string {
+	value := s.required(key)
+	if value == "" {
+		return value
+	}
+	if port, err := strconv.Atoi(value); err != nil || port < 1 || port > 65535 {
+		s.problem("%s must be a port between 1 and 65535, got %q", key, value)
+	}
+	return value
+}
+
+// number - value of key, which must be an integer of at least minimum. A negative fallback makes it required
+func (s *source) number(key string, fallback int, minimum int) int {
+	value := s.str(key, "")
+	if value == "" {
+		if fallback < 0 {
+			s.problem("%s is required", key)
+		}
+		return fallback
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		s.problem("%s must be a whole number, got %q", key, value)
+		return fallback
+	}
+	if number < minimum {
+		s.problem("%s must be at least %d, got %d", key, minimum, number)
+	}
+	return number
+}
+
+// duration - value of key given in unit, which must be at least minimum. A negative fallback makes it required
+func (s *source) duration(key string, unit time.Duration, fallback time.Duration, minimum time.Duration) time.Duration {
+	value := s.str(key, "")
+	if value == "" {
+		if fallback < 0 {
+			s.problem("%s is required", key)
+		}
+		return fallback
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		s.problem("%s must be a whole number of %s, got %q", key, unitName(unit), value)
+		return fallback
+	}
+	duration := time.Duration(number) * unit
+	if duration < minimum {
+		s.problem("%s must be at least %d %s, got %d", key, int64(minimum/unit), unitName(unit), number)
+	}
+	return duration
+}
+
+// milliseconds - duration of key given in milliseconds
+func (s *source) milliseconds(key string, fallback time.Duration, minimum time.Duration) time.Duration {
+	return s.duration(key, time.Millisecond, fallback, minimum)
+}
+
+// cronSchedule - cron schedule of key, see cron.Parse, which must be due at some point
+func (s *source) cronSchedule(key string, fallback string) cron.Schedule {
+	spec := s.str(key, fallback)
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		s.problem("%s must be a cron schedule: %v", key, err)
+		return schedule
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		s.problem("%s is never due, got %q", key, spec)
+	}
+	return schedule
+}
+
+// schedule - cron schedule of key, or every interval of intervalKey in milliseconds, at least a second, when
+// key is not set. A negative fallback makes intervalKey required unless key is set
+func (s *source) schedule(key string, intervalKey string, fallback time.Duration) cron.Schedule {
+	if s.str(key, "") != "" {
+		return s.cronSchedule(key, "")
+	}
+	return cron.Every(s.milliseconds(intervalKey, fallback, time.Second))
+}
+
+func unitName(unit time.Duration) string {
+	if unit == time.Second {
+		return "seconds"
+	}
+	return "milliseconds"
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/connect"
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+)
+
+// testEnv - required settings and the intervals the tests expect, set for every load
+var testEnv = map[string]string{
+	"APP_DOMAIN":                   "http://localhost:4010",
+	"APP_PORT":                     "4010",
+	"BACKEND_AUTH_ACCESS_TOKEN":    "test_backend",
+	"BACKEND_URL":                  "http://localhost:5010",
+	"CORS_ALLOWED_ORIGINS":         "*",
+	"CS_UNSTAKE_PERIOD":            "90",
+	"DB_HOST":                      "127.0.0.1",
+	"DB_NAME":                      "pledgecamp_oracle_test",
+	"DB_PASS":                      "test",
+	"DB_PORT":                      "6012",
+	"DB_USER":                      "pledgecamp_oracle",
+	"ENV_MODE":                     "test",
+	"INTERVALS_CHECK_MILESTONE":    "500000",
+	"INTERVALS_FUND_RECOVERY":      "100000",
+	"NODESERVER_AUTH_ACCESS_TOKEN": "test_internal",
+	"NODESERVER_URL":               "http://localhost:3010/api",
+}
+
+// loadConfig - configuration loaded with env set to testEnv and values, an empty value unsets the key. The
+// previous values are restored afterwards
+func loadConfig(values map[string]string) (Config, error) {
+	settings := map[string]string{}
+	for key, value := range testEnv {
+		settings[key] = value
+	}
+	for key, value := range values {
+		settings[key] = value
+	}
+	previous := map[string]string{}
+	for key, value := range settings {
+		previous[key] = os.Getenv(key)
+		if value == "" {
+			os.Unsetenv(key)
+		} else {
+			os.Setenv(key, value)
+		}
+	}
+	defer func() {
+		for key, value := range previous {
+			os.Setenv(key, value)
+			if value == "" {
+				os.Unsetenv(key)
+			}
+		}
+	}()
+	return Load()
+}
+
+// Tests for config.go
+func TestConfig(t *testing.T) {
+	log.Println("********************************* TestConfig() **************************************")
+	directory, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	configFile := directory + "/oracle.yaml"
+	ioutil.WriteFile(configFile, []byte("nodeserver:\n  timeout: 2500\n  monitor_uri: /health\noutbox_max_attempts: 4\n"), 0600)
+	secretFile := directory + "/db_pass"
+	ioutil.WriteFile(secretFile, []byte("from-secret-file\n"), 0600)
+
+	// Env takes precedence over the file and secrets may be read from files
+	loaded, err := loadConfig(map[string]string{
+		"CONFIG_FILE":         configFile,
+		"OUTBOX_MAX_ATTEMPTS": "6",
+		"DB_PASS":             "",
+		"DB_PASS_FILE":        secretFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.NodeServer.Timeout != 2500*time.Millisecond || loaded.NodeServer.MonitorURI != "/health" {
+		t.Errorf("Expected the Nodeserver settings of the file, got %v and %v", loaded.NodeServer.Timeout, loaded.NodeServer.MonitorURI)
+	}
+	if loaded.Outbox.MaxAttempts != 6 {
+		t.Errorf("Expected env to override the file, got %d", loaded.Outbox.MaxAttempts)
+	}
+	if loaded.Database.Password != "from-secret-file" {
+		t.Errorf("Expected the password of the secret file, got %q", loaded.Database.Password)
+	}
+	if loaded.CampShares.UnstakePeriod != 90*time.Second || loaded.Jobs.Recovery.String() != "@every 1m40s" {
+		t.Errorf("Expected typed durations, got %v and %v", loaded.CampShares.UnstakePeriod, loaded.Jobs.Recovery)
+	}
+
+	// A cron schedule takes the place of the interval
+	loaded, err = loadConfig(map[string]string{
+		"INTERVALS_CHECK_MILESTONE": "",
+		"JOBS_MILESTONE_SCHEDULE":   "*/5 * * * *",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Jobs.Milestone.String() != "*/5 * * * *" || loaded.Jobs.Interest.String() != "@daily" {
+		t.Errorf("Expected the cron schedules, got %v and %v", loaded.Jobs.Milestone, loaded.Jobs.Interest)
+	}
+	if loaded.Jobs.Reconciliation.String() != "*/5 * * * *" || loaded.Jobs.ReconcileAfter != 10*time.Minute || loaded.Jobs.ActivityTimeout != time.Hour {
+		t.Errorf("Expected the reconciliation defaults, got %v, %v and %v", loaded.Jobs.Reconciliation, loaded.Jobs.ReconcileAfter, loaded.Jobs.ActivityTimeout)
+	}
+	if loaded.Jobs.Idempotency.String() != "@hourly" || loaded.App.IdempotencyLease != time.Minute {
+		t.Errorf("Expected the idempotency defaults, got %v and %v", loaded.Jobs.Idempotency, loaded.App.IdempotencyLease)
+	}
+
+	// Every problem is reported at once instead of starting with a 0ms interval
+	_, err = loadConfig(map[string]string{
+		"INTERVALS_CHECK_MILESTONE":  "",
+		"INTERVALS_FUND_RECOVERY":    "soon",
+		"OUTBOX_INTERVAL":            "0",
+		"BACKEND_URL":                "backend.localdev.com",
+		"DB_PASS_FILE":               secretFile,
+		"JOBS_CANCELLATION_SCHEDULE": "61 * * * *",
+		"JOBS_INTEREST_SCHEDULE":     "0 0 30 2 *",
+	})
+	if !errs.Is(err, errs.Validation) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	for _, problem := range []string{
+		"INTERVALS_CHECK_MILESTONE is required",
+		"INTERVALS_FUND_RECOVERY must be a whole number of milliseconds",
+		"OUTBOX_INTERVAL must be at least 1 milliseconds",
+		"BACKEND_URL must be an http or https URL",
+		"Only one of DB_PASS and DB_PASS_FILE may be set",
+		"JOBS_CANCELLATION_SCHEDULE must be a cron schedule",
+		"JOBS_INTEREST_SCHEDULE is never due",
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected %q to be reported, got %v", problem, err)
+		}
+	}
+
+	// A SQLite database only needs its file
+	loaded, err = loadConfig(map[string]string{"DB_DRIVER": "sqlite", "DB_FILE": directory + "/oracle.db", "DB_HOST": "", "DB_PASS": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Database.Driver != connect.DriverSQLite || loaded.Database.File != directory+"/oracle.db" {
+		t.Errorf("Expected the SQLite file, got %+v", loaded.Database)
+	}
+	_, err = loadConfig(map[string]string{"DB_DRIVER": "sqlite", "DB_FILE": ""})
+	if err == nil || !strings.Contains(err.Error(), "DB_FILE is required") {
+		t.Errorf("Expected DB_FILE to be required, got %v", err)
+	}
+	log.Println("********************************* End TestConfig() **************************************")
+}
+
+func TestRateLimitConfig(t *testing.T) {
+	log.Println("********************************* TestRateLimitConfig() **************************************")
+	loaded, err := loadConfig(map[string]string{
+		"RATE_LIMIT_USERS_PER_MINUTE":    "0",
+		"RATE_LIMIT_TREASURY_PER_MINUTE": "10",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.RateLimit.Limits[constants.RouteUsers]; ok {
+		t.Error("Expected a rate of 0 to disable the limit")
+	}
+	if limit := loaded.RateLimit.Limits[constants.RouteTreasury]; limit.PerMinute != 10 || limit.Burst != 10 {
+		t.Errorf("Expected the burst to default to the rate, got %+v", limit)
+	}
+	log.Println("********************************* End TestRateLimitConfig() **************************************")
+}
@@ -2,7 +2,6 @@ package connect
 
 import (
 	"log"
-	"os"
 
 	"github.com/joho/godotenv"
 )
@@ -16,12 +15,3 @@ func init() {
 		}
 	}
 }
-
-func LookupEnvOrExit(key string) string {
-	value, success := os.LookupEnv(key)
-	if !success {
-		log.Print("Could not obtain " + key)
-		os.Exit(1)
-	}
-	return value
-}
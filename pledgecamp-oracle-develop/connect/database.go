@@ -0,0 +1,183 @@
+package connect
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+// Database drivers the Oracle can store its tables in
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Config - database the Oracle stores its tables in and the shared pool. Host, Port, Name, User, Password and
+// SSLMode locate a Postgres database, File a SQLite one
+type Config struct {
+	Driver   string
+	Host     string
+	Port     string
+	Name     string
+	User     string
+	Password string
+	SSLMode  string
+	File     string
+	Pool     PoolConfig
+}
+
+// PoolConfig - sizing and timeouts of the shared pool
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxIdleTime time.Duration
+	ConnMaxLifetime time.Duration
+	QueryTimeout    time.Duration
+}
+
+// PoolStats - snapshot of the shared pool usage
+type PoolStats struct {
+	Driver             string `json:"driver"`
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+var pool sqlbuilder.Database
+var poolDriver string
+var poolMutex sync.Mutex
+
+// Open - Open the shared pooled session on the database of config.Driver. Called once at startup
+func Open(config Config) (sqlbuilder.Database, error) {
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+
+	if pool != nil {
+		return pool, nil
+	}
+
+	var db sqlbuilder.Database
+	var err error
+	if config.Driver == DriverSQLite {
+		db, err = openSQLite(config)
+	} else {
+		db, err = openPostgres(config)
+	}
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(config.Pool.MaxOpenConns)
+	db.SetMaxIdleConns(config.Pool.MaxIdleConns)
+	db.SetConnMaxLifetime(config.Pool.ConnMaxLifetime)
+	if sqlDB, ok := db.Driver().(*sql.DB); ok {
+		sqlDB.SetConnMaxIdleTime(config.Pool.ConnMaxIdleTime)
+	}
+
+	pool = db
+	poolDriver = driverName(config)
+	log.Printf("Connected to %v (max open: %v, max idle: %v)", location(config), config.Pool.MaxOpenConns, config.Pool.MaxIdleConns)
+	return pool, nil
+}
+
+// driverName - driver of config, Postgres unless SQLite is asked for
+func driverName(config Config) string {
+	if config.Driver == DriverSQLite {
+		return DriverSQLite
+	}
+	return DriverPostgres
+}
+
+// location - where the database of config is, without its credentials
+func location(config Config) string {
+	if config.Driver == DriverSQLite {
+		return "SQLite DB at file: " + config.File
+	}
+	return "Postgres DB at host: " + config.Host
+}
+
+// Database - Shared session, which Open must have opened
+func Database() sqlbuilder.Database {
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+
+	if pool == nil {
+		log.Print("DB is not connected, Open must be called first")
+		os.Exit(1)
+	}
+	return pool
+}
+
+// Driver - driver of the shared session, empty when it is not open
+func Driver() string {
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+
+	return poolDriver
+}
+
+// databaseDB - connections of the shared pool, false when it is not open
+func databaseDB() (*sql.DB, bool) {
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+
+	if pool == nil {
+		return nil, false
+	}
+	sqlDB, ok := pool.Driver().(*sql.DB)
+	return sqlDB, ok
+}
+
+// DatabaseStats - usage statistics of the shared pool
+func DatabaseStats() (PoolStats, bool) {
+	sqlDB, ok := databaseDB()
+	if !ok {
+		return PoolStats{}, false
+	}
+	stats := sqlDB.Stats()
+	return PoolStats{
+		Driver:             Driver(),
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration.String(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}, true
+}
+
+// PingDatabase - check that the shared pool reaches the database, false when the pool is not open
+func PingDatabase(ctx context.Context) (bool, error) {
+	sqlDB, ok := databaseDB()
+	if !ok {
+		return false, nil
+	}
+	return true, sqlDB.PingContext(ctx)
+}
+
+// CloseDatabase - Close the shared session
+func CloseDatabase() error {
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+
+	if pool == nil {
+		return nil
+	}
+	err := pool.Close()
+	pool = nil
+	poolDriver = ""
+	return err
+}
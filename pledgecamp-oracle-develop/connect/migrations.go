@@ -0,0 +1,175 @@
+package connect
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/httpfs"
+	"github.com/pledgecamp/pledgecamp-oracle/db/migrations"
+)
+
+// MigrationState - last migration applied to the database, Dirty when it stopped half way
+type MigrationState struct {
+	Version uint `json:"version"`
+	Dirty   bool `json:"dirty"`
+}
+
+// Migration - migration built into the binary, Applied once the database is at its version or a later one
+type Migration struct {
+	Version uint   `json:"version"`
+	Name    string `json:"name"`
+	Down    bool   `json:"down"`
+	Applied bool   `json:"applied"`
+}
+
+// MigrationReport - where the database is among the migrations built into the binary
+type MigrationReport struct {
+	MigrationState
+	Latest     uint        `json:"latest"`
+	Pending    int         `json:"pending"`
+	Migrations []Migration `json:"migrations"`
+}
+
+// embedded - source of the migrations built into the binary for driver
+func embedded(driver string) (source.Driver, error) {
+	if driver == DriverSQLite {
+		return httpfs.New(http.FS(migrations.FS), "/sqlite")
+	}
+	return httpfs.New(http.FS(migrations.FS), "/postgres")
+}
+
+// migrator - migrations built into the binary against the configured database
+func migrator(config Config) (*migrate.Migrate, error) {
+	var dsn string
+	if config.Driver == DriverSQLite {
+		dsn, _ = sqliteConnectionUrl(config)
+	} else {
+		var err error
+		if dsn, _, err = pgConnectionUrl(config); err != nil {
+			return nil, err
+		}
+	}
+	driver, err := embedded(config.Driver)
+	if err != nil {
+		return nil, err
+	}
+	return migrate.NewWithSourceInstance("httpfs", driver, dsn)
+}
+
+// MigrateUp - apply every migration that has not been applied yet
+func MigrateUp(config Config) error {
+	m, err := migrator(config)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+	return nil
+}
+
+// MigrateDown - roll back the last steps migrations applied
+func MigrateDown(config Config, steps int) error {
+	m, err := migrator(config)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return m.Steps(-steps)
+}
+
+// MigrateForce - record version as the clean current migration, once a migration that failed half way has
+// been fixed by hand
+func MigrateForce(config Config, version int) error {
+	m, err := migrator(config)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return m.Force(version)
+}
+
+// MigrationStatus - report of the configured database, which does not need the shared pool to be open
+func MigrationStatus(config Config) (MigrationReport, error) {
+	m, err := migrator(config)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	defer m.Close()
+	var state MigrationState
+	state.Version, state.Dirty, err = m.Version()
+	if err != nil && err != migrate.ErrNilVersion {
+		return MigrationReport{}, err
+	}
+	return Migrations(config.Driver, state)
+}
+
+// MigrationVersion - last migration applied to the database of the shared pool, false when the pool is not open
+func MigrationVersion(ctx context.Context) (MigrationState, bool, error) {
+	sqlDB, ok := databaseDB()
+	if !ok {
+		return MigrationState{}, false, nil
+	}
+	var state MigrationState
+	err := sqlDB.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&state.Version, &state.Dirty)
+	return state, true, err
+}
+
+// DatabaseMigrationStatus - report of the database of the shared pool, false when the pool is not open
+func DatabaseMigrationStatus(ctx context.Context) (MigrationReport, bool, error) {
+	state, open, err := MigrationVersion(ctx)
+	if !open || err != nil {
+		return MigrationReport{}, open, err
+	}
+	report, err := Migrations(Driver(), state)
+	return report, true, err
+}
+
+// Migrations - report of every migration built into the binary for a database of driver at state
+func Migrations(driver string, state MigrationState) (MigrationReport, error) {
+	report := MigrationReport{MigrationState: state, Migrations: []Migration{}}
+	migrationSource, err := embedded(driver)
+	if err != nil {
+		return report, err
+	}
+	defer migrationSource.Close()
+
+	version, next := migrationSource.First()
+	for next == nil {
+		migration := Migration{Version: version, Applied: version <= state.Version}
+		up, name, err := migrationSource.ReadUp(version)
+		if err != nil {
+			return report, err
+		}
+		up.Close()
+		migration.Name = strings.Replace(name, "_", " ", -1)
+		if down, _, err := migrationSource.ReadDown(version); err == nil {
+			down.Close()
+			migration.Down = true
+		}
+		if !migration.Applied {
+			report.Pending++
+		}
+		report.Latest = version
+		report.Migrations = append(report.Migrations, migration)
+		version, next = migrationSource.Next(version)
+	}
+	if !os.IsNotExist(next) {
+		return report, next
+	}
+	return report, nil
+}
+
+// LatestMigration - version of the newest migration built into the binary for driver, the version its
+// database is expected at
+func LatestMigration(driver string) (uint, error) {
+	report, err := Migrations(driver, MigrationState{})
+	return report.Latest, err
+}
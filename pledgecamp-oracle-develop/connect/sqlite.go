@@ -0,0 +1,23 @@
+package connect
+
+import (
+	// The driver behind upper.io/db.v3/sqlite and the sqlite3 migrations, pinned for its _foreign_keys option
+	_ "github.com/mattn/go-sqlite3"
+	"upper.io/db.v3/lib/sqlbuilder"
+	"upper.io/db.v3/sqlite"
+)
+
+// sqliteConnectionUrl - migrate URL and session settings of the SQLite database file of config
+func sqliteConnectionUrl(config Config) (string, sqlite.ConnectionURL) {
+	settings := sqlite.ConnectionURL{
+		Database: config.File,
+		Options:  map[string]string{"_foreign_keys": "1"},
+	}
+	return "sqlite3://" + config.File, settings
+}
+
+// openSQLite - session on the SQLite database file of config, created when it does not exist
+func openSQLite(config Config) (sqlbuilder.Database, error) {
+	_, connURL := sqliteConnectionUrl(config)
+	return sqlite.Open(connURL)
+}
@@ -0,0 +1,43 @@
+package constants
+
+// Role - kind of caller a credential belongs to
+type Role string
+
+const (
+	RoleBackend            Role = "backend"
+	RoleNodeServerCallback Role = "nodeserver-callback"
+	RoleAdmin              Role = "admin"
+	RoleReadOnly           Role = "read-only"
+)
+
+// RouteGroup - set of routes a credential can be allowed to call
+type RouteGroup string
+
+const (
+	// RouteStatus - operational status of the Oracle
+	RouteStatus RouteGroup = "status"
+	// RouteUsers - CampShares state, gains and balance of a user, public unless JWT authentication is enabled
+	RouteUsers RouteGroup = "users"
+	// RouteProjects - project actions requested by the backend
+	RouteProjects RouteGroup = "projects"
+	// RouteCampShares - CampShares actions requested by the backend
+	RouteCampShares RouteGroup = "cs"
+	// RouteTreasury - actions moving funds for every user, POST_INTEREST and FAILED_FUND_RECOVERY
+	RouteTreasury RouteGroup = "treasury"
+	// RouteCallbacks - Nodeserver postbacks
+	RouteCallbacks RouteGroup = "callbacks"
+	// RouteAdmin - outbox and credential administration
+	RouteAdmin RouteGroup = "admin"
+)
+
+// RouteGroups - every route group
+var RouteGroups = []RouteGroup{RouteStatus, RouteUsers, RouteProjects, RouteCampShares, RouteTreasury, RouteCallbacks, RouteAdmin}
+
+// RoleRouteGroups - route groups each role may be granted, a credential is granted all of them unless
+// it is created with a narrower list
+var RoleRouteGroups = map[Role][]RouteGroup{
+	RoleBackend:            {RouteStatus, RouteUsers, RouteProjects, RouteCampShares, RouteTreasury},
+	RoleNodeServerCallback: {RouteCallbacks},
+	RoleAdmin:              {RouteStatus, RouteUsers, RouteProjects, RouteCampShares, RouteTreasury, RouteCallbacks, RouteAdmin},
+	RoleReadOnly:           {RouteStatus, RouteUsers},
+}
@@ -1,5 +1,7 @@
 package constants
 
+import "strconv"
+
 type ActivityStatus int
 
 const (
@@ -40,3 +42,78 @@ const (
 	TransactionStatusFailureTimeout TransactionStatus = "FAILED_TIMEOUT"
 	TransactionStatusFailurePending TransactionStatus = "FAILED_PENDING"
 )
+
+type OutboxStatus int
+
+const (
+	OutboxPending    OutboxStatus = 0
+	OutboxDelivered  OutboxStatus = 1
+	OutboxDeadLetter OutboxStatus = 2
+)
+
+type CallbackOutcome int
+
+const (
+	CallbackApplied   CallbackOutcome = 0
+	CallbackDuplicate CallbackOutcome = 1
+	CallbackStale     CallbackOutcome = 2
+)
+
+var activityStatusNames = map[ActivityStatus]string{
+	ActivityPending:      "pending",
+	ActivitySuccess:      "success",
+	ActivityTimeout:      "timeout",
+	ActivityGasError:     "gas_error",
+	ActivityInitialError: "initial_error",
+	ActivityReceiptError: "receipt_error",
+	ActivityPendingError: "pending_error",
+}
+
+func (s ActivityStatus) String() string {
+	if name, ok := activityStatusNames[s]; ok {
+		return name
+	}
+	return strconv.Itoa(int(s))
+}
+
+// ProjectStatuses - every project status
+var ProjectStatuses = []ProjectStatus{
+	ProjectInactive, ProjectCancelled, ProjectMilestoneFailed, ProjectEnded, ProjectError, ProjectDeployed,
+	ProjectMilestonePhase, ProjectModerationPhase, ProjectReadyToCancel, ProjectFundsRecovered,
+	ProjectMilestoneSuccess, ProjectFailed,
+}
+
+var projectStatusNames = map[ProjectStatus]string{
+	ProjectInactive:         "inactive",
+	ProjectCancelled:        "cancelled",
+	ProjectMilestoneFailed:  "milestone_failed",
+	ProjectEnded:            "ended",
+	ProjectError:            "error",
+	ProjectDeployed:         "deployed",
+	ProjectMilestonePhase:   "milestone_phase",
+	ProjectModerationPhase:  "moderation_phase",
+	ProjectReadyToCancel:    "ready_to_cancel",
+	ProjectFundsRecovered:   "funds_recovered",
+	ProjectMilestoneSuccess: "milestone_success",
+	ProjectFailed:           "failed",
+}
+
+func (s ProjectStatus) String() string {
+	if name, ok := projectStatusNames[s]; ok {
+		return name
+	}
+	return strconv.Itoa(int(s))
+}
+
+var callbackOutcomeNames = map[CallbackOutcome]string{
+	CallbackApplied:   "applied",
+	CallbackDuplicate: "duplicate",
+	CallbackStale:     "stale",
+}
+
+func (o CallbackOutcome) String() string {
+	if name, ok := callbackOutcomeNames[o]; ok {
+		return name
+	}
+	return strconv.Itoa(int(o))
+}
@@ -0,0 +1,215 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// descriptors - shorthands of the common cron expressions
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field - allowed values of one of the five fields of a cron expression
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Schedule - times a job is due, from a five field cron expression in UTC, a descriptor such as @daily or
+// @every <duration>
+type Schedule struct {
+	spec  string
+	every time.Duration
+	// sets - bit n of a set is on when the field allows the value n
+	minutes, hours, days, months, weekdays uint64
+	// anyDay, anyWeekday - whether the day of month or day of week field is *. When both are restricted a
+	// day matching either of them is due, like in cron
+	anyDay, anyWeekday bool
+}
+
+// Every - schedule due every interval after the previous run
+func Every(interval time.Duration) Schedule {
+	return Schedule{spec: "@every " + interval.String(), every: interval}
+}
+
+// Parse - schedule of spec. An @every interval must be at least a second
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return Schedule{}, fmt.Errorf("invalid interval in %q", spec)
+		}
+		if interval < time.Second {
+			return Schedule{}, fmt.Errorf("interval of %q must be at least 1s", spec)
+		}
+		return Every(interval), nil
+	}
+
+	expression := spec
+	if strings.HasPrefix(spec, "@") {
+		var ok bool
+		if expression, ok = descriptors[spec]; !ok {
+			return Schedule{}, fmt.Errorf("unknown descriptor %q", spec)
+		}
+	}
+	parts := strings.Fields(expression)
+	if len(parts) != len(fields) {
+		return Schedule{}, fmt.Errorf("%q must have 5 fields, minute hour day-of-month month day-of-week", spec)
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return Schedule{}, fmt.Errorf("invalid %s in %q: %v", fields[i].name, spec, err)
+		}
+		sets[i] = set
+	}
+	weekdays := sets[4]
+	// Both 0 and 7 are Sunday
+	if weekdays&(1<<7) != 0 {
+		weekdays |= 1
+	}
+	return Schedule{
+		spec:       spec,
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   weekdays,
+		anyDay:     parts[2] == "*",
+		anyWeekday: parts[4] == "*",
+	}, nil
+}
+
+// parseField - set of the values allowed by a comma separated list of *, values, ranges and /steps
+func parseField(part string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(part, ",") {
+		step := 1
+		if slash := strings.Index(item, "/"); slash >= 0 {
+			var err error
+			step, err = strconv.Atoi(item[slash+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("step of %q must be a positive number", item)
+			}
+			item = item[:slash]
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case item == "*":
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			if low, err = value(bounds[0], f); err != nil {
+				return 0, err
+			}
+			if high, err = value(bounds[1], f); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("range %q goes backwards", item)
+			}
+		default:
+			var err error
+			if low, err = value(item, f); err != nil {
+				return 0, err
+			}
+			// A single value with a step runs from it to the end of the field
+			if step == 1 {
+				high = low
+			}
+		}
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// value - number of a field, within its bounds
+func value(text string, f field) (int, error) {
+	v, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", text)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%d is not between %d and %d", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// has - whether bit v of set is on
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+// dayMatches - whether the schedule is due on the day of t
+func (s Schedule) dayMatches(t time.Time) bool {
+	day := has(s.days, t.Day())
+	weekday := has(s.weekdays, int(t.Weekday()))
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
+
+// Next - first time the schedule is due after after, in UTC. The zero time when it is never due, like on
+// February 30th
+func (s Schedule) Next(after time.Time) time.Time {
+	if s.every > 0 {
+		return after.UTC().Add(s.every)
+	}
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	// Every combination of the fields comes around within a leap year cycle
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !has(s.months, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !has(s.hours, t.Hour()):
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !has(s.minutes, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// String - the expression the schedule was parsed from
+func (s Schedule) String() string {
+	return s.spec
+}
+
+// IsZero - whether the schedule was never set
+func (s Schedule) IsZero() bool {
+	return s.spec == ""
+}
@@ -0,0 +1,45 @@
+package cron
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+// Tests for cron.go
+func TestCronSchedule(t *testing.T) {
+	log.Println("********************************* TestCronSchedule() **************************************")
+	// Monday
+	from := time.Date(2021, 3, 15, 10, 7, 30, 0, time.UTC)
+	for spec, expected := range map[string]time.Time{
+		"*/15 * * * *":      time.Date(2021, 3, 15, 10, 15, 0, 0, time.UTC),
+		"0 9 * * *":         time.Date(2021, 3, 16, 9, 0, 0, 0, time.UTC),
+		"30 8-18/2 * * 1-5": time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC),
+		"0 0 1 * *":         time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 7":         time.Date(2021, 3, 21, 0, 0, 0, 0, time.UTC),
+		"0 0 13 * 5":        time.Date(2021, 3, 19, 0, 0, 0, 0, time.UTC),
+		"0 12 29 2 *":       time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+		"@hourly":           time.Date(2021, 3, 15, 11, 0, 0, 0, time.UTC),
+		"@every 90s":        from.Add(90 * time.Second),
+	} {
+		schedule, err := Parse(spec)
+		if err != nil {
+			t.Errorf("Expected %q to parse, got %v", spec, err)
+			continue
+		}
+		if next := schedule.Next(from); !next.Equal(expected) {
+			t.Errorf("Expected %q to be due at %v, got %v", spec, expected, next)
+		}
+	}
+
+	for _, spec := range []string{"61 * * * *", "* * *", "5-1 * * * *", "*/0 * * * *", "@sometimes", "@every 10ms"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+	never, _ := Parse("0 0 30 2 *")
+	if !never.Next(from).IsZero() {
+		t.Error("Expected February 30th never to be due")
+	}
+	log.Println("********************************* End TestCronSchedule() **************************************")
+}
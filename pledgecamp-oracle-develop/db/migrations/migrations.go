@@ -0,0 +1,10 @@
+// Package migrations - SQL migrations of the Oracle database, built into the binary
+package migrations
+
+import "embed"
+
+// FS - every .up.sql and .down.sql migration, named <version>_<title>.<direction>.sql, in a directory per
+// database driver. Both directories have the same versions
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var FS embed.FS
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS outbox
+(
+    outbox_id integer NOT NULL GENERATED ALWAYS AS IDENTITY ( INCREMENT 1 START 1 MINVALUE 1 MAXVALUE 2147483647 CACHE 1 ),
+    ordering_key text NOT NULL,
+    uri text NOT NULL,
+    payload jsonb,
+    outbox_status integer NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamp without time zone NOT NULL,
+    last_error text,
+    created_at timestamp without time zone NOT NULL,
+    modified_at timestamp without time zone,
+    CONSTRAINT outbox_pkey PRIMARY KEY (outbox_id)
+)
+WITH (
+    OIDS = FALSE
+)
+TABLESPACE pg_default;
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (ordering_key, outbox_id) WHERE outbox_status = 0;
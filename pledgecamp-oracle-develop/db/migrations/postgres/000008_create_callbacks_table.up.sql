@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS callbacks
+(
+    callback_id integer NOT NULL GENERATED ALWAYS AS IDENTITY ( INCREMENT 1 START 1 MINVALUE 1 MAXVALUE 2147483647 CACHE 1 ),
+    transaction_uuid text NOT NULL,
+    transaction_status integer NOT NULL,
+    transaction_type text,
+    activity_id integer,
+    callback_outcome integer NOT NULL,
+    received_at timestamp without time zone NOT NULL,
+    CONSTRAINT callbacks_pkey PRIMARY KEY (callback_id)
+)
+WITH (
+    OIDS = FALSE
+)
+TABLESPACE pg_default;
+
+CREATE UNIQUE INDEX IF NOT EXISTS callbacks_applied_idx ON callbacks (transaction_uuid, transaction_status) WHERE callback_outcome = 0;
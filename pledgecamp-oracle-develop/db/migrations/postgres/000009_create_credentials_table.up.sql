@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS credentials
+(
+    credential_id integer NOT NULL GENERATED ALWAYS AS IDENTITY ( INCREMENT 1 START 1 MINVALUE 1 MAXVALUE 2147483647 CACHE 1 ),
+    credential_name text NOT NULL,
+    token_hash text NOT NULL,
+    credential_role text NOT NULL,
+    route_groups text[] NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    revoked_at timestamp without time zone,
+    CONSTRAINT credentials_pkey PRIMARY KEY (credential_id),
+    CONSTRAINT credentials_token_hash_key UNIQUE (token_hash)
+)
+WITH (
+    OIDS = FALSE
+)
+TABLESPACE pg_default;
+
+CREATE UNIQUE INDEX IF NOT EXISTS credentials_active_name_idx ON credentials (credential_name) WHERE revoked_at IS NULL;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS leases
+(
+    lease_name text NOT NULL,
+    holder text NOT NULL,
+    acquired_at timestamp without time zone NOT NULL,
+    expires_at timestamp without time zone NOT NULL,
+    CONSTRAINT leases_pkey PRIMARY KEY (lease_name)
+)
+WITH (
+    OIDS = FALSE
+)
+TABLESPACE pg_default;
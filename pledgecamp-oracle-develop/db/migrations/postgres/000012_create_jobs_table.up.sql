@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS jobs
+(
+    job_name text NOT NULL,
+    schedule text NOT NULL,
+    paused boolean NOT NULL DEFAULT false,
+    next_run_at timestamp without time zone NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    last_run_at timestamp without time zone,
+    last_success_at timestamp without time zone,
+    last_error text,
+    last_duration_ms integer NOT NULL DEFAULT 0,
+    last_processed integer NOT NULL DEFAULT 0,
+    lease_holder text,
+    lease_expires_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL,
+    modified_at timestamp without time zone NOT NULL,
+    CONSTRAINT jobs_pkey PRIMARY KEY (job_name)
+)
+WITH (
+    OIDS = FALSE
+)
+TABLESPACE pg_default;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS project_milestone_idx ON project (status, next_activity_date);
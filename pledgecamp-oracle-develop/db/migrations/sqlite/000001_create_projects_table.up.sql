@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS project
+(
+    id integer NOT NULL,
+    created_at timestamp NOT NULL,
+    completed_at timestamp,
+    status integer NOT NULL,
+    next_activity_date timestamp,
+    contract_address text,
+    -- Postgres text[] literal, read and written by pq.StringArray
+    activities_completed text,
+    -- JSON document
+    project_param text,
+    CONSTRAINT project_pkey PRIMARY KEY (id)
+);
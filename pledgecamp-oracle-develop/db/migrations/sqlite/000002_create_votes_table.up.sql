@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS votes
+(
+    vote_id integer PRIMARY KEY AUTOINCREMENT,
+    vote_created_at timestamp NOT NULL,
+    contract_address text,
+    user_id integer,
+    fk_project_id integer NOT NULL,
+    -- JSON document
+    vote_param text,
+    CONSTRAINT fk_project_id FOREIGN KEY (fk_project_id) REFERENCES project(id)
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS campshare;
\ No newline at end of file
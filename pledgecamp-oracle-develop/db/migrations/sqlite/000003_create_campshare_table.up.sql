@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS campshare
+(
+    cs_id integer NOT NULL,
+    contract_address text,
+    created_at timestamp,
+    cs_type integer,
+    user_id integer,
+    amount integer,
+    balance_movement integer,
+    unstake_complete_date timestamp,
+    -- JSON document
+    cs_param text,
+    CONSTRAINT campshare_pkey PRIMARY KEY (cs_id)
+);
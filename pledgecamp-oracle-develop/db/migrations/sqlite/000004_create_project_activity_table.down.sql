@@ -0,0 +1 @@
+DROP TABLE IF EXISTS project_activity;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS project_activity
+(
+    project_activity_id integer PRIMARY KEY AUTOINCREMENT,
+    fk_project_id integer,
+    created_at timestamp NOT NULL,
+    modified_at timestamp,
+    transaction_hash text,
+    activity_status integer NOT NULL,
+    activity_type text NOT NULL
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS cs_activity;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS cs_activity
+(
+    cs_activity_id integer PRIMARY KEY AUTOINCREMENT,
+    fk_cs_id integer,
+    created_at timestamp NOT NULL,
+    modified_at timestamp,
+    transaction_hash text,
+    activity_status integer NOT NULL,
+    activity_type text NOT NULL
+);
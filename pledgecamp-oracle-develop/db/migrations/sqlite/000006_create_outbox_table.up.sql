@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS outbox
+(
+    outbox_id integer PRIMARY KEY AUTOINCREMENT,
+    ordering_key text NOT NULL,
+    uri text NOT NULL,
+    -- JSON document
+    payload text,
+    outbox_status integer NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamp NOT NULL,
+    last_error text,
+    created_at timestamp NOT NULL,
+    modified_at timestamp
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (ordering_key, outbox_id) WHERE outbox_status = 0;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    idempotency_key text NOT NULL,
+    fingerprint text NOT NULL,
+    status_code integer NOT NULL DEFAULT 0,
+    response_body text,
+    created_at timestamp NOT NULL,
+    modified_at timestamp,
+    CONSTRAINT idempotency_keys_pkey PRIMARY KEY (idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);
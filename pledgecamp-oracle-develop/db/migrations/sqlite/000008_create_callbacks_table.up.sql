@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS callbacks
+(
+    callback_id integer PRIMARY KEY AUTOINCREMENT,
+    transaction_uuid text NOT NULL,
+    transaction_status integer NOT NULL,
+    transaction_type text,
+    activity_id integer,
+    callback_outcome integer NOT NULL,
+    received_at timestamp NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS callbacks_applied_idx ON callbacks (transaction_uuid, transaction_status) WHERE callback_outcome = 0;
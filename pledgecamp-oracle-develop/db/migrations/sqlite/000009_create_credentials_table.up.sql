@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS credentials
+(
+    credential_id integer PRIMARY KEY AUTOINCREMENT,
+    credential_name text NOT NULL,
+    token_hash text NOT NULL,
+    credential_role text NOT NULL,
+    -- Postgres text[] literal, read and written by pq.StringArray
+    route_groups text NOT NULL,
+    created_at timestamp NOT NULL,
+    revoked_at timestamp,
+    CONSTRAINT credentials_token_hash_key UNIQUE (token_hash)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS credentials_active_name_idx ON credentials (credential_name) WHERE revoked_at IS NULL;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS leases
+(
+    lease_name text NOT NULL,
+    holder text NOT NULL,
+    acquired_at timestamp NOT NULL,
+    expires_at timestamp NOT NULL,
+    CONSTRAINT leases_pkey PRIMARY KEY (lease_name)
+);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS jobs
+(
+    job_name text NOT NULL,
+    schedule text NOT NULL,
+    paused boolean NOT NULL DEFAULT false,
+    next_run_at timestamp NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    last_run_at timestamp,
+    last_success_at timestamp,
+    last_error text,
+    last_duration_ms integer NOT NULL DEFAULT 0,
+    last_processed integer NOT NULL DEFAULT 0,
+    lease_holder text,
+    lease_expires_at timestamp,
+    created_at timestamp NOT NULL,
+    modified_at timestamp NOT NULL,
+    CONSTRAINT jobs_pkey PRIMARY KEY (job_name)
+);
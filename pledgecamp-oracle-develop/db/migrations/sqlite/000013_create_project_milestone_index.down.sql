@@ -0,0 +1 @@
+DROP INDEX IF EXISTS project_milestone_idx;
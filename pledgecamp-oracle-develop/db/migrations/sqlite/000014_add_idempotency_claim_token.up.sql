@@ -0,0 +1 @@
+ALTER TABLE idempotency_keys ADD COLUMN claim_token text NOT NULL DEFAULT '';
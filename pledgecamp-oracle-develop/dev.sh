@@ -59,4 +59,4 @@ echo "Starting containers"
 docker-compose up -d
 # ./config/postgres_wait.sh
 echo "Starting Go"
-go run main.go
+go run . serve
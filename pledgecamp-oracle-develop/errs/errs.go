@@ -0,0 +1,122 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"upper.io/db.v3"
+)
+
+// Kind - category of an Oracle failure, used to decide how it is reported to the caller
+type Kind int
+
+const (
+	Internal Kind = iota
+	NotFound
+	Validation
+	NodeServer
+	Backend
+	Conflict
+	Unauthorized
+	Forbidden
+	RateLimited
+)
+
+var kindNames = map[Kind]string{
+	Internal:     "internal",
+	NotFound:     "not_found",
+	Validation:   "validation",
+	NodeServer:   "nodeserver_unavailable",
+	Backend:      "backend_unavailable",
+	Conflict:     "conflict",
+	Unauthorized: "unauthorized",
+	Forbidden:    "forbidden",
+	RateLimited:  "rate_limited",
+}
+
+var kindStatus = map[Kind]int{
+	Internal:     http.StatusInternalServerError,
+	NotFound:     http.StatusNotFound,
+	Validation:   http.StatusBadRequest,
+	NodeServer:   http.StatusBadGateway,
+	Backend:      http.StatusBadGateway,
+	Conflict:     http.StatusConflict,
+	Unauthorized: http.StatusUnauthorized,
+	Forbidden:    http.StatusForbidden,
+	RateLimited:  http.StatusTooManyRequests,
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// HTTPStatus - status code returned to API callers for this kind of failure
+func (k Kind) HTTPStatus() int {
+	return kindStatus[k]
+}
+
+// Error - failure with a Kind, a message safe to return to API callers and the underlying cause
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New - create an error of the given kind
+func New(kind Kind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap - attach a kind and message to err, returns nil when err is nil
+func Wrap(kind Kind, err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// Store - wrap a store error, lookups that matched no rows become NotFound
+func Store(err error, format string, args ...interface{}) error {
+	if errors.Is(err, db.ErrNoMoreRows) {
+		return Wrap(NotFound, err, format, args...)
+	}
+	return Wrap(Internal, err, format, args...)
+}
+
+// KindOf - kind of the outermost typed error in err's chain, Internal for untyped errors
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	if errors.Is(err, db.ErrNoMoreRows) {
+		return NotFound
+	}
+	return Internal
+}
+
+// Is - reports whether err carries the given kind
+func Is(err error, kind Kind) bool {
+	return err != nil && KindOf(err) == kind
+}
+
+// Message - caller facing message for err, internal details are not exposed
+func Message(err error) string {
+	var e *Error
+	if errors.As(err, &e) && e.Kind != Internal {
+		return e.Message
+	}
+	return "Internal error"
+}
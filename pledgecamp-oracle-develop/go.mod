@@ -1,6 +1,6 @@
 module github.com/pledgecamp/pledgecamp-oracle
 
-go 1.13
+go 1.16
 
 require (
 	github.com/ethereum/go-ethereum v1.9.12 // indirect
@@ -11,7 +11,9 @@ require (
 	github.com/joho/godotenv v1.3.0
 	github.com/lib/pq v1.3.0
 	github.com/mattn/go-isatty v0.0.11 // indirect
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/miguelmota/go-solidity-sha3 v0.1.0
 	gopkg.in/mgo.v2 v2.0.0-20190816093944-a6b53ec6cb22 // indirect
+	gopkg.in/yaml.v2 v2.2.7
 	upper.io/db.v3 v3.6.3+incompatible
 )
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/jwt"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/models"
+)
+
+// callerKey - request context key of the authenticated credential
+const callerKey = "caller"
+
+// roleCredential - identity of a caller that is not stored, granted every route group of its role
+func roleCredential(name string, role constants.Role) models.Credential {
+	groups := pq.StringArray{}
+	for _, group := range constants.RoleRouteGroups[role] {
+		groups = append(groups, string(group))
+	}
+	return models.Credential{Name: name, Role: role, RouteGroups: groups}
+}
+
+// jwtCredential - identity carried by the claims of a verified JWT
+func jwtCredential(claims jwt.Claims) (models.Credential, error) {
+	role := constants.Role(claims.Role)
+	if _, ok := constants.RoleRouteGroups[role]; !ok {
+		return models.Credential{}, errs.New(errs.Unauthorized, "Token has an unknown role %q", claims.Role)
+	}
+	credential := roleCredential("jwt:"+claims.Subject, role)
+	credential.UserIds = claims.UserIds
+	return credential, nil
+}
+
+// authenticate - credential of a bearer token. JWTs are checked against verifier, when it is set, and other
+// tokens against the stored credentials
+func (h *Handler) authenticate(token string, bootstrapToken string, verifier *jwt.Verifier) (models.Credential, error) {
+	if bootstrapToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(bootstrapToken)) == 1 {
+		return roleCredential("bootstrap", constants.RoleAdmin), nil
+	}
+	if verifier != nil && jwt.LooksLikeJWT(token) {
+		claims, err := verifier.Verify(token, time.Now())
+		if err != nil {
+			return models.Credential{}, err
+		}
+		return jwtCredential(claims)
+	}
+	return h.Service.Authenticate(token)
+}
+
+// bearerToken - token of an "Authorization: Bearer <token>" header
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
+// Authenticate - identify the caller from its bearer token and attach its credential to the request context.
+// bootstrapToken, when set, is accepted as an admin credential and JWTs are accepted when verifier is set
+func (h *Handler) Authenticate(bootstrapToken string, verifier *jwt.Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		credential, err := h.authenticate(bearerToken(c), bootstrapToken, verifier)
+		if err != nil {
+			logger.Warnf(c.Request.Context(), "SECURITY: rejected request to %s from %s: %v", c.Request.URL.Path, c.ClientIP(), err)
+			ErrorResponse(c, err)
+			return
+		}
+		c.Set(callerKey, credential)
+		c.Next()
+	}
+}
+
+// Authorize - only let callers whose credential is granted group through
+func Authorize(group constants.RouteGroup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		credential, ok := Caller(c)
+		if !ok || !credential.Allows(group) {
+			logger.Warnf(c.Request.Context(), "SECURITY: credential %q is not allowed to call %s %s", credential.Name, c.Request.Method, c.Request.URL.Path)
+			ErrorResponse(c, errs.New(errs.Forbidden, "Credential is not allowed to call %s routes", group))
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorizeUser - check that the caller, if any, may act for userId. Responds with forbidden when it may not
+func authorizeUser(c *gin.Context, userId int) bool {
+	credential, ok := Caller(c)
+	if ok && !credential.AllowsUser(userId) {
+		logger.Warnf(c.Request.Context(), "SECURITY: credential %q is not allowed to act for user %d", credential.Name, userId)
+		ErrorResponse(c, errs.New(errs.Forbidden, "Credential is not allowed to act for user %d", userId))
+		return false
+	}
+	return true
+}
+
+// authorizePathUser - check that the user_id of the request body is the user of the :id path parameter and
+// that the caller, if any, may act for it. Responds with validation or forbidden errors when not
+func authorizePathUser(c *gin.Context, userId int) bool {
+	if c.Param("id") != strconv.Itoa(userId) {
+		ErrorResponse(c, errs.New(errs.Validation, "user_id %d does not match user %s of the path", userId, c.Param("id")))
+		return false
+	}
+	return authorizeUser(c, userId)
+}
+
+// Caller - credential of the authenticated caller of the request
+func Caller(c *gin.Context) (models.Credential, bool) {
+	value, ok := c.Get(callerKey)
+	if !ok {
+		return models.Credential{}, false
+	}
+	credential, ok := value.(models.Credential)
+	return credential, ok
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/structs"
+)
+
+// CredentialsHandler - list every credential, tokens are never returned
+func (h *Handler) CredentialsHandler(c *gin.Context) {
+	credentials, err := h.service(c).ListCredentials()
+	if err != nil {
+		ErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": credentials,
+	})
+}
+
+// CreateCredentialHandler - add a credential, its token is only returned in this response
+func (h *Handler) CreateCredentialHandler(c *gin.Context) {
+	var request structs.RequestCreateCredential
+	if !bindRequest(c, &request) {
+		return
+	}
+
+	credential, token, err := h.service(c).CreateCredential(request)
+	if err != nil {
+		ErrorResponse(c, err)
+		return
+	}
+
+	caller, _ := Caller(c)
+	logger.Infof(c.Request.Context(), "Credential %q created with role %s by %q", credential.Name, credential.Role, caller.Name)
+	c.JSON(http.StatusCreated, gin.H{
+		"msg":   credential,
+		"token": token,
+	})
+}
+
+// RevokeCredentialHandler - stop accepting the token of a credential
+func (h *Handler) RevokeCredentialHandler(c *gin.Context) {
+	credentialId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ErrorResponse(c, errs.Wrap(errs.Validation, err, "Invalid credential id"))
+		return
+	}
+
+	credential, err := h.service(c).RevokeCredential(credentialId)
+	if err != nil {
+		ErrorResponse(c, err)
+		return
+	}
+
+	caller, _ := Caller(c)
+	logger.Infof(c.Request.Context(), "Credential %q revoked by %q", credential.Name, caller.Name)
+	c.JSON(http.StatusOK, gin.H{
+		"msg": credential,
+	})
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+)
+
+// ErrorResponse - abort the request with the status code and JSON body matching the kind of err
+func ErrorResponse(c *gin.Context, err error) {
+	kind := errs.KindOf(err)
+	level := logger.LevelWarn
+	if kind.HTTPStatus() >= http.StatusInternalServerError {
+		level = logger.LevelError
+	}
+	logger.Log(c.Request.Context(), level, err.Error(), nil)
+	c.AbortWithStatusJSON(kind.HTTPStatus(), gin.H{
+		"error": kind.String(),
+		"msg":   errs.Message(err),
+	})
+}
+
+// bindRequest - decode the JSON body into request, responding with a validation error when it is invalid
+func bindRequest(c *gin.Context, request interface{}) bool {
+	if err := c.ShouldBindJSON(request); err != nil {
+		ErrorResponse(c, errs.Wrap(errs.Validation, err, "Invalid Request Data"))
+		return false
+	}
+	return true
+}
+
+// Recovery - respond with an internal error instead of dropping the connection when a handler panics
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Log(c.Request.Context(), logger.LevelError, fmt.Sprintf("Recovered from panic: %v", r), logger.Fields{"stack": string(debug.Stack())})
+				ErrorResponse(c, errs.New(errs.Internal, "%v", r))
+			}
+		}()
+		c.Next()
+	}
+}
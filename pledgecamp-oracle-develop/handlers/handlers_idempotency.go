@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/utils"
+)
+
+const (
+	// IdempotencyKeyHeader - request header identifying repeats of the same POST request
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader - set on responses replayed from an earlier request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// responseRecorder - keeps a copy of the response body written by the handlers
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(data string) (int, error) {
+	r.body.WriteString(data)
+	return r.ResponseWriter.WriteString(data)
+}
+
+// requestFingerprint - hash of the route and body a key was first used with
+func requestFingerprint(request *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(request.Method + " " + request.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Idempotency - POST requests carrying an Idempotency-Key header are processed once per caller. Repeats within
+// ttl get the stored response back without running the handler again, and a repeat may run the request again
+// once the first one did not answer within lease
+func (h *Handler) Idempotency(ttl time.Duration, lease time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			ErrorResponse(c, errs.New(errs.Validation, "%s is longer than %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+			return
+		}
+
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			ErrorResponse(c, errs.Wrap(errs.Validation, err, "Could not read request body"))
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		caller, _ := Caller(c)
+		record, replay, err := h.service(c).ClaimIdempotencyKey(caller.Name, key, requestFingerprint(c.Request, body), ttl, lease)
+		if err != nil {
+			ErrorResponse(c, err)
+			return
+		}
+		if replay {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.StatusCode, "application/json; charset=utf-8", []byte(record.ResponseBody))
+			c.Abort()
+			return
+		}
+
+		// The claim is renewed while the handler waits on Nodeserver, so a repeat only takes over a request
+		// that stopped answering
+		stopRenewing := renewIdempotencyKey(c.Request.Context(), h.service(c), record, lease/3)
+
+		// A panicking handler is answered by Recovery with an internal error, repeats run the request again
+		defer func() {
+			if r := recover(); r != nil {
+				stopRenewing()
+				if err := h.service(c).ReleaseIdempotencyKey(record); err != nil {
+					logger.Error(c.Request.Context(), err)
+				}
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		stopRenewing()
+
+		if err := h.service(c).CompleteIdempotencyKey(record, recorder.Status(), recorder.body.String()); err != nil {
+			logger.Error(c.Request.Context(), err)
+		}
+	}
+}
+
+// renewIdempotencyKey - renew the claim of record every interval until the returned function is called, which
+// waits for a renewal in flight. Renewing stops once a repeat took the key over
+func renewIdempotencyKey(ctx context.Context, service *utils.Service, record models.IdempotencyRecord, interval time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := service.RenewIdempotencyKey(record); err != nil {
+					logger.Warn(ctx, err)
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
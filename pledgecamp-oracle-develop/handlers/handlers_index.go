@@ -2,10 +2,92 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pledgecamp/pledgecamp-oracle/connect"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/metrics"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
+	"github.com/pledgecamp/pledgecamp-oracle/utils"
 )
 
-func IndexHandler(c *gin.Context) {
+// Handler - gin handlers backed by the injected utils Service
+type Handler struct {
+	Service *utils.Service
+	// ReadinessTimeout - deadline of the dependency checks of ReadyzHandler
+	ReadinessTimeout time.Duration
+}
+
+// NewHandler - create the route handlers for a Service
+func NewHandler(service *utils.Service) *Handler {
+	return &Handler{Service: service, ReadinessTimeout: service.Config.App.ReadinessTimeout}
+}
+
+// service - Service serving the request of c, logging with its request ID. The work of the request is not
+// cancelled when the caller goes away
+func (h *Handler) service(c *gin.Context) *utils.Service {
+	return h.Service.WithContext(logger.Detach(c.Request.Context()))
+}
+
+func (h *Handler) IndexHandler(c *gin.Context) {
 	c.String(http.StatusOK, "Pledgecamp Oracle")
 }
+
+// HealthzHandler - liveness, the process is up and serving requests
+func (h *Handler) HealthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": utils.CheckOk})
+}
+
+// ReadyzHandler - readiness, every dependency of the Oracle is usable. Answers 503 with the failing checks
+// so the Oracle is taken out of rotation until they pass
+func (h *Handler) ReadyzHandler(c *gin.Context) {
+	readiness := h.service(c).Readiness(h.ReadinessTimeout)
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+		logger.Warn(c.Request.Context(), "Readiness check failed: ", readiness.Checks)
+	}
+	c.JSON(status, readiness)
+}
+
+// StatusHandler - operational status of the Oracle
+func (h *Handler) StatusHandler(c *gin.Context) {
+	status := gin.H{}
+	if stats, ok := connect.DatabaseStats(); ok {
+		status["database"] = stats
+	}
+	if stats, ok := nodeserver.Breaker(h.Service.NodeServer); ok {
+		status["nodeserver"] = stats
+	}
+	if leadership, ok := utils.CurrentLeadership(); ok {
+		status["leader"] = leadership
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// MigrationsHandler - the migration the database is at and every migration built into the binary
+func (h *Handler) MigrationsHandler(c *gin.Context) {
+	report, open, err := connect.DatabaseMigrationStatus(c.Request.Context())
+	if !open {
+		ErrorResponse(c, errs.New(errs.NotFound, "The Oracle does not use a database"))
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, errs.Wrap(errs.Internal, err, "Could not get the migration status"))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// MetricsHandler - Oracle metrics in the Prometheus text format. The project gauges are read from the store
+// on every scrape, the previous values are served when that fails
+func (h *Handler) MetricsHandler(c *gin.Context) {
+	if err := h.service(c).RefreshMetrics(); err != nil {
+		logger.Warn(c.Request.Context(), err)
+	}
+	c.Header("Content-Type", metrics.ContentType)
+	c.Status(http.StatusOK)
+	metrics.Write(c.Writer)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pledgecamp/pledgecamp-oracle/utils"
+)
+
+// JobsHandler - list the scheduled jobs with the outcome of their last run
+func (h *Handler) JobsHandler(c *gin.Context) {
+	jobs, err := h.service(c).ListJobs()
+	if err != nil {
+		ErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": jobs,
+	})
+}
+
+// PauseJobHandler - stop running a job until it is resumed
+func (h *Handler) PauseJobHandler(c *gin.Context) {
+	h.jobResponse(c, http.StatusOK, h.service(c).PauseJob)
+}
+
+// ResumeJobHandler - run a paused job on its schedule again
+func (h *Handler) ResumeJobHandler(c *gin.Context) {
+	h.jobResponse(c, http.StatusOK, h.service(c).ResumeJob)
+}
+
+// TriggerJobHandler - run a job on the next poll of the job worker
+func (h *Handler) TriggerJobHandler(c *gin.Context) {
+	h.jobResponse(c, http.StatusAccepted, h.service(c).TriggerJob)
+}
+
+// jobResponse - apply change to the job of the name path parameter and respond with the job
+func (h *Handler) jobResponse(c *gin.Context, status int, change func(name string) (utils.Job, error)) {
+	job, err := change(c.Param("name"))
+	if err != nil {
+		ErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"msg": job,
+	})
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+)
+
+// requestIDPattern - request IDs accepted from callers, anything else is replaced so it cannot forge log lines
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestLogger - give every request a request ID, taken from the X-Request-ID header when the caller sent a
+// valid one, and log the outcome of the request as a JSON line carrying it. The ID is returned in the response
+// header and sent along with the Nodeserver and backend requests made for the request
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestId := c.GetHeader(logger.RequestIDHeader)
+		if !requestIDPattern.MatchString(requestId) {
+			requestId = logger.NewRequestID()
+		}
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestId))
+		c.Header(logger.RequestIDHeader, requestId)
+
+		c.Next()
+
+		fields := logger.Fields{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"status":     c.Writer.Status(),
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+		}
+		if credential, ok := Caller(c); ok {
+			fields["caller"] = credential.Name
+		}
+		level := logger.LevelInfo
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			level = logger.LevelError
+		}
+		logger.Log(c.Request.Context(), level, "Request served", fields)
+	}
+}
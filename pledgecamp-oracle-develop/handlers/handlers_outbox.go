@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+)
+
+// DeadLettersHandler - list the backend events that ran out of delivery attempts
+func (h *Handler) DeadLettersHandler(c *gin.Context) {
+	events, err := h.service(c).DeadLetters()
+	if err != nil {
+		ErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": events,
+	})
+}
+
+// RedriveHandler - queue a dead-lettered backend event for delivery again
+func (h *Handler) RedriveHandler(c *gin.Context) {
+	eventId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ErrorResponse(c, errs.Wrap(errs.Validation, err, "Invalid outbox event id"))
+		return
+	}
+
+	event, err := h.service(c).RedriveOutboxEvent(eventId)
+	if err != nil {
+		ErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"msg": event,
+	})
+}
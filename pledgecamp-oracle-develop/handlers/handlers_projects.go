@@ -1,315 +1,54 @@
 package handlers
 
 import (
-	"database/sql"
-	"log"
 	"net/http"
-	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/imroc/req"
-	"github.com/pledgecamp/pledgecamp-oracle/constants"
-	"github.com/pledgecamp/pledgecamp-oracle/models"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
-	"github.com/pledgecamp/pledgecamp-oracle/utils"
 )
 
-func ProjectCallbackHandler(c *gin.Context) {
+func (h *Handler) ProjectCallbackHandler(c *gin.Context) {
 
 	var projectNSResp structs.NodeServerModel
-	err := c.BindJSON(&projectNSResp)
-	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &projectNSResp) {
 		return
 	}
 
-	// Get Project Activity Record
-	projectActivityId := projectNSResp.ParentID
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
-	if err != nil {
-		log.Fatal(err)
-	}
-	// TODO Set transaction hash on activity
-	targetActivity.TransactionHash = sql.NullString{String: projectNSResp.Hash, Valid: true}
-	updatedActivity, err := models.ProjectActivityUpdateFields(targetActivity)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// TODO Generic response failure handler
-	var errorResponse error
-	log.Println("Type: ", projectNSResp.Type)
-	if projectNSResp.Status <= 2 {
-		log.Println("Project Status")
-		log.Println(projectNSResp.Status)
-		switch projectNSResp.Type {
-		case string(constants.ProjectDeploy):
-			errorResponse = utils.ProjectCreateCallback(projectNSResp, updatedActivity)
-		case string(constants.SetBackers):
-			errorResponse = utils.SetBackersCallback(projectNSResp, updatedActivity)
-		case string(constants.SetProjectInfo):
-			errorResponse = utils.SetProjectInfoCallback(projectNSResp, updatedActivity)
-		case string(constants.SetModerators):
-			errorResponse = utils.SetModeratorsCallback(projectNSResp, updatedActivity)
-		case string(constants.CommitFinalVotes):
-			errorResponse = utils.CommitModerationVotesCallback(projectNSResp, updatedActivity)
-		case string(constants.CancelProject):
-			errorResponse = utils.CancelProjectCallback(projectNSResp, updatedActivity)
-		case string(constants.MilestoneVote):
-			errorResponse = utils.VoteCallback(projectNSResp, updatedActivity)
-		case string(constants.ModerationVote):
-			errorResponse = utils.VoteCallback(projectNSResp, updatedActivity)
-		case string(constants.CheckMilestone):
-			errorResponse = utils.CheckMilestoneCallback(projectNSResp, updatedActivity)
-		case string(constants.WithdrawFunds):
-			errorResponse = utils.WithdrawFundsCallback(projectNSResp, updatedActivity)
-		case string(constants.RequestRefund):
-			errorResponse = utils.RequestRefundCallback(projectNSResp, updatedActivity)
-		case string(constants.FailedFundRecovery):
-			errorResponse = utils.FailedFundRecoveryCallback(projectNSResp, updatedActivity)
-
-		// Dumb transactions with no advanced behaviour but simple postback to backend
-		default:
-			// TODO Generic success handler
-			if projectNSResp.Status == structs.Complete {
-
-				// Update Activity status to success
-				targetActivity.Status = constants.ActivitySuccess
-				targetActivity.TransactionHash = sql.NullString{String: projectNSResp.Hash, Valid: true}
-				_, err := models.ProjectActivityUpdateFields(targetActivity)
-				if err != nil {
-					log.Fatal(err)
-				}
-
-				log.Println("The following callback has been completed: ", projectNSResp.Type)
-
-				backendEventType, err := constants.GetEventType(targetActivity.Type)
-				if err != nil {
-					log.Fatal(err)
-				}
-
-				backendCallbackURL := "/events/blockchain/projects/" + strconv.Itoa(updatedActivity.ProjectId) + "/callback/" + string(projectNSResp.Type)
-				requestParameters := req.Param{
-					"eventType":       backendEventType,
-					"projectId":       updatedActivity.ProjectId,
-					"contractAddress": projectNSResp.ContractAddress,
-					"status":          true,
-				}
-				_, err = utils.PostBackend(requestParameters, backendCallbackURL)
-				if err != nil {
-					log.Fatal(err)
-				}
-			}
-		}
-	} else {
-		log.Println("Function failed: ", projectNSResp.Status)
-
-		switch projectNSResp.Status {
-		case structs.FailedTimeout:
-			updatedActivity.Status = constants.ActivityTimeout
-		case structs.FailedGas:
-			updatedActivity.Status = constants.ActivityGasError
-		case structs.FailedInitial:
-			updatedActivity.Status = constants.ActivityInitialError
-		case structs.FailedReceipt:
-			updatedActivity.Status = constants.ActivityReceiptError
-		case structs.FailedPending:
-			updatedActivity.Status = constants.ActivityPendingError
-		}
-		updatedActivity.TransactionHash = sql.NullString{String: projectNSResp.Hash, Valid: true}
-		updatedActivity.ModifiedAt = time.Now()
-		_, err := models.ProjectActivityUpdateFields(updatedActivity)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		backendEventType, err := constants.GetEventType(updatedActivity.Type)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Send response back to backend if activities required are completed
-		backendCallbackURL := "/events/blockchain/projects/" + strconv.Itoa(updatedActivity.ProjectId) + "/callback/" + string(projectNSResp.Type)
-		requestParameters := req.Param{
-			"eventType":       backendEventType,
-			"projectId":       updatedActivity.ProjectId,
-			"contractAddress": projectNSResp.ContractAddress,
-			"status":          false,
-		}
-		_, err = utils.PostBackend(requestParameters, backendCallbackURL)
-		if err != nil {
-			log.Fatal(err)
-		}
-	}
-
-	if errorResponse != nil {
-		log.Fatal(errorResponse)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Utility callback failed",
-		})
-	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"msg": "Project callback is OK",
-		})
+	if err := h.service(c).ProjectCallback(projectNSResp); err != nil {
+		ErrorResponse(c, err)
+		return
 	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": "Project callback is OK",
+	})
 }
 
-func CsCallbackHandler(c *gin.Context) {
+func (h *Handler) CsCallbackHandler(c *gin.Context) {
 	var csNSResp structs.NodeServerModel
-	err := c.BindJSON(&csNSResp)
-	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &csNSResp) {
 		return
 	}
 
-	// Get CS Activity Record
-	csActivityId := csNSResp.ParentID
-	targetCsActivity, err := models.CSActivitySearchActivityID(csActivityId)
-	if err != nil {
-		log.Fatal(err)
-	}
-	// TODO Set transaction hash on activity
-	targetCsActivity.TransactionHash = sql.NullString{String: csNSResp.Hash, Valid: true}
-	updatedCsActivity, err := models.CSActivityUpdateFields(targetCsActivity)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// TODO Generic response failure handler
-	var errorResponse error
-	log.Println("Type: ", csNSResp.Type)
-	if csNSResp.Status <= 2 {
-		log.Println("CS Status")
-		log.Println(csNSResp.Status)
-		switch csNSResp.Type {
-		case string(constants.StakePLG):
-			errorResponse = utils.StakePLGCallback(csNSResp, updatedCsActivity)
-		case string(constants.UnstakePLG):
-			errorResponse = utils.UnstakePLGCallback(csNSResp, updatedCsActivity)
-		case string(constants.WithdrawInterest):
-			errorResponse = utils.WithdrawInterestCallback(csNSResp, updatedCsActivity)
-		case string(constants.ReinvestPLG):
-			errorResponse = utils.ReinvestPLGCallback(csNSResp, updatedCsActivity)
-		case string(constants.PostInterest):
-			errorResponse = utils.PostInterestCallback(csNSResp, updatedCsActivity)
-
-		// Dumb transactions with no advanced behaviour but simple postback to backend
-		default:
-			// TODO Generic success handler
-			if csNSResp.Status == structs.Complete {
-
-				// Update Activity status to success
-				targetCsActivity.Status = constants.ActivitySuccess
-				targetCsActivity.TransactionHash = sql.NullString{String: csNSResp.Hash, Valid: true}
-				updatedCsActivity, err := models.CSActivityUpdateFields(targetCsActivity)
-				if err != nil {
-					log.Fatal(err)
-				}
-
-				updatedCS, err := models.CSSearchCSId(updatedCsActivity.CsId)
-				if err != nil {
-					log.Fatal(err)
-				}
-
-				log.Println("The following callback has been completed: ", csNSResp.Type)
-
-				backendEventType, err := constants.GetEventType(targetCsActivity.Type)
-				if err != nil {
-					log.Fatal(err)
-				}
-
-				backendCallbackURL := "/events/blockchain/cs/" + strconv.Itoa(updatedCS.UserId) + "/callback/" + string(csNSResp.Type)
-				requestParameters := req.Param{
-					"eventType": backendEventType,
-					"projectId": updatedCS.UserId,
-					"status":    true,
-				}
-				_, err = utils.PostBackend(requestParameters, backendCallbackURL)
-				if err != nil {
-					log.Fatal(err)
-				}
-			}
-		}
-	} else {
-		log.Println("Function failed: ", csNSResp.Status)
-
-		switch csNSResp.Status {
-		case structs.FailedTimeout:
-			updatedCsActivity.Status = constants.ActivityTimeout
-		case structs.FailedGas:
-			updatedCsActivity.Status = constants.ActivityGasError
-		case structs.FailedInitial:
-			updatedCsActivity.Status = constants.ActivityInitialError
-		case structs.FailedReceipt:
-			updatedCsActivity.Status = constants.ActivityReceiptError
-		case structs.FailedPending:
-			updatedCsActivity.Status = constants.ActivityPendingError
-		}
-		updatedCsActivity.TransactionHash = sql.NullString{String: csNSResp.Hash, Valid: true}
-		updatedCsActivity.ModifiedAt = time.Now()
-		_, err := models.CSActivityUpdateFields(updatedCsActivity)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		updatedCS, err := models.CSSearchCSId(updatedCsActivity.CsId)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		backendEventType, err := constants.GetEventType(updatedCsActivity.Type)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Send response back to backend if activities required are completed
-		backendCallbackURL := "/events/blockchain/cs/" + strconv.Itoa(updatedCS.UserId) + "/callback/" + string(csNSResp.Type)
-		requestParameters := req.Param{
-			"eventType":       backendEventType,
-			"userId":          updatedCS.UserId,
-			"contractAddress": csNSResp.ContractAddress,
-			"status":          false,
-		}
-		_, err = utils.PostBackend(requestParameters, backendCallbackURL)
-		if err != nil {
-			log.Fatal(err)
-		}
-	}
-
-	if errorResponse != nil {
-		log.Fatal(errorResponse)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Utility callback failed",
-		})
-	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"msg": "Project callback is OK",
-		})
+	if err := h.service(c).CsCallback(csNSResp); err != nil {
+		ErrorResponse(c, err)
+		return
 	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": "Project callback is OK",
+	})
 }
 
 // POST requests
-func ProjectCreateHandler(c *gin.Context) {
+func (h *Handler) ProjectCreateHandler(c *gin.Context) {
 	var projectRequest structs.RequestProjectCreate
-	if err := c.BindJSON(&projectRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &projectRequest) {
 		return
 	}
-	_, err := utils.ProjectCreate(projectRequest)
+	_, err := h.service(c).ProjectCreate(projectRequest)
 	if err != nil {
-		log.Printf("%v", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": err.Error(),
-		})
+		ErrorResponse(c, err)
 		return
 	}
 
@@ -318,30 +57,30 @@ func ProjectCreateHandler(c *gin.Context) {
 	})
 }
 
-func SetBackersHandler(c *gin.Context) {
+func (h *Handler) SetBackersHandler(c *gin.Context) {
 	var setBackersRequest structs.RequestSetBackers
-	if err := c.BindJSON(&setBackersRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &setBackersRequest) {
+		return
+	}
+	if err := h.service(c).SetBackers(setBackersRequest); err != nil {
+		ErrorResponse(c, err)
 		return
 	}
-	utils.SetBackers(setBackersRequest)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Set Backers accepted",
 	})
 }
 
-func SetProjectInfoHandler(c *gin.Context) {
+func (h *Handler) SetProjectInfoHandler(c *gin.Context) {
 	var setProjectInfoRequest structs.RequestSetProjectInfo
-	if err := c.BindJSON(&setProjectInfoRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &setProjectInfoRequest) {
+		return
+	}
+	if err := h.service(c).SetProjectInfo(setProjectInfoRequest); err != nil {
+		ErrorResponse(c, err)
 		return
 	}
-	utils.SetProjectInfo(setProjectInfoRequest)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Set Project Info accepted",
@@ -349,182 +88,200 @@ func SetProjectInfoHandler(c *gin.Context) {
 
 }
 
-func VoteHandler(c *gin.Context) {
+func (h *Handler) VoteHandler(c *gin.Context) {
 
 	var voteRequest structs.RequestVote
-	if err := c.BindJSON(&voteRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &voteRequest) {
+		return
+	}
+	if !authorizeUser(c, voteRequest.UserId) {
 		return
 	}
 
-	utils.SubmitVote(voteRequest)
+	if _, err := h.service(c).SubmitVote(voteRequest); err != nil {
+		ErrorResponse(c, err)
+		return
+	}
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Submit Vote accepted",
 	})
 }
 
-func SetModeratorsHandler(c *gin.Context) {
+func (h *Handler) SetModeratorsHandler(c *gin.Context) {
 	var moderatorRequest structs.RequestSetModerators
-	if err := c.BindJSON(&moderatorRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &moderatorRequest) {
+		return
+	}
+	if err := h.service(c).SetModerators(moderatorRequest); err != nil {
+		ErrorResponse(c, err)
 		return
 	}
-	utils.SetModerators(moderatorRequest)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Set moderators accepted",
 	})
 }
 
-func CommitModerationVoteHandler(c *gin.Context) {
+func (h *Handler) CommitModerationVoteHandler(c *gin.Context) {
 	var moderationVoteRequest structs.RequestCommitModerationVotes
-	if err := c.BindJSON(&moderationVoteRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &moderationVoteRequest) {
+		return
+	}
+	if err := h.service(c).CommitModerationVotes(moderationVoteRequest); err != nil {
+		ErrorResponse(c, err)
 		return
 	}
-	utils.CommitModerationVotes(moderationVoteRequest)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Commit moderation accepted",
 	})
 }
 
-func CancelHandler(c *gin.Context) {
+func (h *Handler) CancelHandler(c *gin.Context) {
 	var cancelRequest structs.RequestCancelProject
-	if err := c.BindJSON(&cancelRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &cancelRequest) {
+		return
+	}
+	if err := h.service(c).CancelProject(cancelRequest); err != nil {
+		ErrorResponse(c, err)
 		return
 	}
-	utils.CancelProject(cancelRequest)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Cancel project accepted",
 	})
 }
 
-func ReleaseFundsHandler(c *gin.Context) {
+func (h *Handler) ReleaseFundsHandler(c *gin.Context) {
 	var releaseFundRequest structs.RequestReleaseFunds
-	if err := c.BindJSON(&releaseFundRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &releaseFundRequest) {
+		return
+	}
+	if !authorizeUser(c, releaseFundRequest.UserId) {
+		return
+	}
+	if err := h.service(c).ReleaseFunds(releaseFundRequest); err != nil {
+		ErrorResponse(c, err)
 		return
 	}
-	utils.ReleaseFunds(releaseFundRequest)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Release funds accepted",
 	})
 }
 
-func FundRecoveryHandler(c *gin.Context) {
+func (h *Handler) FundRecoveryHandler(c *gin.Context) {
 	var fundRecoveryRequest structs.RequestFailedFundRecovery
-	if err := c.BindJSON(&fundRecoveryRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &fundRecoveryRequest) {
+		return
+	}
+	if err := h.service(c).FailedFundRecovery(fundRecoveryRequest); err != nil {
+		ErrorResponse(c, err)
 		return
 	}
-	utils.FailedFundRecovery(fundRecoveryRequest)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Failed Fund recovery accepted",
 	})
 }
 
-func StakeHandler(c *gin.Context) {
+func (h *Handler) StakeHandler(c *gin.Context) {
 	var stakeRequest structs.RequestStakePLG
-	if err := c.BindJSON(&stakeRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &stakeRequest) {
+		return
+	}
+	if !authorizePathUser(c, stakeRequest.UserId) {
+		return
+	}
+	if _, err := h.service(c).StakePLG(stakeRequest); err != nil {
+		ErrorResponse(c, err)
 		return
 	}
-	utils.StakePLG(stakeRequest)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Stake PLG accepted",
 	})
 }
 
-func UnstakeHandler(c *gin.Context) {
+func (h *Handler) UnstakeHandler(c *gin.Context) {
 	var unstakeRequest structs.RequestUnstakePLG
-	if err := c.BindJSON(&unstakeRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &unstakeRequest) {
+		return
+	}
+	if !authorizePathUser(c, unstakeRequest.UserId) {
+		return
+	}
+	if _, err := h.service(c).UnstakePLG(unstakeRequest); err != nil {
+		ErrorResponse(c, err)
 		return
 	}
-	utils.UnstakePLG(unstakeRequest)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Unstake PLG accepted",
 	})
 }
 
-func WithdrawInterestHandler(c *gin.Context) {
+func (h *Handler) WithdrawInterestHandler(c *gin.Context) {
 	var withdrawInterestRequest structs.RequestWithdrawInterest
-	if err := c.BindJSON(&withdrawInterestRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &withdrawInterestRequest) {
+		return
+	}
+	if !authorizePathUser(c, withdrawInterestRequest.UserId) {
+		return
+	}
+	if _, err := h.service(c).WithdrawInterest(withdrawInterestRequest); err != nil {
+		ErrorResponse(c, err)
 		return
 	}
-	utils.WithdrawInterest(withdrawInterestRequest)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Withdraw Interest accepted",
 	})
 }
 
-func ReinvestHandler(c *gin.Context) {
+func (h *Handler) ReinvestHandler(c *gin.Context) {
 	var reinvestRequest structs.RequestReinvestPLG
-	if err := c.BindJSON(&reinvestRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &reinvestRequest) {
+		return
+	}
+	if !authorizePathUser(c, reinvestRequest.UserId) {
+		return
+	}
+	if _, err := h.service(c).ReinvestPLG(reinvestRequest); err != nil {
+		ErrorResponse(c, err)
 		return
 	}
-	utils.ReinvestPLG(reinvestRequest)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Request reinvest accepted",
 	})
 }
 
-func PostInterestHandler(c *gin.Context) {
+func (h *Handler) PostInterestHandler(c *gin.Context) {
 	var postInterestRequest structs.RequestPostInterest
-	if err := c.BindJSON(&postInterestRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &postInterestRequest) {
+		return
+	}
+	if _, err := h.service(c).PostInterest(postInterestRequest); err != nil {
+		ErrorResponse(c, err)
 		return
 	}
-	utils.PostInterest(postInterestRequest)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Request post interest accepted",
 	})
 }
 
-func CheckMilestonesHandler(c *gin.Context) {
+func (h *Handler) CheckMilestonesHandler(c *gin.Context) {
 	var milestoneCheckRequest structs.RequestCheckMilestones
-	if err := c.BindJSON(&milestoneCheckRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &milestoneCheckRequest) {
+		return
+	}
+	if err := h.service(c).CheckMilestones(milestoneCheckRequest); err != nil {
+		ErrorResponse(c, err)
 		return
 	}
-	utils.CheckMilestones(milestoneCheckRequest)
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"msg": "Milestone Check accepted",
@@ -532,20 +289,14 @@ func CheckMilestonesHandler(c *gin.Context) {
 }
 
 // GET requests
-func ProjectStateHandler(c *gin.Context) {
+func (h *Handler) ProjectStateHandler(c *gin.Context) {
 	var projectRequest structs.RequestProjectState
-	if err := c.BindJSON(&projectRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &projectRequest) {
 		return
 	}
-	projectState, err := utils.ProjectGetState(projectRequest)
+	projectState, err := h.service(c).ProjectGetState(projectRequest)
 	if err != nil {
-		log.Printf("%v", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": err.Error(),
-		})
+		ErrorResponse(c, err)
 		return
 	}
 
@@ -554,20 +305,17 @@ func ProjectStateHandler(c *gin.Context) {
 	})
 }
 
-func CsStateHandler(c *gin.Context) {
+func (h *Handler) CsStateHandler(c *gin.Context) {
 	var csRequest structs.RequestCsState
-	if err := c.BindJSON(&csRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &csRequest) {
+		return
+	}
+	if !authorizePathUser(c, csRequest.UserId) {
 		return
 	}
-	csState, err := utils.CsGetState(csRequest)
+	csState, err := h.service(c).CsGetState(csRequest)
 	if err != nil {
-		log.Printf("%v", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": err.Error(),
-		})
+		ErrorResponse(c, err)
 		return
 	}
 
@@ -576,20 +324,17 @@ func CsStateHandler(c *gin.Context) {
 	})
 }
 
-func CsGainsHandler(c *gin.Context) {
+func (h *Handler) CsGainsHandler(c *gin.Context) {
 	var gainsRequest structs.RequestCsGains
-	if err := c.BindJSON(&gainsRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &gainsRequest) {
 		return
 	}
-	csGains, err := utils.CsGains(gainsRequest)
+	if !authorizePathUser(c, gainsRequest.UserId) {
+		return
+	}
+	csGains, err := h.service(c).CsGains(gainsRequest)
 	if err != nil {
-		log.Printf("%v", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": err.Error(),
-		})
+		ErrorResponse(c, err)
 		return
 	}
 
@@ -598,20 +343,17 @@ func CsGainsHandler(c *gin.Context) {
 	})
 }
 
-func UserBalanceHandler(c *gin.Context) {
+func (h *Handler) UserBalanceHandler(c *gin.Context) {
 	var balanceRequest structs.RequestUserBalance
-	if err := c.BindJSON(&balanceRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Invalid Request Data",
-		})
+	if !bindRequest(c, &balanceRequest) {
+		return
+	}
+	if !authorizePathUser(c, balanceRequest.UserId) {
 		return
 	}
-	userBalance, err := utils.GetBalance(balanceRequest)
+	userBalance, err := h.service(c).GetBalance(balanceRequest)
 	if err != nil {
-		log.Printf("%v", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": err.Error(),
-		})
+		ErrorResponse(c, err)
 		return
 	}
 
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/ratelimit"
+)
+
+// RateLimit - limit the requests to group of each client IP, credential and :id path parameter. Requests over
+// the limit get 429 with the seconds to wait in Retry-After
+func RateLimit(limiter *ratelimit.Limiter, group constants.RouteGroup) gin.HandlerFunc {
+	if _, ok := limiter.Limit(group); !ok {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		keys := []string{"ip:" + c.ClientIP()}
+		if credential, ok := Caller(c); ok {
+			keys = append(keys, "caller:"+credential.Name)
+		}
+		if id := c.Param("id"); id != "" {
+			keys = append(keys, "id:"+id)
+		}
+
+		wait := limiter.Take(group, keys, time.Now())
+		if wait > 0 {
+			logger.Warnf(c.Request.Context(), "SECURITY: rate limited %s %s from %s", c.Request.Method, c.Request.URL.Path, c.ClientIP())
+			seconds := int(math.Ceil(wait.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			ErrorResponse(c, errs.New(errs.RateLimited, "Too many requests, retry in %d seconds", seconds))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
+)
+
+// VerifyCallback - reject Nodeserver postbacks that are not signed with secret or were signed outside window.
+// Rejections are logged as security events
+func VerifyCallback(secret string, window time.Duration) gin.HandlerFunc {
+	if secret == "" {
+		log.Println("SECURITY: NODESERVER_CALLBACK_SECRET is not set, every Nodeserver postback will be rejected")
+	}
+
+	return func(c *gin.Context) {
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			ErrorResponse(c, errs.Wrap(errs.Validation, err, "Could not read request body"))
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		timestamp := c.GetHeader(nodeserver.TimestampHeader)
+		signature := c.GetHeader(nodeserver.SignatureHeader)
+		if err := nodeserver.VerifyCallback(secret, timestamp, signature, body, window, time.Now()); err != nil {
+			logger.Warnf(c.Request.Context(), "SECURITY: rejected postback to %s from %s with timestamp %q: %v", c.Request.URL.Path, c.ClientIP(), timestamp, err)
+			ErrorResponse(c, err)
+			return
+		}
+		c.Next()
+	}
+}
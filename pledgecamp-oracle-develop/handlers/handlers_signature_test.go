@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
+)
+
+// Tests for handlers_signature.go
+func TestVerifyCallbackMiddleware(t *testing.T) {
+	log.Println("********************************* TestVerifyCallbackMiddleware() **************************************")
+	gin.SetMode(gin.TestMode)
+	secret := "callback-secret"
+	router := gin.New()
+	router.POST("/projects/:id/callback/:transaction_type", VerifyCallback(secret, time.Minute), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	body := []byte(`{"transaction_uuid":"abc","transaction_status":2}`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	post := func(signature string) int {
+		request := httptest.NewRequest(http.MethodPost, "/projects/1/callback/DEPLOY", bytes.NewReader(body))
+		request.Header.Set(nodeserver.TimestampHeader, timestamp)
+		request.Header.Set(nodeserver.SignatureHeader, signature)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	if code := post(nodeserver.SignCallback(secret, timestamp, body)); code != http.StatusOK {
+		t.Errorf("Expected a signed postback to reach the handler, got %d", code)
+	}
+	if code := post(nodeserver.SignCallback("other", timestamp, body)); code != http.StatusUnauthorized {
+		t.Errorf("Expected a forged postback to be rejected, got %d", code)
+	}
+	log.Println("********************************* End TestVerifyCallbackMiddleware() **************************************")
+}
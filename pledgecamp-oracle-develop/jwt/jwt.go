@@ -0,0 +1,163 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+)
+
+// Audience - "aud" claim, a single string or a list of them
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Claims - registered claims checked by the Verifier, the caller role and the users the token may act for.
+// An empty UserIds list does not restrict the users
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+	Role      string   `json:"role"`
+	UserIds   []int    `json:"user_ids"`
+}
+
+// Config - JWKS file of the signing keys and the expected issuer and audience, empty values are not checked
+type Config struct {
+	JWKSFile       string
+	ReloadInterval time.Duration
+	Issuer         string
+	Audience       string
+	Leeway         time.Duration
+}
+
+// Verifier - checks RS256 and ES256 tokens against a KeySet
+type Verifier struct {
+	Keys     *KeySet
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+// NewVerifier - Verifier for config, nil when no JWKS file is configured
+func NewVerifier(config Config) (*Verifier, error) {
+	if config.JWKSFile == "" {
+		return nil, nil
+	}
+	keys, err := LoadKeySet(config.JWKSFile)
+	if err != nil {
+		return nil, err
+	}
+	if config.ReloadInterval > 0 {
+		keys.Watch(config.ReloadInterval)
+	}
+	return &Verifier{Keys: keys, Issuer: config.Issuer, Audience: config.Audience, Leeway: config.Leeway}, nil
+}
+
+// LooksLikeJWT - whether a bearer token has the three segments of a compact JWT
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func decodeSegment(segment string, value interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, value)
+}
+
+// verifySignature - check signature over signed with key, which must match the algorithm
+func verifySignature(algorithm string, key crypto.PublicKey, signed string, signature []byte) bool {
+	digest := sha256.Sum256([]byte(signed))
+	switch algorithm {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature) == nil
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(ecKey, digest[:], r, s)
+	}
+	return false
+}
+
+// Verify - claims of a token signed by one of the keys and valid at now
+func (v *Verifier) Verify(token string, now time.Time) (Claims, error) {
+	var claims Claims
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return claims, errs.New(errs.Unauthorized, "Malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(segments[0], &header); err != nil {
+		return claims, errs.Wrap(errs.Unauthorized, err, "Malformed token header")
+	}
+	if header.Alg != "RS256" && header.Alg != "ES256" {
+		return claims, errs.New(errs.Unauthorized, "Unsupported token algorithm %q", header.Alg)
+	}
+	key, ok := v.Keys.Key(header.Kid)
+	if !ok {
+		return claims, errs.New(errs.Unauthorized, "Unknown token key %q", header.Kid)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil || !verifySignature(header.Alg, key, segments[0]+"."+segments[1], signature) {
+		return claims, errs.New(errs.Unauthorized, "Invalid token signature")
+	}
+
+	if err := decodeSegment(segments[1], &claims); err != nil {
+		return claims, errs.Wrap(errs.Unauthorized, err, "Malformed token claims")
+	}
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(v.Leeway)) {
+		return claims, errs.New(errs.Unauthorized, "Token has expired")
+	}
+	if claims.NotBefore != 0 && now.Add(v.Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return claims, errs.New(errs.Unauthorized, "Token is not valid yet")
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return claims, errs.New(errs.Unauthorized, "Unexpected token issuer")
+	}
+	if v.Audience != "" && !claims.Audience.contains(v.Audience) {
+		return claims, errs.New(errs.Unauthorized, "Unexpected token audience")
+	}
+	return claims, nil
+}
+
+func (a Audience) contains(audience string) bool {
+	for _, value := range a {
+		if value == audience {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// jsonWebKey - public key entry of a JWKS document
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// KeySet - verification keys read from a JWKS file, keyed by kid. The file is read again by Reload so keys
+// can be rotated without a restart
+type KeySet struct {
+	path string
+
+	mu      sync.RWMutex
+	keys    map[string]crypto.PublicKey
+	modTime time.Time
+}
+
+// LoadKeySet - read the JWKS file at path
+func LoadKeySet(path string) (*KeySet, error) {
+	keySet := &KeySet{path: path}
+	if err := keySet.Reload(); err != nil {
+		return nil, err
+	}
+	return keySet, nil
+}
+
+// Key - public key with the given kid
+func (k *KeySet) Key(kid string) (crypto.PublicKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// Reload - read the JWKS file again. The current keys are kept when it cannot be read
+func (k *KeySet) Reload() error {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return err
+	}
+	contents, err := ioutil.ReadFile(k.path)
+	if err != nil {
+		return err
+	}
+	keys, err := parseKeySet(contents)
+	if err != nil {
+		return fmt.Errorf("invalid JWKS file %s: %w", k.path, err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = keys
+	k.modTime = info.ModTime()
+	return nil
+}
+
+// reloadIfChanged - reload when the JWKS file was modified since it was last read
+func (k *KeySet) reloadIfChanged() error {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return err
+	}
+	k.mu.RLock()
+	changed := !info.ModTime().Equal(k.modTime)
+	k.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+	log.Printf("Reloading JWKS file %s", k.path)
+	return k.Reload()
+}
+
+// Watch - reload the keys every interval when the file changed. Send to the returned channel to stop
+func (k *KeySet) Watch(interval time.Duration) chan bool {
+	stop := make(chan bool)
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := k.reloadIfChanged(); err != nil {
+					log.Println("Could not reload JWKS file: ", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return stop
+}
+
+// parseKeySet - RSA and P-256 keys of a JWKS document
+func parseKeySet(contents []byte) (map[string]crypto.PublicKey, error) {
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(contents, &document); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
+
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", jwk.Crv)
+		}
+		x, err := decodeInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("point is not on P-256")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+}
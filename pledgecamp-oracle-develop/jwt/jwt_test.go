@@ -0,0 +1,129 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+)
+
+// Tests for jwt.go
+func signJWT(t *testing.T, algorithm string, kid string, key crypto.Signer, claims Claims) string {
+	header, _ := json.Marshal(map[string]string{"alg": algorithm, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch signer := key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, signer, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, signer, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		rBytes, sBytes := r.Bytes(), s.Bytes()
+		copy(signature[32-len(rBytes):32], rBytes)
+		copy(signature[64-len(sBytes):], sBytes)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeJWKS(t *testing.T, path string, rsaKeys map[string]*rsa.PrivateKey, ecKeys map[string]*ecdsa.PrivateKey) {
+	encode := func(value *big.Int) string { return base64.RawURLEncoding.EncodeToString(value.Bytes()) }
+	var keys []map[string]string
+	for kid, key := range rsaKeys {
+		keys = append(keys, map[string]string{"kid": kid, "kty": "RSA", "use": "sig", "n": encode(key.N), "e": encode(big.NewInt(int64(key.E)))})
+	}
+	for kid, key := range ecKeys {
+		keys = append(keys, map[string]string{"kid": kid, "kty": "EC", "crv": "P-256", "x": encode(key.X), "y": encode(key.Y)})
+	}
+	contents, _ := json.Marshal(map[string]interface{}{"keys": keys})
+	if err := ioutil.WriteFile(path, contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJWTVerifier(t *testing.T) {
+	log.Println("********************************* TestJWTVerifier() **************************************")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	directory, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	jwksFile := directory + "/jwks.json"
+	writeJWKS(t, jwksFile, map[string]*rsa.PrivateKey{"rsa-1": rsaKey}, map[string]*ecdsa.PrivateKey{"ec-1": ecKey})
+
+	verifier, err := NewVerifier(Config{JWKSFile: jwksFile, Issuer: "pledgecamp-backend", Audience: "oracle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	claims := Claims{
+		Subject:   "client-1",
+		Issuer:    "pledgecamp-backend",
+		Audience:  Audience{"oracle"},
+		ExpiresAt: now.Add(5 * time.Minute).Unix(),
+		Role:      string(constants.RoleReadOnly),
+		UserIds:   []int{42},
+	}
+
+	for _, token := range []string{signJWT(t, "RS256", "rsa-1", rsaKey, claims), signJWT(t, "ES256", "ec-1", ecKey, claims)} {
+		verified, err := verifier.Verify(token, now)
+		if err != nil || verified.Role != claims.Role || len(verified.UserIds) != 1 || verified.UserIds[0] != 42 {
+			t.Errorf("Expected the token to verify, got %+v and %v", verified, err)
+		}
+	}
+
+	expired := claims
+	expired.ExpiresAt = now.Add(-time.Hour).Unix()
+	otherIssuer := claims
+	otherIssuer.Issuer = "someone-else"
+	rejected := map[string]string{
+		"expired":        signJWT(t, "RS256", "rsa-1", rsaKey, expired),
+		"other issuer":   signJWT(t, "RS256", "rsa-1", rsaKey, otherIssuer),
+		"unknown key":    signJWT(t, "RS256", "rsa-2", rsaKey, claims),
+		"key mismatch":   signJWT(t, "RS256", "ec-1", rsaKey, claims),
+		"unsigned":       strings.Join(strings.Split(signJWT(t, "RS256", "rsa-1", rsaKey, claims), ".")[:2], ".") + ".",
+		"tampered":       signJWT(t, "RS256", "rsa-1", rsaKey, claims)[:20] + "x" + signJWT(t, "RS256", "rsa-1", rsaKey, claims)[21:],
+		"not a jwt":      "static-token",
+		"none algorithm": base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa-1"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"role":"admin"}`)) + ".",
+	}
+	for name, token := range rejected {
+		if _, err := verifier.Verify(token, now); !errs.Is(err, errs.Unauthorized) {
+			t.Errorf("Expected the %s token to be unauthorized, got %v", name, err)
+		}
+	}
+
+	// Rotating the keys only needs the file to be reloaded
+	rotatedKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writeJWKS(t, jwksFile, map[string]*rsa.PrivateKey{"rsa-2": rotatedKey}, nil)
+	if err := verifier.Keys.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(signJWT(t, "RS256", "rsa-1", rsaKey, claims), now); !errs.Is(err, errs.Unauthorized) {
+		t.Errorf("Expected the retired key to be rejected, got %v", err)
+	}
+	if _, err := verifier.Verify(signJWT(t, "RS256", "rsa-2", rotatedKey, claims), now); err != nil {
+		t.Errorf("Expected the rotated key to be accepted, got %v", err)
+	}
+	log.Println("********************************* End TestJWTVerifier() **************************************")
+}
@@ -0,0 +1,222 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestIDHeader - header carrying the request ID to and from the Oracle
+const RequestIDHeader = "X-Request-ID"
+
+// Level - severity of a log line, lines below the configured level are dropped
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel - level named by s, LevelInfo when s is not a level name
+func ParseLevel(s string) Level {
+	for level, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return level
+		}
+	}
+	return LevelInfo
+}
+
+// Fields - values attached to a log line, keyed by field name
+type Fields map[string]interface{}
+
+var (
+	mu     sync.Mutex
+	output io.Writer = os.Stdout
+	level            = LevelInfo
+)
+
+// Configure - write lines of at least minimum to out
+func Configure(minimum Level, out io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	level = minimum
+	output = out
+}
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	fieldsKey
+)
+
+// WithRequestID - ctx carrying the request ID logged with every line of the request
+func WithRequestID(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestId)
+}
+
+// RequestID - request ID carried by ctx, empty when there is none
+func RequestID(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIDKey).(string)
+	return requestId
+}
+
+// NewRequestID - random ID for a request that did not come with one
+func NewRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+// WithFields - ctx carrying fields, added to those already carried, logged with every line of ctx
+func WithFields(ctx context.Context, fields Fields) context.Context {
+	merged := Fields{}
+	for key, value := range contextFields(ctx) {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, fieldsKey, merged)
+}
+
+func contextFields(ctx context.Context) Fields {
+	fields, _ := ctx.Value(fieldsKey).(Fields)
+	return fields
+}
+
+// detached - values of a context without its deadline and cancellation
+type detached struct {
+	context.Context
+	values context.Context
+}
+
+func (d detached) Value(key interface{}) interface{} {
+	return d.values.Value(key)
+}
+
+// Detach - context carrying the request ID and fields of ctx that is not cancelled with it, for work that
+// must not be abandoned when the caller goes away
+func Detach(ctx context.Context) context.Context {
+	return detached{Context: context.Background(), values: ctx}
+}
+
+// Log - write msg at level as a JSON line with the fields of ctx and fields
+func Log(ctx context.Context, lineLevel Level, msg string, fields Fields) {
+	mu.Lock()
+	defer mu.Unlock()
+	if lineLevel < level {
+		return
+	}
+
+	line := map[string]interface{}{}
+	for key, value := range contextFields(ctx) {
+		line[key] = redactField(key, value)
+	}
+	for key, value := range fields {
+		line[key] = redactField(key, value)
+	}
+	if requestId := RequestID(ctx); requestId != "" {
+		line["request_id"] = requestId
+	}
+	line["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	line["level"] = lineLevel.String()
+	line["msg"] = Redact(msg)
+
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		encoded, _ = json.Marshal(map[string]interface{}{
+			"time":  line["time"],
+			"level": line["level"],
+			"msg":   line["msg"],
+			"error": Redact(err.Error()),
+		})
+	}
+	output.Write(append(encoded, '\n'))
+}
+
+// sprint - arguments joined like log.Println does
+func sprint(args ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
+
+// Debugf - log a formatted debug line
+func Debugf(ctx context.Context, format string, args ...interface{}) {
+	Log(ctx, LevelDebug, fmt.Sprintf(format, args...), nil)
+}
+
+// Infof - log a formatted info line
+func Infof(ctx context.Context, format string, args ...interface{}) {
+	Log(ctx, LevelInfo, fmt.Sprintf(format, args...), nil)
+}
+
+// Warnf - log a formatted warning
+func Warnf(ctx context.Context, format string, args ...interface{}) {
+	Log(ctx, LevelWarn, fmt.Sprintf(format, args...), nil)
+}
+
+// Errorf - log a formatted error
+func Errorf(ctx context.Context, format string, args ...interface{}) {
+	Log(ctx, LevelError, fmt.Sprintf(format, args...), nil)
+}
+
+// Debug - log args joined by spaces at debug level
+func Debug(ctx context.Context, args ...interface{}) {
+	Log(ctx, LevelDebug, sprint(args...), nil)
+}
+
+// Info - log args joined by spaces at info level
+func Info(ctx context.Context, args ...interface{}) {
+	Log(ctx, LevelInfo, sprint(args...), nil)
+}
+
+// Warn - log args joined by spaces at warning level
+func Warn(ctx context.Context, args ...interface{}) {
+	Log(ctx, LevelWarn, sprint(args...), nil)
+}
+
+// Error - log args joined by spaces at error level
+func Error(ctx context.Context, args ...interface{}) {
+	Log(ctx, LevelError, sprint(args...), nil)
+}
+
+// standardWriter - turns the lines of the standard log package into info lines, security events into warnings
+type standardWriter struct{}
+
+func (standardWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	lineLevel := LevelInfo
+	if strings.HasPrefix(msg, "SECURITY:") {
+		lineLevel = LevelWarn
+	}
+	Log(context.Background(), lineLevel, msg, nil)
+	return len(p), nil
+}
+
+// StandardWriter - output for the standard log package so its lines are structured and redacted too
+func StandardWriter() io.Writer {
+	return standardWriter{}
+}
@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// redacted - replaces every secret in a log line
+const redacted = "[REDACTED]"
+
+// secretKeys - field names, and parts of them, whose values are always redacted
+var secretKeys = []string{"token", "secret", "password", "authorization", "signature", "api_key"}
+
+var (
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[^\s"',}\]]+`)
+	assignPattern = regexp.MustCompile(`(?i)((?:access_token|token|secret|password|passwd|api_key)"?\s*[:=]\s*"?)[^\s"',}\]]+`)
+
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret - value that is replaced wherever it appears in a log line. Values under 4 characters are
+// ignored as they would redact ordinary text
+func RegisterSecret(value string) {
+	if len(value) < 4 {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, secret := range secrets {
+		if secret == value {
+			return
+		}
+	}
+	secrets = append(secrets, value)
+}
+
+// Redact - s without registered secrets, bearer tokens and values assigned to secret names
+func Redact(s string) string {
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		s = strings.Replace(s, secret, redacted, -1)
+	}
+	secretsMu.RUnlock()
+	s = bearerPattern.ReplaceAllString(s, "${1}"+redacted)
+	return assignPattern.ReplaceAllString(s, "${1}"+redacted)
+}
+
+// redactField - value of a field, hidden entirely when the field name marks a secret
+func redactField(key string, value interface{}) interface{} {
+	lower := strings.ToLower(key)
+	for _, secretKey := range secretKeys {
+		if strings.Contains(lower, secretKey) {
+			return redacted
+		}
+	}
+	if s, ok := value.(string); ok {
+		return Redact(s)
+	}
+	if err, ok := value.(error); ok {
+		return Redact(err.Error())
+	}
+	return value
+}
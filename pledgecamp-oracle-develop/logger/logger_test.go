@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Tests for logger.go
+func TestLogger(t *testing.T) {
+	log.Println("********************************* TestLogger() **************************************")
+	var output bytes.Buffer
+	Configure(LevelInfo, &output)
+	defer Configure(LevelInfo, os.Stdout)
+	RegisterSecret("very-secret-value")
+
+	ctx := WithRequestID(context.Background(), "request-1")
+	ctx = WithFields(ctx, Fields{"project_id": 7})
+	ctx = WithFields(ctx, Fields{"access_token": "abc"})
+	Debugf(ctx, "Dropped below the level")
+	Errorf(ctx, "Authorization: Bearer eyJhbGciOi.x.y failed with token=%s and %s", "plain", "very-secret-value")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(output.Bytes(), &line); err != nil {
+		t.Fatalf("Expected a single JSON line, got %q: %v", output.String(), err)
+	}
+	if line["level"] != "error" || line["request_id"] != "request-1" || line["project_id"] != float64(7) {
+		t.Errorf("Expected the level, request ID and fields of the context, got %v", line)
+	}
+	if line["access_token"] != "[REDACTED]" {
+		t.Errorf("Expected the token field to be redacted, got %v", line["access_token"])
+	}
+	msg, _ := line["msg"].(string)
+	if strings.Contains(msg, "eyJhbGciOi") || strings.Contains(msg, "plain") || strings.Contains(msg, "very-secret-value") {
+		t.Errorf("Expected the secrets to be redacted, got %q", msg)
+	}
+
+	// A detached context keeps the request ID and fields but is not cancelled with its parent
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	detached := Detach(cancelled)
+	if detached.Err() != nil || RequestID(detached) != "request-1" || contextFields(detached)["project_id"] != 7 {
+		t.Errorf("Expected a live context with the request ID and fields, got %v", detached.Err())
+	}
+
+	// Lines of the standard log package are structured too
+	output.Reset()
+	Configure(LevelDebug, &output)
+	standard := log.New(StandardWriter(), "", 0)
+	standard.Print("SECURITY: rejected postback")
+	line = nil
+	if err := json.Unmarshal(output.Bytes(), &line); err != nil {
+		t.Fatalf("Expected a single JSON line, got %q: %v", output.String(), err)
+	}
+	if line["level"] != "warn" || line["msg"] != "SECURITY: rejected postback" {
+		t.Errorf("Expected a security event to be a warning, got %v", line)
+	}
+	log.Println("********************************* End TestLogger() **************************************")
+}
+
+// Tests for logger_redact.go
+func TestRedact(t *testing.T) {
+	log.Println("********************************* TestRedact() **************************************")
+	RegisterSecret("another-secret")
+	RegisterSecret("abc")
+	for input, expected := range map[string]string{
+		"Authorization: Bearer eyJhbGciOi.x.y": "Authorization: Bearer [REDACTED]",
+		`{"password": "hunter2"}`:              `{"password": "[REDACTED]"}`,
+		"api_key=k-123 sent":                   "api_key=[REDACTED] sent",
+		"posted another-secret upstream":       "posted [REDACTED] upstream",
+		"abc is too short to be a secret":      "abc is too short to be a secret",
+	} {
+		if redacted := Redact(input); redacted != expected {
+			t.Errorf("Expected %q to be redacted as %q, got %q", input, expected, redacted)
+		}
+	}
+
+	if value := redactField("signature", "not-a-secret"); value != "[REDACTED]" {
+		t.Errorf("Expected the signature field to be hidden, got %v", value)
+	}
+	if value := redactField("error", errors.New("token=plain")); value != "token=[REDACTED]" {
+		t.Errorf("Expected the error to be redacted, got %v", value)
+	}
+	if value := redactField("count", 3); value != 3 {
+		t.Errorf("Expected other values to be kept, got %v", value)
+	}
+	log.Println("********************************* End TestRedact() **************************************")
+}
@@ -1,53 +1,36 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/pledgecamp/pledgecamp-oracle/config"
 	"github.com/pledgecamp/pledgecamp-oracle/connect"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
 	"github.com/pledgecamp/pledgecamp-oracle/handlers"
+	"github.com/pledgecamp/pledgecamp-oracle/jwt"
+	"github.com/pledgecamp/pledgecamp-oracle/ratelimit"
 	"github.com/pledgecamp/pledgecamp-oracle/utils"
 )
 
-func TokenAuth() gin.HandlerFunc {
-	requiredToken := "Bearer " + os.Getenv("APP_AUTH_ACCESS_TOKEN")
-
-	// We want to make sure the token is set, bail if not
-	if requiredToken == "" {
-		log.Fatal("Please set APP_AUTH_ACCESS_TOKEN environment variable")
-	}
-
-	return func(c *gin.Context) {
-		log.Println("Inside the authorization section")
-		log.Println(c.Request)
-		token := c.Request.Header.Get("Authorization")
-		if token == requiredToken {
-			tokenArray := strings.Fields(token)
-			inputToken := tokenArray[1]
-			if inputToken == requiredToken {
-				c.Next()
-			}
-		} else {
-			log.Println("Inputted token: ", token)
-			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid APP_AUTH_ACCESS_TOKEN"})
-			return
-		}
-	}
-}
-
-func setupRouter() *gin.Engine {
+func setupRouter(h *handlers.Handler, cfg config.Config, verifier *jwt.Verifier, limiter *ratelimit.Limiter) *gin.Engine {
 	log.Print("Setting up router")
-	r := gin.Default()
+	r := gin.New()
+	r.Use(handlers.RequestLogger(), handlers.Recovery())
 
 	corsConfig := cors.DefaultConfig()
 
-	corsOrigins := os.Getenv("CORS_ALLOWED_ORIGINS")
+	corsOrigins := cfg.App.CorsAllowedOrigins
 	log.Println("CORS Origins: " + corsOrigins)
 
 	// CORS
@@ -60,58 +43,180 @@ func setupRouter() *gin.Engine {
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
 	r.Use(cors.New(corsConfig))
 
+	// Callers authenticate with a named credential or a JWT, APP_AUTH_ACCESS_TOKEN is kept as a bootstrap admin token
+	authenticate := h.Authenticate(cfg.App.AuthAccessToken, verifier)
+
 	// Route Definition
-	r.GET("/", handlers.IndexHandler)
-	r.GET("/projects/:id", handlers.ProjectStateHandler)
-	r.GET("/cs/:id", handlers.CsStateHandler)
-	r.GET("/cs/:id/"+string(constants.GetGains), handlers.CsGainsHandler)
-	r.GET("/users/:id/"+string(constants.GetBalance), handlers.UserBalanceHandler)
+	r.GET("/", h.IndexHandler)
+	// Kubernetes probes, unauthenticated
+	r.GET("/healthz", h.HealthzHandler)
+	r.GET("/readyz", h.ReadyzHandler)
+	r.GET("/projects/:id", h.ProjectStateHandler)
 	r.OPTIONS("/*anything", preflight)
 
-	r.Use(TokenAuth())
+	// User state is public until JWT authentication is enabled, then callers need a token allowed for the user
+	users := r.Group("")
+	if verifier != nil {
+		users.Use(authenticate, handlers.Authorize(constants.RouteUsers))
+	}
+	// This group and each one below limit the requests of every client IP, credential and path id, see RATE_LIMIT_*
+	users.Use(handlers.RateLimit(limiter, constants.RouteUsers))
+	users.GET("/cs/:id", h.CsStateHandler)
+	users.GET("/cs/:id/"+string(constants.GetGains), h.CsGainsHandler)
+	users.GET("/users/:id/"+string(constants.GetBalance), h.UserBalanceHandler)
+
+	r.Use(authenticate)
+
+	// Repeats of a POST with the same Idempotency-Key get the first response back
+	idempotency := h.Idempotency(cfg.App.IdempotencyTTL, cfg.App.IdempotencyLease)
+
+	// Oracle status
+	status := r.Group("", handlers.RateLimit(limiter, constants.RouteStatus), handlers.Authorize(constants.RouteStatus))
+	status.GET("/status", h.StatusHandler)
+	status.GET("/metrics", h.MetricsHandler)
+	status.GET("/migrations", h.MigrationsHandler)
+
+	// Backend event outbox and API credentials
+	admin := r.Group("/admin", handlers.RateLimit(limiter, constants.RouteAdmin), handlers.Authorize(constants.RouteAdmin), idempotency)
+	admin.GET("/outbox/dead", h.DeadLettersHandler)
+	admin.POST("/outbox/:id/redrive", h.RedriveHandler)
+	admin.GET("/credentials", h.CredentialsHandler)
+	admin.POST("/credentials", h.CreateCredentialHandler)
+	admin.DELETE("/credentials/:id", h.RevokeCredentialHandler)
+	admin.GET("/jobs", h.JobsHandler)
+	admin.POST("/jobs/:name/pause", h.PauseJobHandler)
+	admin.POST("/jobs/:name/resume", h.ResumeJobHandler)
+	admin.POST("/jobs/:name/trigger", h.TriggerJobHandler)
+
+	// Nodeserver postbacks, signed with NODESERVER_CALLBACK_SECRET. They are deduplicated by transaction uuid
+	// instead of Idempotency-Key, so an unsigned postback cannot claim the key of the signed one
+	callbacks := r.Group("", handlers.RateLimit(limiter, constants.RouteCallbacks), handlers.Authorize(constants.RouteCallbacks),
+		handlers.VerifyCallback(cfg.NodeServer.CallbackSecret, cfg.NodeServer.CallbackWindow))
+	callbacks.POST("/projects/:id/callback/:transaction_type", h.ProjectCallbackHandler)
+	callbacks.POST("/cs/:id/callback/:transaction_type", h.CsCallbackHandler)
 
 	// Project Actions
-	r.POST("/projects/:id/callback/:transaction_type", handlers.ProjectCallbackHandler)
-	r.POST("/cs/:id/callback/:transaction_type", handlers.CsCallbackHandler)
-	r.POST("/projects/:id", handlers.ProjectCreateHandler)
-	r.POST("/projects/:id/"+string(constants.SetBackers), handlers.SetBackersHandler)
-	r.POST("/projects/:id/"+string(constants.SetProjectInfo), handlers.SetProjectInfoHandler)
-	r.POST("/projects/:id/"+string(constants.SetModerators), handlers.SetModeratorsHandler)
-	r.POST("/projects/:id/"+string(constants.MilestoneVote), handlers.VoteHandler)
-	r.POST("/projects/:id/"+string(constants.CheckMilestone), handlers.CheckMilestonesHandler)
-	r.POST("/projects/:id/"+string(constants.ModerationVote), handlers.VoteHandler)
-	r.POST("/projects/:id/"+string(constants.CommitFinalVotes), handlers.CommitModerationVoteHandler)
-	r.POST("/projects/:id/"+string(constants.FailedFundRecovery), handlers.FundRecoveryHandler)
-	r.POST("/projects/:id/"+string(constants.WithdrawFunds), handlers.ReleaseFundsHandler)
-	r.POST("/projects/:id/"+string(constants.RequestRefund), handlers.ReleaseFundsHandler)
-	r.POST("/projects/:id/"+string(constants.CancelProject), handlers.CancelHandler)
-	r.POST("/cs/:id/"+string(constants.StakePLG), handlers.StakeHandler)
-	r.POST("/cs/:id/"+string(constants.UnstakePLG), handlers.UnstakeHandler)
-	r.POST("/cs/:id/"+string(constants.WithdrawInterest), handlers.WithdrawInterestHandler)
-	r.POST("/cs/:id/"+string(constants.ReinvestPLG), handlers.ReinvestHandler)
-	r.POST("/cs/:id/"+string(constants.PostInterest), handlers.PostInterestHandler)
+	projects := r.Group("/projects", handlers.RateLimit(limiter, constants.RouteProjects), handlers.Authorize(constants.RouteProjects), idempotency)
+	projects.POST("/:id", h.ProjectCreateHandler)
+	projects.POST("/:id/"+string(constants.SetBackers), h.SetBackersHandler)
+	projects.POST("/:id/"+string(constants.SetProjectInfo), h.SetProjectInfoHandler)
+	projects.POST("/:id/"+string(constants.SetModerators), h.SetModeratorsHandler)
+	projects.POST("/:id/"+string(constants.MilestoneVote), h.VoteHandler)
+	projects.POST("/:id/"+string(constants.CheckMilestone), h.CheckMilestonesHandler)
+	projects.POST("/:id/"+string(constants.ModerationVote), h.VoteHandler)
+	projects.POST("/:id/"+string(constants.CommitFinalVotes), h.CommitModerationVoteHandler)
+	projects.POST("/:id/"+string(constants.WithdrawFunds), h.ReleaseFundsHandler)
+	projects.POST("/:id/"+string(constants.RequestRefund), h.ReleaseFundsHandler)
+	projects.POST("/:id/"+string(constants.CancelProject), h.CancelHandler)
+
+	// CampShares Actions
+	cs := r.Group("/cs", handlers.RateLimit(limiter, constants.RouteCampShares), handlers.Authorize(constants.RouteCampShares), idempotency)
+	cs.POST("/:id/"+string(constants.StakePLG), h.StakeHandler)
+	cs.POST("/:id/"+string(constants.UnstakePLG), h.UnstakeHandler)
+	cs.POST("/:id/"+string(constants.WithdrawInterest), h.WithdrawInterestHandler)
+	cs.POST("/:id/"+string(constants.ReinvestPLG), h.ReinvestHandler)
+
+	// Actions moving funds for every user
+	treasury := r.Group("", handlers.RateLimit(limiter, constants.RouteTreasury), handlers.Authorize(constants.RouteTreasury), idempotency)
+	treasury.POST("/projects/:id/"+string(constants.FailedFundRecovery), h.FundRecoveryHandler)
+	treasury.POST("/cs/:id/"+string(constants.PostInterest), h.PostInterestHandler)
 
 	return r
 }
 
 func main() {
-	log.Print("Pledgecamp Oracle started")
 	_ = godotenv.Load()
-	connect.PostgresMigrations()
-	// envVar := connect.LookupEnvOrExit("ENV_MODE")
-	// if strings.ToLower(envVar) == "test" {
-	// 	connect.SqLiteMigrations()
-	// } else {
-	// 	connect.PostgresMigrations()
-	// }
-
-	utils.Warmup()
-	router := setupRouter()
-
-	if err := router.Run(":" + os.Getenv("APP_PORT")); err != nil {
+	os.Exit(run(os.Args[1:]))
+}
+
+// serve - apply the migrations unless told not to, then serve the API and run the scheduler jobs until
+// SIGTERM or SIGINT
+func serve(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	migrations := flags.Bool("migrate", true, "apply pending migrations before serving")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return exitUsage
+	}
+	// Every setting is checked before anything starts, so a bad deploy fails fast with all of its problems
+	cfg, ok := loadConfig(os.Stdout)
+	if !ok {
+		return exitFailed
+	}
+	log.Printf("Pledgecamp Oracle started, env: %v", cfg.App.EnvMode)
+	if *migrations {
+		if err := connect.MigrateUp(cfg.Database); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Migrations complete")
+	}
+
+	service, err := openService(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Every replica competes for the scheduler lease, only the leader runs the scheduler jobs
+	if err := service.StartLeaderElection(); err != nil {
+		log.Print(err)
+	}
+	service.Warmup()
+	if err := service.StartMilestoneTimers(); err != nil {
+		log.Print(err)
+	}
+	service.StartOutboxDispatcher(cfg.Outbox)
+	verifier, err := jwt.NewVerifier(cfg.JWT)
+	if err != nil {
+		log.Fatal(err)
+	}
+	limiter := ratelimit.NewLimiter(cfg.RateLimit)
+	router := setupRouter(handlers.NewHandler(service), cfg, verifier, limiter)
+
+	server := &http.Server{Addr: ":" + cfg.App.Port, Handler: router}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-serveErr:
 		log.Fatal(err)
+	case received := <-signals:
+		log.Printf("Received %v, shutting down", received)
 	}
+	shutdown(server, service, cfg.App.ShutdownTimeout)
+	return exitOk
+}
 
+// shutdown - stop accepting requests and the scheduler jobs, wait for the requests in flight, callbacks
+// included, and the running jobs until timeout, then hand over the scheduler lease and close the database pool
+func shutdown(server *http.Server, service *utils.Service, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Requests still in flight after %v: %v", timeout, err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := utils.StopJobs(ctx); err != nil {
+			log.Print(err)
+		}
+	}()
+	wg.Wait()
+
+	if err := service.ReleaseLeadership(); err != nil {
+		log.Print(err)
+	}
+	if err := connect.CloseDatabase(); err != nil {
+		log.Print(err)
+	}
+	log.Print("Pledgecamp Oracle stopped")
 }
 
 func preflight(c *gin.Context) {
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pledgecamp/pledgecamp-oracle/config"
+	"github.com/pledgecamp/pledgecamp-oracle/connect"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
+	"github.com/pledgecamp/pledgecamp-oracle/utils"
+)
+
+// Exit statuses of the commands
+const (
+	exitOk     = 0
+	exitFailed = 1
+	exitUsage  = 2
+)
+
+// command - subcommand of the Oracle binary, run with the arguments after its name
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) int
+}
+
+// commands - every subcommand, serve is run when none is given
+func commands() []command {
+	return []command{
+		{"serve", "serve [-migrate=false]", "Apply pending migrations, then serve the API and run the scheduler jobs", serve},
+		{"migrate", "migrate up|down [steps]|status|force <version>", "Apply, roll back, report or force the database migrations", migrateCommand},
+		{"scheduler", "scheduler run-once " + strings.Join(utils.SchedulerJobs, "|"), "Run a scheduler job once now", schedulerCommand},
+		{"activity", "activity show [-cs] <id>", "Print a project activity, or a CampShares activity with -cs", activityCommand},
+		{"project", "project show <id>", "Print a project and its activities", projectCommand},
+		{"config", "config check", "Load and check the configuration", configCommand},
+	}
+}
+
+// run - run the subcommand named by the first argument, returning the exit status
+func run(args []string) int {
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	for _, command := range commands() {
+		if command.name == name {
+			return command.run(args)
+		}
+	}
+	printUsage(os.Stderr)
+	if name == "help" || name == "-h" || name == "--help" {
+		return exitOk
+	}
+	return exitUsage
+}
+
+// printUsage - every subcommand and what it does
+func printUsage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: pledgecamp-oracle <command>")
+	fmt.Fprintln(out)
+	for _, command := range commands() {
+		fmt.Fprintf(out, "  %-52s %s\n", command.usage, command.summary)
+	}
+}
+
+// usage - report wrong arguments of a subcommand
+func usage(name string) int {
+	for _, command := range commands() {
+		if command.name == name {
+			fmt.Fprintln(os.Stderr, "Usage: pledgecamp-oracle "+command.usage)
+		}
+	}
+	return exitUsage
+}
+
+// fail - report the error a subcommand stopped on, without the configured secrets
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, logger.Redact(err.Error()))
+	return exitFailed
+}
+
+// loadConfig - load the configuration and write every log line as redacted JSON to out, including those of
+// the standard log package. Commands other than serve log to stderr so their output can be read or piped
+func loadConfig(out io.Writer) (config.Config, bool) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return cfg, false
+	}
+	logger.Configure(logger.ParseLevel(cfg.App.LogLevel), out)
+	for _, secret := range cfg.Secrets() {
+		logger.RegisterSecret(secret)
+	}
+	log.SetFlags(0)
+	log.SetOutput(logger.StandardWriter())
+	return cfg, true
+}
+
+// openService - Service backed by the shared database pool and the configured Nodeserver
+func openService(cfg config.Config) (*utils.Service, error) {
+	database, err := connect.Open(cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+	store := models.NewSQLStore(database, cfg.Database.Driver, cfg.Database.Pool.QueryTimeout)
+	return utils.NewService(cfg, store, nodeserver.NewClient(cfg.NodeServer)), nil
+}
+
+// printJSON - write value to stdout as indented JSON
+func printJSON(value interface{}) int {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return fail(err)
+	}
+	return exitOk
+}
+
+// parseId - positive id given on the command line
+func parseId(arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id <= 0 {
+		return 0, errs.New(errs.Validation, "Invalid id %q", arg)
+	}
+	return id, nil
+}
+
+// migrateCommand - migrate up, down [steps], status or force <version>
+func migrateCommand(args []string) int {
+	if len(args) == 0 {
+		return usage("migrate")
+	}
+	cfg, ok := loadConfig(os.Stderr)
+	if !ok {
+		return exitFailed
+	}
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		if err := connect.MigrateUp(cfg.Database); err != nil {
+			return fail(err)
+		}
+		fmt.Println("Migrations complete")
+	case args[0] == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			var err error
+			if steps, err = parseId(args[1]); err != nil {
+				return usage("migrate")
+			}
+		}
+		if err := connect.MigrateDown(cfg.Database, steps); err != nil {
+			return fail(err)
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", steps)
+	case args[0] == "status" && len(args) == 1:
+		report, err := connect.MigrationStatus(cfg.Database)
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Printf("version: %d\ndirty: %v\nlatest: %d\npending: %d\n", report.Version, report.Dirty, report.Latest, report.Pending)
+		for _, migration := range report.Migrations {
+			applied := " "
+			if migration.Applied {
+				applied = "x"
+			}
+			fmt.Printf("  [%s] %06d %s\n", applied, migration.Version, migration.Name)
+		}
+		if report.Dirty {
+			return exitFailed
+		}
+	case args[0] == "force" && len(args) == 2:
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < -1 {
+			return usage("migrate")
+		}
+		if err := connect.MigrateForce(cfg.Database, version); err != nil {
+			return fail(err)
+		}
+		fmt.Printf("Forced migration version %d\n", version)
+	default:
+		return usage("migrate")
+	}
+	return exitOk
+}
+
+// schedulerCommand - scheduler run-once <job>, stopped at the next point it can on SIGTERM or SIGINT
+func schedulerCommand(args []string) int {
+	if len(args) != 2 || args[0] != "run-once" {
+		return usage("scheduler")
+	}
+	cfg, ok := loadConfig(os.Stderr)
+	if !ok {
+		return exitFailed
+	}
+	service, err := openService(cfg)
+	if err != nil {
+		return fail(err)
+	}
+	defer connect.CloseDatabase()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case received := <-signals:
+			log.Printf("Received %v, stopping job %s", received, args[1])
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := service.RunJobOnce(ctx, args[1]); err != nil {
+		return fail(err)
+	}
+	fmt.Printf("Job %s completed\n", args[1])
+	return exitOk
+}
+
+// activityCommand - activity show [-cs] <id>
+func activityCommand(args []string) int {
+	flags := flag.NewFlagSet("activity", flag.ContinueOnError)
+	campShares := flags.Bool("cs", false, "show a CampShares activity")
+	if len(args) == 0 || args[0] != "show" || flags.Parse(args[1:]) != nil || flags.NArg() != 1 {
+		return usage("activity")
+	}
+	activityId, err := parseId(flags.Arg(0))
+	if err != nil {
+		return fail(err)
+	}
+	cfg, ok := loadConfig(os.Stderr)
+	if !ok {
+		return exitFailed
+	}
+	service, err := openService(cfg)
+	if err != nil {
+		return fail(err)
+	}
+	defer connect.CloseDatabase()
+
+	if *campShares {
+		activity, err := service.CSActivities.SearchActivityID(activityId)
+		if err != nil {
+			return fail(errs.Store(err, "Could not find CampShares activity %d", activityId))
+		}
+		return printJSON(map[string]interface{}{"activity": activity, "activity_status": activity.Status.String()})
+	}
+	activity, err := service.ProjectActivities.SearchActivityID(activityId)
+	if err != nil {
+		return fail(errs.Store(err, "Could not find project activity %d", activityId))
+	}
+	return printJSON(map[string]interface{}{"activity": activity, "activity_status": activity.Status.String()})
+}
+
+// projectCommand - project show <id>
+func projectCommand(args []string) int {
+	if len(args) != 2 || args[0] != "show" {
+		return usage("project")
+	}
+	projectId, err := parseId(args[1])
+	if err != nil {
+		return fail(err)
+	}
+	cfg, ok := loadConfig(os.Stderr)
+	if !ok {
+		return exitFailed
+	}
+	service, err := openService(cfg)
+	if err != nil {
+		return fail(err)
+	}
+	defer connect.CloseDatabase()
+
+	project, err := service.Projects.FetchById(projectId)
+	if err != nil {
+		return fail(errs.Store(err, "Could not find project %d", projectId))
+	}
+	activities, err := service.ProjectActivities.SearchProjectID(projectId)
+	if err != nil {
+		return fail(errs.Store(err, "Could not get the activities of project %d", projectId))
+	}
+	return printJSON(map[string]interface{}{"project": project, "status": project.Status.String(), "activities": activities})
+}
+
+// configCommand - config check, every missing or invalid setting is listed
+func configCommand(args []string) int {
+	if len(args) != 1 || args[0] != "check" {
+		return usage("config")
+	}
+	if _, err := config.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailed
+	}
+	fmt.Println("Configuration is valid")
+	return exitOk
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import "time"
+
+// Outcome label values of the upstream requests and scheduler runs
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+var (
+	// Activities - project and CS activities that reached a final status through a Nodeserver postback
+	Activities = NewCounter("oracle_activities_total",
+		"Activities that reached a final status, by activity reference and activity status.",
+		"activity_reference", "activity_status")
+
+	// NodeServerRequestDuration - time taken by each request to Nodeserver, retries are observed one by one
+	NodeServerRequestDuration = NewHistogram("oracle_nodeserver_request_duration_seconds",
+		"Duration of the requests to Nodeserver in seconds, by HTTP method and outcome.",
+		DefaultBuckets, "method", "outcome")
+
+	// BackendRequestDuration - time taken by each post to the backend
+	BackendRequestDuration = NewHistogram("oracle_backend_request_duration_seconds",
+		"Duration of the posts to the backend in seconds, by outcome.",
+		DefaultBuckets, "outcome")
+
+	// Callbacks - Nodeserver postbacks received, by transaction status and whether they were applied
+	Callbacks = NewCounter("oracle_callbacks_total",
+		"Nodeserver postbacks received, by transaction status and outcome (applied, duplicate, stale or error).",
+		"status", "outcome")
+
+	// SchedulerRunDuration - time taken by each run of the milestone and recovery intervals
+	SchedulerRunDuration = NewHistogram("oracle_scheduler_run_duration_seconds",
+		"Duration of the scheduler runs in seconds, by job and outcome.",
+		[]float64{0.1, 0.5, 1, 5, 10, 30, 60, 300}, "job", "outcome")
+
+	// SchedulerLastSuccess - when each scheduler job last ran without error
+	SchedulerLastSuccess = NewGauge("oracle_scheduler_last_success_timestamp_seconds",
+		"Unix time of the last scheduler run that completed without error, by job.",
+		"job")
+
+	// SchedulerLeader - whether this instance holds the scheduler lease
+	SchedulerLeader = NewGauge("oracle_scheduler_leader",
+		"1 when this instance holds the scheduler lease and runs the scheduler jobs, 0 otherwise.")
+
+	// Projects - projects in each status, refreshed before every scrape
+	Projects = NewGauge("oracle_projects",
+		"Projects by project status.",
+		"status")
+)
+
+// Outcome - outcome label value of err
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeSuccess
+}
+
+// Since - seconds elapsed since start, the unit of the duration histograms
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// written - lines of the metrics output that belong to the metric name
+func written(t *testing.T, name string) []string {
+	var output bytes.Buffer
+	if err := Write(&output); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, line := range strings.Split(output.String(), "\n") {
+		if strings.HasPrefix(line, name) || strings.HasPrefix(line, "# HELP "+name+" ") || strings.HasPrefix(line, "# TYPE "+name+" ") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// expectLines - fail t unless the metric name is written as expected
+func expectLines(t *testing.T, name string, expected ...string) {
+	lines := written(t, name)
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %s to be written as\n%s\ngot\n%s", name, strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+}
+
+// expectPanic - fail t unless run panics
+func expectPanic(t *testing.T, description string, run func()) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected %s to panic", description)
+		}
+	}()
+	run()
+}
+
+// Tests for metrics.go
+func TestCounter(t *testing.T) {
+	log.Println("********************************* TestCounter() **************************************")
+	counter := NewCounter("test_counter_total", "Counted\nevents.", "kind")
+	expectLines(t, "test_counter_total",
+		"# HELP test_counter_total Counted events.",
+		"# TYPE test_counter_total counter")
+
+	counter.Inc("b")
+	counter.Add(2.5, `a"\`)
+	counter.Inc("b")
+	expectLines(t, "test_counter_total",
+		"# HELP test_counter_total Counted events.",
+		"# TYPE test_counter_total counter",
+		`test_counter_total{kind="a\"\\"} 2.5`,
+		`test_counter_total{kind="b"} 2`)
+
+	expectPanic(t, "a series with missing label values", func() { counter.Inc() })
+	expectPanic(t, "a second metric with the same name", func() { NewCounter("test_counter_total", "Again.") })
+	log.Println("********************************* End TestCounter() **************************************")
+}
+
+func TestGauge(t *testing.T) {
+	log.Println("********************************* TestGauge() **************************************")
+	gauge := NewGauge("test_gauge", "Current value.")
+	gauge.Set(3)
+	gauge.Set(1)
+	expectLines(t, "test_gauge",
+		"# HELP test_gauge Current value.",
+		"# TYPE test_gauge gauge",
+		"test_gauge 1")
+	log.Println("********************************* End TestGauge() **************************************")
+}
+
+func TestHistogram(t *testing.T) {
+	log.Println("********************************* TestHistogram() **************************************")
+	histogram := NewHistogram("test_duration_seconds", "Durations.", []float64{1, 0.5}, "job")
+	histogram.Observe(0.2, "sync")
+	histogram.Observe(0.7, "sync")
+	histogram.Observe(4, "sync")
+	expectLines(t, "test_duration_seconds",
+		"# HELP test_duration_seconds Durations.",
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{job="sync",le="0.5"} 1`,
+		`test_duration_seconds_bucket{job="sync",le="1"} 2`,
+		`test_duration_seconds_bucket{job="sync",le="+Inf"} 3`,
+		`test_duration_seconds_sum{job="sync"} 4.9`,
+		`test_duration_seconds_count{job="sync"} 3`)
+	log.Println("********************************* End TestHistogram() **************************************")
+}
+
+// Tests for metrics_oracle.go
+func TestOutcome(t *testing.T) {
+	log.Println("********************************* TestOutcome() **************************************")
+	if outcome := Outcome(nil); outcome != OutcomeSuccess {
+		t.Errorf("Expected no error to be a success, got %s", outcome)
+	}
+	if outcome := Outcome(errors.New("Could not reach Nodeserver")); outcome != OutcomeError {
+		t.Errorf("Expected an error outcome, got %s", outcome)
+	}
+	if elapsed := Since(time.Now().Add(-2 * time.Second)); elapsed < 2 || elapsed > 3 {
+		t.Errorf("Expected about 2 seconds, got %v", elapsed)
+	}
+	log.Println("********************************* End TestOutcome() **************************************")
+}
@@ -0,0 +1,73 @@
+// ******** Connects to Postgresql DB to extract and modify data in DB tables
+
+package models
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/structs"
+)
+
+const (
+	callbackTable = "callbacks"
+)
+
+// CallbackRecord - Nodeserver postback received for a transaction and what the Oracle did with it
+type CallbackRecord struct {
+	Id         int                       `db:"callback_id" json:"callback_id"`
+	UUID       string                    `db:"transaction_uuid" json:"transaction_uuid"`
+	Status     structs.StatusIndex       `db:"transaction_status" json:"transaction_status"`
+	Type       string                    `db:"transaction_type" json:"transaction_type"`
+	ActivityId int                       `db:"activity_id" json:"activity_id"`
+	Outcome    constants.CallbackOutcome `db:"callback_outcome" json:"callback_outcome"`
+	ReceivedAt time.Time                 `db:"received_at" json:"received_at"`
+}
+
+// CallbackStore - persistence of received postbacks
+type CallbackStore interface {
+	Insert(record CallbackRecord) (CallbackRecord, error)
+	SearchUUID(uuid string) ([]CallbackRecord, error)
+}
+
+// sqlCallbackStore - CallbackStore backed by the callbacks table
+type sqlCallbackStore struct {
+	databaseSession
+}
+
+// Insert - record a received postback. Only one postback per transaction uuid and status can be applied
+func (p sqlCallbackStore) Insert(record CallbackRecord) (CallbackRecord, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	callbackCollection := dbConnection.Collection(callbackTable)
+	newId, err := callbackCollection.Insert(map[string]interface{}{
+		"transaction_uuid":   record.UUID,
+		"transaction_status": record.Status,
+		"transaction_type":   record.Type,
+		"activity_id":        record.ActivityId,
+		"callback_outcome":   record.Outcome,
+		"received_at":        record.ReceivedAt,
+	})
+	if err != nil {
+		log.Println(err)
+		return record, errors.New("Could not insert record")
+	}
+	record.Id = int(newId.(int64))
+	return record, nil
+}
+
+// SearchUUID - postbacks received for a transaction, oldest first
+func (p sqlCallbackStore) SearchUUID(uuid string) ([]CallbackRecord, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	res := dbConnection.SelectFrom(callbackTable).Where("transaction_uuid = ?", uuid).OrderBy("callback_id")
+	var records []CallbackRecord
+	err := res.All(&records)
+	if err != nil {
+		log.Println(err)
+		return records, err
+	}
+	return records, nil
+}
@@ -7,7 +7,6 @@ import (
 	"log"
 	"time"
 
-	"github.com/pledgecamp/pledgecamp-oracle/connect"
 	"upper.io/db.v3/postgresql"
 )
 
@@ -33,24 +32,38 @@ CSType:
 4 - Post interest
 */
 type CampShares struct {
-	CSId                int                    `db:"cs_id"`
-	ContractAddress     string                 `db:"contract_address"`
-	CSTime              time.Time              `db:"created_at"`
-	CSType              int                    `db:"cs_type"`
-	UserId              int                    `db:"user_id"`
-	Amount              int                    `db:"amount"`
-	BalanceMovement     int                    `db:"balance_movement"`
-	UnstakeCompleteDate time.Time              `db:"unstake_complete_date"`
-	CSParameters        map[string]interface{} `db:"cs_param"`
+	CSId                int       `db:"cs_id"`
+	ContractAddress     string    `db:"contract_address"`
+	CSTime              time.Time `db:"created_at"`
+	CSType              int       `db:"cs_type"`
+	UserId              int       `db:"user_id"`
+	Amount              int       `db:"amount"`
+	BalanceMovement     int       `db:"balance_movement"`
+	UnstakeCompleteDate time.Time `db:"unstake_complete_date"`
+	CSParameters        JSONMap   `db:"cs_param"`
 }
 
-var csList []CampShares
-var cs CampShares
+// CampShareStore - persistence of CampShare entries
+type CampShareStore interface {
+	Insert(cs CampShares) (CampShares, error)
+	UpdateFields(cs CampShares) (CampShares, error)
+	GetHolderIds() ([]int, error)
+	SearchCSId(csId int) (CampShares, error)
+	GetLatest() (CampShares, error)
+	GetByUserId(userId int) ([]CampShares, error)
+	GetByUserIdCsType(userId int, csType int) ([]CampShares, error)
+	GetByType(csType int) ([]CampShares, error)
+}
+
+// sqlCampShareStore - CampShareStore backed by the campshare table
+type sqlCampShareStore struct {
+	databaseSession
+}
 
-// CSInsert function
-func CSInsert(cs CampShares) (CampShares, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// Insert function
+func (p sqlCampShareStore) Insert(cs CampShares) (CampShares, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 
 	csCollection := dbConnection.Collection(csTable)
 	log.Print("Inside CampShares model")
@@ -63,10 +76,10 @@ func CSInsert(cs CampShares) (CampShares, error) {
 	return cs, nil
 }
 
-// CSUpdateFields - Update entries in CS table
-func CSUpdateFields(cs CampShares) (CampShares, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// UpdateFields - Update entries in CS table
+func (p sqlCampShareStore) UpdateFields(cs CampShares) (CampShares, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 
 	csCollection := dbConnection.Collection(csTable)
 	res := csCollection.Find("cs_id", cs.CSId)
@@ -79,12 +92,13 @@ func CSUpdateFields(cs CampShares) (CampShares, error) {
 	return cs, nil
 }
 
-// GetCSHolderIds - Get list of CS Ids
-func GetCSHolderIds() ([]int, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// GetHolderIds - Get list of CS Ids
+func (p sqlCampShareStore) GetHolderIds() ([]int, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	csCollection := dbConnection.Select("user_id").From(csTable)
 	res := csCollection.GroupBy("user_id")
+	var csList []CampShares
 	err := res.All(&csList)
 	var csHoldersList []int
 	for _, csHolder := range csList {
@@ -97,12 +111,13 @@ func GetCSHolderIds() ([]int, error) {
 	return csHoldersList, nil
 }
 
-// CSSearchCSId - search by cs id
-func CSSearchCSId(csId int) (CampShares, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// SearchCSId - search by cs id
+func (p sqlCampShareStore) SearchCSId(csId int) (CampShares, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	csCollection := dbConnection.Collection(csTable)
 	res := csCollection.Find("cs_id", csId)
+	var cs CampShares
 	err := res.One(&cs)
 	if err != nil {
 		log.Println("Could not find any cs records")
@@ -111,13 +126,14 @@ func CSSearchCSId(csId int) (CampShares, error) {
 	return cs, nil
 }
 
-// Get list of CS transactions sorted by the latest first
-func GetlatestCSId() (CampShares, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// GetLatest - Get the latest CS transaction
+func (p sqlCampShareStore) GetLatest() (CampShares, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	csCollection := dbConnection.SelectFrom(csTable)
 	res := csCollection.OrderBy("-cs_id")
 	log.Println(res)
+	var cs CampShares
 	err := res.One(&cs)
 	if err != nil {
 		log.Println("Could not find any cs records")
@@ -126,12 +142,13 @@ func GetlatestCSId() (CampShares, error) {
 	return cs, nil
 }
 
-// GetCSByUserId - Get list of CS transactions related to a user
-func GetCSByUserId(userId int) ([]CampShares, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// GetByUserId - Get list of CS transactions related to a user
+func (p sqlCampShareStore) GetByUserId(userId int) ([]CampShares, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	csCollection := dbConnection.SelectFrom(csTable)
 	res := csCollection.Where("user_id = ?", userId)
+	var csList []CampShares
 	err := res.All(&csList)
 	if err != nil {
 		log.Println(err)
@@ -140,12 +157,13 @@ func GetCSByUserId(userId int) ([]CampShares, error) {
 	return csList, nil
 }
 
-// GetCSByUserId - Get list of CS transactions related to a user and csType
-func GetCSByUserIdCsType(userId int, csType int) ([]CampShares, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// GetByUserIdCsType - Get list of CS transactions related to a user and csType
+func (p sqlCampShareStore) GetByUserIdCsType(userId int, csType int) ([]CampShares, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	csCollection := dbConnection.SelectFrom(csTable)
 	res := csCollection.Where("user_id = ? AND cs_type = ?", userId, csType)
+	var csList []CampShares
 	err := res.All(&csList)
 	if err != nil {
 		log.Println(err)
@@ -154,12 +172,13 @@ func GetCSByUserIdCsType(userId int, csType int) ([]CampShares, error) {
 	return csList, nil
 }
 
-// GetCSByType - Get list of certain type of CS
-func GetCSByType(csType int) ([]CampShares, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// GetByType - Get list of certain type of CS
+func (p sqlCampShareStore) GetByType(csType int) ([]CampShares, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	csCollection := dbConnection.SelectFrom(csTable)
 	res := csCollection.Where("cs_type = ?", csType)
+	var csList []CampShares
 	err := res.All(&csList)
 	if err != nil {
 		log.Println(err)
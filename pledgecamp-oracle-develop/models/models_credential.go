@@ -0,0 +1,144 @@
+// ******** Connects to Postgresql DB to extract and modify data in DB tables
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+)
+
+const (
+	credentialTable = "credentials"
+)
+
+// Credential - named API token of a caller. Only the SHA-256 hash of the token is stored
+type Credential struct {
+	Id          int            `db:"credential_id" json:"credential_id"`
+	Name        string         `db:"credential_name" json:"credential_name"`
+	TokenHash   string         `db:"token_hash" json:"-"`
+	Role        constants.Role `db:"credential_role" json:"credential_role"`
+	RouteGroups pq.StringArray `db:"route_groups" json:"route_groups"`
+	CreatedAt   time.Time      `db:"created_at" json:"created_at"`
+	RevokedAt   sql.NullTime   `db:"revoked_at" json:"revoked_at"`
+	// UserIds - users a JWT caller may act for, not stored. Empty when the caller is not restricted to users
+	UserIds []int `db:"-" json:"user_ids,omitempty"`
+}
+
+// Allows - whether the credential may call routes of group
+func (c Credential) Allows(group constants.RouteGroup) bool {
+	for _, allowed := range c.RouteGroups {
+		if allowed == string(group) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsUser - whether the credential may act for userId
+func (c Credential) AllowsUser(userId int) bool {
+	if len(c.UserIds) == 0 {
+		return true
+	}
+	for _, allowed := range c.UserIds {
+		if allowed == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// CredentialStore - persistence of API credentials
+type CredentialStore interface {
+	Insert(credential Credential) (Credential, error)
+	FetchById(credentialId int) (Credential, error)
+	FetchActiveByTokenHash(tokenHash string) (Credential, error)
+	FetchAll() ([]Credential, error)
+	UpdateFields(credential Credential) (Credential, error)
+}
+
+// sqlCredentialStore - CredentialStore backed by the credentials table
+type sqlCredentialStore struct {
+	databaseSession
+}
+
+// Insert - add a credential
+func (p sqlCredentialStore) Insert(credential Credential) (Credential, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	credentialCollection := dbConnection.Collection(credentialTable)
+	newId, err := credentialCollection.Insert(map[string]interface{}{
+		"credential_name": credential.Name,
+		"token_hash":      credential.TokenHash,
+		"credential_role": credential.Role,
+		"route_groups":    credential.RouteGroups,
+		"created_at":      credential.CreatedAt,
+		"revoked_at":      credential.RevokedAt,
+	})
+	if err != nil {
+		log.Println(err)
+		return credential, errors.New("Could not insert record")
+	}
+	credential.Id = int(newId.(int64))
+	return credential, nil
+}
+
+// FetchById - get a credential by Id
+func (p sqlCredentialStore) FetchById(credentialId int) (Credential, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	credentialCollection := dbConnection.Collection(credentialTable)
+	var credential Credential
+	err := credentialCollection.Find("credential_id", credentialId).One(&credential)
+	if err != nil {
+		log.Println(err)
+		return credential, err
+	}
+	return credential, nil
+}
+
+// FetchActiveByTokenHash - get the credential that has not been revoked for a token hash
+func (p sqlCredentialStore) FetchActiveByTokenHash(tokenHash string) (Credential, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	res := dbConnection.SelectFrom(credentialTable).Where("token_hash = ? AND revoked_at IS NULL", tokenHash)
+	var credential Credential
+	err := res.One(&credential)
+	if err != nil {
+		return credential, err
+	}
+	return credential, nil
+}
+
+// FetchAll - every credential, including revoked ones
+func (p sqlCredentialStore) FetchAll() ([]Credential, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	res := dbConnection.SelectFrom(credentialTable).OrderBy("credential_id")
+	var credentials []Credential
+	err := res.All(&credentials)
+	if err != nil {
+		log.Println(err)
+		return credentials, err
+	}
+	return credentials, nil
+}
+
+// UpdateFields - update the revocation of a credential
+func (p sqlCredentialStore) UpdateFields(credential Credential) (Credential, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	credentialCollection := dbConnection.Collection(credentialTable)
+	err := credentialCollection.Find("credential_id", credential.Id).Update(map[string]interface{}{
+		"revoked_at": credential.RevokedAt,
+	})
+	if err != nil {
+		log.Println(err)
+		return credential, err
+	}
+	return credential, nil
+}
@@ -8,7 +8,6 @@ import (
 	"log"
 	"time"
 
-	"github.com/pledgecamp/pledgecamp-oracle/connect"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
 )
 
@@ -27,13 +26,26 @@ type CSActivity struct {
 	Type            constants.ActivityReference `db:"activity_type" json:"activity_type"`
 }
 
-var csActivities []CSActivity
-var csActivity CSActivity
+// CSActivityStore - persistence of CS activity entries
+type CSActivityStore interface {
+	Insert(csActivity CSActivity) (CSActivity, error)
+	SearchActivityID(csActivityId int) (CSActivity, error)
+	SearchCsID(csId int) ([]CSActivity, error)
+	SearchCsIDTransType(csId int, transactionType string) ([]CSActivity, error)
+	// Pending - activities still pending that were created before createdBefore, oldest first
+	Pending(createdBefore time.Time) ([]CSActivity, error)
+	UpdateFields(csActivity CSActivity) (CSActivity, error)
+}
+
+// sqlCSActivityStore - CSActivityStore backed by the cs_activity table
+type sqlCSActivityStore struct {
+	databaseSession
+}
 
-// CSActivityInsert - Insert a new activity into activity table
-func CSActivityInsert(csActivity CSActivity) (CSActivity, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// Insert - Insert a new activity into activity table
+func (p sqlCSActivityStore) Insert(csActivity CSActivity) (CSActivity, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	activityCollection := dbConnection.Collection(csActivityTable)
 	newId, err := activityCollection.Insert(map[string]interface{}{
 		"fk_cs_id":         csActivity.CsId,
@@ -52,13 +64,14 @@ func CSActivityInsert(csActivity CSActivity) (CSActivity, error) {
 	return csActivity, nil
 }
 
-// CSActivitySearchActivityID - Search CS activity entries using activity Id
-func CSActivitySearchActivityID(csActivityId int) (CSActivity, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// SearchActivityID - Search CS activity entries using activity Id
+func (p sqlCSActivityStore) SearchActivityID(csActivityId int) (CSActivity, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	activityCollection := dbConnection.Collection(csActivityTable)
 	res := activityCollection.Find("cs_activity_id", csActivityId)
 	log.Println("CSActivitySearchActivityID ", res)
+	var csActivity CSActivity
 	err := res.One(&csActivity)
 	if err != nil {
 		log.Println(err)
@@ -67,13 +80,14 @@ func CSActivitySearchActivityID(csActivityId int) (CSActivity, error) {
 	return csActivity, nil
 }
 
-// CSActivitySearchCsID - Search CS activity entries using csId
-func CSActivitySearchCsID(csId int) ([]CSActivity, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// SearchCsID - Search CS activity entries using csId
+func (p sqlCSActivityStore) SearchCsID(csId int) ([]CSActivity, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	activityCollection := dbConnection.SelectFrom(csActivityTable)
 	res := activityCollection.Where("fk_cs_id = ?", csId)
 	log.Println("CSActivitySearchCsID ", res)
+	var csActivities []CSActivity
 	err := res.All(&csActivities)
 	if err != nil {
 		log.Println(err)
@@ -83,13 +97,14 @@ func CSActivitySearchCsID(csId int) ([]CSActivity, error) {
 	return csActivities, nil
 }
 
-// CSActivitySearchCsIDTransType - Search CS activity entries using csId and transaction type
-func CSActivitySearchCsIDTransType(csId int, transactionType string) ([]CSActivity, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// SearchCsIDTransType - Search CS activity entries using csId and transaction type
+func (p sqlCSActivityStore) SearchCsIDTransType(csId int, transactionType string) ([]CSActivity, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	activityCollection := dbConnection.SelectFrom(csActivityTable)
 	res := activityCollection.Where("fk_cs_id = ? AND activity_type = ?", csId, transactionType)
 	log.Println("CSActivitySearchCsID ", res)
+	var csActivities []CSActivity
 	err := res.All(&csActivities)
 	if err != nil {
 		log.Println(err)
@@ -99,13 +114,14 @@ func CSActivitySearchCsIDTransType(csId int, transactionType string) ([]CSActivi
 	return csActivities, nil
 }
 
-// CSActivityPending - Get CS activity entries which are still pending after 10 minutes
-func CSActivityPending() ([]CSActivity, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// Pending - Get CS activity entries still pending that were created before createdBefore
+func (p sqlCSActivityStore) Pending(createdBefore time.Time) ([]CSActivity, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	activityCollection := dbConnection.SelectFrom(csActivityTable)
-	res := activityCollection.Where("activity_status = 0 AND created_at > (now() + interval '10 minutes')")
+	res := activityCollection.Where("activity_status = ? AND created_at <= ?", constants.ActivityPending, createdBefore).OrderBy("created_at")
 	log.Print("CSActivityPending ", res)
+	var csActivities []CSActivity
 	err := res.All(&csActivities)
 	if err != nil {
 		log.Println(err)
@@ -115,10 +131,10 @@ func CSActivityPending() ([]CSActivity, error) {
 	return csActivities, nil
 }
 
-// CSActivityUpdateFields - Update CS activity entry fields
-func CSActivityUpdateFields(csActivity CSActivity) (CSActivity, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// UpdateFields - Update CS activity entry fields
+func (p sqlCSActivityStore) UpdateFields(csActivity CSActivity) (CSActivity, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	activityCollection := dbConnection.Collection(csActivityTable)
 	res := activityCollection.Find("cs_activity_id", csActivity.Id)
 	log.Println("Incoming activity", csActivity)
@@ -140,15 +156,15 @@ func CSActivityUpdateFields(csActivity CSActivity) (CSActivity, error) {
 }
 
 // SetCSActivity - Set new CS activity
-func SetCSActivity(csId int, activityType constants.ActivityReference) (CSActivity, error) {
+func SetCSActivity(csActivities CSActivityStore, csId int, activityType constants.ActivityReference) (CSActivity, error) {
 	var csActivity CSActivity
 	csActivity.CsId = csId
 	csActivity.CreatedAt = time.Now()
 	csActivity.ModifiedAt = time.Now()
 	csActivity.Type = activityType
-	csActivity, err := CSActivityInsert(csActivity)
+	csActivity, err := csActivities.Insert(csActivity)
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
 		return csActivity, err
 	}
 	return csActivity, nil
@@ -0,0 +1,153 @@
+// ******** Connects to Postgresql DB to extract and modify data in DB tables
+
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+const (
+	idempotencyTable = "idempotency_keys"
+)
+
+// IdempotencyRecord - outcome of a request made with an Idempotency-Key header. StatusCode is 0 while the
+// first request with the key is still being processed. ClaimToken identifies the request holding the key, a
+// request whose key was taken over can no longer store its response
+type IdempotencyRecord struct {
+	Key          string    `db:"idempotency_key" json:"idempotency_key"`
+	Fingerprint  string    `db:"fingerprint" json:"fingerprint"`
+	ClaimToken   string    `db:"claim_token" json:"-"`
+	StatusCode   int       `db:"status_code" json:"status_code"`
+	ResponseBody string    `db:"response_body" json:"response_body"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	ModifiedAt   time.Time `db:"modified_at" json:"modified_at"`
+}
+
+// IdempotencyStore - persistence of idempotency keys. Insert fails when the key is already recorded. Renew,
+// UpdateFields and Delete only apply to the claim of record, they report false once the key was taken over
+type IdempotencyStore interface {
+	Insert(record IdempotencyRecord) (IdempotencyRecord, error)
+	FetchByKey(key string) (IdempotencyRecord, error)
+	Reclaim(record IdempotencyRecord, createdBefore time.Time, leasedBefore time.Time) (bool, error)
+	Renew(record IdempotencyRecord) (bool, error)
+	UpdateFields(record IdempotencyRecord) (IdempotencyRecord, bool, error)
+	Delete(record IdempotencyRecord) (bool, error)
+	DeleteBefore(createdAt time.Time) error
+}
+
+// sqlIdempotencyStore - IdempotencyStore backed by the idempotency_keys table
+type sqlIdempotencyStore struct {
+	databaseSession
+}
+
+// Insert - claim an idempotency key
+func (p sqlIdempotencyStore) Insert(record IdempotencyRecord) (IdempotencyRecord, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	idempotencyCollection := dbConnection.Collection(idempotencyTable)
+	record.CreatedAt = record.CreatedAt.UTC()
+	record.ModifiedAt = record.ModifiedAt.UTC()
+	_, err := idempotencyCollection.Insert(record)
+	if err != nil {
+		log.Println(err)
+		return record, err
+	}
+	return record, nil
+}
+
+// FetchByKey - get the record of an idempotency key
+func (p sqlIdempotencyStore) FetchByKey(key string) (IdempotencyRecord, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	idempotencyCollection := dbConnection.Collection(idempotencyTable)
+	var record IdempotencyRecord
+	err := idempotencyCollection.Find("idempotency_key", key).One(&record)
+	if err != nil {
+		log.Println(err)
+		return record, err
+	}
+	return record, nil
+}
+
+// Reclaim - claim a recorded key again in a single statement, so only one request takes it over. The key is
+// taken when it was recorded before createdBefore, or when the request that holds it for the same fingerprint
+// has not answered since leasedBefore
+func (p sqlIdempotencyStore) Reclaim(record IdempotencyRecord, createdBefore time.Time, leasedBefore time.Time) (bool, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	res, err := dbConnection.Exec(`UPDATE idempotency_keys SET
+			fingerprint = ?, claim_token = ?, status_code = 0, response_body = '', created_at = ?, modified_at = ?
+		WHERE idempotency_key = ? AND (created_at < ? OR (status_code = 0 AND fingerprint = ? AND modified_at < ?))`,
+		record.Fingerprint, record.ClaimToken, record.CreatedAt.UTC(), record.ModifiedAt.UTC(), record.Key, createdBefore.UTC(), record.Fingerprint, leasedBefore.UTC())
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return rowsAffected(res)
+}
+
+// Renew - extend the lease of the claim of record while its request is still being processed
+func (p sqlIdempotencyStore) Renew(record IdempotencyRecord) (bool, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	res, err := dbConnection.Exec(`UPDATE idempotency_keys SET modified_at = ?
+		WHERE idempotency_key = ? AND claim_token = ? AND status_code = 0`,
+		record.ModifiedAt.UTC(), record.Key, record.ClaimToken)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return rowsAffected(res)
+}
+
+// UpdateFields - store the response of the request that claimed the key
+func (p sqlIdempotencyStore) UpdateFields(record IdempotencyRecord) (IdempotencyRecord, bool, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	res, err := dbConnection.Exec(`UPDATE idempotency_keys SET status_code = ?, response_body = ?, modified_at = ?
+		WHERE idempotency_key = ? AND claim_token = ?`,
+		record.StatusCode, record.ResponseBody, record.ModifiedAt.UTC(), record.Key, record.ClaimToken)
+	if err != nil {
+		log.Println(err)
+		return record, false, err
+	}
+	updated, err := rowsAffected(res)
+	return record, updated, err
+}
+
+// Delete - release the key of the request that claimed it
+func (p sqlIdempotencyStore) Delete(record IdempotencyRecord) (bool, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	res, err := dbConnection.Exec(`DELETE FROM idempotency_keys WHERE idempotency_key = ? AND claim_token = ?`,
+		record.Key, record.ClaimToken)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return rowsAffected(res)
+}
+
+// rowsAffected - whether the statement of res changed a row
+func rowsAffected(res sql.Result) (bool, error) {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// DeleteBefore - forget the keys recorded before createdAt
+func (p sqlIdempotencyStore) DeleteBefore(createdAt time.Time) error {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	idempotencyCollection := dbConnection.Collection(idempotencyTable)
+	err := idempotencyCollection.Find("created_at < ?", createdAt.UTC()).Delete()
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
@@ -0,0 +1,174 @@
+// ******** Connects to Postgresql DB to extract and modify data in DB tables
+
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"upper.io/db.v3"
+)
+
+const (
+	jobTable = "jobs"
+)
+
+// Job - scheduled job run by the job worker, with the outcome of its last run. A worker holds the job until
+// LeaseExpiresAt while it runs it. Attempts counts the runs since the last one that succeeded
+type Job struct {
+	Name           string         `db:"job_name" json:"job_name"`
+	Schedule       string         `db:"schedule" json:"schedule"`
+	Paused         bool           `db:"paused" json:"paused"`
+	NextRunAt      time.Time      `db:"next_run_at" json:"next_run_at"`
+	Attempts       int            `db:"attempts" json:"attempts"`
+	LastRunAt      sql.NullTime   `db:"last_run_at" json:"last_run_at"`
+	LastSuccessAt  sql.NullTime   `db:"last_success_at" json:"last_success_at"`
+	LastError      sql.NullString `db:"last_error" json:"last_error"`
+	LastDurationMs int            `db:"last_duration_ms" json:"last_duration_ms"`
+	LastProcessed  int            `db:"last_processed" json:"last_processed"`
+	LeaseHolder    sql.NullString `db:"lease_holder" json:"lease_holder"`
+	LeaseExpiresAt sql.NullTime   `db:"lease_expires_at" json:"lease_expires_at"`
+	CreatedAt      time.Time      `db:"created_at" json:"created_at"`
+	ModifiedAt     time.Time      `db:"modified_at" json:"modified_at"`
+}
+
+// Running - whether a worker holds the job at now
+func (j Job) Running(now time.Time) bool {
+	return j.LeaseHolder.Valid && j.LeaseExpiresAt.Valid && j.LeaseExpiresAt.Time.After(now)
+}
+
+// JobStore - persistence of the scheduled jobs
+type JobStore interface {
+	// Register - add the job unless it exists. An existing job whose schedule changed takes the schedule and
+	// NextRunAt of job, its other fields are kept
+	Register(job Job) (Job, error)
+	FetchByName(name string) (Job, error)
+	FetchAll() ([]Job, error)
+	// Claim - hold the job for holder until now + ttl when it is due, not paused and not held by a worker.
+	// Returns false when the job was not claimed
+	Claim(name string, holder string, now time.Time, ttl time.Duration) (Job, bool, error)
+	// Finish - record the outcome of a run and release the job, when holder still has it
+	Finish(job Job) (Job, error)
+	// UpdateFields - update whether the job is paused and when it runs next
+	UpdateFields(job Job) (Job, error)
+}
+
+// sqlJobStore - JobStore backed by the jobs table
+type sqlJobStore struct {
+	databaseSession
+}
+
+// Register - insert the job, or update the schedule of the existing one when it changed
+func (p sqlJobStore) Register(job Job) (Job, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	_, err := dbConnection.Exec(`INSERT INTO jobs (job_name, schedule, paused, next_run_at, attempts, last_duration_ms,
+			last_processed, created_at, modified_at)
+		VALUES (?, ?, ?, ?, 0, 0, 0, ?, ?)
+		ON CONFLICT (job_name) DO UPDATE SET
+			schedule = excluded.schedule,
+			next_run_at = excluded.next_run_at,
+			modified_at = excluded.modified_at
+		WHERE jobs.schedule <> excluded.schedule`,
+		job.Name, job.Schedule, job.Paused, job.NextRunAt.UTC(), job.CreatedAt.UTC(), job.ModifiedAt.UTC())
+	if err != nil {
+		log.Println(err)
+		return job, err
+	}
+	return p.FetchByName(job.Name)
+}
+
+// FetchByName - get a job by name
+func (p sqlJobStore) FetchByName(name string) (Job, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	jobCollection := dbConnection.Collection(jobTable)
+	var job Job
+	err := jobCollection.Find("job_name", name).One(&job)
+	if err != nil {
+		log.Println(err)
+		return job, err
+	}
+	return job, nil
+}
+
+// FetchAll - every job by name
+func (p sqlJobStore) FetchAll() ([]Job, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	res := dbConnection.SelectFrom(jobTable).OrderBy("job_name")
+	var jobs []Job
+	err := res.All(&jobs)
+	if err != nil {
+		log.Println(err)
+		return jobs, err
+	}
+	return jobs, nil
+}
+
+// Claim - take the lease of a due job in a single statement, so only one worker runs it
+func (p sqlJobStore) Claim(name string, holder string, now time.Time, ttl time.Duration) (Job, bool, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	now = now.UTC()
+	res, err := dbConnection.Exec(`UPDATE jobs SET
+			lease_holder = ?, lease_expires_at = ?, attempts = attempts + 1, last_run_at = ?, modified_at = ?
+		WHERE job_name = ? AND paused = ? AND next_run_at <= ? AND (lease_holder IS NULL OR lease_expires_at <= ?)`,
+		holder, now.Add(ttl), now, now, name, false, now, now)
+	if err != nil {
+		log.Println(err)
+		return Job{}, false, err
+	}
+	claimed, err := res.RowsAffected()
+	if err != nil || claimed == 0 {
+		return Job{}, false, err
+	}
+
+	job, err := p.FetchByName(name)
+	if err != nil {
+		return job, false, err
+	}
+	return job, true, nil
+}
+
+// Finish - store the outcome of the run and clear the lease of its holder
+func (p sqlJobStore) Finish(job Job) (Job, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	jobCollection := dbConnection.Collection(jobTable)
+	res := jobCollection.Find(db.Cond{"job_name": job.Name, "lease_holder": job.LeaseHolder.String})
+	err := res.Update(map[string]interface{}{
+		"next_run_at":      job.NextRunAt.UTC(),
+		"attempts":         job.Attempts,
+		"last_success_at":  job.LastSuccessAt,
+		"last_error":       job.LastError,
+		"last_duration_ms": job.LastDurationMs,
+		"last_processed":   job.LastProcessed,
+		"lease_holder":     nil,
+		"lease_expires_at": nil,
+		"modified_at":      job.ModifiedAt.UTC(),
+	})
+	if err != nil {
+		log.Println(err)
+		return job, err
+	}
+	return p.FetchByName(job.Name)
+}
+
+// UpdateFields - update whether the job is paused and when it runs next
+func (p sqlJobStore) UpdateFields(job Job) (Job, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	jobCollection := dbConnection.Collection(jobTable)
+	err := jobCollection.Find("job_name", job.Name).Update(map[string]interface{}{
+		"paused":      job.Paused,
+		"next_run_at": job.NextRunAt.UTC(),
+		"modified_at": job.ModifiedAt.UTC(),
+	})
+	if err != nil {
+		log.Println(err)
+		return job, err
+	}
+	return p.FetchByName(job.Name)
+}
@@ -0,0 +1,73 @@
+// ******** Connects to Postgresql DB to extract and modify data in DB tables
+
+package models
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	leaseTable = "leases"
+)
+
+// Lease - named lock held by one Oracle instance until ExpiresAt, unless it renews it before then
+type Lease struct {
+	Name       string    `db:"lease_name" json:"name"`
+	Holder     string    `db:"holder" json:"holder"`
+	AcquiredAt time.Time `db:"acquired_at" json:"acquired_at"`
+	ExpiresAt  time.Time `db:"expires_at" json:"expires_at"`
+}
+
+// LeaseStore - persistence of leases shared by the Oracle instances
+type LeaseStore interface {
+	// Acquire - take the lease for holder until now + ttl, or renew it when holder has it already. Another
+	// holder keeps the lease until it expires. Returns the lease as it stands after the attempt
+	Acquire(name string, holder string, now time.Time, ttl time.Duration) (Lease, error)
+	// Release - give up the lease when holder has it, so another instance can take it right away
+	Release(name string, holder string) error
+}
+
+// sqlLeaseStore - LeaseStore backed by the leases table
+type sqlLeaseStore struct {
+	databaseSession
+}
+
+// Acquire - insert the lease, or take it over in the same statement when it is free, expired or already
+// held by holder, so two instances can never both succeed
+func (p sqlLeaseStore) Acquire(name string, holder string, now time.Time, ttl time.Duration) (Lease, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	now = now.UTC()
+	_, err := dbConnection.Exec(`INSERT INTO leases (lease_name, holder, acquired_at, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (lease_name) DO UPDATE SET
+			holder = excluded.holder,
+			acquired_at = CASE WHEN leases.holder = excluded.holder THEN leases.acquired_at ELSE excluded.acquired_at END,
+			expires_at = excluded.expires_at
+		WHERE leases.holder = excluded.holder OR leases.expires_at <= excluded.acquired_at`,
+		name, holder, now, now.Add(ttl))
+	if err != nil {
+		log.Println(err)
+		return Lease{}, err
+	}
+
+	var lease Lease
+	err = dbConnection.Collection(leaseTable).Find("lease_name", name).One(&lease)
+	if err != nil {
+		log.Println(err)
+		return lease, err
+	}
+	return lease, nil
+}
+
+// Release - delete the lease when holder has it
+func (p sqlLeaseStore) Release(name string, holder string) error {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	leaseCollection := dbConnection.Collection(leaseTable)
+	err := leaseCollection.Find("lease_name = ? AND holder = ?", name, holder).Delete()
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
@@ -0,0 +1,1044 @@
+// ******** In-memory implementation of the model stores for tests and local runs
+
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"upper.io/db.v3"
+)
+
+// memoryDB holds the rows of every table behind a single lock
+type memoryDB struct {
+	mu                sync.Mutex
+	txMu              sync.Mutex
+	projects          []Project
+	votes             []Vote
+	campShares        []CampShares
+	projectActivities []ProjectActivity
+	csActivities      []CSActivity
+	outbox            []OutboxEvent
+	idempotency       []IdempotencyRecord
+	callbacks         []CallbackRecord
+	credentials       []Credential
+	leases            map[string]Lease
+	jobs              map[string]Job
+	lastVoteId        int
+	lastActivityId    int
+	lastCSActivityId  int
+	lastOutboxId      int
+	lastCallbackId    int
+	lastCredentialId  int
+}
+
+// NewMemoryStore - Store that keeps every table in memory
+func NewMemoryStore() Store {
+	memory := &memoryDB{}
+	return memory.store(memory.atomic)
+}
+
+func (m *memoryDB) store(atomic func(work UnitOfWork) error) Store {
+	return Store{
+		Projects:          memoryProjectStore{m},
+		Votes:             memoryVoteStore{m},
+		CampShares:        memoryCampShareStore{m},
+		ProjectActivities: memoryProjectActivityStore{m},
+		CSActivities:      memoryCSActivityStore{m},
+		Outbox:            memoryOutboxStore{m},
+		Idempotency:       memoryIdempotencyStore{m},
+		Callbacks:         memoryCallbackStore{m},
+		Credentials:       memoryCredentialStore{m},
+		Leases:            memoryLeaseStore{m},
+		Jobs:              memoryJobStore{m},
+		atomic:            atomic,
+	}
+}
+
+// atomic - units of work run one at a time and the tables are restored when one fails.
+// Writes made outside a unit of work while it runs are lost if it rolls back.
+func (m *memoryDB) atomic(work UnitOfWork) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	snapshot := m.snapshot()
+	tx := m.store(func(inner UnitOfWork) error {
+		return inner(m.store(nil))
+	})
+	err := work(tx)
+	if err != nil {
+		m.restore(snapshot)
+	}
+	return err
+}
+
+// snapshot - copy of the tables. Like Postgres identity columns, ids are not reused after a rollback
+func (m *memoryDB) snapshot() *memoryDB {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &memoryDB{
+		projects:          append([]Project(nil), m.projects...),
+		votes:             append([]Vote(nil), m.votes...),
+		campShares:        append([]CampShares(nil), m.campShares...),
+		projectActivities: append([]ProjectActivity(nil), m.projectActivities...),
+		csActivities:      append([]CSActivity(nil), m.csActivities...),
+		outbox:            append([]OutboxEvent(nil), m.outbox...),
+		idempotency:       append([]IdempotencyRecord(nil), m.idempotency...),
+		callbacks:         append([]CallbackRecord(nil), m.callbacks...),
+		credentials:       append([]Credential(nil), m.credentials...),
+	}
+}
+
+func (m *memoryDB) restore(snapshot *memoryDB) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.projects = snapshot.projects
+	m.votes = snapshot.votes
+	m.campShares = snapshot.campShares
+	m.projectActivities = snapshot.projectActivities
+	m.csActivities = snapshot.csActivities
+	m.outbox = snapshot.outbox
+	m.idempotency = snapshot.idempotency
+	m.callbacks = snapshot.callbacks
+	m.credentials = snapshot.credentials
+}
+
+// jsonbCopy - round trip a parameter map through JSON the same way a jsonb column does
+func jsonbCopy(params map[string]interface{}) map[string]interface{} {
+	if params == nil {
+		return nil
+	}
+	var copied map[string]interface{}
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return nil
+	}
+	json.Unmarshal(encoded, &copied)
+	return copied
+}
+
+// stringArrayCopy - copy a text[] column so callers cannot mutate stored rows
+func stringArrayCopy(values pq.StringArray) pq.StringArray {
+	if values == nil {
+		return nil
+	}
+	return append(pq.StringArray{}, values...)
+}
+
+func (m *memoryDB) projectIndex(projectId int) int {
+	for i, project := range m.projects {
+		if project.Id == projectId {
+			return i
+		}
+	}
+	return -1
+}
+
+func copyProject(project Project) Project {
+	project.ActivitiesCompleted = stringArrayCopy(project.ActivitiesCompleted)
+	project.ProjectParameters = jsonbCopy(project.ProjectParameters)
+	return project
+}
+
+func copyVote(vote Vote) Vote {
+	vote.VoteParameters = jsonbCopy(vote.VoteParameters)
+	return vote
+}
+
+func copyCampShares(cs CampShares) CampShares {
+	cs.CSParameters = jsonbCopy(cs.CSParameters)
+	return cs
+}
+
+// memoryProjectStore - ProjectStore kept in memory
+type memoryProjectStore struct {
+	*memoryDB
+}
+
+// Insert - insert new project entry
+func (m memoryProjectStore) Insert(project Project) (Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.projectIndex(project.Id) >= 0 {
+		return project, errors.New("Could not insert record")
+	}
+	stored := copyProject(project)
+	// Matches the Postgres insert, which does not write activities_completed
+	stored.ActivitiesCompleted = nil
+	m.projects = append(m.projects, stored)
+	return project, nil
+}
+
+// UpdateFields - Update entries in project table
+func (m memoryProjectStore) UpdateFields(project Project) (Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := m.projectIndex(project.Id); i >= 0 {
+		m.projects[i] = copyProject(project)
+	}
+	return project, nil
+}
+
+// FetchById - Get project entry using project Id
+func (m memoryProjectStore) FetchById(projectId int) (Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.projectIndex(projectId)
+	if i < 0 {
+		return Project{}, db.ErrNoMoreRows
+	}
+	return copyProject(m.projects[i]), nil
+}
+
+func (m memoryProjectStore) filter(match func(Project) bool) ([]Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var projects []Project
+	for _, project := range m.projects {
+		if match(project) {
+			projects = append(projects, copyProject(project))
+		}
+	}
+	return projects, nil
+}
+
+// FetchActive - Get project entries that are active
+func (m memoryProjectStore) FetchActive() ([]Project, error) {
+	unset := time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return m.filter(func(project Project) bool {
+		return project.Status >= 5 && project.NextActivityDate.After(unset)
+	})
+}
+
+// FetchCurrent - Get project entries reaching the next activity date
+func (m memoryProjectStore) FetchCurrent() ([]Project, error) {
+	now := time.Now()
+	return m.filter(func(project Project) bool {
+		return (project.Status == 5 || project.Status == 6) && project.NextActivityDate.After(now)
+	})
+}
+
+// FetchMilestonesDue - Get project entries in the milestone phase whose next milestone is at or before before
+func (m memoryProjectStore) FetchMilestonesDue(before time.Time) ([]Project, error) {
+	projects, err := m.filter(func(project Project) bool {
+		return project.Status == constants.ProjectMilestonePhase && !project.NextActivityDate.After(before)
+	})
+	sort.SliceStable(projects, func(i, j int) bool { return projects[i].NextActivityDate.Before(projects[j].NextActivityDate) })
+	return projects, err
+}
+
+// FetchCancellable - Get project entries that are ready to be cancelled
+func (m memoryProjectStore) FetchCancellable() ([]Project, error) {
+	return m.filter(func(project Project) bool {
+		return project.Status == 8
+	})
+}
+
+// FetchCompleted - Fetch projects that have been completed
+func (m memoryProjectStore) FetchCompleted() ([]Project, error) {
+	return m.filter(func(project Project) bool {
+		return project.Status == 3
+	})
+}
+
+// CountByStatus - Count project entries in each status
+func (m memoryProjectStore) CountByStatus() (map[constants.ProjectStatus]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := map[constants.ProjectStatus]int{}
+	for _, project := range m.projects {
+		counts[project.Status]++
+	}
+	return counts, nil
+}
+
+// memoryVoteStore - VoteStore kept in memory
+type memoryVoteStore struct {
+	*memoryDB
+}
+
+// Insert function
+func (m memoryVoteStore) Insert(vote Vote) (Vote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// votes.fk_project_id references project(id)
+	if m.projectIndex(vote.FkProjectId) < 0 {
+		return vote, errors.New("Could not insert record")
+	}
+	m.lastVoteId++
+	vote.VoteId = m.lastVoteId
+	m.votes = append(m.votes, copyVote(vote))
+	return vote, nil
+}
+
+func (m memoryVoteStore) filter(match func(Vote) bool) ([]Vote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var votes []Vote
+	for _, vote := range m.votes {
+		if match(vote) {
+			votes = append(votes, copyVote(vote))
+		}
+	}
+	return votes, nil
+}
+
+// voteType - read vote_type out of the stored jsonb parameters
+func voteType(vote Vote) (int, bool) {
+	value, ok := vote.VoteParameters["vote_type"].(float64)
+	return int(value), ok
+}
+
+// SearchVoteId - search by vote id
+func (m memoryVoteStore) SearchVoteId(voteId int) (Vote, error) {
+	votes, _ := m.filter(func(vote Vote) bool {
+		return vote.VoteId == voteId
+	})
+	if len(votes) == 0 {
+		return Vote{}, db.ErrNoMoreRows
+	}
+	return votes[0], nil
+}
+
+// SearchProjectId - search by project id
+func (m memoryVoteStore) SearchProjectId(projectId int) ([]Vote, error) {
+	return m.filter(func(vote Vote) bool {
+		return vote.FkProjectId == projectId
+	})
+}
+
+// SearchProjectIdVoteType - search by project id and vote type
+func (m memoryVoteStore) SearchProjectIdVoteType(projectId int, wantedType int) ([]Vote, error) {
+	return m.filter(func(vote Vote) bool {
+		currentType, ok := voteType(vote)
+		return vote.FkProjectId == projectId && ok && currentType == wantedType
+	})
+}
+
+// SearchVoteIdVoteType - search by vote id and vote type
+func (m memoryVoteStore) SearchVoteIdVoteType(voteId int, wantedType int) ([]Vote, error) {
+	return m.filter(func(vote Vote) bool {
+		currentType, ok := voteType(vote)
+		return vote.VoteId == voteId && ok && currentType == wantedType
+	})
+}
+
+// memoryCampShareStore - CampShareStore kept in memory
+type memoryCampShareStore struct {
+	*memoryDB
+}
+
+func (m memoryCampShareStore) csIndex(csId int) int {
+	for i, cs := range m.campShares {
+		if cs.CSId == csId {
+			return i
+		}
+	}
+	return -1
+}
+
+// Insert function
+func (m memoryCampShareStore) Insert(cs CampShares) (CampShares, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.csIndex(cs.CSId) >= 0 {
+		return cs, errors.New("Could not insert record")
+	}
+	m.campShares = append(m.campShares, copyCampShares(cs))
+	return cs, nil
+}
+
+// UpdateFields - Update entries in CS table
+func (m memoryCampShareStore) UpdateFields(cs CampShares) (CampShares, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := m.csIndex(cs.CSId); i >= 0 {
+		m.campShares[i] = copyCampShares(cs)
+	}
+	return cs, nil
+}
+
+func (m memoryCampShareStore) filter(match func(CampShares) bool) ([]CampShares, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var csList []CampShares
+	for _, cs := range m.campShares {
+		if match(cs) {
+			csList = append(csList, copyCampShares(cs))
+		}
+	}
+	return csList, nil
+}
+
+// GetHolderIds - Get list of CS Ids
+func (m memoryCampShareStore) GetHolderIds() ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := make(map[int]bool)
+	var csHoldersList []int
+	for _, cs := range m.campShares {
+		if !seen[cs.UserId] {
+			seen[cs.UserId] = true
+			csHoldersList = append(csHoldersList, cs.UserId)
+		}
+	}
+	sort.Ints(csHoldersList)
+	return csHoldersList, nil
+}
+
+// SearchCSId - search by cs id
+func (m memoryCampShareStore) SearchCSId(csId int) (CampShares, error) {
+	csList, _ := m.filter(func(cs CampShares) bool {
+		return cs.CSId == csId
+	})
+	if len(csList) == 0 {
+		return CampShares{}, db.ErrNoMoreRows
+	}
+	return csList[0], nil
+}
+
+// GetLatest - Get the latest CS transaction
+func (m memoryCampShareStore) GetLatest() (CampShares, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var latest CampShares
+	found := false
+	for _, cs := range m.campShares {
+		if !found || cs.CSId > latest.CSId {
+			latest = cs
+			found = true
+		}
+	}
+	if !found {
+		return latest, db.ErrNoMoreRows
+	}
+	return copyCampShares(latest), nil
+}
+
+// GetByUserId - Get list of CS transactions related to a user
+func (m memoryCampShareStore) GetByUserId(userId int) ([]CampShares, error) {
+	return m.filter(func(cs CampShares) bool {
+		return cs.UserId == userId
+	})
+}
+
+// GetByUserIdCsType - Get list of CS transactions related to a user and csType
+func (m memoryCampShareStore) GetByUserIdCsType(userId int, csType int) ([]CampShares, error) {
+	return m.filter(func(cs CampShares) bool {
+		return cs.UserId == userId && cs.CSType == csType
+	})
+}
+
+// GetByType - Get list of certain type of CS
+func (m memoryCampShareStore) GetByType(csType int) ([]CampShares, error) {
+	return m.filter(func(cs CampShares) bool {
+		return cs.CSType == csType
+	})
+}
+
+// memoryProjectActivityStore - ProjectActivityStore kept in memory
+type memoryProjectActivityStore struct {
+	*memoryDB
+}
+
+// Insert - Insert a new project activity into activity table
+func (m memoryProjectActivityStore) Insert(activity ProjectActivity) (ProjectActivity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastActivityId++
+	activity.Id = m.lastActivityId
+	m.projectActivities = append(m.projectActivities, activity)
+	return activity, nil
+}
+
+func (m memoryProjectActivityStore) filter(match func(ProjectActivity) bool) ([]ProjectActivity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var activities []ProjectActivity
+	for _, activity := range m.projectActivities {
+		if match(activity) {
+			activities = append(activities, activity)
+		}
+	}
+	return activities, nil
+}
+
+// SearchActivityID - Search project activity entries using activity Id
+func (m memoryProjectActivityStore) SearchActivityID(activityId int) (ProjectActivity, error) {
+	activities, _ := m.filter(func(activity ProjectActivity) bool {
+		return activity.Id == activityId
+	})
+	if len(activities) == 0 {
+		return ProjectActivity{}, db.ErrNoMoreRows
+	}
+	return activities[0], nil
+}
+
+// SearchProjectID - Search project activity entries using project Id
+func (m memoryProjectActivityStore) SearchProjectID(projectId int) ([]ProjectActivity, error) {
+	return m.filter(func(activity ProjectActivity) bool {
+		return activity.ProjectId == projectId
+	})
+}
+
+// SearchProjectIDTransType - Search project activity entries using project Id and transaction type
+func (m memoryProjectActivityStore) SearchProjectIDTransType(projectId int, transactionType string) ([]ProjectActivity, error) {
+	return m.filter(func(activity ProjectActivity) bool {
+		return activity.ProjectId == projectId && string(activity.Type) == transactionType
+	})
+}
+
+// PendingProject - Get project activity entries still pending that were created before createdBefore
+func (m memoryProjectActivityStore) PendingProject(createdBefore time.Time) ([]ProjectActivity, error) {
+	activities, err := m.filter(func(activity ProjectActivity) bool {
+		return activity.Status == constants.ActivityPending && !activity.CreatedAt.After(createdBefore)
+	})
+	sort.SliceStable(activities, func(i, j int) bool { return activities[i].CreatedAt.Before(activities[j].CreatedAt) })
+	return activities, err
+}
+
+// UpdateFields - Update project activity entry fields
+func (m memoryProjectActivityStore) UpdateFields(projectActivity ProjectActivity) (ProjectActivity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, activity := range m.projectActivities {
+		if activity.Id == projectActivity.Id {
+			m.projectActivities[i] = projectActivity
+			return projectActivity, nil
+		}
+	}
+	return ProjectActivity{}, nil
+}
+
+// memoryCSActivityStore - CSActivityStore kept in memory
+type memoryCSActivityStore struct {
+	*memoryDB
+}
+
+// Insert - Insert a new activity into activity table
+func (m memoryCSActivityStore) Insert(csActivity CSActivity) (CSActivity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastCSActivityId++
+	csActivity.Id = m.lastCSActivityId
+	m.csActivities = append(m.csActivities, csActivity)
+	return csActivity, nil
+}
+
+func (m memoryCSActivityStore) filter(match func(CSActivity) bool) ([]CSActivity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var csActivities []CSActivity
+	for _, csActivity := range m.csActivities {
+		if match(csActivity) {
+			csActivities = append(csActivities, csActivity)
+		}
+	}
+	return csActivities, nil
+}
+
+// SearchActivityID - Search CS activity entries using activity Id
+func (m memoryCSActivityStore) SearchActivityID(csActivityId int) (CSActivity, error) {
+	csActivities, _ := m.filter(func(csActivity CSActivity) bool {
+		return csActivity.Id == csActivityId
+	})
+	if len(csActivities) == 0 {
+		return CSActivity{}, db.ErrNoMoreRows
+	}
+	return csActivities[0], nil
+}
+
+// SearchCsID - Search CS activity entries using csId
+func (m memoryCSActivityStore) SearchCsID(csId int) ([]CSActivity, error) {
+	return m.filter(func(csActivity CSActivity) bool {
+		return csActivity.CsId == csId
+	})
+}
+
+// SearchCsIDTransType - Search CS activity entries using csId and transaction type
+func (m memoryCSActivityStore) SearchCsIDTransType(csId int, transactionType string) ([]CSActivity, error) {
+	return m.filter(func(csActivity CSActivity) bool {
+		return csActivity.CsId == csId && string(csActivity.Type) == transactionType
+	})
+}
+
+// Pending - Get CS activity entries still pending that were created before createdBefore
+func (m memoryCSActivityStore) Pending(createdBefore time.Time) ([]CSActivity, error) {
+	csActivities, err := m.filter(func(csActivity CSActivity) bool {
+		return csActivity.Status == constants.ActivityPending && !csActivity.CreatedAt.After(createdBefore)
+	})
+	sort.SliceStable(csActivities, func(i, j int) bool { return csActivities[i].CreatedAt.Before(csActivities[j].CreatedAt) })
+	return csActivities, err
+}
+
+// UpdateFields - Update CS activity entry fields
+func (m memoryCSActivityStore) UpdateFields(csActivity CSActivity) (CSActivity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, current := range m.csActivities {
+		if current.Id == csActivity.Id {
+			m.csActivities[i] = csActivity
+			break
+		}
+	}
+	return csActivity, nil
+}
+
+// memoryOutboxStore - OutboxStore kept in memory
+type memoryOutboxStore struct {
+	*memoryDB
+}
+
+func copyOutboxEvent(event OutboxEvent) OutboxEvent {
+	event.Payload = jsonbCopy(event.Payload)
+	return event
+}
+
+// Insert - add a pending event to the outbox
+func (m memoryOutboxStore) Insert(event OutboxEvent) (OutboxEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastOutboxId++
+	event.Id = m.lastOutboxId
+	event = copyOutboxEvent(event)
+	m.outbox = append(m.outbox, event)
+	return copyOutboxEvent(event), nil
+}
+
+// FetchById - get an outbox event by Id
+func (m memoryOutboxStore) FetchById(eventId int) (OutboxEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, event := range m.outbox {
+		if event.Id == eventId {
+			return copyOutboxEvent(event), nil
+		}
+	}
+	return OutboxEvent{}, db.ErrNoMoreRows
+}
+
+// Due - pending events ready for delivery, only the oldest pending event of each ordering key
+func (m memoryOutboxStore) Due(now time.Time, limit int) ([]OutboxEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var events []OutboxEvent
+	blocked := map[string]bool{}
+	for _, event := range m.outbox {
+		if event.Status != constants.OutboxPending || blocked[event.OrderingKey] {
+			continue
+		}
+		blocked[event.OrderingKey] = true
+		if !event.NextAttemptAt.After(now) && len(events) < limit {
+			events = append(events, copyOutboxEvent(event))
+		}
+	}
+	return events, nil
+}
+
+// DeadLettered - events that ran out of delivery attempts
+func (m memoryOutboxStore) DeadLettered() ([]OutboxEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var events []OutboxEvent
+	for _, event := range m.outbox {
+		if event.Status == constants.OutboxDeadLetter {
+			events = append(events, copyOutboxEvent(event))
+		}
+	}
+	return events, nil
+}
+
+// UpdateFields - update the delivery state of an outbox event
+func (m memoryOutboxStore) UpdateFields(event OutboxEvent) (OutboxEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, current := range m.outbox {
+		if current.Id == event.Id {
+			current.Status = event.Status
+			current.Attempts = event.Attempts
+			current.NextAttemptAt = event.NextAttemptAt
+			current.LastError = event.LastError
+			current.ModifiedAt = event.ModifiedAt
+			m.outbox[i] = current
+			break
+		}
+	}
+	return event, nil
+}
+
+// memoryIdempotencyStore - IdempotencyStore kept in memory
+type memoryIdempotencyStore struct {
+	*memoryDB
+}
+
+func (m memoryIdempotencyStore) index(key string) int {
+	for i, record := range m.idempotency {
+		if record.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Insert - claim an idempotency key
+func (m memoryIdempotencyStore) Insert(record IdempotencyRecord) (IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.index(record.Key) >= 0 {
+		return record, errors.New("Idempotency key already recorded")
+	}
+	m.idempotency = append(m.idempotency, record)
+	return record, nil
+}
+
+// FetchByKey - get the record of an idempotency key
+func (m memoryIdempotencyStore) FetchByKey(key string) (IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := m.index(key); i >= 0 {
+		return m.idempotency[i], nil
+	}
+	return IdempotencyRecord{}, db.ErrNoMoreRows
+}
+
+// Reclaim - claim a recorded key again when it expired or its request stopped answering
+func (m memoryIdempotencyStore) Reclaim(record IdempotencyRecord, createdBefore time.Time, leasedBefore time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.index(record.Key)
+	if i < 0 {
+		return false, nil
+	}
+	existing := m.idempotency[i]
+	expired := existing.CreatedAt.Before(createdBefore)
+	abandoned := existing.StatusCode == 0 && existing.Fingerprint == record.Fingerprint && existing.ModifiedAt.Before(leasedBefore)
+	if !expired && !abandoned {
+		return false, nil
+	}
+	record.StatusCode = 0
+	record.ResponseBody = ""
+	m.idempotency[i] = record
+	return true, nil
+}
+
+// claimed - index of the claim of record, -1 once the key was released or taken over
+func (m memoryIdempotencyStore) claimed(record IdempotencyRecord) int {
+	i := m.index(record.Key)
+	if i < 0 || m.idempotency[i].ClaimToken != record.ClaimToken {
+		return -1
+	}
+	return i
+}
+
+// Renew - extend the lease of the claim of record while its request is still being processed
+func (m memoryIdempotencyStore) Renew(record IdempotencyRecord) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.claimed(record)
+	if i < 0 || m.idempotency[i].StatusCode != 0 {
+		return false, nil
+	}
+	m.idempotency[i].ModifiedAt = record.ModifiedAt
+	return true, nil
+}
+
+// UpdateFields - store the response of the request that claimed the key
+func (m memoryIdempotencyStore) UpdateFields(record IdempotencyRecord) (IdempotencyRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.claimed(record)
+	if i < 0 {
+		return record, false, nil
+	}
+	m.idempotency[i].StatusCode = record.StatusCode
+	m.idempotency[i].ResponseBody = record.ResponseBody
+	m.idempotency[i].ModifiedAt = record.ModifiedAt
+	return record, true, nil
+}
+
+// Delete - release the key of the request that claimed it
+func (m memoryIdempotencyStore) Delete(record IdempotencyRecord) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.claimed(record)
+	if i < 0 {
+		return false, nil
+	}
+	m.idempotency = append(m.idempotency[:i:i], m.idempotency[i+1:]...)
+	return true, nil
+}
+
+// DeleteBefore - forget the keys recorded before createdAt
+func (m memoryIdempotencyStore) DeleteBefore(createdAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var kept []IdempotencyRecord
+	for _, record := range m.idempotency {
+		if !record.CreatedAt.Before(createdAt) {
+			kept = append(kept, record)
+		}
+	}
+	m.idempotency = kept
+	return nil
+}
+
+// memoryCallbackStore - CallbackStore kept in memory
+type memoryCallbackStore struct {
+	*memoryDB
+}
+
+// Insert - record a received postback. Only one postback per transaction uuid and status can be applied
+func (m memoryCallbackStore) Insert(record CallbackRecord) (CallbackRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if record.Outcome == constants.CallbackApplied {
+		for _, existing := range m.callbacks {
+			if existing.Outcome == constants.CallbackApplied && existing.UUID == record.UUID && existing.Status == record.Status {
+				return record, errors.New("Could not insert record")
+			}
+		}
+	}
+	m.lastCallbackId++
+	record.Id = m.lastCallbackId
+	m.callbacks = append(m.callbacks, record)
+	return record, nil
+}
+
+// SearchUUID - postbacks received for a transaction, oldest first
+func (m memoryCallbackStore) SearchUUID(uuid string) ([]CallbackRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var records []CallbackRecord
+	for _, record := range m.callbacks {
+		if record.UUID == uuid {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// memoryCredentialStore - CredentialStore kept in memory
+type memoryCredentialStore struct {
+	*memoryDB
+}
+
+func copyCredential(credential Credential) Credential {
+	credential.RouteGroups = stringArrayCopy(credential.RouteGroups)
+	return credential
+}
+
+// Insert - add a credential, active names and token hashes are unique
+func (m memoryCredentialStore) Insert(credential Credential) (Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.credentials {
+		if existing.TokenHash == credential.TokenHash || (existing.Name == credential.Name && !existing.RevokedAt.Valid) {
+			return credential, errors.New("Could not insert record")
+		}
+	}
+	m.lastCredentialId++
+	credential.Id = m.lastCredentialId
+	m.credentials = append(m.credentials, copyCredential(credential))
+	return copyCredential(credential), nil
+}
+
+// FetchById - get a credential by Id
+func (m memoryCredentialStore) FetchById(credentialId int) (Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, credential := range m.credentials {
+		if credential.Id == credentialId {
+			return copyCredential(credential), nil
+		}
+	}
+	return Credential{}, db.ErrNoMoreRows
+}
+
+// FetchActiveByTokenHash - get the credential that has not been revoked for a token hash
+func (m memoryCredentialStore) FetchActiveByTokenHash(tokenHash string) (Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, credential := range m.credentials {
+		if credential.TokenHash == tokenHash && !credential.RevokedAt.Valid {
+			return copyCredential(credential), nil
+		}
+	}
+	return Credential{}, db.ErrNoMoreRows
+}
+
+// FetchAll - every credential, including revoked ones
+func (m memoryCredentialStore) FetchAll() ([]Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var credentials []Credential
+	for _, credential := range m.credentials {
+		credentials = append(credentials, copyCredential(credential))
+	}
+	return credentials, nil
+}
+
+// UpdateFields - update the revocation of a credential
+func (m memoryCredentialStore) UpdateFields(credential Credential) (Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, current := range m.credentials {
+		if current.Id == credential.Id {
+			m.credentials[i].RevokedAt = credential.RevokedAt
+			break
+		}
+	}
+	return credential, nil
+}
+
+// memoryLeaseStore - LeaseStore kept in memory. Leases are not part of the snapshot of a unit of work
+type memoryLeaseStore struct {
+	*memoryDB
+}
+
+// Acquire - take or renew the lease for holder unless another holder has it until after now
+func (m memoryLeaseStore) Acquire(name string, holder string, now time.Time, ttl time.Duration) (Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now = now.UTC()
+	if m.leases == nil {
+		m.leases = map[string]Lease{}
+	}
+	lease, held := m.leases[name]
+	switch {
+	case held && lease.Holder == holder:
+		lease.ExpiresAt = now.Add(ttl)
+	case !held || !lease.ExpiresAt.After(now):
+		lease = Lease{Name: name, Holder: holder, AcquiredAt: now, ExpiresAt: now.Add(ttl)}
+	default:
+		return lease, nil
+	}
+	m.leases[name] = lease
+	return lease, nil
+}
+
+// Release - remove the lease of holder
+func (m memoryLeaseStore) Release(name string, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if lease, held := m.leases[name]; held && lease.Holder == holder {
+		delete(m.leases, name)
+	}
+	return nil
+}
+
+// memoryJobStore - JobStore kept in memory. Jobs are not part of the snapshot of a unit of work
+type memoryJobStore struct {
+	*memoryDB
+}
+
+// Register - add the job unless it exists, or take the schedule of job when it changed
+func (m memoryJobStore) Register(job Job) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.jobs == nil {
+		m.jobs = map[string]Job{}
+	}
+	current, exists := m.jobs[job.Name]
+	switch {
+	case !exists:
+		current = Job{
+			Name:       job.Name,
+			Schedule:   job.Schedule,
+			Paused:     job.Paused,
+			NextRunAt:  job.NextRunAt.UTC(),
+			CreatedAt:  job.CreatedAt.UTC(),
+			ModifiedAt: job.ModifiedAt.UTC(),
+		}
+	case current.Schedule != job.Schedule:
+		current.Schedule = job.Schedule
+		current.NextRunAt = job.NextRunAt.UTC()
+		current.ModifiedAt = job.ModifiedAt.UTC()
+	}
+	m.jobs[job.Name] = current
+	return current, nil
+}
+
+// FetchByName - get a job by name
+func (m memoryJobStore) FetchByName(name string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job, exists := m.jobs[name]; exists {
+		return job, nil
+	}
+	return Job{}, db.ErrNoMoreRows
+}
+
+// FetchAll - every job by name
+func (m memoryJobStore) FetchAll() ([]Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var jobs []Job
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs, nil
+}
+
+// Claim - hold the job for holder when it is due, not paused and not held
+func (m memoryJobStore) Claim(name string, holder string, now time.Time, ttl time.Duration) (Job, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now = now.UTC()
+	job, exists := m.jobs[name]
+	if !exists || job.Paused || job.NextRunAt.After(now) || job.Running(now) {
+		return Job{}, false, nil
+	}
+	job.LeaseHolder = sql.NullString{String: holder, Valid: true}
+	job.LeaseExpiresAt = sql.NullTime{Time: now.Add(ttl), Valid: true}
+	job.Attempts++
+	job.LastRunAt = sql.NullTime{Time: now, Valid: true}
+	job.ModifiedAt = now
+	m.jobs[name] = job
+	return job, true, nil
+}
+
+// Finish - record the outcome of the run and release the job, when holder still has it
+func (m memoryJobStore) Finish(job Job) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, exists := m.jobs[job.Name]
+	if !exists {
+		return Job{}, db.ErrNoMoreRows
+	}
+	if current.LeaseHolder.String != job.LeaseHolder.String {
+		return current, nil
+	}
+	current.NextRunAt = job.NextRunAt.UTC()
+	current.Attempts = job.Attempts
+	current.LastSuccessAt = job.LastSuccessAt
+	current.LastError = job.LastError
+	current.LastDurationMs = job.LastDurationMs
+	current.LastProcessed = job.LastProcessed
+	current.LeaseHolder = sql.NullString{}
+	current.LeaseExpiresAt = sql.NullTime{}
+	current.ModifiedAt = job.ModifiedAt.UTC()
+	m.jobs[job.Name] = current
+	return current, nil
+}
+
+// UpdateFields - update whether the job is paused and when it runs next
+func (m memoryJobStore) UpdateFields(job Job) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, exists := m.jobs[job.Name]
+	if !exists {
+		return Job{}, db.ErrNoMoreRows
+	}
+	current.Paused = job.Paused
+	current.NextRunAt = job.NextRunAt.UTC()
+	current.ModifiedAt = job.ModifiedAt.UTC()
+	m.jobs[job.Name] = current
+	return current, nil
+}
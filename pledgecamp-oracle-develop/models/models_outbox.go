@@ -0,0 +1,139 @@
+// ******** Connects to Postgresql DB to extract and modify data in DB tables
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+)
+
+const (
+	outboxTable = "outbox"
+)
+
+// OutboxEvent - backend event waiting to be delivered. Events sharing an OrderingKey are delivered in Id order
+type OutboxEvent struct {
+	Id            int                    `db:"outbox_id" json:"outbox_id"`
+	OrderingKey   string                 `db:"ordering_key" json:"ordering_key"`
+	URI           string                 `db:"uri" json:"uri"`
+	Payload       JSONMap                `db:"payload" json:"payload"`
+	Status        constants.OutboxStatus `db:"outbox_status" json:"outbox_status"`
+	Attempts      int                    `db:"attempts" json:"attempts"`
+	NextAttemptAt time.Time              `db:"next_attempt_at" json:"next_attempt_at"`
+	LastError     sql.NullString         `db:"last_error" json:"last_error"`
+	CreatedAt     time.Time              `db:"created_at" json:"created_at"`
+	ModifiedAt    time.Time              `db:"modified_at" json:"modified_at"`
+	// RequestId - request that recorded the event, sent along to the backend
+	RequestId string `db:"request_id" json:"request_id"`
+}
+
+// OutboxStore - persistence of outbox events
+type OutboxStore interface {
+	Insert(event OutboxEvent) (OutboxEvent, error)
+	FetchById(eventId int) (OutboxEvent, error)
+	Due(now time.Time, limit int) ([]OutboxEvent, error)
+	DeadLettered() ([]OutboxEvent, error)
+	UpdateFields(event OutboxEvent) (OutboxEvent, error)
+}
+
+// sqlOutboxStore - OutboxStore backed by the outbox table
+type sqlOutboxStore struct {
+	databaseSession
+}
+
+// Insert - add a pending event to the outbox
+func (p sqlOutboxStore) Insert(event OutboxEvent) (OutboxEvent, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	outboxCollection := dbConnection.Collection(outboxTable)
+	newId, err := outboxCollection.Insert(map[string]interface{}{
+		"ordering_key":    event.OrderingKey,
+		"uri":             event.URI,
+		"payload":         event.Payload,
+		"outbox_status":   event.Status,
+		"attempts":        event.Attempts,
+		"next_attempt_at": event.NextAttemptAt,
+		"last_error":      event.LastError,
+		"created_at":      event.CreatedAt,
+		"modified_at":     event.ModifiedAt,
+		"request_id":      event.RequestId,
+	})
+	if err != nil {
+		log.Println(err)
+		return event, errors.New("Could not insert record")
+	}
+	event.Id = int(newId.(int64))
+	return event, nil
+}
+
+// FetchById - get an outbox event by Id
+func (p sqlOutboxStore) FetchById(eventId int) (OutboxEvent, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	outboxCollection := dbConnection.Collection(outboxTable)
+	var event OutboxEvent
+	err := outboxCollection.Find("outbox_id", eventId).One(&event)
+	if err != nil {
+		log.Println(err)
+		return event, err
+	}
+	return event, nil
+}
+
+// Due - pending events ready for delivery. Only the oldest pending event of each ordering key is returned
+// so that later events wait until it is delivered or dead-lettered
+func (p sqlOutboxStore) Due(now time.Time, limit int) ([]OutboxEvent, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	res := dbConnection.SelectFrom(outboxTable).
+		Where(`outbox_status = ? AND next_attempt_at <= ? AND NOT EXISTS (
+			SELECT 1 FROM outbox earlier
+			WHERE earlier.ordering_key = outbox.ordering_key AND earlier.outbox_status = ? AND earlier.outbox_id < outbox.outbox_id)`,
+			constants.OutboxPending, now, constants.OutboxPending).
+		OrderBy("outbox_id").
+		Limit(limit)
+	var events []OutboxEvent
+	err := res.All(&events)
+	if err != nil {
+		log.Println(err)
+		return events, err
+	}
+	return events, nil
+}
+
+// DeadLettered - events that ran out of delivery attempts
+func (p sqlOutboxStore) DeadLettered() ([]OutboxEvent, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	res := dbConnection.SelectFrom(outboxTable).Where("outbox_status = ?", constants.OutboxDeadLetter).OrderBy("outbox_id")
+	var events []OutboxEvent
+	err := res.All(&events)
+	if err != nil {
+		log.Println(err)
+		return events, err
+	}
+	return events, nil
+}
+
+// UpdateFields - update the delivery state of an outbox event
+func (p sqlOutboxStore) UpdateFields(event OutboxEvent) (OutboxEvent, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	outboxCollection := dbConnection.Collection(outboxTable)
+	err := outboxCollection.Find("outbox_id", event.Id).Update(map[string]interface{}{
+		"outbox_status":   event.Status,
+		"attempts":        event.Attempts,
+		"next_attempt_at": event.NextAttemptAt,
+		"last_error":      event.LastError,
+		"modified_at":     event.ModifiedAt,
+	})
+	if err != nil {
+		log.Println(err)
+		return event, err
+	}
+	return event, nil
+}
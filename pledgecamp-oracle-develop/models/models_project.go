@@ -4,13 +4,12 @@ package models
 
 import (
 	"errors"
-	"fmt"
 	"log"
 	"time"
 
 	"github.com/lib/pq"
-	"github.com/pledgecamp/pledgecamp-oracle/connect"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"upper.io/db.v3"
 	"upper.io/db.v3/postgresql"
 )
 
@@ -42,19 +41,33 @@ type Project struct {
 	Status              constants.ProjectStatus `db:"status"`
 	NextActivityDate    time.Time               `db:"next_activity_date"`
 	ActivitiesCompleted pq.StringArray          `db:"activities_completed"`
-	ProjectParameters   map[string]interface{}  `db:"project_param"`
+	ProjectParameters   JSONMap                 `db:"project_param"`
 }
 
-var projects []Project
-var project Project
+// ProjectStore - persistence of project entries
+type ProjectStore interface {
+	Insert(project Project) (Project, error)
+	UpdateFields(project Project) (Project, error)
+	FetchById(projectId int) (Project, error)
+	FetchActive() ([]Project, error)
+	FetchCurrent() ([]Project, error)
+	FetchCancellable() ([]Project, error)
+	FetchMilestonesDue(before time.Time) ([]Project, error)
+	FetchCompleted() ([]Project, error)
+	CountByStatus() (map[constants.ProjectStatus]int, error)
+}
+
+// sqlProjectStore - ProjectStore backed by the project table
+type sqlProjectStore struct {
+	databaseSession
+}
 
-// ProjectInsert - insert new project entry
-func ProjectInsert(project Project) (Project, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// Insert - insert new project entry
+func (p sqlProjectStore) Insert(project Project) (Project, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 
 	projectCollection := dbConnection.Collection(projectTable)
-	log.Print("Inside project model ", project)
 	_, err := projectCollection.Insert(map[string]interface{}{
 		"id":                 project.Id,
 		"contract_address":   project.ContractAddress,
@@ -72,14 +85,13 @@ func ProjectInsert(project Project) (Project, error) {
 	return project, nil
 }
 
-// ProjectUpdateFields - Update entries in project table
-func ProjectUpdateFields(project Project) (Project, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// UpdateFields - Update entries in project table
+func (p sqlProjectStore) UpdateFields(project Project) (Project, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 
 	projectCollection := dbConnection.Collection(projectTable)
 	res := projectCollection.Find("id", project.Id)
-	log.Println("ProjectUpdateFields ", res)
 	err := res.Update(&project)
 	if err != nil {
 		log.Println(err)
@@ -88,17 +100,17 @@ func ProjectUpdateFields(project Project) (Project, error) {
 	return project, nil
 }
 
-// ProjectFetchById - Get project entry using project Id
-func ProjectFetchById(projectId int) (Project, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// FetchById - Get project entry using project Id
+func (p sqlProjectStore) FetchById(projectId int) (Project, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 
 	projectCollection := dbConnection.Collection(projectTable)
 
 	res := projectCollection.Find("id", projectId)
 
+	var project Project
 	err := res.One(&project)
-	fmt.Printf("ProjectFetchById %+v\n", project)
 	if err != nil {
 		log.Println(err)
 		return project, err
@@ -107,13 +119,13 @@ func ProjectFetchById(projectId int) (Project, error) {
 	return project, nil
 }
 
-// ProjectFetchActive - Get project entries that are active
-func ProjectFetchActive() ([]Project, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// FetchActive - Get project entries that are active
+func (p sqlProjectStore) FetchActive() ([]Project, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	projectCollection := dbConnection.SelectFrom(projectTable)
-	res := projectCollection.Where("status >= 5 AND next_activity_date > '0001-01-01'")
-	log.Print(res)
+	res := projectCollection.Where("status >= 5 AND next_activity_date > ?", time.Time{})
+	var projects []Project
 	err := res.All(&projects)
 	if err != nil {
 		log.Println(err)
@@ -122,13 +134,13 @@ func ProjectFetchActive() ([]Project, error) {
 	return projects, nil
 }
 
-// ProjectFetchCurrent - Get project entries reaching the next activity date
-func ProjectFetchCurrent() ([]Project, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// FetchCurrent - Get project entries reaching the next activity date
+func (p sqlProjectStore) FetchCurrent() ([]Project, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	projectCollection := dbConnection.SelectFrom(projectTable)
 	res := projectCollection.Where("(status = 5 OR status = 6) AND next_activity_date > ?", time.Now())
-	log.Print(res)
+	var projects []Project
 	err := res.All(&projects)
 	if err != nil {
 		log.Println(err)
@@ -137,13 +149,29 @@ func ProjectFetchCurrent() ([]Project, error) {
 	return projects, nil
 }
 
-// ProjectFetchCancellable - Get project entries that are ready to be cancelled
-func ProjectFetchCancellable() ([]Project, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// FetchMilestonesDue - Get project entries in the milestone phase whose next milestone is at or before before,
+// earliest first. Served by the project_milestone_idx index
+func (p sqlProjectStore) FetchMilestonesDue(before time.Time) ([]Project, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	projectCollection := dbConnection.SelectFrom(projectTable)
+	res := projectCollection.Where("status = ? AND next_activity_date <= ?", constants.ProjectMilestonePhase, before).OrderBy("next_activity_date")
+	var projects []Project
+	err := res.All(&projects)
+	if err != nil {
+		log.Println(err)
+		return projects, err
+	}
+	return projects, nil
+}
+
+// FetchCancellable - Get project entries that are ready to be cancelled
+func (p sqlProjectStore) FetchCancellable() ([]Project, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	projectCollection := dbConnection.SelectFrom(projectTable)
 	res := projectCollection.Where("status = 8")
-	log.Print(res)
+	var projects []Project
 	err := res.All(&projects)
 	if err != nil {
 		log.Println(err)
@@ -152,13 +180,13 @@ func ProjectFetchCancellable() ([]Project, error) {
 	return projects, nil
 }
 
-// ProjectFetchCompleted - Fetch projects that have been completed
-func ProjectFetchCompleted() ([]Project, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// FetchCompleted - Fetch projects that have been completed
+func (p sqlProjectStore) FetchCompleted() ([]Project, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	projectCollection := dbConnection.SelectFrom(projectTable)
 	res := projectCollection.Where("status = 3")
-	log.Print(res)
+	var projects []Project
 	err := res.All(&projects)
 	if err != nil {
 		log.Println(err)
@@ -166,3 +194,23 @@ func ProjectFetchCompleted() ([]Project, error) {
 	}
 	return projects, nil
 }
+
+// CountByStatus - Count project entries in each status
+func (p sqlProjectStore) CountByStatus() (map[constants.ProjectStatus]int, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
+	var rows []struct {
+		Status constants.ProjectStatus `db:"status"`
+		Count  int                     `db:"project_count"`
+	}
+	err := dbConnection.Select("status", db.Raw("count(*) AS project_count")).From(projectTable).GroupBy("status").All(&rows)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	counts := map[constants.ProjectStatus]int{}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/lib/pq"
-	"github.com/pledgecamp/pledgecamp-oracle/connect"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
 )
 
@@ -28,13 +27,26 @@ type ProjectActivity struct {
 	Type            constants.ActivityReference `db:"activity_type" json:"activity_type"`
 }
 
-var activities []ProjectActivity
-var activity ProjectActivity
+// ProjectActivityStore - persistence of project activity entries
+type ProjectActivityStore interface {
+	Insert(activity ProjectActivity) (ProjectActivity, error)
+	SearchActivityID(activityId int) (ProjectActivity, error)
+	SearchProjectID(projectId int) ([]ProjectActivity, error)
+	SearchProjectIDTransType(projectId int, transactionType string) ([]ProjectActivity, error)
+	// PendingProject - activities still pending that were created before createdBefore, oldest first
+	PendingProject(createdBefore time.Time) ([]ProjectActivity, error)
+	UpdateFields(projectActivity ProjectActivity) (ProjectActivity, error)
+}
+
+// sqlProjectActivityStore - ProjectActivityStore backed by the project_activity table
+type sqlProjectActivityStore struct {
+	databaseSession
+}
 
-// ProjectActivityInsert - Insert a new project activity into activity table
-func ProjectActivityInsert(activity ProjectActivity) (ProjectActivity, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// Insert - Insert a new project activity into activity table
+func (p sqlProjectActivityStore) Insert(activity ProjectActivity) (ProjectActivity, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	activityCollection := dbConnection.Collection(activityTable)
 	newId, err := activityCollection.Insert(map[string]interface{}{
 		"fk_project_id":    activity.ProjectId,
@@ -53,13 +65,14 @@ func ProjectActivityInsert(activity ProjectActivity) (ProjectActivity, error) {
 	return activity, nil
 }
 
-// ProjectActivitySearchActivityID - Search project activity entries using activity Id
-func ProjectActivitySearchActivityID(activityId int) (ProjectActivity, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// SearchActivityID - Search project activity entries using activity Id
+func (p sqlProjectActivityStore) SearchActivityID(activityId int) (ProjectActivity, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	activityCollection := dbConnection.Collection(activityTable)
 	res := activityCollection.Find("project_activity_id", activityId)
 	log.Println("ProjectActivitySearchActivityID ", res)
+	var activity ProjectActivity
 	err := res.One(&activity)
 	if err != nil {
 		log.Println(err)
@@ -68,13 +81,14 @@ func ProjectActivitySearchActivityID(activityId int) (ProjectActivity, error) {
 	return activity, nil
 }
 
-// ProjectActivitySearchProjectID - Search project activity entries using project Id
-func ProjectActivitySearchProjectID(projectId int) ([]ProjectActivity, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// SearchProjectID - Search project activity entries using project Id
+func (p sqlProjectActivityStore) SearchProjectID(projectId int) ([]ProjectActivity, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	activityCollection := dbConnection.SelectFrom(activityTable)
 	res := activityCollection.Where("fk_project_id = ?", projectId)
 	log.Println("ProjectActivitySearchProjectID ", res)
+	var activities []ProjectActivity
 	err := res.All(&activities)
 	if err != nil {
 		log.Println(err)
@@ -84,13 +98,14 @@ func ProjectActivitySearchProjectID(projectId int) ([]ProjectActivity, error) {
 	return activities, nil
 }
 
-// ProjectActivitySearchProjectIDTransType - Search project activity entries using project Id and transaction type
-func ProjectActivitySearchProjectIDTransType(projectId int, transactionType string) ([]ProjectActivity, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// SearchProjectIDTransType - Search project activity entries using project Id and transaction type
+func (p sqlProjectActivityStore) SearchProjectIDTransType(projectId int, transactionType string) ([]ProjectActivity, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	activityCollection := dbConnection.SelectFrom(activityTable)
 	res := activityCollection.Where("fk_project_id = ? AND activity_type = ?", projectId, transactionType)
 	log.Println("ProjectActivitySearchProjectID ", res)
+	var activities []ProjectActivity
 	err := res.All(&activities)
 	if err != nil {
 		log.Println(err)
@@ -100,13 +115,14 @@ func ProjectActivitySearchProjectIDTransType(projectId int, transactionType stri
 	return activities, nil
 }
 
-// ProjectActivityPendingProject - Get project activity entries which are still pending after 10 minutes
-func ProjectActivityPendingProject() ([]ProjectActivity, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// PendingProject - Get project activity entries still pending that were created before createdBefore
+func (p sqlProjectActivityStore) PendingProject(createdBefore time.Time) ([]ProjectActivity, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	activityCollection := dbConnection.SelectFrom(activityTable)
-	res := activityCollection.Where("activity_status = 0 AND created_at > (now() + interval '10 minutes')")
+	res := activityCollection.Where("activity_status = ? AND created_at <= ?", constants.ActivityPending, createdBefore).OrderBy("created_at")
 	log.Print("ProjectActivityPendingProject ", res)
+	var activities []ProjectActivity
 	err := res.All(&activities)
 	if err != nil {
 		log.Println("No activity was found")
@@ -115,10 +131,10 @@ func ProjectActivityPendingProject() ([]ProjectActivity, error) {
 	return activities, nil
 }
 
-// ProjectActivityUpdateFields - Update project activity entry fields
-func ProjectActivityUpdateFields(projectActivity ProjectActivity) (ProjectActivity, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// UpdateFields - Update project activity entry fields
+func (p sqlProjectActivityStore) UpdateFields(projectActivity ProjectActivity) (ProjectActivity, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	activityCollection := dbConnection.Collection(activityTable)
 	res := activityCollection.Find("project_activity_id", projectActivity.Id)
 	log.Println("Incoming activity", projectActivity)
@@ -136,19 +152,21 @@ func ProjectActivityUpdateFields(projectActivity ProjectActivity) (ProjectActivi
 		log.Println("No activity was found")
 		return projectActivity, err
 	}
+	var activity ProjectActivity
 	res.One(&activity)
 	return activity, nil
 }
 
-func SetProjectActivity(projectId int, activityType constants.ActivityReference) (ProjectActivity, error) {
+// SetProjectActivity - Set new project activity
+func SetProjectActivity(activities ProjectActivityStore, projectId int, activityType constants.ActivityReference) (ProjectActivity, error) {
 	var projectActivity ProjectActivity
 	projectActivity.ProjectId = projectId
 	projectActivity.CreatedAt = time.Now()
 	projectActivity.ModifiedAt = time.Now()
 	projectActivity.Type = activityType
-	projectActivity, err := ProjectActivityInsert(projectActivity)
+	projectActivity, err := activities.Insert(projectActivity)
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
 		return projectActivity, err
 	}
 	return projectActivity, nil
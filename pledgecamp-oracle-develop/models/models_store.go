@@ -0,0 +1,146 @@
+package models
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/connect"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+// Store groups the repositories used by the Oracle so they can be injected together
+type Store struct {
+	Projects          ProjectStore
+	Votes             VoteStore
+	CampShares        CampShareStore
+	ProjectActivities ProjectActivityStore
+	CSActivities      CSActivityStore
+	Outbox            OutboxStore
+	Idempotency       IdempotencyStore
+	Callbacks         CallbackStore
+	Credentials       CredentialStore
+	Leases            LeaseStore
+	Jobs              JobStore
+
+	atomic func(work UnitOfWork) error
+}
+
+// UnitOfWork - group of store changes that must be applied together
+type UnitOfWork func(tx Store) error
+
+// Atomic - apply a unit of work so that all of its changes commit or none of them do.
+// Returning an error from the unit of work rolls the changes back.
+// Calling Atomic on the tx Store runs the work inside the surrounding transaction.
+// A panic inside the unit of work is returned as an internal error so the changes are rolled back.
+func (s Store) Atomic(work UnitOfWork) error {
+	guarded := func(tx Store) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = errs.New(errs.Internal, "Unit of work failed: %v", r)
+			}
+		}()
+		return work(tx)
+	}
+	if s.atomic == nil {
+		return guarded(s)
+	}
+	return s.atomic(guarded)
+}
+
+// sqlSession - query builder shared by the pooled session and transactions
+type sqlSession interface {
+	db.Database
+	sqlbuilder.SQLBuilder
+}
+
+// databaseSession - shared pooled session, or a transaction on it, used by the SQL stores. driver is the
+// connect driver of the database, for the few queries Postgres and SQLite write differently
+type databaseSession struct {
+	database     sqlbuilder.Database
+	tx           sqlbuilder.Tx
+	driver       string
+	queryTimeout time.Duration
+}
+
+// session - session bound to a context with the query deadline
+func (p databaseSession) session() (sqlSession, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.queryTimeout)
+	if p.tx != nil {
+		return p.tx.WithContext(ctx), cancel
+	}
+	return p.database.WithContext(ctx), cancel
+}
+
+// atomic - run the unit of work in a transaction, or in the current one when already inside it
+func (p databaseSession) atomic(work UnitOfWork) error {
+	if p.tx != nil {
+		return work(p.store())
+	}
+	return p.database.Tx(context.Background(), func(tx sqlbuilder.Tx) error {
+		txSession := databaseSession{database: p.database, tx: tx, driver: p.driver, queryTimeout: p.queryTimeout}
+		return work(txSession.store())
+	})
+}
+
+// jsonField - SQL of the text of a top level field of a JSON column
+func (p databaseSession) jsonField(column string, field string) string {
+	if p.driver == connect.DriverSQLite {
+		return "json_extract(" + column + ", '$." + field + "')"
+	}
+	return column + "->>'" + field + "'"
+}
+
+func (p databaseSession) store() Store {
+	return Store{
+		Projects:          sqlProjectStore{p},
+		Votes:             sqlVoteStore{p},
+		CampShares:        sqlCampShareStore{p},
+		ProjectActivities: sqlProjectActivityStore{p},
+		CSActivities:      sqlCSActivityStore{p},
+		Outbox:            sqlOutboxStore{p},
+		Idempotency:       sqlIdempotencyStore{p},
+		Callbacks:         sqlCallbackStore{p},
+		Credentials:       sqlCredentialStore{p},
+		Leases:            sqlLeaseStore{p},
+		Jobs:              sqlJobStore{p},
+		atomic:            p.atomic,
+	}
+}
+
+// NewSQLStore - Store backed by the tables of the shared pool, on a database of the connect driver
+func NewSQLStore(database sqlbuilder.Database, driver string, queryTimeout time.Duration) Store {
+	return databaseSession{database: database, driver: driver, queryTimeout: queryTimeout}.store()
+}
+
+// JSONMap - JSON object column, jsonb in Postgres and text in SQLite
+type JSONMap map[string]interface{}
+
+// Value - the object as JSON text, NULL when it is nil
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(map[string]interface{}(m))
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// Scan - decode the JSON text of the column, which Postgres returns as bytes and SQLite as a string
+func (m *JSONMap) Scan(src interface{}) error {
+	*m = nil
+	switch value := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(value, m)
+	case string:
+		return json.Unmarshal([]byte(value), m)
+	}
+	return errs.New(errs.Internal, "Cannot scan %T into a JSON object", src)
+}
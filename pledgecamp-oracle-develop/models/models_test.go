@@ -4,12 +4,14 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/lib/pq"
+	"github.com/pledgecamp/pledgecamp-oracle/config"
 	"github.com/pledgecamp/pledgecamp-oracle/connect"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
 )
@@ -22,22 +24,42 @@ var testCancellableProject int
 var testCompletedProject int
 var testCSActivityId int
 
+var testStore Store
+var testDatabase connect.Config
+
 func init() {
 	err := godotenv.Load("../.env")
 	if err != nil {
 		log.Fatal("Models Test - No .env file found")
 	}
 
-	env := connect.LookupEnvOrExit("ENV_MODE")
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	if strings.ToLower(env) != "test" {
+	if strings.ToLower(cfg.App.EnvMode) != "test" {
 		log.Fatal("Please set ENV_MODE to 'test' in .env file, change all URLs to 'http' and update 'DB_NAME' in .env file & rerun DB migrations for testing DB.")
 	}
+
+	testDatabase = cfg.Database
+	database, err := connect.Open(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	testStore = NewSQLStore(database, cfg.Database.Driver, cfg.Database.Pool.QueryTimeout)
+}
+
+// skipOnSQLite - skip a test of Postgres behaviour the SQLite driver does not share
+func skipOnSQLite(t *testing.T, reason string) {
+	if testDatabase.Driver == connect.DriverSQLite {
+		t.Skip("SQLite: " + reason)
+	}
 }
 
 func getCounter(tableName string) int {
 	var counter int
-	dbConnection := connect.Postgres()
+	dbConnection := connect.Database()
 	tableCollection := dbConnection.SelectFrom(tableName)
 	if tableName == projectTable {
 		var lastProject Project
@@ -89,7 +111,7 @@ func TestProjectInsert(t *testing.T) {
 		"funding_complete": false,
 		"release_percents": []int{50, 50},
 	}
-	_, err = ProjectInsert(testProject)
+	_, err = testStore.Projects.Insert(testProject)
 	if err != nil {
 		t.Error("Could not insert project")
 	} else {
@@ -117,7 +139,7 @@ func TestProjectInsert(t *testing.T) {
 		"funding_complete": false,
 		"release_percents": []int{50, 50},
 	}
-	_, err = ProjectInsert(testProject)
+	_, err = testStore.Projects.Insert(testProject)
 	if err != nil {
 		t.Error("Failed at project insert")
 	}
@@ -130,7 +152,7 @@ func TestProjectInsert(t *testing.T) {
 	testProject.CreatedAt = time.Now().Add(-24 * time.Hour)
 	testProject.CompletedAt = time.Now()
 	testProject.Status = constants.ProjectEnded
-	_, err = ProjectInsert(testProject)
+	_, err = testStore.Projects.Insert(testProject)
 	if err != nil {
 		t.Error("Failed at project insert")
 	}
@@ -143,7 +165,7 @@ func TestProjectInsert(t *testing.T) {
 	testProject.CreatedAt = time.Now().Add(-24 * time.Hour)
 	testProject.CompletedAt = time.Now()
 	testProject.Status = constants.ProjectReadyToCancel
-	_, err = ProjectInsert(testProject)
+	_, err = testStore.Projects.Insert(testProject)
 	if err != nil {
 		t.Error("Failed at project insert")
 	}
@@ -174,7 +196,7 @@ func TestProjectInsertFailure(t *testing.T) {
 		"funding_complete": false,
 		"release_percents": []int{50, 50},
 	}
-	_, err = ProjectInsert(testProject)
+	_, err = testStore.Projects.Insert(testProject)
 	if err == nil {
 		t.Error("Should have failed")
 	}
@@ -186,7 +208,7 @@ func TestProjectUpdateFields(t *testing.T) {
 	var testProject Project
 	testProject.Id = testProjectId
 	testProject.Status = constants.ProjectDeployed
-	_, err := ProjectFetchById(testProject.Id)
+	_, err := testStore.Projects.FetchById(testProject.Id)
 	testProject.ContractAddress = "abc93rjf93g490uj0ijf93gj0f329"
 	testProject.CreatedAt = time.Now()
 	testProject.CompletedAt = time.Now().Add(10 * time.Second)
@@ -203,7 +225,7 @@ func TestProjectUpdateFields(t *testing.T) {
 		"funding_complete": false,
 		"release_percents": []int{80, 20},
 	}
-	updatedProject, err := ProjectUpdateFields(testProject)
+	updatedProject, err := testStore.Projects.UpdateFields(testProject)
 	if err != nil {
 		t.Error("Could not update project status")
 	}
@@ -213,10 +235,11 @@ func TestProjectUpdateFields(t *testing.T) {
 
 func TestProjectUpdateFieldsFailure(t *testing.T) {
 	log.Println("********************************* TestProjectUpdateFieldsFailure() **************************************")
+	skipOnSQLite(t, "SQLite integers are 64 bit, the id does not overflow")
 	var testProject Project
 	testProject.Id = 98109810983
 	testProject.Status = 5
-	_, err := ProjectUpdateFields(testProject)
+	_, err := testStore.Projects.UpdateFields(testProject)
 	if err == nil {
 		t.Error("Should have failed")
 	}
@@ -224,7 +247,7 @@ func TestProjectUpdateFieldsFailure(t *testing.T) {
 }
 func TestProjectFetch(t *testing.T) {
 	log.Println("********************************* TestProjectFetch() **************************************")
-	testProjectResult, err := ProjectFetchById(testProjectId)
+	testProjectResult, err := testStore.Projects.FetchById(testProjectId)
 	log.Println(testProjectResult)
 	if err != nil {
 		t.Error("Could not get projects")
@@ -234,7 +257,7 @@ func TestProjectFetch(t *testing.T) {
 
 func TestProjectFetchFailure(t *testing.T) {
 	log.Println("********************************* TestProjectFetchFailure() **************************************")
-	testProjectSet, err := ProjectFetchById(873487)
+	testProjectSet, err := testStore.Projects.FetchById(873487)
 	log.Println(testProjectSet)
 	if err == nil {
 		t.Error("Should have failed")
@@ -244,7 +267,7 @@ func TestProjectFetchFailure(t *testing.T) {
 
 func TestProjectFetchActive(t *testing.T) {
 	log.Println("********************************* TestProjectFetchActive() **************************************")
-	testProjectSet, err := ProjectFetchActive()
+	testProjectSet, err := testStore.Projects.FetchActive()
 	log.Println(len(testProjectSet), "records returned")
 	if err != nil {
 		t.Error("Could not get active projects")
@@ -264,7 +287,7 @@ func TestProjectFetchActive(t *testing.T) {
 
 func TestProjectFetchCurrent(t *testing.T) {
 	log.Println("********************************* TestProjectFetchCurrent() **************************************")
-	testProjectSet, err := ProjectFetchCurrent()
+	testProjectSet, err := testStore.Projects.FetchCurrent()
 	log.Println(len(testProjectSet), "records returned")
 	if err != nil {
 		t.Error("Could not get current projects")
@@ -283,7 +306,7 @@ func TestProjectFetchCurrent(t *testing.T) {
 
 func TestProjectFetchCancellable(t *testing.T) {
 	log.Println("********************************* TestProjectFetchCancellable() **************************************")
-	testProjectSet, err := ProjectFetchCancellable()
+	testProjectSet, err := testStore.Projects.FetchCancellable()
 	log.Println(len(testProjectSet), "records returned")
 	if err != nil {
 		t.Error("Could not get cancellable projects")
@@ -295,7 +318,7 @@ func TestProjectFetchCancellable(t *testing.T) {
 
 func TestProjectFetchCompleted(t *testing.T) {
 	log.Println("********************************* TestProjectFetchCompleted() **************************************")
-	testProjectSet, err := ProjectFetchCompleted()
+	testProjectSet, err := testStore.Projects.FetchCompleted()
 	log.Println(len(testProjectSet), "records returned")
 	if err != nil {
 		t.Error("Could not get completed projects")
@@ -305,6 +328,25 @@ func TestProjectFetchCompleted(t *testing.T) {
 	log.Println("********************************* End TestProjectFetchCompleted() **************************************")
 }
 
+func TestProjectFetchMilestonesDue(t *testing.T) {
+	log.Println("********************************* TestProjectFetchMilestonesDue() **************************************")
+	now := time.Now()
+	testProjectSet, err := testStore.Projects.FetchMilestonesDue(now)
+	log.Println(len(testProjectSet), "records returned")
+	if err != nil {
+		t.Error("Could not get projects due for a milestone check")
+	}
+	for i, project := range testProjectSet {
+		if project.Status != constants.ProjectMilestonePhase || project.NextActivityDate.After(now) {
+			t.Errorf("The project extracted is not due for a milestone check: %v", project)
+		}
+		if i > 0 && project.NextActivityDate.Before(testProjectSet[i-1].NextActivityDate) {
+			t.Error("Projects were not ordered by their next milestone")
+		}
+	}
+	log.Println("********************************* End TestProjectFetchMilestonesDue() **************************************")
+}
+
 // Tests for models_actitity.go
 func TestProjectActivityInsert(t *testing.T) {
 	log.Println("********************************* TestProjectActivityInsert() **************************************")
@@ -316,7 +358,7 @@ func TestProjectActivityInsert(t *testing.T) {
 	testProjectActivity.Status = 0
 	testProjectActivity.Type = constants.ProjectCreate
 	log.Println(testProjectActivity)
-	activity, err := ProjectActivityInsert(testProjectActivity)
+	activity, err := testStore.ProjectActivities.Insert(testProjectActivity)
 	if err != nil {
 		t.Error("Could not insert activity")
 	}
@@ -327,6 +369,7 @@ func TestProjectActivityInsert(t *testing.T) {
 
 func TestProjectActivityInsertFailure(t *testing.T) {
 	log.Println("********************************* TestProjectActivityInsertFailure() **************************************")
+	skipOnSQLite(t, "SQLite integers are 64 bit, the id does not overflow")
 	var testProjectActivity ProjectActivity
 	testProjectActivity.ProjectId = 45756546676755
 	testProjectActivity.CreatedAt = time.Now()
@@ -335,7 +378,7 @@ func TestProjectActivityInsertFailure(t *testing.T) {
 	testProjectActivity.Status = 0
 	testProjectActivity.Type = constants.ProjectCreate
 	log.Println(testProjectActivity)
-	_, err := ProjectActivityInsert(testProjectActivity)
+	_, err := testStore.ProjectActivities.Insert(testProjectActivity)
 	if err == nil {
 		t.Error("Should have failed")
 	}
@@ -344,7 +387,7 @@ func TestProjectActivityInsertFailure(t *testing.T) {
 
 func TestProjectActivitySearchActvityID(t *testing.T) {
 	log.Println("********************************* TestProjectActivitySearchActvityID() **************************************")
-	testProjectActivity, err := ProjectActivitySearchActivityID(testActivityId)
+	testProjectActivity, err := testStore.ProjectActivities.SearchActivityID(testActivityId)
 	if err != nil {
 		t.Error("Could not get activity based on Activity Id")
 	}
@@ -354,7 +397,7 @@ func TestProjectActivitySearchActvityID(t *testing.T) {
 
 func TestProjectActivitySearchActvityIDFailure(t *testing.T) {
 	log.Println("********************************* TestProjectActivitySearchActvityIDFailure() **************************************")
-	_, err := ProjectActivitySearchActivityID(8997879)
+	_, err := testStore.ProjectActivities.SearchActivityID(8997879)
 	if err == nil {
 		t.Error("Should have failed")
 	}
@@ -363,7 +406,7 @@ func TestProjectActivitySearchActvityIDFailure(t *testing.T) {
 
 func TestProjectActivityGetByProjectID(t *testing.T) {
 	log.Println("********************************* TestProjectActivityGetByProjectID() **************************************")
-	testProjectActivity, err := ProjectActivitySearchProjectID(testProjectId)
+	testProjectActivity, err := testStore.ProjectActivities.SearchProjectID(testProjectId)
 	if err != nil {
 		t.Error("Could not get activity from project Id")
 	} else if len(testProjectActivity) == 0 {
@@ -375,7 +418,7 @@ func TestProjectActivityGetByProjectID(t *testing.T) {
 
 func TestProjectActivityGetByProjectIDTransType(t *testing.T) {
 	log.Println("********************************* TestProjectActivityGetByProjectIDTransType() **************************************")
-	testProjectActivity, err := ProjectActivitySearchProjectIDTransType(testProjectId, "PROJECT_CREATE")
+	testProjectActivity, err := testStore.ProjectActivities.SearchProjectIDTransType(testProjectId, "PROJECT_CREATE")
 	if err != nil {
 		t.Error("Could not get activity from project Id")
 	} else if len(testProjectActivity) == 0 {
@@ -387,7 +430,7 @@ func TestProjectActivityGetByProjectIDTransType(t *testing.T) {
 
 func TestProjectActivityGetPendingProject(t *testing.T) {
 	log.Println("********************************* TestProjectActivityGetPendingProject() **************************************")
-	testProjectActivities, err := ProjectActivityPendingProject()
+	testProjectActivities, err := testStore.ProjectActivities.PendingProject(time.Now())
 	if err != nil {
 		t.Error("Could not get activity from project Id")
 	} else if len(testProjectActivities) == 0 {
@@ -401,7 +444,7 @@ func TestActivityUpdateFields(t *testing.T) {
 	log.Println("********************************* TestActivityUpdateFields() **************************************")
 	var testProjectActivity ProjectActivity
 	testProjectActivity.ProjectId = testProjectId
-	fetchedActivity, _ := ProjectActivitySearchProjectID(testProjectId)
+	fetchedActivity, _ := testStore.ProjectActivities.SearchProjectID(testProjectId)
 	log.Println("Original Activity: ", fetchedActivity[0])
 	testProjectActivity.ProjectId = testProjectId + 7040
 	testProjectActivity.Id = fetchedActivity[0].Id
@@ -410,7 +453,7 @@ func TestActivityUpdateFields(t *testing.T) {
 	testProjectActivity.TransactionHash = sql.NullString{String: "0x80453bb689362b78a3f3da5ecc4e34c2384c1f60a511f9f0dfaabeb14c3cf46", Valid: true}
 	testProjectActivity.Status = 2
 	testProjectActivity.Type = constants.StakePLG
-	activity, _ := ProjectActivityUpdateFields(testProjectActivity)
+	activity, _ := testStore.ProjectActivities.UpdateFields(testProjectActivity)
 	log.Print("Returned Activities: ", activity)
 	log.Println("********************************* End TestActivityUpdateFields() **************************************")
 }
@@ -418,7 +461,7 @@ func TestActivityUpdateFields(t *testing.T) {
 func TestSetActivity(t *testing.T) {
 	log.Println("********************************* TestSetActivity() **************************************")
 	activityType := constants.CancelProject
-	activity, err := SetProjectActivity(testProjectId, activityType)
+	activity, err := SetProjectActivity(testStore.ProjectActivities, testProjectId, activityType)
 	log.Println("Activity Type Updated: ", activity)
 	if err != nil {
 		t.Error("Could not update Project Activity")
@@ -455,7 +498,7 @@ func TestVoteInsert(t *testing.T) {
 		"decryption_key": "0x8f4a0d1940bbb011db54926c65572b03fd379cfc3c2da3d5765043dd682dc353",
 		"vote_type":      1,
 	}
-	vote, err := VoteInsert(testVote)
+	vote, err := testStore.Votes.Insert(testVote)
 	if err != nil {
 		t.Error("Could not insert vote")
 	}
@@ -476,7 +519,7 @@ func TestVoteInsertFailure(t *testing.T) {
 		"decryption_key": "0x8f4a0d1940bbb011db54926c65572b03fd379cfc3c2da3d5765043dd682dc353",
 		"vote_type":      1,
 	}
-	_, err := VoteInsert(testVote)
+	_, err := testStore.Votes.Insert(testVote)
 	if err == nil {
 		t.Error("Should have failed")
 	}
@@ -485,7 +528,7 @@ func TestVoteInsertFailure(t *testing.T) {
 
 func TestVoteSearchVoteId(t *testing.T) {
 	log.Println("********************************* TestVoteSearchVoteId() **************************************")
-	testVote, err := VoteSearchVoteId(testVoteId)
+	testVote, err := testStore.Votes.SearchVoteId(testVoteId)
 	if err != nil {
 		t.Error("Could not get activity from project Id")
 	}
@@ -495,7 +538,7 @@ func TestVoteSearchVoteId(t *testing.T) {
 
 func TestVoteSearchProjectId(t *testing.T) {
 	log.Println("********************************* TestVoteSearchProjectId() **************************************")
-	testVote, err := VoteSearchProjectId(testProjectId)
+	testVote, err := testStore.Votes.SearchProjectId(testProjectId)
 	if err != nil {
 		t.Error("Could not get activity from project Id")
 	}
@@ -506,7 +549,7 @@ func TestVoteSearchProjectId(t *testing.T) {
 
 func TestVoteSearchProjectIdVoteType(t *testing.T) {
 	log.Println("********************************* TestVoteSearchProjectIdVoteType() **************************************")
-	testVote, err := VoteSearchProjectIdVoteType(testProjectId, 1)
+	testVote, err := testStore.Votes.SearchProjectIdVoteType(testProjectId, 1)
 	if err != nil {
 		t.Error("Could not get activity from project Id")
 	}
@@ -517,7 +560,7 @@ func TestVoteSearchProjectIdVoteType(t *testing.T) {
 
 func TestVoteSearchVoteIdVoteType(t *testing.T) {
 	log.Println("********************************* TestVoteSearchVoteIdVoteType() **************************************")
-	testVote, err := VoteSearchVoteIdVoteType(testVoteId, 1)
+	testVote, err := testStore.Votes.SearchVoteIdVoteType(testVoteId, 1)
 	if err != nil {
 		t.Error("Could not get activity from project Id")
 	}
@@ -538,7 +581,7 @@ func TestCSInsert(t *testing.T) {
 	testCS.CSParameters = map[string]interface{}{
 		"is_moderator": true,
 	}
-	cs, err := CSInsert(testCS)
+	cs, err := testStore.CampShares.Insert(testCS)
 	if err != nil {
 		t.Error("Could not insert CampShare entry")
 	}
@@ -555,7 +598,7 @@ func TestCSInsertFailure(t *testing.T) {
 	testCS.CSParameters = map[string]interface{}{
 		"is_moderator": true,
 	}
-	_, err := CSInsert(testCS)
+	_, err := testStore.CampShares.Insert(testCS)
 	if err == nil {
 		t.Error("Should have failed")
 	}
@@ -567,13 +610,13 @@ func TestCSUpdateFields(t *testing.T) {
 	var testCS CampShares
 	counter := getCounter(csTable)
 	testCS.CSId = counter
-	returnedCS, _ := CSSearchCSId(testCS.CSId)
+	returnedCS, _ := testStore.CampShares.SearchCSId(testCS.CSId)
 	log.Println("Original record: ", returnedCS)
 	testCS.CSTime = time.Now()
 	testCS.UserId = 888
 	testCS.Amount = 100
 	testCS.CSType = 1
-	cs, err := CSUpdateFields(testCS)
+	cs, err := testStore.CampShares.UpdateFields(testCS)
 	log.Println("Updated record: ", cs)
 	if err != nil {
 		t.Error("Could not update CampShare entry")
@@ -583,11 +626,12 @@ func TestCSUpdateFields(t *testing.T) {
 
 func TestCSUpdateFieldsFailure(t *testing.T) {
 	log.Println("********************************* TestCSUpdateFieldsFailure() **************************************")
+	skipOnSQLite(t, "SQLite integers are 64 bit, the id does not overflow")
 	var testCS CampShares
 	testCS.CSId = 79847983274
 	testCS.CSTime = time.Now()
 	testCS.UserId = 888
-	_, err := CSUpdateFields(testCS)
+	_, err := testStore.CampShares.UpdateFields(testCS)
 	if err == nil {
 		t.Error("Should have failed")
 	}
@@ -596,11 +640,11 @@ func TestCSUpdateFieldsFailure(t *testing.T) {
 
 func TestGetCSHolderIds(t *testing.T) {
 	log.Println("********************************* TestGetCSHolderIds() **************************************")
-	cs, err := GetCSHolderIds()
+	cs, err := testStore.CampShares.GetHolderIds()
 	log.Println("CS Holder Ids: ", cs)
 	if err != nil {
 		t.Error("Could not get CampShare entries")
-	} else if len(csList) == 0 {
+	} else if len(cs) == 0 {
 		t.Error("CS were not extracted properly")
 	}
 	log.Println("********************************* End TestGetCSHolderIds() **************************************")
@@ -609,7 +653,7 @@ func TestGetCSHolderIds(t *testing.T) {
 func TestCSSearchCSId(t *testing.T) {
 	log.Println("********************************* TestCSSearchCSId() **************************************")
 	counter := getCounter(csTable)
-	cs, err := CSSearchCSId(counter)
+	cs, err := testStore.CampShares.SearchCSId(counter)
 	log.Println("CS returned: ", counter, cs)
 	if err != nil {
 		t.Error("Could not get CampShare entries")
@@ -619,7 +663,7 @@ func TestCSSearchCSId(t *testing.T) {
 
 func TestGetLatestCSId(t *testing.T) {
 	log.Println("********************************* TestGetLatestCSId() **************************************")
-	cs, err := GetlatestCSId()
+	cs, err := testStore.CampShares.GetLatest()
 	log.Println("CS returned: ", cs)
 	if err != nil {
 		t.Error("Could not get CampShare entries")
@@ -629,7 +673,7 @@ func TestGetLatestCSId(t *testing.T) {
 
 func TestGetCSByUserId(t *testing.T) {
 	log.Println("********************************* TestGetCSByUserId() **************************************")
-	cs, err := GetCSByUserId(888)
+	cs, err := testStore.CampShares.GetByUserId(888)
 	log.Println(len(cs), "records returned")
 	if err != nil {
 		t.Error("Could not get CampShare entries")
@@ -639,7 +683,7 @@ func TestGetCSByUserId(t *testing.T) {
 
 func TestGetCSByUserIdCsType(t *testing.T) {
 	log.Println("********************************* TestGetCSByUserIdCsType() **************************************")
-	cs, err := GetCSByUserIdCsType(888, 1)
+	cs, err := testStore.CampShares.GetByUserIdCsType(888, 1)
 	log.Println(len(cs), "records returned")
 	if err != nil {
 		t.Error("Could not get CampShare entries")
@@ -649,7 +693,7 @@ func TestGetCSByUserIdCsType(t *testing.T) {
 
 func TestGetCSByType(t *testing.T) {
 	log.Println("********************************* TestGetCSByType() **************************************")
-	cs, err := GetCSByType(1)
+	cs, err := testStore.CampShares.GetByType(1)
 	log.Println(len(cs), "records returned")
 	if err != nil {
 		t.Error("Could not get CampShare entries")
@@ -667,7 +711,7 @@ func TestCSActivityInsert(t *testing.T) {
 	testCSactivity.TransactionHash = sql.NullString{String: "0x8051b3bb689362b78a3f3da5ecc4e34c2384c1f60a511f9f0dfaabeb14c3cf46", Valid: true}
 	testCSactivity.Status = 0
 	testCSactivity.Type = constants.StakePLG
-	activity, err := CSActivityInsert(testCSactivity)
+	activity, err := testStore.CSActivities.Insert(testCSactivity)
 	log.Println("CS Activity Inserted: ", activity)
 	if err != nil {
 		t.Error("Could not insert CS Activity")
@@ -678,6 +722,7 @@ func TestCSActivityInsert(t *testing.T) {
 
 func TestCSActivityInsertFailure(t *testing.T) {
 	log.Println("********************************* TestCSActivityInsertFailure() **************************************")
+	skipOnSQLite(t, "SQLite integers are 64 bit, the id does not overflow")
 	var testCSactivity CSActivity
 	counter := 2480328489820842
 	testCSactivity.CsId = counter
@@ -686,7 +731,7 @@ func TestCSActivityInsertFailure(t *testing.T) {
 	testCSactivity.TransactionHash = sql.NullString{String: "0x8051b3bb689362b78a3f3da5ecc4e34c2384c1f60a511f9f0dfaabeb14c3cf46", Valid: true}
 	testCSactivity.Status = 0
 	testCSactivity.Type = constants.StakePLG
-	activity, err := CSActivityInsert(testCSactivity)
+	activity, err := testStore.CSActivities.Insert(testCSactivity)
 	log.Println("CS Activity Inserted: ", activity)
 	if err == nil {
 		t.Error("Should have failed")
@@ -696,7 +741,7 @@ func TestCSActivityInsertFailure(t *testing.T) {
 
 func TestCSActivitySearchActivityID(t *testing.T) {
 	log.Println("********************************* TestCSActivitySearchActivityID() **************************************")
-	activity, err := CSActivitySearchActivityID(testCSActivityId)
+	activity, err := testStore.CSActivities.SearchActivityID(testCSActivityId)
 	log.Println("CS Activity Returned: ", activity)
 	if err != nil {
 		t.Error("Could not return CS Activity")
@@ -705,7 +750,7 @@ func TestCSActivitySearchActivityID(t *testing.T) {
 }
 func TestCSActivitySearchCsID(t *testing.T) {
 	log.Println("********************************* TestCSActivitySearchCsID() **************************************")
-	activity, err := CSActivitySearchCsID(testCSId)
+	activity, err := testStore.CSActivities.SearchCsID(testCSId)
 	log.Println("CS Activity Returned: ", activity)
 	if err != nil {
 		t.Error("Could not return CS Activity")
@@ -717,7 +762,7 @@ func TestCSActivitySearchCsID(t *testing.T) {
 
 func TestCSActivitySearchCsIDTransType(t *testing.T) {
 	log.Println("********************************* TestCSActivitySearchCsIDTransType() **************************************")
-	activity, err := CSActivitySearchCsIDTransType(testCSId, "STAKE_PLG")
+	activity, err := testStore.CSActivities.SearchCsIDTransType(testCSId, "STAKE_PLG")
 	log.Println("CS Activity Returned: ", activity)
 	if err != nil {
 		t.Error("Could not return CS Activity")
@@ -729,7 +774,7 @@ func TestCSActivitySearchCsIDTransType(t *testing.T) {
 
 func TestCSActivityPending(t *testing.T) {
 	log.Println("********************************* TestCSActivityPending() **************************************")
-	activity, err := CSActivityPending()
+	activity, err := testStore.CSActivities.Pending(time.Now())
 	if err != nil {
 		t.Error("Could not return CS Activity")
 	} else if len(activity) == 0 {
@@ -742,16 +787,20 @@ func TestCSActivityPending(t *testing.T) {
 func TestCSActivityUpdateFields(t *testing.T) {
 	log.Println("********************************* TestCSActivityUpdateFields() **************************************")
 	var testCSactivity CSActivity
-	testCSactivity.CsId = 100308
-	returnedActivity, _ := CSActivitySearchCsID(testCSactivity.CsId)
-	log.Println("Original CS Activity: ", returnedActivity[0])
-	testCSactivity.Id = returnedActivity[0].Id
+	// The last CS activity inserted by the tests, so the suite runs on a freshly migrated database
+	returnedActivity, err := testStore.CSActivities.SearchActivityID(getCounter(csActivityTable))
+	if err != nil {
+		t.Fatal("Could not find a CS activity to update")
+	}
+	log.Println("Original CS Activity: ", returnedActivity)
+	testCSactivity.CsId = returnedActivity.CsId
+	testCSactivity.Id = returnedActivity.Id
 	testCSactivity.CreatedAt = time.Now()
 	testCSactivity.ModifiedAt = time.Now()
 	testCSactivity.TransactionHash = sql.NullString{String: "0x9151b3bb689362b78a3f3da5ecc4e34c2384c1f60a511f9f0dfaabeb14c3cf46", Valid: true}
 	testCSactivity.Status = 0
 	testCSactivity.Type = constants.ProjectCreate
-	activity, err := CSActivityUpdateFields(testCSactivity)
+	activity, err := testStore.CSActivities.UpdateFields(testCSactivity)
 	log.Println("CS Activity Updated: ", activity)
 	if err != nil {
 		t.Error("Could not update CS Activity")
@@ -761,6 +810,7 @@ func TestCSActivityUpdateFields(t *testing.T) {
 
 func TestCSActivityUpdateFieldsFailure(t *testing.T) {
 	log.Println("********************************* TestCSActivityUpdateFieldsFailure() **************************************")
+	skipOnSQLite(t, "SQLite integers are 64 bit, the id does not overflow")
 	var testCSactivity CSActivity
 	testCSactivity.CsId = 92384023740237498
 	testCSactivity.CreatedAt = time.Now()
@@ -768,7 +818,7 @@ func TestCSActivityUpdateFieldsFailure(t *testing.T) {
 	testCSactivity.TransactionHash = sql.NullString{String: "0x9151b3bb689362b78a3f3da5ecc4e34c2384c1f60a511f9f0dfaabeb14c3cf46", Valid: true}
 	testCSactivity.Status = 0
 	testCSactivity.Type = constants.StakePLG
-	activity, err := CSActivityUpdateFields(testCSactivity)
+	activity, err := testStore.CSActivities.UpdateFields(testCSactivity)
 	log.Println("CS Activity Updated: ", activity)
 	if err == nil {
 		t.Error("Should have failed")
@@ -781,7 +831,7 @@ func TestSetCSActivity(t *testing.T) {
 	counter := getCounter(csTable)
 	testCsId := counter
 	activityType := constants.StakePLG
-	activity, err := SetCSActivity(testCsId, activityType)
+	activity, err := SetCSActivity(testStore.CSActivities, testCsId, activityType)
 	log.Println("CS Activity Type Added: ", activity)
 	if err != nil {
 		t.Error("Could not update CS Activity")
@@ -804,3 +854,140 @@ func TestCsState(t *testing.T) {
 	log.Println("CS State: ", csState)
 	log.Println("********************************* End TestCsState() **************************************")
 }
+
+// Tests for connect/migrations.go
+func TestMigrations(t *testing.T) {
+	log.Println("********************************* TestMigrations() **************************************")
+	// Every migration is applied, rolled back and applied again on a scratch database next to the test one
+	scratch := testDatabase
+	if scratch.Driver == connect.DriverSQLite {
+		scratch.File = fmt.Sprintf("%s.migrations_%d", testDatabase.File, time.Now().Unix())
+		defer os.Remove(scratch.File)
+	} else {
+		scratch.Name = fmt.Sprintf("%s_migrations_%d", testDatabase.Name, time.Now().Unix())
+		dbConnection := connect.Database()
+		if _, err := dbConnection.Exec("CREATE DATABASE " + pq.QuoteIdentifier(scratch.Name)); err != nil {
+			t.Fatal("Could not create scratch database: ", err)
+		}
+		defer dbConnection.Exec("DROP DATABASE IF EXISTS " + pq.QuoteIdentifier(scratch.Name))
+	}
+
+	report, err := connect.MigrationStatus(scratch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Version != 0 || report.Pending != len(report.Migrations) {
+		t.Errorf("Scratch database should have no migration applied, got %+v", report)
+	}
+	for _, migration := range report.Migrations {
+		if !migration.Down {
+			t.Errorf("Migration %d %s cannot be rolled back", migration.Version, migration.Name)
+		}
+	}
+
+	steps := []struct {
+		name    string
+		migrate func() error
+		version uint
+	}{
+		{"up", func() error { return connect.MigrateUp(scratch) }, report.Latest},
+		{"down", func() error { return connect.MigrateDown(scratch, len(report.Migrations)) }, 0},
+		{"up again", func() error { return connect.MigrateUp(scratch) }, report.Latest},
+	}
+	for _, step := range steps {
+		if err := step.migrate(); err != nil {
+			t.Fatalf("Migrating %s failed: %v", step.name, err)
+		}
+		status, err := connect.MigrationStatus(scratch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.Printf("Migrated %s: version %d, dirty %v", step.name, status.Version, status.Dirty)
+		if status.Version != step.version || status.Dirty {
+			t.Errorf("Migrating %s should leave version %d clean, got %d dirty %v", step.name, step.version, status.Version, status.Dirty)
+		}
+	}
+	log.Println("********************************* End TestMigrations() **************************************")
+}
+
+// Tests for models_lease.go
+func TestLeaseAcquire(t *testing.T) {
+	log.Println("********************************* TestLeaseAcquire() **************************************")
+	name := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	now := time.Now()
+	lease, err := testStore.Leases.Acquire(name, "oracle-1", now, time.Minute)
+	if err != nil || lease.Holder != "oracle-1" {
+		t.Fatalf("Expected oracle-1 to take the lease, got %+v %v", lease, err)
+	}
+	lease, err = testStore.Leases.Acquire(name, "oracle-2", now.Add(time.Second), time.Minute)
+	if err != nil || lease.Holder != "oracle-1" {
+		t.Errorf("Expected oracle-1 to keep the lease, got %+v %v", lease, err)
+	}
+	lease, err = testStore.Leases.Acquire(name, "oracle-1", now.Add(30*time.Second), time.Minute)
+	if err != nil || lease.Holder != "oracle-1" || lease.ExpiresAt.Sub(lease.AcquiredAt) < 90*time.Second-time.Millisecond {
+		t.Errorf("Expected oracle-1 to renew the lease, got %+v %v", lease, err)
+	}
+	lease, err = testStore.Leases.Acquire(name, "oracle-2", now.Add(2*time.Minute), time.Minute)
+	if err != nil || lease.Holder != "oracle-2" {
+		t.Errorf("Expected oracle-2 to take over the expired lease, got %+v %v", lease, err)
+	}
+	if err := testStore.Leases.Release(name, "oracle-1"); err != nil {
+		t.Error(err)
+	}
+	if err := testStore.Leases.Release(name, "oracle-2"); err != nil {
+		t.Error(err)
+	}
+	lease, err = testStore.Leases.Acquire(name, "oracle-1", now.Add(2*time.Minute), time.Minute)
+	if err != nil || lease.Holder != "oracle-1" {
+		t.Errorf("Expected oracle-1 to take the released lease, got %+v %v", lease, err)
+	}
+	testStore.Leases.Release(name, "oracle-1")
+	log.Println("********************************* End TestLeaseAcquire() **************************************")
+}
+
+// Tests for models_job.go
+func TestJobClaim(t *testing.T) {
+	log.Println("********************************* TestJobClaim() **************************************")
+	name := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	now := time.Now()
+	job, err := testStore.Jobs.Register(Job{Name: name, Schedule: "@hourly", NextRunAt: now, CreatedAt: now, ModifiedAt: now})
+	if err != nil || job.Schedule != "@hourly" || job.Paused {
+		t.Fatalf("Expected the job to be registered, got %+v %v", job, err)
+	}
+	job, err = testStore.Jobs.Register(Job{Name: name, Schedule: "@hourly", NextRunAt: now.Add(time.Hour), CreatedAt: now, ModifiedAt: now})
+	if err != nil || job.NextRunAt.Unix() != now.Unix() {
+		t.Errorf("Expected the registered job to be kept, got %+v %v", job, err)
+	}
+
+	job, claimed, err := testStore.Jobs.Claim(name, "oracle-1", now.Add(time.Second), time.Minute)
+	if err != nil || !claimed || job.LeaseHolder.String != "oracle-1" || job.Attempts != 1 {
+		t.Fatalf("Expected oracle-1 to claim the due job, got %+v %v", job, err)
+	}
+	if _, claimed, err := testStore.Jobs.Claim(name, "oracle-2", now.Add(2*time.Second), time.Minute); err != nil || claimed {
+		t.Errorf("Expected the running job not to be claimed again, got %v", err)
+	}
+
+	job.Attempts = 0
+	job.NextRunAt = now.Add(time.Hour)
+	job.LastSuccessAt = sql.NullTime{Time: now, Valid: true}
+	job.LastProcessed = 3
+	job.ModifiedAt = now
+	job, err = testStore.Jobs.Finish(job)
+	if err != nil || job.LeaseHolder.Valid || job.LastProcessed != 3 || job.Attempts != 0 {
+		t.Errorf("Expected the run to be recorded and the job released, got %+v %v", job, err)
+	}
+	if _, claimed, _ := testStore.Jobs.Claim(name, "oracle-2", now.Add(time.Minute), time.Minute); claimed {
+		t.Error("Expected a job that is not due not to be claimed")
+	}
+
+	job.Paused = true
+	job.NextRunAt = now
+	job, err = testStore.Jobs.UpdateFields(job)
+	if err != nil || !job.Paused {
+		t.Errorf("Expected the job to be paused, got %+v %v", job, err)
+	}
+	if _, claimed, _ := testStore.Jobs.Claim(name, "oracle-2", now.Add(time.Minute), time.Minute); claimed {
+		t.Error("Expected a paused job not to be claimed")
+	}
+	log.Println("********************************* End TestJobClaim() **************************************")
+}
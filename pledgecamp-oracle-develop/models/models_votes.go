@@ -7,7 +7,6 @@ import (
 	"log"
 	"time"
 
-	"github.com/pledgecamp/pledgecamp-oracle/connect"
 	"upper.io/db.v3"
 	"upper.io/db.v3/postgresql"
 )
@@ -33,21 +32,32 @@ type VoteParameters struct {
 
 // Project struct
 type Vote struct {
-	VoteId          int                    `db:"vote_id"`
-	ContractAddress string                 `db:"contract_address"`
-	VoteTime        time.Time              `db:"vote_created_at"`
-	UserId          int                    `db:"user_id"`
-	FkProjectId     int                    `db:"fk_project_id"`
-	VoteParameters  map[string]interface{} `db:"vote_param"`
+	VoteId          int       `db:"vote_id"`
+	ContractAddress string    `db:"contract_address"`
+	VoteTime        time.Time `db:"vote_created_at"`
+	UserId          int       `db:"user_id"`
+	FkProjectId     int       `db:"fk_project_id"`
+	VoteParameters  JSONMap   `db:"vote_param"`
 }
 
-var votes []Vote
-var vote Vote
+// VoteStore - persistence of vote entries
+type VoteStore interface {
+	Insert(vote Vote) (Vote, error)
+	SearchVoteId(voteId int) (Vote, error)
+	SearchProjectId(projectId int) ([]Vote, error)
+	SearchProjectIdVoteType(projectId int, voteType int) ([]Vote, error)
+	SearchVoteIdVoteType(voteId int, voteType int) ([]Vote, error)
+}
+
+// sqlVoteStore - VoteStore backed by the votes table
+type sqlVoteStore struct {
+	databaseSession
+}
 
-// VoteInsert function
-func VoteInsert(vote Vote) (Vote, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// Insert function
+func (p sqlVoteStore) Insert(vote Vote) (Vote, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	voteCollection := dbConnection.Collection(voteTable)
 	log.Print("Inside vote model")
 	returnedVotes, err := voteCollection.Insert(
@@ -67,12 +77,13 @@ func VoteInsert(vote Vote) (Vote, error) {
 	return vote, nil
 }
 
-// VoteSearchVoteId - search by vote id
-func VoteSearchVoteId(voteId int) (Vote, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// SearchVoteId - search by vote id
+func (p sqlVoteStore) SearchVoteId(voteId int) (Vote, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	voteCollection := dbConnection.Collection(voteTable)
 	res := voteCollection.Find("vote_id", voteId)
+	var vote Vote
 	err := res.One(&vote)
 	if err != nil {
 		log.Println("Could not find any votes")
@@ -81,12 +92,13 @@ func VoteSearchVoteId(voteId int) (Vote, error) {
 	return vote, nil
 }
 
-// VoteSearchProjectId - search by project id
-func VoteSearchProjectId(projectId int) ([]Vote, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// SearchProjectId - search by project id
+func (p sqlVoteStore) SearchProjectId(projectId int) ([]Vote, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	voteCollection := dbConnection.Collection(voteTable)
 	res := voteCollection.Find("fk_project_id", projectId)
+	var votes []Vote
 	err := res.All(&votes)
 	if err != nil {
 		log.Println("Could not find any votes")
@@ -95,12 +107,13 @@ func VoteSearchProjectId(projectId int) ([]Vote, error) {
 	return votes, nil
 }
 
-// VoteSearchProjectIdVoteType - search by project id and vote type
-func VoteSearchProjectIdVoteType(projectId int, voteType int) ([]Vote, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// SearchProjectIdVoteType - search by project id and vote type
+func (p sqlVoteStore) SearchProjectIdVoteType(projectId int, voteType int) ([]Vote, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	voteCollection := dbConnection.SelectFrom(voteTable)
-	res := voteCollection.Where(db.Raw(`fk_project_id = ? AND vote_param->>'vote_type' = ?`, projectId, voteType))
+	res := voteCollection.Where(db.Raw("fk_project_id = ? AND "+p.jsonField("vote_param", "vote_type")+" = ?", projectId, voteType))
+	var votes []Vote
 	err := res.All(&votes)
 	if err != nil {
 		log.Println("Could not find any votes")
@@ -109,12 +122,13 @@ func VoteSearchProjectIdVoteType(projectId int, voteType int) ([]Vote, error) {
 	return votes, nil
 }
 
-// VoteSearchProjectIdVoteType - search by vote id and vote type
-func VoteSearchVoteIdVoteType(voteId int, voteType int) ([]Vote, error) {
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
+// SearchVoteIdVoteType - search by vote id and vote type
+func (p sqlVoteStore) SearchVoteIdVoteType(voteId int, voteType int) ([]Vote, error) {
+	dbConnection, cancel := p.session()
+	defer cancel()
 	voteCollection := dbConnection.SelectFrom(voteTable)
-	res := voteCollection.Where(db.Raw(`vote_id = ? AND vote_param->>'vote_type' = ?`, voteId, voteType))
+	res := voteCollection.Where(db.Raw("vote_id = ? AND "+p.jsonField("vote_param", "vote_type")+" = ?", voteId, voteType))
+	var votes []Vote
 	err := res.All(&votes)
 	if err != nil {
 		log.Println("Could not find any votes")
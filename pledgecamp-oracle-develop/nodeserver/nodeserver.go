@@ -0,0 +1,151 @@
+package nodeserver
+
+import (
+	"context"
+
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/structs"
+)
+
+// Transaction - Nodeserver record of a submitted blockchain transaction
+type Transaction = structs.NodeServerModel
+
+// Callback - Oracle activity the transaction belongs to and where Nodeserver posts its result
+type Callback struct {
+	ActivityId int
+	URL        string
+}
+
+// DeployProjectRequest - deploy the contract of a new project
+type DeployProjectRequest struct {
+	ProjectId       int
+	Milestones      []int64
+	ReleasePercents []int64
+	Callback
+}
+
+// SetBackersRequest - register the backers of a project and the amounts they pledged
+type SetBackersRequest struct {
+	ProjectId       int
+	ContractAddress string
+	Beneficiaries   []int64
+	Amounts         []int64
+	FundingComplete bool
+	TotalAmount     int64
+	Callback
+}
+
+// SetProjectInfoRequest - set the listing fee of a deployed project
+type SetProjectInfoRequest struct {
+	ProjectId       int
+	ContractAddress string
+	ListingFee      int64
+	Callback
+}
+
+// SetModeratorsRequest - start the moderation phase of a project
+type SetModeratorsRequest struct {
+	ProjectId         int
+	ContractAddress   string
+	Moderators        []int64
+	ModerationEndTime int64
+	Callback
+}
+
+// MilestoneVoteRequest - backer vote on the current milestone of a project
+type MilestoneVoteRequest struct {
+	ProjectId       int
+	ContractAddress string
+	UserId          int
+	Vote            bool
+	Callback
+}
+
+// ModerationVoteRequest - encrypted moderator vote on cancelling a project
+type ModerationVoteRequest struct {
+	ProjectId       int
+	ContractAddress string
+	UserId          int
+	EncryptedVote   string
+	Callback
+}
+
+// CommitModerationVotesRequest - reveal the moderation votes of a project
+type CommitModerationVotesRequest struct {
+	ProjectId       int
+	ContractAddress string
+	Votes           []bool
+	DecryptionKeys  []string
+	UserIds         []int
+	Callback
+}
+
+// ProjectRequest - project transaction that only needs the project contract
+type ProjectRequest struct {
+	ProjectId       int
+	ContractAddress string
+	Callback
+}
+
+// ReleaseFundsRequest - release project funds to a user
+type ReleaseFundsRequest struct {
+	ProjectId       int
+	ContractAddress string
+	UserId          int
+	Callback
+}
+
+// StakeRequest - stake PLG for CampShares
+type StakeRequest struct {
+	UserId int
+	Amount int
+	Callback
+}
+
+// UserRequest - CampShares transaction that only needs the user
+type UserRequest struct {
+	UserId int
+	Callback
+}
+
+// PostInterestRequest - post interest to the CampShares holders
+type PostInterestRequest struct {
+	Amount int
+	Callback
+}
+
+// TransactionRequest - transaction submitted for an Oracle activity. Project and CS activities are numbered
+// apart, so the activity type tells them apart
+type TransactionRequest struct {
+	ActivityId int
+	Type       constants.ActivityReference
+}
+
+// Client - one method per Nodeserver operation. Transactions are asynchronous, Nodeserver posts the result
+// to the request Callback once the transaction is mined
+type Client interface {
+	DeployProject(ctx context.Context, request DeployProjectRequest) (Transaction, error)
+	SetBackers(ctx context.Context, request SetBackersRequest) (Transaction, error)
+	SetProjectInfo(ctx context.Context, request SetProjectInfoRequest) (Transaction, error)
+	SetModerators(ctx context.Context, request SetModeratorsRequest) (Transaction, error)
+	MilestoneVote(ctx context.Context, request MilestoneVoteRequest) (Transaction, error)
+	ModerationVote(ctx context.Context, request ModerationVoteRequest) (Transaction, error)
+	CommitModerationVotes(ctx context.Context, request CommitModerationVotesRequest) (Transaction, error)
+	CheckMilestone(ctx context.Context, request ProjectRequest) (Transaction, error)
+	CancelProject(ctx context.Context, request ProjectRequest) (Transaction, error)
+	FailedFundRecovery(ctx context.Context, request ProjectRequest) (Transaction, error)
+	WithdrawFunds(ctx context.Context, request ReleaseFundsRequest) (Transaction, error)
+	RequestRefund(ctx context.Context, request ReleaseFundsRequest) (Transaction, error)
+	StakePLG(ctx context.Context, request StakeRequest) (Transaction, error)
+	UnstakePLG(ctx context.Context, request UserRequest) (Transaction, error)
+	WithdrawInterest(ctx context.Context, request UserRequest) (Transaction, error)
+	ReinvestPLG(ctx context.Context, request UserRequest) (Transaction, error)
+	PostInterest(ctx context.Context, request PostInterestRequest) (Transaction, error)
+	GetGains(ctx context.Context, userId int) (int, error)
+	GetBalance(ctx context.Context, userId int) (int, error)
+	// FindTransaction - current Nodeserver record of the transaction submitted for an activity, a NotFound
+	// error when Nodeserver has none
+	FindTransaction(ctx context.Context, request TransactionRequest) (Transaction, error)
+	// Monitor - check that Nodeserver is up, without going through the circuit breaker or retrying
+	Monitor(ctx context.Context) error
+}
@@ -0,0 +1,140 @@
+package nodeserver
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+)
+
+// ErrBreakerOpen - returned without contacting Nodeserver while the circuit breaker is open
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+// BreakerState - whether requests are let through to Nodeserver
+type BreakerState int
+
+const (
+	// BreakerClosed - Nodeserver is healthy, every request is sent
+	BreakerClosed BreakerState = iota
+	// BreakerOpen - Nodeserver kept failing, requests fail fast until the cooldown has passed
+	BreakerOpen
+	// BreakerHalfOpen - cooldown has passed, a single probe request decides whether to close again
+	BreakerHalfOpen
+)
+
+var breakerStateNames = map[BreakerState]string{
+	BreakerClosed:   "closed",
+	BreakerOpen:     "open",
+	BreakerHalfOpen: "half_open",
+}
+
+func (s BreakerState) String() string {
+	return breakerStateNames[s]
+}
+
+// BreakerStats - circuit breaker state reported on /status
+type BreakerStats struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Threshold           int    `json:"threshold"`
+	Cooldown            string `json:"cooldown"`
+	OpenedAt            string `json:"opened_at,omitempty"`
+}
+
+// breaker - opens after threshold consecutive failures, a threshold of 0 disables it
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow - reserve a request, fails fast while the breaker is open or a half-open probe is in flight
+func (b *breaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		wait := b.cooldown - time.Since(b.openedAt)
+		if wait > 0 {
+			return errs.Wrap(errs.NodeServer, ErrBreakerOpen, "Nodeserver is unavailable, retry in %v", wait.Round(time.Millisecond))
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+	case BreakerHalfOpen:
+		if b.probing {
+			return errs.Wrap(errs.NodeServer, ErrBreakerOpen, "Nodeserver is unavailable, waiting on a probe request")
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record - outcome of a request let through by allow. Only failures pointing at an unhealthy Nodeserver count
+func (b *breaker) record(healthy bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if healthy {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// release - give back a request that ended without saying anything about Nodeserver health
+func (b *breaker) release() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := BreakerStats{
+		State:               b.state.String(),
+		ConsecutiveFailures: b.failures,
+		Threshold:           b.threshold,
+		Cooldown:            b.cooldown.String(),
+	}
+	if b.state != BreakerClosed {
+		stats.OpenedAt = b.openedAt.Format(time.RFC3339)
+	}
+	return stats
+}
+
+// Breaker - circuit breaker state of client, false when client has none
+func Breaker(client Client) (BreakerStats, bool) {
+	c, ok := client.(*httpClient)
+	if !ok || c.breaker.threshold <= 0 {
+		return BreakerStats{}, false
+	}
+	return c.breaker.stats(), true
+}
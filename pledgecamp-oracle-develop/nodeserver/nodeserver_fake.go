@@ -0,0 +1,181 @@
+package nodeserver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+)
+
+// Call - one request received by the Fake client
+type Call struct {
+	Operation string
+	Request   interface{}
+}
+
+// Fake - in-memory Client recording every request, for tests
+type Fake struct {
+	// Err - returned by every operation when set
+	Err error
+	// Gains and Balances - values returned by GetGains and GetBalance, keyed by user id
+	Gains    map[int]int
+	Balances map[int]int
+	// Transactions - records returned by FindTransaction, the others are not found
+	Transactions map[TransactionRequest]Transaction
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// NewFake - empty Fake client
+func NewFake() *Fake {
+	return &Fake{
+		Gains:        map[int]int{},
+		Balances:     map[int]int{},
+		Transactions: map[TransactionRequest]Transaction{},
+	}
+}
+
+// Calls - requests received so far, oldest first
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// LastCall - most recent request received, false when there were none
+func (f *Fake) LastCall() (Call, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.calls) == 0 {
+		return Call{}, false
+	}
+	return f.calls[len(f.calls)-1], true
+}
+
+// Reset - forget the recorded requests
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+}
+
+func (f *Fake) record(ctx context.Context, operation string, request interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, Call{Operation: operation, Request: request})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.Err
+}
+
+func (f *Fake) submit(ctx context.Context, operation string, request interface{}, callback Callback) (Transaction, error) {
+	if err := f.record(ctx, operation, request); err != nil {
+		return Transaction{}, err
+	}
+	return Transaction{Type: operation, ParentID: callback.ActivityId}, nil
+}
+
+func (f *Fake) DeployProject(ctx context.Context, request DeployProjectRequest) (Transaction, error) {
+	return f.submit(ctx, "DeployProject", request, request.Callback)
+}
+
+func (f *Fake) SetBackers(ctx context.Context, request SetBackersRequest) (Transaction, error) {
+	return f.submit(ctx, "SetBackers", request, request.Callback)
+}
+
+func (f *Fake) SetProjectInfo(ctx context.Context, request SetProjectInfoRequest) (Transaction, error) {
+	return f.submit(ctx, "SetProjectInfo", request, request.Callback)
+}
+
+func (f *Fake) SetModerators(ctx context.Context, request SetModeratorsRequest) (Transaction, error) {
+	return f.submit(ctx, "SetModerators", request, request.Callback)
+}
+
+func (f *Fake) MilestoneVote(ctx context.Context, request MilestoneVoteRequest) (Transaction, error) {
+	return f.submit(ctx, "MilestoneVote", request, request.Callback)
+}
+
+func (f *Fake) ModerationVote(ctx context.Context, request ModerationVoteRequest) (Transaction, error) {
+	return f.submit(ctx, "ModerationVote", request, request.Callback)
+}
+
+func (f *Fake) CommitModerationVotes(ctx context.Context, request CommitModerationVotesRequest) (Transaction, error) {
+	return f.submit(ctx, "CommitModerationVotes", request, request.Callback)
+}
+
+func (f *Fake) CheckMilestone(ctx context.Context, request ProjectRequest) (Transaction, error) {
+	return f.submit(ctx, "CheckMilestone", request, request.Callback)
+}
+
+func (f *Fake) CancelProject(ctx context.Context, request ProjectRequest) (Transaction, error) {
+	return f.submit(ctx, "CancelProject", request, request.Callback)
+}
+
+func (f *Fake) FailedFundRecovery(ctx context.Context, request ProjectRequest) (Transaction, error) {
+	return f.submit(ctx, "FailedFundRecovery", request, request.Callback)
+}
+
+func (f *Fake) WithdrawFunds(ctx context.Context, request ReleaseFundsRequest) (Transaction, error) {
+	return f.submit(ctx, "WithdrawFunds", request, request.Callback)
+}
+
+func (f *Fake) RequestRefund(ctx context.Context, request ReleaseFundsRequest) (Transaction, error) {
+	return f.submit(ctx, "RequestRefund", request, request.Callback)
+}
+
+func (f *Fake) StakePLG(ctx context.Context, request StakeRequest) (Transaction, error) {
+	return f.submit(ctx, "StakePLG", request, request.Callback)
+}
+
+func (f *Fake) UnstakePLG(ctx context.Context, request UserRequest) (Transaction, error) {
+	return f.submit(ctx, "UnstakePLG", request, request.Callback)
+}
+
+func (f *Fake) WithdrawInterest(ctx context.Context, request UserRequest) (Transaction, error) {
+	return f.submit(ctx, "WithdrawInterest", request, request.Callback)
+}
+
+func (f *Fake) ReinvestPLG(ctx context.Context, request UserRequest) (Transaction, error) {
+	return f.submit(ctx, "ReinvestPLG", request, request.Callback)
+}
+
+func (f *Fake) PostInterest(ctx context.Context, request PostInterestRequest) (Transaction, error) {
+	return f.submit(ctx, "PostInterest", request, request.Callback)
+}
+
+func (f *Fake) GetGains(ctx context.Context, userId int) (int, error) {
+	if err := f.record(ctx, "GetGains", userId); err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Gains[userId], nil
+}
+
+func (f *Fake) GetBalance(ctx context.Context, userId int) (int, error) {
+	if err := f.record(ctx, "GetBalance", userId); err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Balances[userId], nil
+}
+
+func (f *Fake) FindTransaction(ctx context.Context, request TransactionRequest) (Transaction, error) {
+	if err := f.record(ctx, "FindTransaction", request); err != nil {
+		return Transaction{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	transaction, ok := f.Transactions[request]
+	if !ok {
+		return transaction, errs.New(errs.NotFound, "Nodeserver has no %s transaction for activity %d", request.Type, request.ActivityId)
+	}
+	return transaction, nil
+}
+
+func (f *Fake) Monitor(ctx context.Context) error {
+	return f.record(ctx, "Monitor", nil)
+}
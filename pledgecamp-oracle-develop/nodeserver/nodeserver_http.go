@@ -0,0 +1,365 @@
+package nodeserver
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/imroc/req"
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/metrics"
+)
+
+// Config - Nodeserver location, credentials, the deadline of each request and how failures are handled.
+// MaxAttempts below 2 disables retries and a BreakerThreshold of 0 disables the circuit breaker.
+// CallbackSecret and CallbackWindow are used to verify the postbacks Nodeserver signs and MonitorURI is
+// requested by the readiness check
+type Config struct {
+	URL              string
+	AccessToken      string
+	MonitorURI       string
+	Timeout          time.Duration
+	MaxAttempts      int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	CallbackSecret   string
+	CallbackWindow   time.Duration
+}
+
+type httpClient struct {
+	config  Config
+	breaker *breaker
+}
+
+// NewClient - Nodeserver client speaking the HTTP API at config.URL
+func NewClient(config Config) Client {
+	return &httpClient{
+		config:  config,
+		breaker: newBreaker(config.BreakerThreshold, config.BreakerCooldown),
+	}
+}
+
+// transactionParam - parameters shared by every transaction submission
+func transactionParam(transactionType string, callback Callback) req.Param {
+	return req.Param{
+		"transaction_type": transactionType,
+		"activity_id":      callback.ActivityId,
+		"url_callback":     callback.URL,
+	}
+}
+
+// attemptOutcome - how a single Nodeserver request ended
+type attemptOutcome int
+
+const (
+	// attemptDone - success, or an answer that retrying will not change
+	attemptDone attemptOutcome = iota
+	// attemptUnsent - Nodeserver refused or never saw the request, always safe to send again
+	attemptUnsent
+	// attemptFailed - Nodeserver may have acted on the request before failing
+	attemptFailed
+	// attemptCancelled - the caller gave up, nothing is known about Nodeserver
+	attemptCancelled
+)
+
+// classify - outcome of one request from its transport error or response status
+func classify(ctx context.Context, response *req.Resp, err error) attemptOutcome {
+	if err != nil {
+		if ctx.Err() != nil {
+			return attemptCancelled
+		}
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return attemptUnsent
+		}
+		return attemptFailed
+	}
+	switch status := response.Response().StatusCode; {
+	case status == http.StatusTooManyRequests, status == http.StatusBadGateway, status == http.StatusServiceUnavailable:
+		return attemptUnsent
+	case status >= http.StatusInternalServerError:
+		return attemptFailed
+	}
+	return attemptDone
+}
+
+// retryDelay - jittered exponential backoff before retry number attempt, between half and all of
+// RetryBaseDelay doubled on each attempt and capped at RetryMaxDelay
+func (c *httpClient) retryDelay(attempt int) time.Duration {
+	delay := c.config.RetryBaseDelay
+	for i := 1; i < attempt && delay < c.config.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > c.config.RetryMaxDelay {
+		delay = c.config.RetryMaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// send - one request to Nodeserver, bounded by the configured timeout
+func (c *httpClient) send(ctx context.Context, method string, uri string, parameters req.Param) (*req.Resp, attemptOutcome, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	header := req.Header{
+		"Accept":        "application/json",
+		"Authorization": "Bearer " + c.config.AccessToken,
+	}
+	if requestId := logger.RequestID(ctx); requestId != "" {
+		header[logger.RequestIDHeader] = requestId
+	}
+
+	fullUrl := c.config.URL + uri
+	logger.Debugf(ctx, "Making a request to %s", fullUrl)
+	start := time.Now()
+	response, err := req.Do(method, fullUrl, header, parameters, attemptCtx)
+	outcome := classify(ctx, response, err)
+	if err == nil && response.Response().StatusCode > 201 {
+		err = errs.New(errs.NodeServer, "Nodeserver responded with status %d", response.Response().StatusCode)
+	} else if err != nil {
+		err = errs.Wrap(errs.NodeServer, err, "Nodeserver request failed")
+	}
+	metrics.NodeServerRequestDuration.Observe(metrics.Since(start), method, metrics.Outcome(err))
+	if err != nil {
+		logger.Error(ctx, err)
+	}
+	return response, outcome, err
+}
+
+// do - send a request to Nodeserver through the circuit breaker. Failures are retried with backoff when
+// idempotent is set or Nodeserver did not get to act on the request
+func (c *httpClient) do(ctx context.Context, method string, uri string, parameters req.Param, idempotent bool) (*req.Resp, error) {
+	for attempt := 1; ; attempt++ {
+		if err := c.breaker.allow(); err != nil {
+			logger.Warn(ctx, err)
+			return nil, err
+		}
+
+		response, outcome, err := c.send(ctx, method, uri, parameters)
+		switch outcome {
+		case attemptCancelled:
+			c.breaker.release()
+		case attemptDone:
+			c.breaker.record(true)
+		default:
+			c.breaker.record(false)
+		}
+
+		retryable := outcome == attemptUnsent || (outcome == attemptFailed && idempotent)
+		if err == nil || !retryable || attempt >= c.config.MaxAttempts {
+			return response, err
+		}
+
+		delay := c.retryDelay(attempt)
+		logger.Warnf(ctx, "Retrying Nodeserver request %s in %v (attempt %d of %d)", uri, delay, attempt+1, c.config.MaxAttempts)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return response, errs.Wrap(errs.NodeServer, ctx.Err(), "Nodeserver request cancelled")
+		}
+	}
+}
+
+// submit - post a transaction and decode the Nodeserver record of it
+func (c *httpClient) submit(ctx context.Context, uri string, parameters req.Param) (Transaction, error) {
+	var transaction Transaction
+	response, err := c.do(ctx, "POST", uri, parameters, false)
+	if err != nil {
+		return transaction, err
+	}
+	response.ToJSON(&transaction)
+	return transaction, nil
+}
+
+// query - read an integer value from Nodeserver
+func (c *httpClient) query(ctx context.Context, uri string, parameters req.Param) (int, error) {
+	response, err := c.do(ctx, "GET", uri, parameters, true)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.Atoi(response.String())
+	if err != nil {
+		logger.Error(ctx, err)
+		return 0, errs.Wrap(errs.NodeServer, err, "Unexpected Nodeserver response")
+	}
+	return value, nil
+}
+
+func (c *httpClient) DeployProject(ctx context.Context, request DeployProjectRequest) (Transaction, error) {
+	parameters := transactionParam(string(constants.ProjectDeploy), request.Callback)
+	parameters["project_id"] = request.ProjectId
+	parameters["milestone_times"] = request.Milestones
+	parameters["release_percents"] = request.ReleasePercents
+	return c.submit(ctx, "/projects/"+strconv.Itoa(request.ProjectId), parameters)
+}
+
+func (c *httpClient) SetBackers(ctx context.Context, request SetBackersRequest) (Transaction, error) {
+	activityReference := string(constants.SetBackers)
+	parameters := transactionParam(activityReference, request.Callback)
+	parameters["beneficiaries"] = request.Beneficiaries
+	parameters["amounts"] = request.Amounts
+	parameters["funding_complete"] = request.FundingComplete
+	parameters["total_amount"] = request.TotalAmount
+	parameters["contract_address"] = request.ContractAddress
+	return c.submit(ctx, "/manager/projects/"+strconv.Itoa(request.ProjectId)+"/"+activityReference, parameters)
+}
+
+func (c *httpClient) SetProjectInfo(ctx context.Context, request SetProjectInfoRequest) (Transaction, error) {
+	activityReference := string(constants.SetProjectInfo)
+	parameters := transactionParam(activityReference, request.Callback)
+	parameters["contract_address"] = request.ContractAddress
+	parameters["listing_fee"] = request.ListingFee
+	return c.submit(ctx, "/admin/projects/"+strconv.Itoa(request.ProjectId)+"/"+activityReference, parameters)
+}
+
+func (c *httpClient) SetModerators(ctx context.Context, request SetModeratorsRequest) (Transaction, error) {
+	activityReference := string(constants.SetModerators)
+	parameters := transactionParam(activityReference, request.Callback)
+	parameters["contract_address"] = request.ContractAddress
+	parameters["moderators"] = request.Moderators
+	parameters["moderation_end_time"] = request.ModerationEndTime
+	return c.submit(ctx, "/cs/projects/"+strconv.Itoa(request.ProjectId)+"/"+activityReference, parameters)
+}
+
+func (c *httpClient) MilestoneVote(ctx context.Context, request MilestoneVoteRequest) (Transaction, error) {
+	activityReference := string(constants.MilestoneVote)
+	parameters := transactionParam(activityReference, request.Callback)
+	parameters["contract_address"] = request.ContractAddress
+	parameters["user_id"] = request.UserId
+	parameters["vote"] = request.Vote
+	uri := "/manager/projects/" + strconv.Itoa(request.ProjectId) + "/" + activityReference + "/" + strconv.Itoa(request.UserId)
+	return c.submit(ctx, uri, parameters)
+}
+
+func (c *httpClient) ModerationVote(ctx context.Context, request ModerationVoteRequest) (Transaction, error) {
+	activityReference := string(constants.ModerationVote)
+	parameters := transactionParam(activityReference, request.Callback)
+	parameters["contract_address"] = request.ContractAddress
+	parameters["user_id"] = request.UserId
+	parameters["encrypted_vote"] = request.EncryptedVote
+	uri := "/manager/projects/" + strconv.Itoa(request.ProjectId) + "/" + activityReference + "/" + strconv.Itoa(request.UserId)
+	return c.submit(ctx, uri, parameters)
+}
+
+func (c *httpClient) CommitModerationVotes(ctx context.Context, request CommitModerationVotesRequest) (Transaction, error) {
+	activityReference := string(constants.CommitFinalVotes)
+	parameters := transactionParam(activityReference, request.Callback)
+	parameters["votes"] = request.Votes
+	parameters["decryption_keys"] = request.DecryptionKeys
+	parameters["user_ids"] = request.UserIds
+	parameters["contract_address"] = request.ContractAddress
+	parameters["project_id"] = request.ProjectId
+	return c.submit(ctx, "/moderator/projects/"+strconv.Itoa(request.ProjectId)+"/"+activityReference, parameters)
+}
+
+// projectTransaction - submit a transaction that only needs the project contract
+func (c *httpClient) projectTransaction(ctx context.Context, prefix string, activityReference constants.ActivityReference, request ProjectRequest) (Transaction, error) {
+	parameters := transactionParam(string(activityReference), request.Callback)
+	parameters["contract_address"] = request.ContractAddress
+	return c.submit(ctx, prefix+strconv.Itoa(request.ProjectId)+"/"+string(activityReference), parameters)
+}
+
+func (c *httpClient) CheckMilestone(ctx context.Context, request ProjectRequest) (Transaction, error) {
+	return c.projectTransaction(ctx, "/projects/", constants.CheckMilestone, request)
+}
+
+func (c *httpClient) CancelProject(ctx context.Context, request ProjectRequest) (Transaction, error) {
+	return c.projectTransaction(ctx, "/moderator/projects/", constants.CancelProject, request)
+}
+
+func (c *httpClient) FailedFundRecovery(ctx context.Context, request ProjectRequest) (Transaction, error) {
+	return c.projectTransaction(ctx, "/projects/", constants.FailedFundRecovery, request)
+}
+
+// releaseFunds - submit a transaction releasing project funds to a user
+func (c *httpClient) releaseFunds(ctx context.Context, activityReference constants.ActivityReference, request ReleaseFundsRequest) (Transaction, error) {
+	parameters := transactionParam(string(activityReference), request.Callback)
+	parameters["contract_address"] = request.ContractAddress
+	parameters["user_id"] = request.UserId
+	return c.submit(ctx, "/manager/projects/"+strconv.Itoa(request.ProjectId)+"/"+string(activityReference), parameters)
+}
+
+func (c *httpClient) WithdrawFunds(ctx context.Context, request ReleaseFundsRequest) (Transaction, error) {
+	return c.releaseFunds(ctx, constants.WithdrawFunds, request)
+}
+
+func (c *httpClient) RequestRefund(ctx context.Context, request ReleaseFundsRequest) (Transaction, error) {
+	return c.releaseFunds(ctx, constants.RequestRefund, request)
+}
+
+func (c *httpClient) StakePLG(ctx context.Context, request StakeRequest) (Transaction, error) {
+	activityReference := string(constants.StakePLG)
+	parameters := transactionParam(activityReference, request.Callback)
+	parameters["user_id"] = request.UserId
+	parameters["amount"] = request.Amount
+	return c.submit(ctx, "/manager/cs/"+strconv.Itoa(request.UserId)+"/"+activityReference, parameters)
+}
+
+// userTransaction - submit a CampShares transaction that only needs the user
+func (c *httpClient) userTransaction(ctx context.Context, activityReference constants.ActivityReference, request UserRequest) (Transaction, error) {
+	parameters := transactionParam(string(activityReference), request.Callback)
+	parameters["user_id"] = request.UserId
+	return c.submit(ctx, "/manager/cs/"+strconv.Itoa(request.UserId)+"/"+string(activityReference), parameters)
+}
+
+func (c *httpClient) UnstakePLG(ctx context.Context, request UserRequest) (Transaction, error) {
+	return c.userTransaction(ctx, constants.UnstakePLG, request)
+}
+
+func (c *httpClient) WithdrawInterest(ctx context.Context, request UserRequest) (Transaction, error) {
+	return c.userTransaction(ctx, constants.WithdrawInterest, request)
+}
+
+func (c *httpClient) ReinvestPLG(ctx context.Context, request UserRequest) (Transaction, error) {
+	return c.userTransaction(ctx, constants.ReinvestPLG, request)
+}
+
+func (c *httpClient) PostInterest(ctx context.Context, request PostInterestRequest) (Transaction, error) {
+	activityReference := string(constants.PostInterest)
+	parameters := transactionParam(activityReference, request.Callback)
+	parameters["amount"] = request.Amount
+	return c.submit(ctx, "/cs/"+activityReference, parameters)
+}
+
+func (c *httpClient) GetGains(ctx context.Context, userId int) (int, error) {
+	uri := "/manager/cs/" + strconv.Itoa(userId) + "/" + string(constants.GetGains)
+	return c.query(ctx, uri, req.Param{"user_id": userId})
+}
+
+func (c *httpClient) GetBalance(ctx context.Context, userId int) (int, error) {
+	uri := "/manager/users/" + strconv.Itoa(userId) + "/" + string(constants.GetBalance)
+	return c.query(ctx, uri, req.Param{"user_id": userId})
+}
+
+func (c *httpClient) FindTransaction(ctx context.Context, request TransactionRequest) (Transaction, error) {
+	var transaction Transaction
+	parameters := req.Param{"activity_id": request.ActivityId, "transaction_type": string(request.Type)}
+	response, err := c.do(ctx, "GET", "/transactions", parameters, true)
+	if err != nil {
+		if response != nil && response.Response().StatusCode == http.StatusNotFound {
+			return transaction, errs.New(errs.NotFound, "Nodeserver has no %s transaction for activity %d", request.Type, request.ActivityId)
+		}
+		return transaction, err
+	}
+	if err := response.ToJSON(&transaction); err != nil {
+		logger.Error(ctx, err)
+		return transaction, errs.Wrap(errs.NodeServer, err, "Unexpected Nodeserver response")
+	}
+	return transaction, nil
+}
+
+func (c *httpClient) Monitor(ctx context.Context) error {
+	_, _, err := c.send(ctx, "GET", c.config.MonitorURI, nil)
+	return err
+}
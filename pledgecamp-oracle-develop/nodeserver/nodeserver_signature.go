@@ -0,0 +1,52 @@
+package nodeserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+)
+
+const (
+	// SignatureHeader - hex HMAC-SHA256 of the timestamp and body of a postback
+	SignatureHeader = "X-Nodeserver-Signature"
+	// TimestampHeader - unix time in seconds at which Nodeserver signed the postback
+	TimestampHeader = "X-Nodeserver-Timestamp"
+)
+
+// SignCallback - signature Nodeserver sends with a postback, computed over "<timestamp>.<body>"
+func SignCallback(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCallback - check that a postback was signed with secret less than window before or after now.
+// Postbacks outside the window are rejected as replays
+func VerifyCallback(secret string, timestamp string, signature string, body []byte, window time.Duration, now time.Time) error {
+	if secret == "" {
+		return errs.New(errs.Unauthorized, "Postback signing secret is not configured")
+	}
+	if timestamp == "" || signature == "" {
+		return errs.New(errs.Unauthorized, "Postback is not signed")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errs.Wrap(errs.Unauthorized, err, "Invalid postback timestamp")
+	}
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > window || age < -window {
+		return errs.New(errs.Unauthorized, "Postback timestamp is outside the accepted window")
+	}
+
+	expected := SignCallback(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errs.New(errs.Unauthorized, "Invalid postback signature")
+	}
+	return nil
+}
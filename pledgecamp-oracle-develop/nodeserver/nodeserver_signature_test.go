@@ -0,0 +1,39 @@
+package nodeserver
+
+import (
+	"log"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+)
+
+// Tests for nodeserver_signature.go
+func TestVerifyCallback(t *testing.T) {
+	log.Println("********************************* TestVerifyCallback() **************************************")
+	secret := "callback-secret"
+	body := []byte(`{"transaction_uuid":"abc","transaction_status":2}`)
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := SignCallback(secret, timestamp, body)
+
+	if err := VerifyCallback(secret, timestamp, signature, body, time.Minute, now); err != nil {
+		t.Errorf("Expected a valid signature, got %v", err)
+	}
+
+	rejected := map[string]error{
+		"tampered body":   VerifyCallback(secret, timestamp, signature, []byte(`{}`), time.Minute, now),
+		"wrong secret":    VerifyCallback("other", timestamp, signature, body, time.Minute, now),
+		"unsigned":        VerifyCallback(secret, "", "", body, time.Minute, now),
+		"no secret":       VerifyCallback("", timestamp, signature, body, time.Minute, now),
+		"replayed":        VerifyCallback(secret, timestamp, signature, body, time.Minute, now.Add(2*time.Minute)),
+		"from the future": VerifyCallback(secret, timestamp, signature, body, time.Minute, now.Add(-2*time.Minute)),
+	}
+	for name, err := range rejected {
+		if !errs.Is(err, errs.Unauthorized) {
+			t.Errorf("Expected %s postback to be unauthorized, got %v", name, err)
+		}
+	}
+	log.Println("********************************* End TestVerifyCallback() **************************************")
+}
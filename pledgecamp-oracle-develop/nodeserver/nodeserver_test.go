@@ -0,0 +1,234 @@
+package nodeserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/metrics"
+)
+
+// metricValue - value of the series written as name in the metrics output, 0 when there is none yet
+func metricValue(t *testing.T, series string) float64 {
+	var output bytes.Buffer
+	if err := metrics.Write(&output); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(output.String(), "\n") {
+		if strings.HasPrefix(line, series+" ") {
+			value, err := strconv.ParseFloat(strings.TrimPrefix(line, series+" "), 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return value
+		}
+	}
+	return 0
+}
+
+// Tests for nodeserver_http.go
+func TestNodeServerClient(t *testing.T) {
+	log.Println("********************************* TestNodeServerClient() **************************************")
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		fmt.Fprint(w, `{"transaction_uuid":"deploy-123"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{URL: server.URL, AccessToken: "test_internal", Timeout: time.Second})
+	requests := `oracle_nodeserver_request_duration_seconds_count{method="POST",outcome="success"}`
+	count := metricValue(t, requests)
+	ctx := logger.WithRequestID(context.Background(), "request-1")
+	transaction, err := client.DeployProject(ctx, DeployProjectRequest{
+		ProjectId:       123,
+		Milestones:      []int64{1617539309000},
+		ReleasePercents: []int64{100},
+		Callback:        Callback{ActivityId: 1, URL: "http://localhost/projects/123/callback/DEPLOY"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.Method != http.MethodPost || received.URL.Path != "/projects/123" || received.Header.Get("Authorization") != "Bearer test_internal" {
+		t.Errorf("Unexpected request %s %s", received.Method, received.URL.Path)
+	}
+	if requestId := received.Header.Get(logger.RequestIDHeader); requestId != "request-1" {
+		t.Errorf("Expected Nodeserver to get the request ID, got %q", requestId)
+	}
+	if transaction.UUID != "deploy-123" {
+		t.Errorf("Expected the transaction of the answer, got %+v", transaction)
+	}
+	if value := metricValue(t, requests); value != count+1 {
+		t.Errorf("Expected the request to be timed, got %v after %v", value, count)
+	}
+	log.Println("********************************* End TestNodeServerClient() **************************************")
+}
+
+func TestNodeServerClientTimeout(t *testing.T) {
+	log.Println("********************************* TestNodeServerClientTimeout() **************************************")
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer slowServer.Close()
+
+	client := NewClient(Config{URL: slowServer.URL, Timeout: 50 * time.Millisecond})
+	start := time.Now()
+	_, err := client.GetBalance(context.Background(), 1)
+	if !errs.Is(err, errs.NodeServer) {
+		t.Errorf("Expected a nodeserver error, got %v", err)
+	}
+	if time.Since(start) >= 500*time.Millisecond {
+		t.Error("Request was not cut off by the client timeout")
+	}
+	log.Println("********************************* End TestNodeServerClientTimeout() **************************************")
+}
+
+func TestNodeServerRetry(t *testing.T) {
+	log.Println("********************************* TestNodeServerRetry() **************************************")
+	var requests int32
+	restartingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1, 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			fmt.Fprint(w, "{}")
+		}
+	}))
+	defer restartingServer.Close()
+
+	config := Config{
+		URL:            restartingServer.URL,
+		Timeout:        time.Second,
+		MaxAttempts:    3,
+		RetryBaseDelay: 10 * time.Millisecond,
+		RetryMaxDelay:  50 * time.Millisecond,
+	}
+	client := NewClient(config)
+	_, err := client.StakePLG(context.Background(), StakeRequest{UserId: 1, Amount: 10})
+	if err != nil {
+		t.Errorf("Expected the submission to succeed once Nodeserver was back, got %v", err)
+	}
+	if atomic.LoadInt32(&requests) != 3 {
+		t.Errorf("Expected 3 attempts, got %d", requests)
+	}
+
+	// A submission Nodeserver may have acted on is not sent twice
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failingServer.Close()
+	atomic.StoreInt32(&requests, 0)
+	config.URL = failingServer.URL
+	client = NewClient(config)
+	_, err = client.StakePLG(context.Background(), StakeRequest{UserId: 1, Amount: 10})
+	if !errs.Is(err, errs.NodeServer) || atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("Expected a single failed submission, got %d attempts and %v", requests, err)
+	}
+
+	// Queries are idempotent and retried on any failure
+	atomic.StoreInt32(&requests, 0)
+	_, err = client.GetBalance(context.Background(), 1)
+	if !errs.Is(err, errs.NodeServer) || atomic.LoadInt32(&requests) != 3 {
+		t.Errorf("Expected 3 failed queries, got %d attempts and %v", requests, err)
+	}
+	log.Println("********************************* End TestNodeServerRetry() **************************************")
+}
+
+// Tests for nodeserver_breaker.go
+func TestNodeServerBreaker(t *testing.T) {
+	log.Println("********************************* TestNodeServerBreaker() **************************************")
+	var requests int32
+	var healthy int32
+	flakyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, "{}")
+	}))
+	defer flakyServer.Close()
+
+	client := NewClient(Config{
+		URL:              flakyServer.URL,
+		Timeout:          time.Second,
+		BreakerThreshold: 2,
+		BreakerCooldown:  100 * time.Millisecond,
+	})
+	request := ProjectRequest{ProjectId: 1, ContractAddress: "0x0"}
+	for i := 0; i < 2; i++ {
+		client.CheckMilestone(context.Background(), request)
+	}
+	if stats, _ := Breaker(client); stats.State != "open" {
+		t.Errorf("Expected the breaker to open after 2 failures, got %v", stats.State)
+	}
+
+	// Requests fail fast without reaching Nodeserver while the breaker is open
+	_, err := client.CheckMilestone(context.Background(), request)
+	if !errors.Is(err, ErrBreakerOpen) || !errs.Is(err, errs.NodeServer) {
+		t.Errorf("Expected the breaker to reject the request, got %v", err)
+	}
+	if atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("Expected 2 requests to reach Nodeserver, got %d", requests)
+	}
+
+	// After the cooldown a successful probe closes the breaker
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(150 * time.Millisecond)
+	if _, err := client.CheckMilestone(context.Background(), request); err != nil {
+		t.Errorf("Expected the probe request to succeed, got %v", err)
+	}
+	if stats, _ := Breaker(client); stats.State != "closed" || stats.ConsecutiveFailures != 0 {
+		t.Errorf("Expected the breaker to close, got %+v", stats)
+	}
+
+	if _, ok := Breaker(NewFake()); ok {
+		t.Error("Fake client should not report a breaker")
+	}
+	log.Println("********************************* End TestNodeServerBreaker() **************************************")
+}
+
+// Tests for nodeserver_fake.go
+func TestFake(t *testing.T) {
+	log.Println("********************************* TestFake() **************************************")
+	fake := NewFake()
+	fake.Balances[7] = 300
+	balance, err := fake.GetBalance(context.Background(), 7)
+	if err != nil || balance != 300 {
+		t.Errorf("Expected the configured balance, got %d and %v", balance, err)
+	}
+	if _, err := fake.FindTransaction(context.Background(), TransactionRequest{ActivityId: 1}); !errs.Is(err, errs.NotFound) {
+		t.Errorf("Expected an unknown transaction not to be found, got %v", err)
+	}
+
+	request := ProjectRequest{ProjectId: 1, ContractAddress: "0x0"}
+	if _, err := fake.CheckMilestone(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+	call, ok := fake.LastCall()
+	if !ok || call.Operation != "CheckMilestone" || call.Request.(ProjectRequest) != request {
+		t.Errorf("Expected the CheckMilestone call to be recorded, got %+v", call)
+	}
+
+	fake.Err = errs.New(errs.NodeServer, "Nodeserver unavailable")
+	if _, err := fake.CheckMilestone(context.Background(), request); !errs.Is(err, errs.NodeServer) {
+		t.Errorf("Expected the configured error, got %v", err)
+	}
+	fake.Reset()
+	if _, ok := fake.LastCall(); ok {
+		t.Error("Expected the calls to be forgotten")
+	}
+	log.Println("********************************* End TestFake() **************************************")
+}
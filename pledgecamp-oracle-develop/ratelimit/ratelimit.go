@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+)
+
+// sweepInterval - how often buckets that have refilled are forgotten
+const sweepInterval = time.Minute
+
+// Limit - requests a single client, credential or path id may make to a route group. PerMinute is the
+// sustained rate and Burst the number of requests that can be made at once
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+// Config - limits of each route group, groups without a limit are not rate limited
+type Config struct {
+	Limits map[constants.RouteGroup]Limit
+}
+
+// DefaultLimits - the user state routes are public and reach Nodeserver and the backend on every call
+var DefaultLimits = map[constants.RouteGroup]Limit{
+	constants.RouteUsers: {PerMinute: 120, Burst: 30},
+}
+
+// bucket - tokens left to a key, refilled continuously up to the burst of its limit
+type bucket struct {
+	limit     Limit
+	tokens    float64
+	updatedAt time.Time
+}
+
+// Limiter - token buckets of every key that called a rate limited route group
+type Limiter struct {
+	limits map[constants.RouteGroup]Limit
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweptAt time.Time
+}
+
+// NewLimiter - Limiter enforcing the limits of config
+func NewLimiter(config Config) *Limiter {
+	return &Limiter{limits: config.Limits, buckets: map[string]*bucket{}}
+}
+
+// Limit - limit of group, false when the group is not rate limited
+func (l *Limiter) Limit(group constants.RouteGroup) (Limit, bool) {
+	limit, ok := l.limits[group]
+	return limit, ok
+}
+
+// refill - add the tokens earned since the bucket was last updated
+func (b *bucket) refill(now time.Time) {
+	if !now.After(b.updatedAt) {
+		return
+	}
+	perSecond := float64(b.limit.PerMinute) / 60
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.updatedAt).Seconds()*perSecond)
+	b.updatedAt = now
+}
+
+// Take - spend a token of group from the bucket of every key. When one of the buckets is empty nothing is
+// spent and the time until all of them have a token again is returned
+func (l *Limiter) Take(group constants.RouteGroup, keys []string, now time.Time) time.Duration {
+	limit, ok := l.limits[group]
+	if !ok {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	perSecond := float64(limit.PerMinute) / 60
+	var wait time.Duration
+	buckets := make([]*bucket, 0, len(keys))
+	for _, key := range keys {
+		b, ok := l.buckets[string(group)+"|"+key]
+		if !ok {
+			b = &bucket{limit: limit, tokens: float64(limit.Burst), updatedAt: now}
+			l.buckets[string(group)+"|"+key] = b
+		}
+		b.refill(now)
+		if b.tokens < 1 {
+			keyWait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+			if keyWait > wait {
+				wait = keyWait
+			}
+		}
+		buckets = append(buckets, b)
+	}
+	if wait > 0 {
+		return wait
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return 0
+}
+
+// sweep - forget the buckets that are full again, a new bucket would be the same
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.sweptAt) < sweepInterval {
+		return
+	}
+	l.sweptAt = now
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+)
+
+// Tests for ratelimit.go
+func TestRateLimiter(t *testing.T) {
+	log.Println("********************************* TestRateLimiter() **************************************")
+	limiter := NewLimiter(Config{Limits: map[constants.RouteGroup]Limit{
+		constants.RouteUsers: {PerMinute: 60, Burst: 2},
+	}})
+	now := time.Now()
+
+	// The burst is spent at once, then the bucket refills at one token a second
+	for i := 0; i < 2; i++ {
+		if wait := limiter.Take(constants.RouteUsers, []string{"ip:10.0.0.1", "id:42"}, now); wait != 0 {
+			t.Fatalf("Expected request %d to be allowed, got wait %v", i, wait)
+		}
+	}
+	wait := limiter.Take(constants.RouteUsers, []string{"ip:10.0.0.1", "id:42"}, now)
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("Expected to wait up to a second, got %v", wait)
+	}
+
+	// Another client is still limited on the same path id, without spending its own tokens
+	if wait := limiter.Take(constants.RouteUsers, []string{"ip:10.0.0.2", "id:42"}, now); wait <= 0 {
+		t.Error("Expected requests for the same id to be limited")
+	}
+	if wait := limiter.Take(constants.RouteUsers, []string{"ip:10.0.0.2", "id:43"}, now); wait != 0 {
+		t.Errorf("Expected another id to be allowed, got wait %v", wait)
+	}
+	if wait := limiter.Take(constants.RouteUsers, []string{"ip:10.0.0.1", "id:42"}, now.Add(time.Second)); wait != 0 {
+		t.Errorf("Expected the bucket to have refilled, got wait %v", wait)
+	}
+
+	// Groups without a limit are not rate limited
+	for i := 0; i < 10; i++ {
+		if wait := limiter.Take(constants.RouteProjects, []string{"ip:10.0.0.1"}, now); wait != 0 {
+			t.Fatalf("Expected unlimited group to be allowed, got wait %v", wait)
+		}
+	}
+	log.Println("********************************* End TestRateLimiter() **************************************")
+}
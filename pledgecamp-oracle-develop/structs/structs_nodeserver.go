@@ -1,5 +1,7 @@
 package structs
 
+import "strconv"
+
 type StatusIndex int
 
 const (
@@ -31,3 +33,21 @@ type NodeServerModel struct {
 	RetryAttempts     int         `json:"transaction_retry_attempts"`
 	TransactionEvents interface{} `json:"transaction_events"`
 }
+
+var statusIndexNames = map[StatusIndex]string{
+	Initial:       "INITIAL",
+	Pending:       "PENDING",
+	Complete:      "COMPLETE",
+	FailedTimeout: "FAILED_TIMEOUT",
+	FailedGas:     "FAILED_GAS",
+	FailedInitial: "FAILED_INITIAL",
+	FailedReceipt: "FAILED_RECEIPT",
+	FailedPending: "FAILED_PENDING",
+}
+
+func (s StatusIndex) String() string {
+	if name, ok := statusIndexNames[s]; ok {
+		return name
+	}
+	return strconv.Itoa(int(s))
+}
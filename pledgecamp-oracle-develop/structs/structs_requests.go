@@ -75,7 +75,7 @@ type RequestCancelProject struct {
 }
 
 type RequestReleaseFunds struct {
-	UserId          int    `json:"user_id" binding: "required"`
+	UserId          int    `json:"user_id" binding:"required"`
 	FkProjectId     int    `json:"fk_project_id" binding:"required"`
 	TransactionType string `json:"transaction_type"`
 }
@@ -85,22 +85,28 @@ type RequestFailedFundRecovery struct {
 }
 
 type RequestStakePLG struct {
-	UserId int `json:"user_id" binding: "required"`
-	Amount int `json:"amount" binding: "required"`
+	UserId int `json:"user_id" binding:"required"`
+	Amount int `json:"amount" binding:"required"`
 }
 
 type RequestUnstakePLG struct {
-	UserId int `json:"user_id" binding: "required"`
+	UserId int `json:"user_id" binding:"required"`
 }
 
 type RequestWithdrawInterest struct {
-	UserId int `json:"user_id" binding: "required"`
+	UserId int `json:"user_id" binding:"required"`
 }
 
 type RequestReinvestPLG struct {
-	UserId int `json:"user_id" binding: "required"`
+	UserId int `json:"user_id" binding:"required"`
 }
 
 type RequestPostInterest struct {
-	Amount int `json:"amount" binding: "required"`
+	Amount int `json:"amount" binding:"required"`
+}
+
+type RequestCreateCredential struct {
+	Name        string   `json:"credential_name" binding:"required"`
+	Role        string   `json:"credential_role" binding:"required"`
+	RouteGroups []string `json:"route_groups"`
 }
@@ -1,7 +1,12 @@
 package utils
 
 import (
+	"context"
+
+	"github.com/pledgecamp/pledgecamp-oracle/config"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
@@ -55,3 +60,39 @@ type RequestCsState = structs.RequestCsState
 type CsStateResponse = models.CsStateResponse
 
 var err error
+
+// Service - runs the Oracle workflows with the loaded configuration against the injected model stores and
+// Nodeserver client. ctx carries the request ID and log fields of the request being served
+type Service struct {
+	models.Store
+	NodeServer nodeserver.Client
+	Config     config.Config
+	ctx        context.Context
+	// followUps - Nodeserver requests held back until the postback being applied is committed
+	followUps *[]followUp
+}
+
+// NewService - create a Service using the given configuration, stores and Nodeserver client
+func NewService(config config.Config, store models.Store, nodeServer nodeserver.Client) *Service {
+	return &Service{Store: store, NodeServer: nodeServer, Config: config}
+}
+
+// WithContext - copy of the Service serving the request of ctx
+func (s *Service) WithContext(ctx context.Context) *Service {
+	service := *s
+	service.ctx = ctx
+	return &service
+}
+
+// withFields - copy of the Service that logs fields with every line
+func (s *Service) withFields(fields logger.Fields) *Service {
+	return s.WithContext(logger.WithFields(s.context(), fields))
+}
+
+// context - request context of the Service, the background context outside of a request
+func (s *Service) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
@@ -0,0 +1,431 @@
+package utils
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/imroc/req"
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/metrics"
+	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/structs"
+)
+
+// ProjectCallback - apply a Nodeserver postback to the project activity that requested it
+func (s *Service) ProjectCallback(transactionResponse NodeServerModel) (err error) {
+	var activityReference constants.ActivityReference
+	outcome := constants.CallbackApplied
+	defer func() { observeCallback(transactionResponse, activityReference, outcome, err) }()
+	defer recoverMalformedPostback(transactionResponse, &err)
+	s = s.withFields(logger.Fields{"activity_id": transactionResponse.ParentID, "transaction_uuid": transactionResponse.UUID})
+
+	// Get Project Activity Record
+	projectActivity, err := s.ProjectActivities.SearchActivityID(transactionResponse.ParentID)
+	if err != nil {
+		logger.Error(s.context(), err)
+		return errs.Store(err, "Could not find project activity %d", transactionResponse.ParentID)
+	}
+	s = s.withFields(logger.Fields{"project_id": projectActivity.ProjectId})
+	activityReference = projectActivity.Type
+
+	outcome, err = s.applyCallback(transactionResponse, func(tx *Service) error {
+		return tx.projectCallback(transactionResponse, projectActivity)
+	})
+	if err == nil && outcome == constants.CallbackApplied {
+		// The postback may have moved the project to its next milestone or out of the milestone phase
+		s.retimeMilestone(projectActivity.ProjectId)
+	}
+	return err
+}
+
+// projectCallback - route a Nodeserver postback to the handling of its transaction type
+func (s *Service) projectCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) (err error) {
+	logger.Debug(s.context(), "Type: ", transactionResponse.Type)
+	if transactionResponse.Status > structs.Complete {
+		return s.projectCallbackFailed(transactionResponse, projectActivity)
+	}
+
+	// Transaction is still in flight, only keep track of the hash
+	if transactionResponse.Status != structs.Complete {
+		projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+		_, err = s.ProjectActivities.UpdateFields(projectActivity)
+		return err
+	}
+
+	logger.Debug(s.context(), "Project Status")
+	logger.Debug(s.context(), transactionResponse.Status)
+	switch transactionResponse.Type {
+	case string(constants.ProjectDeploy):
+		return s.ProjectCreateCallback(transactionResponse, projectActivity)
+	case string(constants.SetBackers):
+		return s.SetBackersCallback(transactionResponse, projectActivity)
+	case string(constants.SetProjectInfo):
+		return s.SetProjectInfoCallback(transactionResponse, projectActivity)
+	case string(constants.SetModerators):
+		return s.SetModeratorsCallback(transactionResponse, projectActivity)
+	case string(constants.CommitFinalVotes):
+		return s.CommitModerationVotesCallback(transactionResponse, projectActivity)
+	case string(constants.CancelProject):
+		return s.CancelProjectCallback(transactionResponse, projectActivity)
+	case string(constants.MilestoneVote):
+		return s.VoteCallback(transactionResponse, projectActivity)
+	case string(constants.ModerationVote):
+		return s.VoteCallback(transactionResponse, projectActivity)
+	case string(constants.CheckMilestone):
+		return s.CheckMilestoneCallback(transactionResponse, projectActivity)
+	case string(constants.WithdrawFunds):
+		return s.WithdrawFundsCallback(transactionResponse, projectActivity)
+	case string(constants.RequestRefund):
+		return s.RequestRefundCallback(transactionResponse, projectActivity)
+	case string(constants.FailedFundRecovery):
+		return s.FailedFundRecoveryCallback(transactionResponse, projectActivity)
+	}
+
+	// Dumb transactions with no advanced behaviour but simple postback to backend
+	backendEventType, err := constants.GetEventType(projectActivity.Type)
+	if err != nil {
+		logger.Error(s.context(), err)
+		return err
+	}
+
+	err = s.Atomic(func(tx models.Store) error {
+		// Update Activity status to success
+		projectActivity.Status = constants.ActivitySuccess
+		projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+		_, err := tx.ProjectActivities.UpdateFields(projectActivity)
+		if err != nil {
+			return err
+		}
+
+		// Queue the backend event with the changes
+		backendCallbackURL := "/events/blockchain/projects/" + strconv.Itoa(projectActivity.ProjectId) + "/callback/" + string(transactionResponse.Type)
+		requestParameters := req.Param{
+			"eventType":       backendEventType,
+			"projectId":       projectActivity.ProjectId,
+			"contractAddress": transactionResponse.ContractAddress,
+			"status":          true,
+		}
+		return s.enqueueBackend(tx, projectEvents(projectActivity.ProjectId), backendCallbackURL, requestParameters)
+	})
+	if err != nil {
+		logger.Error(s.context(), err)
+		return err
+	}
+
+	logger.Info(s.context(), "The following callback has been completed: ", transactionResponse.Type)
+	return nil
+}
+
+// projectCallbackFailed - mark the project activity with the Nodeserver failure and notify the backend
+func (s *Service) projectCallbackFailed(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
+	logger.Warn(s.context(), "Function failed: ", transactionResponse.Status)
+
+	backendEventType, err := constants.GetEventType(projectActivity.Type)
+	if err != nil {
+		logger.Error(s.context(), err)
+		return err
+	}
+
+	err = s.Atomic(func(tx models.Store) error {
+		projectActivity.Status = activityFailureStatus(transactionResponse.Status)
+		projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+		projectActivity.ModifiedAt = time.Now()
+		_, err := tx.ProjectActivities.UpdateFields(projectActivity)
+		if err != nil {
+			return err
+		}
+
+		// Queue the backend event with the changes
+		backendCallbackURL := "/events/blockchain/projects/" + strconv.Itoa(projectActivity.ProjectId) + "/callback/" + string(transactionResponse.Type)
+		requestParameters := req.Param{
+			"eventType":       backendEventType,
+			"projectId":       projectActivity.ProjectId,
+			"contractAddress": transactionResponse.ContractAddress,
+			"status":          false,
+		}
+		return s.enqueueBackend(tx, projectEvents(projectActivity.ProjectId), backendCallbackURL, requestParameters)
+	})
+	if err != nil {
+		logger.Error(s.context(), err)
+	}
+	return err
+}
+
+// CsCallback - apply a Nodeserver postback to the CS activity that requested it
+func (s *Service) CsCallback(transactionResponse NodeServerModel) (err error) {
+	var activityReference constants.ActivityReference
+	outcome := constants.CallbackApplied
+	defer func() { observeCallback(transactionResponse, activityReference, outcome, err) }()
+	defer recoverMalformedPostback(transactionResponse, &err)
+	s = s.withFields(logger.Fields{"activity_id": transactionResponse.ParentID, "transaction_uuid": transactionResponse.UUID})
+
+	// Get CS Activity Record
+	csActivity, err := s.CSActivities.SearchActivityID(transactionResponse.ParentID)
+	if err != nil {
+		logger.Error(s.context(), err)
+		return errs.Store(err, "Could not find CS activity %d", transactionResponse.ParentID)
+	}
+	fields := logger.Fields{"cs_id": csActivity.CsId}
+	if cs, err := s.CampShares.SearchCSId(csActivity.CsId); err == nil {
+		fields["user_id"] = cs.UserId
+	}
+	s = s.withFields(fields)
+	activityReference = csActivity.Type
+
+	outcome, err = s.applyCallback(transactionResponse, func(tx *Service) error {
+		return tx.csCallback(transactionResponse, csActivity)
+	})
+	return err
+}
+
+// csCallback - route a Nodeserver postback to the handling of its transaction type
+func (s *Service) csCallback(transactionResponse NodeServerModel, csActivity models.CSActivity) (err error) {
+	logger.Debug(s.context(), "Type: ", transactionResponse.Type)
+	if transactionResponse.Status > structs.Complete {
+		return s.csCallbackFailed(transactionResponse, csActivity)
+	}
+
+	// Transaction is still in flight, only keep track of the hash
+	if transactionResponse.Status != structs.Complete {
+		csActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+		_, err = s.CSActivities.UpdateFields(csActivity)
+		return err
+	}
+
+	logger.Debug(s.context(), "CS Status")
+	logger.Debug(s.context(), transactionResponse.Status)
+	switch transactionResponse.Type {
+	case string(constants.StakePLG):
+		return s.StakePLGCallback(transactionResponse, csActivity)
+	case string(constants.UnstakePLG):
+		return s.UnstakePLGCallback(transactionResponse, csActivity)
+	case string(constants.WithdrawInterest):
+		return s.WithdrawInterestCallback(transactionResponse, csActivity)
+	case string(constants.ReinvestPLG):
+		return s.ReinvestPLGCallback(transactionResponse, csActivity)
+	case string(constants.PostInterest):
+		return s.PostInterestCallback(transactionResponse, csActivity)
+	}
+
+	// Dumb transactions with no advanced behaviour but simple postback to backend
+	backendEventType, err := constants.GetEventType(csActivity.Type)
+	if err != nil {
+		logger.Error(s.context(), err)
+		return err
+	}
+
+	err = s.Atomic(func(tx models.Store) error {
+		// Update Activity status to success
+		csActivity.Status = constants.ActivitySuccess
+		csActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+		_, err := tx.CSActivities.UpdateFields(csActivity)
+		if err != nil {
+			return err
+		}
+
+		cs, err := tx.CampShares.SearchCSId(csActivity.CsId)
+		if err != nil {
+			return err
+		}
+
+		// Queue the backend event with the changes
+		backendCallbackURL := "/events/blockchain/cs/" + strconv.Itoa(cs.UserId) + "/callback/" + string(transactionResponse.Type)
+		requestParameters := req.Param{
+			"eventType": backendEventType,
+			"projectId": cs.UserId,
+			"status":    true,
+		}
+		return s.enqueueBackend(tx, csEvents(cs.UserId), backendCallbackURL, requestParameters)
+	})
+	if err != nil {
+		logger.Error(s.context(), err)
+		return err
+	}
+
+	logger.Info(s.context(), "The following callback has been completed: ", transactionResponse.Type)
+	return nil
+}
+
+// csCallbackFailed - mark the CS activity with the Nodeserver failure and notify the backend
+func (s *Service) csCallbackFailed(transactionResponse NodeServerModel, csActivity models.CSActivity) error {
+	logger.Warn(s.context(), "Function failed: ", transactionResponse.Status)
+
+	backendEventType, err := constants.GetEventType(csActivity.Type)
+	if err != nil {
+		logger.Error(s.context(), err)
+		return err
+	}
+
+	err = s.Atomic(func(tx models.Store) error {
+		csActivity.Status = activityFailureStatus(transactionResponse.Status)
+		csActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+		csActivity.ModifiedAt = time.Now()
+		_, err := tx.CSActivities.UpdateFields(csActivity)
+		if err != nil {
+			return err
+		}
+
+		cs, err := tx.CampShares.SearchCSId(csActivity.CsId)
+		if err != nil {
+			return err
+		}
+
+		// Queue the backend event with the changes
+		backendCallbackURL := "/events/blockchain/cs/" + strconv.Itoa(cs.UserId) + "/callback/" + string(transactionResponse.Type)
+		requestParameters := req.Param{
+			"eventType":       backendEventType,
+			"userId":          cs.UserId,
+			"contractAddress": transactionResponse.ContractAddress,
+			"status":          false,
+		}
+		return s.enqueueBackend(tx, csEvents(cs.UserId), backendCallbackURL, requestParameters)
+	})
+	if err != nil {
+		logger.Error(s.context(), err)
+	}
+	return err
+}
+
+// callbackStage - progress of a transaction status, every success or failure status is final
+func callbackStage(status structs.StatusIndex) int {
+	if status >= structs.Complete {
+		return int(structs.Complete)
+	}
+	return int(status)
+}
+
+// callbackOutcome - whether a postback moves its transaction forward. A status that was already applied is a
+// duplicate and one behind the applied statuses, or a different final status, is stale
+func callbackOutcome(applied []models.CallbackRecord, status structs.StatusIndex) constants.CallbackOutcome {
+	for _, record := range applied {
+		if record.Outcome != constants.CallbackApplied {
+			continue
+		}
+		if record.Status == status {
+			return constants.CallbackDuplicate
+		}
+		if callbackStage(record.Status) >= callbackStage(status) {
+			return constants.CallbackStale
+		}
+	}
+	return constants.CallbackApplied
+}
+
+// followUp - Nodeserver request made by a postback, sent by the Service it is given
+type followUp func(s *Service) error
+
+// afterCommit - send the follow-up once the postback being applied is committed, so a rolled back postback
+// sends nothing and a Nodeserver retry of it does not send the follow-up again. Sent at once outside of a
+// postback. A follow-up that fails leaves its activity pending for the reconciliation job
+func (s *Service) afterCommit(request followUp) error {
+	if s.followUps == nil {
+		return request(s)
+	}
+	*s.followUps = append(*s.followUps, request)
+	return nil
+}
+
+// applyCallback - record the postback by transaction uuid and status and apply it in the same unit of work,
+// then send the follow-up Nodeserver requests. Nodeserver retries and postbacks moving a transaction
+// backwards are recorded and acknowledged without applying them again
+func (s *Service) applyCallback(transactionResponse NodeServerModel, apply func(tx *Service) error) (constants.CallbackOutcome, error) {
+	if transactionResponse.UUID == "" {
+		logger.Warnf(s.context(), "%s postback for activity %d has no transaction uuid and cannot be deduplicated", transactionResponse.Type, transactionResponse.ParentID)
+		return constants.CallbackApplied, apply(s)
+	}
+
+	record := models.CallbackRecord{
+		UUID:       transactionResponse.UUID,
+		Status:     transactionResponse.Status,
+		Type:       transactionResponse.Type,
+		ActivityId: transactionResponse.ParentID,
+		ReceivedAt: time.Now(),
+	}
+	var followUps []followUp
+	err := s.Atomic(func(tx models.Store) (err error) {
+		defer recoverMalformedPostback(transactionResponse, &err)
+		followUps = nil
+
+		received, err := tx.Callbacks.SearchUUID(record.UUID)
+		if err != nil {
+			return err
+		}
+		record.Outcome = callbackOutcome(received, record.Status)
+		if _, err := tx.Callbacks.Insert(record); err != nil {
+			return err
+		}
+		if record.Outcome != constants.CallbackApplied {
+			return nil
+		}
+		// Copy the receiver so the unit of work keeps its configuration and request context
+		svc := *s
+		svc.Store = tx
+		svc.followUps = &followUps
+		return apply(&svc)
+	})
+	if err != nil {
+		logger.Error(s.context(), err)
+		return record.Outcome, err
+	}
+
+	switch record.Outcome {
+	case constants.CallbackDuplicate:
+		logger.Infof(s.context(), "Ignored repeated %s postback for transaction %s with status %d", record.Type, record.UUID, record.Status)
+	case constants.CallbackStale:
+		logger.Warnf(s.context(), "Ignored stale %s postback for transaction %s with status %d", record.Type, record.UUID, record.Status)
+	}
+
+	// Every follow-up is sent, the last error is returned
+	for _, request := range followUps {
+		if sendErr := request(s); sendErr != nil {
+			logger.Error(s.context(), sendErr)
+			err = sendErr
+		}
+	}
+	return record.Outcome, err
+}
+
+// observeCallback - count the postback by status and outcome, and the activity it brought to a final status
+func observeCallback(transactionResponse NodeServerModel, activityReference constants.ActivityReference, outcome constants.CallbackOutcome, err error) {
+	if err != nil {
+		metrics.Callbacks.Inc(transactionResponse.Status.String(), metrics.OutcomeError)
+		return
+	}
+	metrics.Callbacks.Inc(transactionResponse.Status.String(), outcome.String())
+	if outcome != constants.CallbackApplied || transactionResponse.Status < structs.Complete {
+		return
+	}
+	activityStatus := constants.ActivitySuccess
+	if transactionResponse.Status > structs.Complete {
+		activityStatus = activityFailureStatus(transactionResponse.Status)
+	}
+	metrics.Activities.Inc(string(activityReference), activityStatus.String())
+}
+
+// recoverMalformedPostback - the callbacks read the transaction events without checking their shape,
+// so a panic while applying a postback is reported as a malformed request instead of crashing the Oracle
+func recoverMalformedPostback(transactionResponse NodeServerModel, err *error) {
+	if r := recover(); r != nil {
+		log.Printf("Could not apply %s postback for activity %d: %v", transactionResponse.Type, transactionResponse.ParentID, r)
+		*err = errs.New(errs.Validation, "Malformed %s postback for activity %d", transactionResponse.Type, transactionResponse.ParentID)
+	}
+}
+
+// activityFailureStatus - map a failed Nodeserver transaction status to an activity status
+func activityFailureStatus(status structs.StatusIndex) constants.ActivityStatus {
+	switch status {
+	case structs.FailedTimeout:
+		return constants.ActivityTimeout
+	case structs.FailedGas:
+		return constants.ActivityGasError
+	case structs.FailedInitial:
+		return constants.ActivityInitialError
+	case structs.FailedReceipt:
+		return constants.ActivityReceiptError
+	}
+	return constants.ActivityPendingError
+}
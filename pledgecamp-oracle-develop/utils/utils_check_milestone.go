@@ -2,35 +2,39 @@ package utils
 
 import (
 	"database/sql"
-	"log"
-	"os"
+	"errors"
 	"strconv"
 	"time"
 
 	"github.com/imroc/req"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 // CheckMilestones
-func CheckMilestones(milestoneRequest RequestCheckMilestones) error {
+func (s *Service) CheckMilestones(milestoneRequest RequestCheckMilestones) error {
+	s = s.withFields(logger.Fields{"project_id": milestoneRequest.FkProjectId})
 	projectId := strconv.Itoa(milestoneRequest.FkProjectId)
 	activityReference := string(constants.CheckMilestone)
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/projects/" + projectId + "/callback/" + activityReference
-	nodeServerURL := "/projects/" + projectId + "/" + activityReference
+	oracleCallbackURL := s.Config.App.Domain + "/projects/" + projectId + "/callback/" + activityReference
 
-	projectActivity, err := models.SetProjectActivity(milestoneRequest.FkProjectId, constants.CheckMilestone)
+	// Get project information
+	project, err := s.Projects.FetchById(milestoneRequest.FkProjectId)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return errs.Store(err, "Could not find project %d", milestoneRequest.FkProjectId)
 	}
 
-	// Get project information
-	project, _ := models.ProjectFetchById(milestoneRequest.FkProjectId)
+	projectActivity, err := models.SetProjectActivity(s.ProjectActivities, milestoneRequest.FkProjectId, constants.CheckMilestone)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return err
 	}
+	s = s.withFields(logger.Fields{"activity_id": projectActivity.Id})
 
 	milestones := project.ProjectParameters["milestones"]
 	convertedMilestones := make([]int64, len(milestones.([]interface{})))
@@ -39,180 +43,177 @@ func CheckMilestones(milestoneRequest RequestCheckMilestones) error {
 	}
 
 	// Get parameters from the above structs
-	requestParameters := req.Param{
-		"transaction_type": activityReference,
-		"contract_address": project.ContractAddress,
-		"activity_id":      projectActivity.Id,
-		"url_callback":     oracleCallbackURL,
-	}
-
-	_, err = PostNodeServer(requestParameters, nodeServerURL)
+	_, err = s.NodeServer.CheckMilestone(s.context(), nodeserver.ProjectRequest{
+		ProjectId:       milestoneRequest.FkProjectId,
+		ContractAddress: project.ContractAddress,
+		Callback:        nodeserver.Callback{ActivityId: projectActivity.Id, URL: oracleCallbackURL},
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return err
 	}
 
 	return nil
 }
 
-func CheckMilestoneCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
+func (s *Service) CheckMilestoneCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
 
 	// Only process if Nodeserver postback response successful
-	log.Printf("Project status: %v", transactionResponse.Status)
+	logger.Infof(s.context(), "Project status: %v", transactionResponse.Status)
 
 	if transactionResponse.Status == structs.Complete {
 
-		// Update project status & completed activity
-		project, err := models.ProjectFetchById(projectActivity.ProjectId)
-		if err != nil {
-			log.Fatal(err)
-		}
-
 		// Update status depending on the milestone result event from Nodeserver
 		var milestoneResult bool
 		milestoneResultInterface := transactionResponse.TransactionEvents.([]interface{})
-		log.Println("Coming from nodeserver")
-		log.Println(milestoneResultInterface)
+		logger.Debug(s.context(), "Coming from nodeserver")
+		logger.Debug(s.context(), milestoneResultInterface)
 		for _, item := range milestoneResultInterface {
 			milestoneItem := item.([]interface{})
-			log.Println(item)
+			logger.Debug(s.context(), item)
 			milestoneResult, _ = milestoneItem[1].(bool)
-			log.Println(milestoneResult)
+			logger.Debug(s.context(), milestoneResult)
 		}
 
-		switch milestoneResult {
-		// If success_result == true then notify backend
-		case true:
+		var project Project
+		err := s.Atomic(func(tx models.Store) error {
+			// Update project status & completed activity
+			var err error
+			project, err = tx.Projects.FetchById(projectActivity.ProjectId)
+			if err != nil {
+				return errs.Store(err, "Could not find project %d", projectActivity.ProjectId)
+			}
 
 			// Update Activity status to success
 			projectActivity.Status = constants.ActivitySuccess
 			projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-			_, err := models.ProjectActivityUpdateFields(projectActivity)
+			_, err = tx.ProjectActivities.UpdateFields(projectActivity)
 			if err != nil {
-				log.Fatal(err)
+				return err
 			}
 
-			var withdrawRequest RequestReleaseFunds
-			withdrawRequest.TransactionType = string(constants.WithdrawFunds)
-			withdrawRequest.FkProjectId = project.Id
-			withdrawRequest.UserId = int(project.ProjectParameters["creator"].(float64))
-
-			// Handling to update next_activity_date
-			filled := false
-			milestones := project.ProjectParameters["milestones"]
-			convertedMilestones := make([]int64, len(milestones.([]interface{})))
-			for i := range milestones.([]interface{}) {
-				convertedMilestones[i] = int64(milestones.([]interface{})[i].(float64))
-			}
+			switch milestoneResult {
+			// If success_result == true then move on to the next milestone
+			case true:
 
-			// Loop through to find the next milestone
-			for _, milestone := range convertedMilestones {
-				if milestone > time.Now().Unix() && filled == false {
-					newProject := project
-					milestoneTime := time.Unix(milestone, 0)
-					newProject.NextActivityDate = milestoneTime
-					project, err = models.ProjectUpdateFields(newProject)
-					filled = true
-					if err != nil {
-						log.Fatal(err)
-						return err
-					}
+				// Handling to update next_activity_date
+				filled := false
+				milestones := project.ProjectParameters["milestones"]
+				convertedMilestones := make([]int64, len(milestones.([]interface{})))
+				for i := range milestones.([]interface{}) {
+					convertedMilestones[i] = int64(milestones.([]interface{})[i].(float64))
 				}
-			}
 
-			// If we have already hit the final milestone, mark as complete
-			if filled == false {
-				lastArrayItem := len(convertedMilestones) - 1
-				epochLastDate := convertedMilestones[lastArrayItem]
-				lastMilestoneDate := time.Unix(epochLastDate, 0)
-				if len(convertedMilestones) == 1 { // For cases where there is only 1 milestone
-					project.CompletedAt = time.Now()
-					project.Status = constants.ProjectMilestoneSuccess
-					project, err = models.ProjectUpdateFields(project)
-					if err != nil {
-						log.Fatal(err)
-						return err
+				// Loop through to find the next milestone
+				for _, milestone := range convertedMilestones {
+					if milestone > time.Now().Unix() && filled == false {
+						newProject := project
+						milestoneTime := time.Unix(milestone, 0)
+						newProject.NextActivityDate = milestoneTime
+						project, err = tx.Projects.UpdateFields(newProject)
+						filled = true
+						if err != nil {
+							return err
+						}
 					}
-				} else if lastMilestoneDate.Format("2020-08-31") == (project.NextActivityDate).Format("2020-08-31") { // For cases with multiple milestones
-					project.CompletedAt = time.Now()
-					project.Status = constants.ProjectMilestoneSuccess
-					project, err = models.ProjectUpdateFields(project)
-					if err != nil {
-						log.Fatal(err)
-						return err
+				}
+
+				// If we have already hit the final milestone, mark as complete
+				if filled == false {
+					lastArrayItem := len(convertedMilestones) - 1
+					epochLastDate := convertedMilestones[lastArrayItem]
+					lastMilestoneDate := time.Unix(epochLastDate, 0)
+					if len(convertedMilestones) == 1 { // For cases where there is only 1 milestone
+						project.CompletedAt = time.Now()
+						project.Status = constants.ProjectMilestoneSuccess
+						project, err = tx.Projects.UpdateFields(project)
+						if err != nil {
+							return err
+						}
+					} else if lastMilestoneDate.Format("2020-08-31") == (project.NextActivityDate).Format("2020-08-31") { // For cases with multiple milestones
+						project.CompletedAt = time.Now()
+						project.Status = constants.ProjectMilestoneSuccess
+						project, err = tx.Projects.UpdateFields(project)
+						if err != nil {
+							return err
+						}
+					} else {
+						return errors.New("Something went wrong with the milestone checking")
 					}
-				} else {
-					log.Fatal("Something went wrong with the milestone checking")
+				}
+
+			// If success_result == false then change project.Status => 2 (Milestone Failed)
+			case false:
+				project.Status = constants.ProjectMilestoneFailed
+				project, err = tx.Projects.UpdateFields(project)
+				if err != nil {
+					return err
 				}
 			}
 
+			// Queue the backend event with the changes
 			projectId := strconv.Itoa(project.Id)
 			backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.MilestoneRelease)
 			requestParameters := req.Param{
 				"event_type":       constants.MilestoneRelease,
 				"project_id":       project.Id,
 				"project_contract": project.ContractAddress,
-				"status":           true,
-			}
-			_, err = PostBackend(requestParameters, backendURL)
-			if err != nil {
-				log.Fatal(err)
+				"status":           milestoneResult,
 			}
+			return s.enqueueBackend(tx, projectEvents(project.Id), backendURL, requestParameters)
+		})
+		if err != nil {
+			logger.Error(s.context(), err)
+			return err
+		}
 
-		// If success_result == false then change project.Status => 2 (Milestone Failed)
-		case false:
-			// Update status of project
-			project.Status = constants.ProjectMilestoneFailed
-			project, err = models.ProjectUpdateFields(project)
-			if err != nil {
-				log.Fatal(err)
-				return err
-			}
+		// Release funds once the changes are committed
+		return s.afterCommit(func(s *Service) error {
+			return s.releaseMilestoneFunds(project, milestoneResult)
+		})
+	}
 
-			// Update Activity status to success
-			projectActivity.Status = constants.ActivitySuccess
-			projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-			_, err := models.ProjectActivityUpdateFields(projectActivity)
-			if err != nil {
-				log.Fatal(err)
-			}
+	return nil
 
-			backers := project.ProjectParameters["backers"]
-			convertedBackers := make([]int, len(backers.([]interface{})))
-			for i := range backers.([]interface{}) {
-				convertedBackers[i] = int(backers.([]interface{})[i].(float64))
-			}
+}
 
-			log.Println("Distributing refunds")
-			for _, backer := range convertedBackers {
-				var refundRequest RequestReleaseFunds
-				refundRequest.TransactionType = string(constants.RequestRefund)
-				refundRequest.FkProjectId = project.Id
-				refundRequest.UserId = backer
+// releaseMilestoneFunds - release the milestone funds to the creator, or refund every backer when the
+// milestone failed. The remaining backers are refunded when one of the requests fails
+func (s *Service) releaseMilestoneFunds(project Project, milestoneResult bool) error {
+	if milestoneResult {
+		var withdrawRequest RequestReleaseFunds
+		withdrawRequest.TransactionType = string(constants.WithdrawFunds)
+		withdrawRequest.FkProjectId = project.Id
+		withdrawRequest.UserId = int(project.ProjectParameters["creator"].(float64))
+
+		logger.Info(s.context(), "Releasing milestone funds")
+		err := s.ReleaseFunds(withdrawRequest)
+		if err != nil {
+			logger.Error(s.context(), err)
+			return err
+		}
+		return nil
+	}
 
-				err = ReleaseFunds(refundRequest)
-				if err != nil {
-					log.Fatal(err)
-				}
-			}
+	backers := project.ProjectParameters["backers"]
+	convertedBackers := make([]int, len(backers.([]interface{})))
+	for i := range backers.([]interface{}) {
+		convertedBackers[i] = int(backers.([]interface{})[i].(float64))
+	}
 
-			projectId := strconv.Itoa(project.Id)
-			backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.MilestoneRelease)
-			requestParameters := req.Param{
-				"event_type":       constants.MilestoneRelease,
-				"project_id":       project.Id,
-				"project_contract": project.ContractAddress,
-				"status":           false,
-			}
-			_, err = PostBackend(requestParameters, backendURL)
-			if err != nil {
-				log.Fatal(err)
-				return err
-			}
+	logger.Info(s.context(), "Distributing refunds")
+	var refundErr error
+	for _, backer := range convertedBackers {
+		var refundRequest RequestReleaseFunds
+		refundRequest.TransactionType = string(constants.RequestRefund)
+		refundRequest.FkProjectId = project.Id
+		refundRequest.UserId = backer
 
+		err := s.ReleaseFunds(refundRequest)
+		if err != nil {
+			logger.Error(s.context(), err)
+			refundErr = err
 		}
 	}
-
-	return nil
-
+	return refundErr
 }
@@ -3,47 +3,49 @@ package utils
 import (
 	"database/sql"
 	"errors"
-	"log"
-	"os"
 	"strconv"
 
-	"github.com/imroc/req"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 // CommitModerationVotes
-func CommitModerationVotes(commitRequest RequestCommitModerationVotes) error {
+func (s *Service) CommitModerationVotes(commitRequest RequestCommitModerationVotes) error {
+	s = s.withFields(logger.Fields{"project_id": commitRequest.FkProjectId})
 	projectId := strconv.Itoa(commitRequest.FkProjectId)
 	activityReference := string(constants.CommitFinalVotes)
 
-	nodeServerURL := "/moderator/projects/" + projectId + "/" + activityReference
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/projects/" + projectId + "/callback/" + activityReference
+	oracleCallbackURL := s.Config.App.Domain + "/projects/" + projectId + "/callback/" + activityReference
 
-	// Create project activity for tracking purposes
-	projectActivity, err := models.SetProjectActivity(commitRequest.FkProjectId, constants.CommitFinalVotes)
+	// Get project information
+	project, err := s.Projects.FetchById(commitRequest.FkProjectId)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return errs.Store(err, "Could not find project %d", commitRequest.FkProjectId)
 	}
 
-	// Get project information
-	project, _ := models.ProjectFetchById(commitRequest.FkProjectId)
+	// Create project activity for tracking purposes
+	projectActivity, err := models.SetProjectActivity(s.ProjectActivities, commitRequest.FkProjectId, constants.CommitFinalVotes)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return err
 	}
+	s = s.withFields(logger.Fields{"activity_id": projectActivity.Id})
 
 	if len(commitRequest.EncryptedVotes) >= 7 {
 
-		projectVotes, _ := models.VoteSearchProjectId(commitRequest.FkProjectId)
+		projectVotes, _ := s.Votes.SearchProjectId(commitRequest.FkProjectId)
 		decryptionKeys := make([]string, len(projectVotes))
 		finalVotes := make([]bool, len(projectVotes))
 		userIds := make([]int, len(projectVotes))
 
 		for i := 0; i < len(projectVotes); i++ {
 			currentVote := projectVotes[i]
-			log.Println(currentVote)
+			logger.Debug(s.context(), currentVote)
 			if int64(currentVote.VoteParameters["vote_type"].(float64)) == 1 {
 				finalVotes[i] = currentVote.VoteParameters["vote"].(bool)
 				decryptionKeys[i] = currentVote.VoteParameters["decryption_key"].(string)
@@ -51,21 +53,16 @@ func CommitModerationVotes(commitRequest RequestCommitModerationVotes) error {
 			}
 		}
 
-		// Get parameters from the above structs
-		requestParameters := req.Param{
-			"transaction_type": activityReference,
-			"votes":            finalVotes,
-			"decryption_keys":  decryptionKeys,
-			"user_ids":         userIds,
-			"contract_address": project.ContractAddress,
-			"project_id":       commitRequest.FkProjectId,
-			"activity_id":      projectActivity.Id,
-			"url_callback":     oracleCallbackURL,
-		}
-
-		_, err = PostNodeServer(requestParameters, nodeServerURL)
+		_, err = s.NodeServer.CommitModerationVotes(s.context(), nodeserver.CommitModerationVotesRequest{
+			ProjectId:       commitRequest.FkProjectId,
+			ContractAddress: project.ContractAddress,
+			Votes:           finalVotes,
+			DecryptionKeys:  decryptionKeys,
+			UserIds:         userIds,
+			Callback:        nodeserver.Callback{ActivityId: projectActivity.Id, URL: oracleCallbackURL},
+		})
 		if err != nil {
-			log.Fatal(err)
+			logger.Error(s.context(), err)
 			return err
 		}
 
@@ -76,39 +73,47 @@ func CommitModerationVotes(commitRequest RequestCommitModerationVotes) error {
 	return err
 }
 
-func CommitModerationVotesCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
+func (s *Service) CommitModerationVotesCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
 	//transactionStatus := string(transactionResponse.TransactionStatus)
 
 	// Only process if Nodeserver postback response successful
 	if transactionResponse.Status == structs.Complete {
 
-		// Update Activity status to success
-		projectActivity.Status = constants.ActivitySuccess
-		projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.ProjectActivityUpdateFields(projectActivity)
-		if err != nil {
-			log.Fatal(err)
-		}
+		var project Project
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity status to success
+			projectActivity.Status = constants.ActivitySuccess
+			projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.ProjectActivities.UpdateFields(projectActivity)
+			if err != nil {
+				return err
+			}
 
-		// Update project status & completed activity
-		project, err := models.ProjectFetchById(projectActivity.ProjectId)
-		if err != nil {
-			log.Fatal(err)
-		}
-		project.Status = constants.ProjectReadyToCancel
-		project.ActivitiesCompleted = append(project.ActivitiesCompleted, string(constants.CommitFinalVotes))
-		project, err = models.ProjectUpdateFields(project)
+			// Update project status & completed activity
+			project, err = tx.Projects.FetchById(projectActivity.ProjectId)
+			if err != nil {
+				return errs.Store(err, "Could not find project %d", projectActivity.ProjectId)
+			}
+			project.Status = constants.ProjectReadyToCancel
+			project.ActivitiesCompleted = append(project.ActivitiesCompleted, string(constants.CommitFinalVotes))
+			project, err = tx.Projects.UpdateFields(project)
+			return err
+		})
 		if err != nil {
-			log.Fatal(err)
+			logger.Error(s.context(), err)
+			return err
 		}
 
-		// Create request and initiate CancelProject()
+		// Create request and initiate CancelProject() once the changes are committed
 		var cpReq RequestCancelProject
 		cpReq.FkProjectId = project.Id
-		err = CancelProject(cpReq)
-		if err != nil {
-			log.Fatal(err)
-		}
+		return s.afterCommit(func(s *Service) error {
+			err := s.CancelProject(cpReq)
+			if err != nil {
+				logger.Error(s.context(), err)
+			}
+			return err
+		})
 	}
 
 	return nil
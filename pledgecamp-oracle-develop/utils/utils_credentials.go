@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/structs"
+)
+
+type Credential = models.Credential
+type RequestCreateCredential = structs.RequestCreateCredential
+
+// HashToken - form in which credential tokens are stored and looked up
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// newToken - random credential token
+func newToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// credentialRouteGroups - route groups granted to a new credential, every group of the role when none are requested
+func credentialRouteGroups(role constants.Role, requested []string) (pq.StringArray, error) {
+	permitted, ok := constants.RoleRouteGroups[role]
+	if !ok {
+		return nil, errs.New(errs.Validation, "Unknown role %s", role)
+	}
+	if len(requested) == 0 {
+		groups := pq.StringArray{}
+		for _, group := range permitted {
+			groups = append(groups, string(group))
+		}
+		return groups, nil
+	}
+
+	groups := pq.StringArray{}
+	for _, group := range requested {
+		allowed := false
+		for _, permittedGroup := range permitted {
+			allowed = allowed || group == string(permittedGroup)
+		}
+		if !allowed {
+			return nil, errs.New(errs.Validation, "Role %s cannot be granted route group %s", role, group)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// CreateCredential - add a named credential and return its token. The token is not stored and cannot be shown again
+func (s *Service) CreateCredential(request RequestCreateCredential) (Credential, string, error) {
+	role := constants.Role(request.Role)
+	routeGroups, err := credentialRouteGroups(role, request.RouteGroups)
+	if err != nil {
+		return Credential{}, "", err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return Credential{}, "", errs.Wrap(errs.Internal, err, "Could not generate a token")
+	}
+
+	credential, err := s.Credentials.Insert(Credential{
+		Name:        request.Name,
+		TokenHash:   HashToken(token),
+		Role:        role,
+		RouteGroups: routeGroups,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return credential, "", errs.Wrap(errs.Conflict, err, "Could not create credential %s, the name may already be in use", request.Name)
+	}
+	return credential, token, nil
+}
+
+// RevokeCredential - stop accepting the token of a credential
+func (s *Service) RevokeCredential(credentialId int) (Credential, error) {
+	credential, err := s.Credentials.FetchById(credentialId)
+	if err != nil {
+		return credential, errs.Store(err, "Could not find credential %d", credentialId)
+	}
+	if credential.RevokedAt.Valid {
+		return credential, errs.New(errs.Conflict, "Credential %d is already revoked", credentialId)
+	}
+
+	credential.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	credential, err = s.Credentials.UpdateFields(credential)
+	if err != nil {
+		return credential, errs.Store(err, "Could not revoke credential %d", credentialId)
+	}
+	return credential, nil
+}
+
+// ListCredentials - every credential, including revoked ones
+func (s *Service) ListCredentials() ([]Credential, error) {
+	credentials, err := s.Credentials.FetchAll()
+	if err != nil {
+		return credentials, errs.Store(err, "Could not get credentials")
+	}
+	return credentials, nil
+}
+
+// Authenticate - credential a token belongs to, unauthorized when it is unknown or revoked
+func (s *Service) Authenticate(token string) (Credential, error) {
+	if token == "" {
+		return Credential{}, errs.New(errs.Unauthorized, "Missing access token")
+	}
+	credential, err := s.Credentials.FetchActiveByTokenHash(HashToken(token))
+	if err != nil {
+		if errs.Is(err, errs.NotFound) {
+			return credential, errs.New(errs.Unauthorized, "Invalid access token")
+		}
+		return credential, errs.Store(err, "Could not check access token")
+	}
+	return credential, nil
+}
@@ -1,47 +1,36 @@
 package utils
 
 import (
-	"log"
 	"strconv"
 
 	"github.com/imroc/req"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 )
 
 // CsGains()
-func CsGains(gainsRequest RequestCsGains) (int, error) {
+func (s *Service) CsGains(gainsRequest RequestCsGains) (int, error) {
+	s = s.withFields(logger.Fields{"user_id": gainsRequest.UserId})
 	userId := strconv.Itoa(gainsRequest.UserId)
-	activityReference := string(constants.GetGains)
 
-	nodeServerURL := "/manager/cs/" + userId + "/" + activityReference
-
-	// Send request to get gains to Nodeserver
-	requestParameters := req.Param{
-		"user_id": gainsRequest.UserId,
-	}
-
-	resp, err := GetNodeServer(requestParameters, nodeServerURL)
+	// Request the gains from Nodeserver
+	responseValue, err := s.NodeServer.GetGains(s.context(), gainsRequest.UserId)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return 0, err
 	}
 
-	responseValue, err := strconv.Atoi(resp.String())
-	if err != nil {
-		return responseValue, err
-	}
-
-	// Send response back to backend if activities required are completed
+	// Queue the result for the backend
 	backendURL := "/events/blockchain/cs/" + userId + "/" + string(constants.GetGainsEvent) + "/"
-	requestParameters = req.Param{
+	requestParameters := req.Param{
 		"event_type": constants.GetGainsEvent,
 		"user_id":    gainsRequest.UserId,
 		"status":     true,
 		"gains":      responseValue,
 	}
-	_, err = PostBackend(requestParameters, backendURL)
+	err = s.enqueueBackend(s.Store, csEvents(gainsRequest.UserId), backendURL, requestParameters)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return responseValue, err
 	}
 
@@ -1,28 +1,33 @@
 package utils
 
 import (
-	"log"
-
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
 )
 
 // CsGetState() - Get CS activity related to a user
-func CsGetState(csRequest RequestCsState) (CsStateResponse, error) {
+func (s *Service) CsGetState(csRequest RequestCsState) (CsStateResponse, error) {
+	s = s.withFields(logger.Fields{"user_id": csRequest.UserId})
 
 	var csState CsStateResponse
 	csState.UserId = csRequest.UserId
 
 	runningTotal := 0
 
-	csList, err := models.GetCSByUserId(csState.UserId)
+	csList, err := s.CampShares.GetByUserId(csState.UserId)
 	if err != nil {
-		log.Println(err)
+		logger.Error(s.context(), err)
+		return csState, err
 	}
 
 	var activitiesList []models.CSActivity
 	for _, cs := range csList {
 		runningTotal += cs.BalanceMovement
-		partialList, _ := models.CSActivitySearchCsID(cs.CSId)
+		partialList, err := s.CSActivities.SearchCsID(cs.CSId)
+		if err != nil {
+			logger.Error(s.context(), err)
+			return csState, err
+		}
 		activitiesList = append(activitiesList, partialList...)
 	}
 
@@ -31,4 +36,3 @@ func CsGetState(csRequest RequestCsState) (CsStateResponse, error) {
 
 	return csState, nil
 }
-
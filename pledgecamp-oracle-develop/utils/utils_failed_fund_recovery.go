@@ -2,108 +2,114 @@ package utils
 
 import (
 	"database/sql"
-	"log"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/imroc/req"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 // FailedFundRecovery
-func FailedFundRecovery(recoveryRequest RequestFailedFundRecovery) error {
+func (s *Service) FailedFundRecovery(recoveryRequest RequestFailedFundRecovery) error {
+	s = s.withFields(logger.Fields{"project_id": recoveryRequest.FkProjectId})
 	projectId := strconv.Itoa(recoveryRequest.FkProjectId)
 	activityReference := string(constants.FailedFundRecovery)
 
-	nodeServerURL := "/projects/" + projectId + "/" + activityReference
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/projects/" + projectId + "/callback/" + activityReference
+	oracleCallbackURL := s.Config.App.Domain + "/projects/" + projectId + "/callback/" + activityReference
 
 	// Get project information
-	project, _ := models.ProjectFetchById(recoveryRequest.FkProjectId)
+	project, err := s.Projects.FetchById(recoveryRequest.FkProjectId)
 	if err != nil {
-		log.Fatal(err)
-		return err
+		logger.Error(s.context(), err)
+		return errs.Store(err, "Could not find project %d", recoveryRequest.FkProjectId)
 	}
 
 	// Create project activity for tracking purposes
-	projectActivity, err := models.SetProjectActivity(recoveryRequest.FkProjectId, constants.FailedFundRecovery)
+	projectActivity, err := models.SetProjectActivity(s.ProjectActivities, recoveryRequest.FkProjectId, constants.FailedFundRecovery)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return err
 	}
+	s = s.withFields(logger.Fields{"activity_id": projectActivity.Id})
 
 	// Get parameters from the above structs
-	requestParameters := req.Param{
-		"transaction_type": activityReference,
-		"contract_address": project.ContractAddress,
-		"activity_id":      projectActivity.Id,
-		"url_callback":     oracleCallbackURL,
-	}
-
-	_, err = PostNodeServer(requestParameters, nodeServerURL)
+	_, err = s.NodeServer.FailedFundRecovery(s.context(), nodeserver.ProjectRequest{
+		ProjectId:       recoveryRequest.FkProjectId,
+		ContractAddress: project.ContractAddress,
+		Callback:        nodeserver.Callback{ActivityId: projectActivity.Id, URL: oracleCallbackURL},
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return err
 	}
 
 	return nil
 }
 
-func FailedFundRecoveryCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
+func (s *Service) FailedFundRecoveryCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
 
 	// Only process if Nodeserver postback response successful
-	log.Printf("Project status: %v", transactionResponse.Status)
+	logger.Infof(s.context(), "Project status: %v", transactionResponse.Status)
 
 	if transactionResponse.Status == structs.Complete {
 
 		// Update funds recovered amount from Nodeserver
 		fundsRecovered := 0
 		fundsInterface := transactionResponse.TransactionEvents.([]interface{})
-		log.Println("Funds recovered result")
-		log.Println(fundsInterface)
+		logger.Debug(s.context(), "Funds recovered result")
+		logger.Debug(s.context(), fundsInterface)
 
 		for _, funds := range fundsInterface {
 			fundItem := funds.([]interface{})
-			log.Println(funds)
+			logger.Debug(s.context(), funds)
 			fundsRecovered, _ = strconv.Atoi(fundItem[0].(string))
-			log.Println(fundsRecovered)
+			logger.Debug(s.context(), fundsRecovered)
 		}
 
-		// Update Activity status to success
-		projectActivity.Status = constants.ActivitySuccess
-		projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.ProjectActivityUpdateFields(projectActivity)
-
-		// Update project status, created contract address, & completed activity
-		project, err := models.ProjectFetchById(projectActivity.ProjectId)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Reset NextActivityDate fields for projects that have had their funds recovered
-		project.NextActivityDate, _ = time.Parse("0001-01-01 00:00:00", "2020-08-31 18:27:18")
-		project.Status = constants.ProjectFundsRecovered
-
-		project, err = models.ProjectUpdateFields(project)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Send response back to backend if activities required are completed
-		projectId := strconv.Itoa(project.Id)
-		backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.FailedFundRecoveryEvent)
-		requestParameters := req.Param{
-			"event_type":       constants.FailedFundRecoveryEvent,
-			"project_id":       project.Id,
-			"project_contract": project.ContractAddress,
-			"status":           true,
-			"funds_released":   fundsRecovered,
-		}
-		_, err = PostBackend(requestParameters, backendURL)
+		var project Project
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity status to success
+			projectActivity.Status = constants.ActivitySuccess
+			projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.ProjectActivities.UpdateFields(projectActivity)
+			if err != nil {
+				return err
+			}
+
+			// Update project status, created contract address, & completed activity
+			project, err = tx.Projects.FetchById(projectActivity.ProjectId)
+			if err != nil {
+				return errs.Store(err, "Could not find project %d", projectActivity.ProjectId)
+			}
+
+			// Reset NextActivityDate fields for projects that have had their funds recovered
+			project.NextActivityDate, _ = time.Parse("0001-01-01 00:00:00", "2020-08-31 18:27:18")
+			project.Status = constants.ProjectFundsRecovered
+
+			project, err = tx.Projects.UpdateFields(project)
+			if err != nil {
+				return err
+			}
+
+			// Queue the backend event with the changes
+			projectId := strconv.Itoa(project.Id)
+			backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.FailedFundRecoveryEvent)
+			requestParameters := req.Param{
+				"event_type":       constants.FailedFundRecoveryEvent,
+				"project_id":       project.Id,
+				"project_contract": project.ContractAddress,
+				"status":           true,
+				"funds_released":   fundsRecovered,
+			}
+			return s.enqueueBackend(tx, projectEvents(project.Id), backendURL, requestParameters)
+		})
 		if err != nil {
-			log.Fatal(err)
+			logger.Error(s.context(), err)
 			return err
 		}
 
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/connect"
+)
+
+// Readiness check statuses
+const (
+	CheckOk      = "ok"
+	CheckFailing = "failing"
+	CheckSkipped = "skipped"
+)
+
+// Check - outcome of the readiness check of one dependency
+type Check struct {
+	Status    string      `json:"status"`
+	Error     string      `json:"error,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+	LatencyMs int64       `json:"latency_ms"`
+}
+
+// Readiness - checks of every dependency, Ready when none of them is failing
+type Readiness struct {
+	Ready  bool             `json:"ready"`
+	Checks map[string]Check `json:"checks"`
+}
+
+// checkSchedulers - every started job has beaten recently enough
+func checkSchedulers(now time.Time) (interface{}, error) {
+	heartbeatsMu.Lock()
+	defer heartbeatsMu.Unlock()
+	if len(heartbeats) == 0 {
+		return nil, errors.New("No scheduler job has been started")
+	}
+	jobs := map[string]heartbeat{}
+	var err error
+	for job, h := range heartbeats {
+		jobs[job] = h
+		if now.Sub(h.BeatAt) > missedBeats*h.interval+heartbeatGrace {
+			err = fmt.Errorf("Scheduler job %s has not run since %s", job, h.BeatAt.UTC().Format(time.RFC3339))
+		}
+	}
+	return jobs, err
+}
+
+// checkDatabase - the shared pool reaches the database
+func checkDatabase(ctx context.Context) (bool, interface{}, error) {
+	open, err := connect.PingDatabase(ctx)
+	if !open {
+		return false, nil, nil
+	}
+	stats, _ := connect.DatabaseStats()
+	return true, stats, err
+}
+
+// checkMigrations - the database has every migration built into the binary applied and none of them failed
+func checkMigrations(ctx context.Context) (bool, interface{}, error) {
+	state, open, err := connect.MigrationVersion(ctx)
+	if !open {
+		return false, nil, nil
+	}
+	if err != nil {
+		return true, nil, err
+	}
+	expected, err := connect.LatestMigration(connect.Driver())
+	if err != nil {
+		return true, state, err
+	}
+	details := map[string]interface{}{"version": state.Version, "dirty": state.Dirty, "expected": expected}
+	if state.Dirty {
+		return true, details, fmt.Errorf("Migration %d did not complete", state.Version)
+	}
+	if state.Version != expected {
+		return true, details, fmt.Errorf("Database is at migration %d, expected %d", state.Version, expected)
+	}
+	return true, details, nil
+}
+
+// checkBackend - the host of the backend URL resolves
+func checkBackend(ctx context.Context, rawURL string) (interface{}, error) {
+	backendURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if backendURL.Hostname() == "" {
+		return nil, errors.New("BACKEND_URL has no host")
+	}
+	addresses, err := net.DefaultResolver.LookupHost(ctx, backendURL.Hostname())
+	return map[string]interface{}{"host": backendURL.Hostname(), "addresses": addresses}, err
+}
+
+// Readiness - check the database, the migrations, Nodeserver, the backend DNS and the scheduler jobs at once,
+// each bounded by timeout. Database checks are skipped when the Oracle does not use a database
+func (s *Service) Readiness(timeout time.Duration) Readiness {
+	ctx, cancel := context.WithTimeout(s.context(), timeout)
+	defer cancel()
+
+	checks := map[string]func() (bool, interface{}, error){
+		"database":   func() (bool, interface{}, error) { return checkDatabase(ctx) },
+		"migrations": func() (bool, interface{}, error) { return checkMigrations(ctx) },
+		"nodeserver": func() (bool, interface{}, error) { return true, nil, s.NodeServer.Monitor(ctx) },
+		"backend": func() (bool, interface{}, error) {
+			details, err := checkBackend(ctx, s.Config.Backend.URL)
+			return true, details, err
+		},
+		"scheduler": func() (bool, interface{}, error) {
+			details, err := checkSchedulers(time.Now())
+			return true, details, err
+		},
+	}
+
+	readiness := Readiness{Ready: true, Checks: map[string]Check{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, run := range checks {
+		wg.Add(1)
+		go func(name string, run func() (bool, interface{}, error)) {
+			defer wg.Done()
+			start := time.Now()
+			checked, details, err := run()
+			check := Check{Status: CheckOk, Details: details, LatencyMs: time.Since(start).Milliseconds()}
+			if !checked {
+				check.Status = CheckSkipped
+			} else if err != nil {
+				check.Status = CheckFailing
+				check.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			readiness.Checks[name] = check
+			if check.Status == CheckFailing {
+				readiness.Ready = false
+			}
+		}(name, run)
+	}
+	wg.Wait()
+	return readiness
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/models"
+)
+
+type IdempotencyRecord = models.IdempotencyRecord
+
+// idempotencyRecordKey - record key of the Idempotency-Key sent by caller, so callers cannot see or take
+// each other's keys. The separator cannot appear in a header value
+func idempotencyRecordKey(caller string, key string) string {
+	return caller + "\n" + key
+}
+
+// ClaimIdempotencyKey - record that the request of caller identified by key and fingerprint is being processed.
+// When the key was already used by the same request its record is returned with replay set so the stored
+// response can be sent again. Reusing a key for a different request is a validation error and repeating a
+// request that is still being processed is a conflict. Keys recorded more than ttl ago are claimed again, as
+// are the keys of a request that did not renew its claim within lease
+func (s *Service) ClaimIdempotencyKey(caller string, key string, fingerprint string, ttl time.Duration, lease time.Duration) (IdempotencyRecord, bool, error) {
+	token, err := newToken()
+	if err != nil {
+		return IdempotencyRecord{}, false, errs.Wrap(errs.Internal, err, "Could not claim idempotency key %s", key)
+	}
+	now := time.Now().UTC()
+	record := IdempotencyRecord{
+		Key:         idempotencyRecordKey(caller, key),
+		Fingerprint: fingerprint,
+		ClaimToken:  token,
+		CreatedAt:   now,
+		ModifiedAt:  now,
+	}
+	if _, err := s.Idempotency.Insert(record); err == nil {
+		return record, false, nil
+	}
+
+	// The key is taken, find out by which request
+	existing, err := s.Idempotency.FetchByKey(record.Key)
+	if err != nil {
+		return record, false, errs.Wrap(errs.Internal, err, "Could not claim idempotency key %s", key)
+	}
+	expired := existing.CreatedAt.Before(now.Add(-ttl))
+	if !expired && existing.Fingerprint != fingerprint {
+		return existing, false, errs.New(errs.Validation, "Idempotency-Key %s was already used for a different request", key)
+	}
+	if !expired && existing.StatusCode != 0 {
+		return existing, true, nil
+	}
+	if expired || existing.ModifiedAt.Before(now.Add(-lease)) {
+		claimed, err := s.Idempotency.Reclaim(record, now.Add(-ttl), now.Add(-lease))
+		if err != nil {
+			return record, false, errs.Store(err, "Could not claim idempotency key %s", key)
+		}
+		if claimed {
+			logger.Infof(s.context(), "Idempotency-Key %s claimed again", key)
+			return record, false, nil
+		}
+	}
+	return existing, false, errs.New(errs.Conflict, "A request with Idempotency-Key %s is still being processed", key)
+}
+
+// lostIdempotencyKey - error of a request whose key was taken over by a repeat after its lease ran out
+func lostIdempotencyKey(record IdempotencyRecord) error {
+	return errs.New(errs.Conflict, "Idempotency key %q was taken over by a repeat of the request", record.Key)
+}
+
+// RenewIdempotencyKey - extend the lease of a request that is still being processed. Fails with a conflict
+// once a repeat took the key over
+func (s *Service) RenewIdempotencyKey(record IdempotencyRecord) error {
+	record.ModifiedAt = time.Now().UTC()
+	renewed, err := s.Idempotency.Renew(record)
+	if err != nil {
+		return errs.Store(err, "Could not renew idempotency key %q", record.Key)
+	}
+	if !renewed {
+		return lostIdempotencyKey(record)
+	}
+	return nil
+}
+
+// CompleteIdempotencyKey - store the response sent for the request that claimed the key. Rejected requests are
+// kept as well since a repeat gets the same answer. A server error releases the key instead, so a repeat
+// after an outage runs the request again. A request whose key was taken over leaves the repeat's claim alone
+func (s *Service) CompleteIdempotencyKey(record IdempotencyRecord, statusCode int, body string) error {
+	if statusCode >= http.StatusInternalServerError {
+		return s.ReleaseIdempotencyKey(record)
+	}
+	record.StatusCode = statusCode
+	record.ResponseBody = body
+	record.ModifiedAt = time.Now().UTC()
+	_, updated, err := s.Idempotency.UpdateFields(record)
+	if err != nil {
+		return errs.Store(err, "Could not store the response for idempotency key %q", record.Key)
+	}
+	if !updated {
+		return lostIdempotencyKey(record)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey - forget the key of a request that failed on the server side
+func (s *Service) ReleaseIdempotencyKey(record IdempotencyRecord) error {
+	released, err := s.Idempotency.Delete(record)
+	if err != nil {
+		return errs.Store(err, "Could not release idempotency key %q", record.Key)
+	}
+	if !released {
+		return lostIdempotencyKey(record)
+	}
+	return nil
+}
+
+// idempotencyJob - forget the idempotency keys recorded more than IdempotencyTTL ago
+func (s *Service) idempotencyJob(ctx context.Context) (int, error) {
+	if err := s.Idempotency.DeleteBefore(time.Now().UTC().Add(-s.Config.App.IdempotencyTTL)); err != nil {
+		logger.Error(s.context(), "Could not expire idempotency keys: ", err)
+		return 0, errs.Store(err, "Could not expire idempotency keys")
+	}
+	return 0, nil
+}
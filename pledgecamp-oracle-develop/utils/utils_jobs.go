@@ -0,0 +1,264 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/cron"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/models"
+)
+
+type Job = models.Job
+
+// TableJobs - jobs the job worker runs from the jobs table, in the order it runs the due ones
+var TableJobs = []string{jobMilestone, jobRecovery, jobCancellation, jobInterest, jobReconciliation, jobIdempotency}
+
+// jobSchedules - configured schedule of each job of the jobs table
+func (s *Service) jobSchedules() map[string]cron.Schedule {
+	return map[string]cron.Schedule{
+		jobMilestone:      s.Config.Jobs.Milestone,
+		jobRecovery:       s.Config.Jobs.Recovery,
+		jobCancellation:   s.Config.Jobs.Cancellation,
+		jobInterest:       s.Config.Jobs.Interest,
+		jobReconciliation: s.Config.Jobs.Reconciliation,
+		jobIdempotency:    s.Config.Jobs.Idempotency,
+	}
+}
+
+// jobBackoff - delay before retrying a job after its attempts failed in a row, doubling from RetryBaseDelay
+// up to RetryMaxDelay
+func (s *Service) jobBackoff(attempts int) time.Duration {
+	delay := s.Config.Jobs.RetryBaseDelay
+	for i := 1; i < attempts && delay < s.Config.Jobs.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > s.Config.Jobs.RetryMaxDelay {
+		delay = s.Config.Jobs.RetryMaxDelay
+	}
+	return delay
+}
+
+// RegisterJobs - add the jobs missing from the jobs table, due now, and give the others the configured
+// schedule. A job whose schedule changed is due now as well
+func (s *Service) RegisterJobs() error {
+	now := time.Now()
+	schedules := s.jobSchedules()
+	for _, name := range TableJobs {
+		_, err := s.Jobs.Register(Job{
+			Name:       name,
+			Schedule:   schedules[name].String(),
+			NextRunAt:  now,
+			CreatedAt:  now,
+			ModifiedAt: now,
+		})
+		if err != nil {
+			return errs.Store(err, "Could not register job %s", name)
+		}
+	}
+	return nil
+}
+
+// runJobs - claim and run each due job of the jobs table, the last error is returned once every due job ran.
+// Jobs left when ctx is cancelled run on the next poll
+func (s *Service) runJobs(ctx context.Context) (failed error) {
+	runs := s.schedulerJobs()
+	for _, name := range TableJobs {
+		if ctx.Err() != nil {
+			return failed
+		}
+		job, claimed, err := s.Jobs.Claim(name, s.Config.Leader.InstanceID, time.Now(), s.Config.Jobs.LeaseTTL)
+		if err != nil {
+			logger.Error(s.context(), "Could not claim job ", name, ": ", err)
+			failed = err
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if err := s.runClaimedJob(ctx, job, runs[name]); err != nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+// runClaimedJob - run a job claimed by this instance and record its outcome. The job is due again on its
+// schedule, or sooner after a failure to be retried
+func (s *Service) runClaimedJob(ctx context.Context, job Job, run jobRun) error {
+	start := time.Now()
+	var processed int
+	var err error
+	runJob(job.Name, func() error {
+		processed, err = run(ctx)
+		return err
+	})
+	finished := time.Now()
+
+	job.LastProcessed = processed
+	job.LastDurationMs = int(finished.Sub(start) / time.Millisecond)
+	job.ModifiedAt = finished
+	job.NextRunAt = s.jobSchedules()[job.Name].Next(finished)
+	if err == nil {
+		job.Attempts = 0
+		job.LastSuccessAt = sql.NullTime{Time: finished, Valid: true}
+		job.LastError = sql.NullString{}
+	} else {
+		logger.Errorf(s.context(), "Job %s failed on attempt %d: %v", job.Name, job.Attempts, err)
+		job.LastError = sql.NullString{String: err.Error(), Valid: true}
+		retryAt := finished.Add(s.jobBackoff(job.Attempts))
+		if job.NextRunAt.IsZero() || retryAt.Before(job.NextRunAt) {
+			job.NextRunAt = retryAt
+		}
+	}
+
+	if _, finishErr := s.Jobs.Finish(job); finishErr != nil {
+		logger.Error(s.context(), "Could not record the run of job ", job.Name, ": ", finishErr)
+		return finishErr
+	}
+	return err
+}
+
+// StartJobWorker - run the due jobs of the jobs table every PollInterval until StopJobs
+func (s *Service) StartJobWorker() {
+	startJob(jobWorker, s.Config.Jobs.PollInterval, s.runJobs)
+}
+
+// ListJobs - every job of the jobs table
+func (s *Service) ListJobs() ([]Job, error) {
+	jobs, err := s.Jobs.FetchAll()
+	if err != nil {
+		return jobs, errs.Store(err, "Could not get jobs")
+	}
+	return jobs, nil
+}
+
+// fetchJob - job of the jobs table called name
+func (s *Service) fetchJob(name string) (Job, error) {
+	if _, ok := s.jobSchedules()[name]; !ok {
+		return Job{}, errs.New(errs.NotFound, "Unknown job %q, expected one of %s", name, strings.Join(TableJobs, ", "))
+	}
+	job, err := s.Jobs.FetchByName(name)
+	if err != nil {
+		return job, errs.Store(err, "Could not find job %s", name)
+	}
+	return job, nil
+}
+
+// PauseJob - stop running the job until it is resumed. A run in progress finishes
+func (s *Service) PauseJob(name string) (Job, error) {
+	job, err := s.fetchJob(name)
+	if err != nil {
+		return job, err
+	}
+	job.Paused = true
+	job.ModifiedAt = time.Now()
+	job, err = s.Jobs.UpdateFields(job)
+	if err != nil {
+		return job, errs.Store(err, "Could not pause job %s", name)
+	}
+	logger.Infof(s.context(), "Job %s paused", name)
+	return job, nil
+}
+
+// ResumeJob - run a paused job on its schedule again. A job that was due while paused runs on the next poll
+func (s *Service) ResumeJob(name string) (Job, error) {
+	job, err := s.fetchJob(name)
+	if err != nil {
+		return job, err
+	}
+	job.Paused = false
+	job.ModifiedAt = time.Now()
+	job, err = s.Jobs.UpdateFields(job)
+	if err != nil {
+		return job, errs.Store(err, "Could not resume job %s", name)
+	}
+	logger.Infof(s.context(), "Job %s resumed", name)
+	return job, nil
+}
+
+// TriggerJob - make the job due now so the worker runs it on its next poll, paused jobs included once
+// they are resumed. A job that is running cannot be triggered
+func (s *Service) TriggerJob(name string) (Job, error) {
+	job, err := s.fetchJob(name)
+	if err != nil {
+		return job, err
+	}
+	now := time.Now()
+	if job.Running(now) {
+		return job, errs.New(errs.Conflict, "Job %s is running on %s", name, job.LeaseHolder.String)
+	}
+	job.NextRunAt = now
+	job.ModifiedAt = now
+	job, err = s.Jobs.UpdateFields(job)
+	if err != nil {
+		return job, errs.Store(err, "Could not trigger job %s", name)
+	}
+	logger.Infof(s.context(), "Job %s triggered", name)
+	return job, nil
+}
+
+// cancellationJob - cancel the projects whose moderation votes are committed when their cancellation was not
+// sent or failed, the last error is returned once every project has been handled
+func (s *Service) cancellationJob(ctx context.Context) (cancelled int, failed error) {
+	projects, err := s.Projects.FetchCancellable()
+	if err != nil {
+		logger.Error(s.context(), "Could not get cancellable projects: ", err)
+		return 0, err
+	}
+	logger.Info(s.context(), "~~~~~~~~~~Cancelling projects ready to cancel~~~~~~~~~~~~~~~~~")
+
+	for _, project := range projects {
+		if ctx.Err() != nil {
+			logger.Info(s.context(), "Project cancellation stopped before project ", project.Id)
+			return cancelled, failed
+		}
+		activities, err := s.ProjectActivities.SearchProjectIDTransType(project.Id, string(constants.CancelProject))
+		if err != nil {
+			logger.Error(s.context(), err)
+			failed = err
+			continue
+		}
+		if activityPending(activities) {
+			continue
+		}
+		logger.Infof(s.context(), "Cancelling project %v", project.Id)
+		err = s.CancelProject(RequestCancelProject{FkProjectId: project.Id})
+		if err != nil {
+			logger.Error(s.context(), err)
+			failed = err
+			continue
+		}
+		cancelled++
+	}
+	return cancelled, failed
+}
+
+// activityPending - whether one of the activities is waiting for Nodeserver
+func activityPending(activities []ProjectActivity) bool {
+	for _, activity := range activities {
+		if activity.Status == constants.ActivityPending {
+			return true
+		}
+	}
+	return false
+}
+
+// interestJob - post the configured interest for the CampShares holders, nothing when it is 0
+func (s *Service) interestJob(ctx context.Context) (int, error) {
+	amount := s.Config.Jobs.InterestAmount
+	if amount == 0 {
+		logger.Debug(s.context(), "No interest to post")
+		return 0, nil
+	}
+	logger.Infof(s.context(), "Posting interest of %d", amount)
+	if _, err := s.PostInterest(RequestPostInterest{Amount: amount}); err != nil {
+		logger.Error(s.context(), err)
+		return 0, err
+	}
+	return 1, nil
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/metrics"
+)
+
+// leaderLease - lease the Oracle instances compete for, its holder runs the scheduler jobs
+const leaderLease = "scheduler"
+
+// Leadership - which instance runs the scheduler jobs, as this instance last saw it
+type Leadership struct {
+	Instance  string    `json:"instance"`
+	Leader    string    `json:"leader"`
+	IsLeader  bool      `json:"is_leader"`
+	Since     time.Time `json:"since"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+var (
+	leadershipMu sync.Mutex
+	// leadership - nil until StartLeaderElection, every instance runs its jobs until then
+	leadership *Leadership
+)
+
+// leading - whether this instance runs the scheduler jobs. A leader that could not renew its lease stops
+// once the lease expires, when another instance may have taken it
+func leading(now time.Time) bool {
+	leadershipMu.Lock()
+	defer leadershipMu.Unlock()
+	if leadership == nil {
+		return true
+	}
+	return leadership.IsLeader && now.Before(leadership.ExpiresAt)
+}
+
+// CurrentLeadership - the leadership seen by this instance, false when it takes no part in an election
+func CurrentLeadership() (Leadership, bool) {
+	leadershipMu.Lock()
+	defer leadershipMu.Unlock()
+	if leadership == nil {
+		return Leadership{}, false
+	}
+	return *leadership, true
+}
+
+// electLeader - take or renew the scheduler lease, or learn which instance holds it
+func (s *Service) electLeader(ctx context.Context) error {
+	now := time.Now()
+	instance := s.Config.Leader.InstanceID
+	lease, err := s.Leases.Acquire(leaderLease, instance, now, s.Config.Leader.LeaseTTL)
+
+	leadershipMu.Lock()
+	defer leadershipMu.Unlock()
+	if leadership == nil {
+		return nil
+	}
+	leadership.CheckedAt = now
+	if err != nil {
+		leadership.Error = err.Error()
+		logger.Error(s.context(), "Could not renew the scheduler lease: ", err)
+		return err
+	}
+
+	wasLeader, previous := leadership.IsLeader, leadership.Leader
+	leadership.Error = ""
+	leadership.Leader = lease.Holder
+	leadership.IsLeader = lease.Holder == instance
+	leadership.Since = lease.AcquiredAt
+	leadership.ExpiresAt = lease.ExpiresAt
+	if leadership.IsLeader && !wasLeader {
+		logger.Infof(s.context(), "Instance %s is now the scheduler leader", instance)
+	} else if !leadership.IsLeader && (wasLeader || previous != lease.Holder) {
+		logger.Infof(s.context(), "Instance %s follows scheduler leader %s", instance, lease.Holder)
+	}
+	if leadership.IsLeader {
+		metrics.SchedulerLeader.Set(1)
+	} else {
+		metrics.SchedulerLeader.Set(0)
+	}
+	return nil
+}
+
+// StartLeaderElection - compete for the scheduler lease now, then renew it or try to take it over every third
+// of its TTL until StopJobs. Only the holder runs the scheduler jobs, another instance takes over once the
+// lease of a leader that died expires
+func (s *Service) StartLeaderElection() error {
+	leadershipMu.Lock()
+	leadership = &Leadership{Instance: s.Config.Leader.InstanceID}
+	leadershipMu.Unlock()
+
+	err := s.electLeader(s.context())
+	startJob(jobLeader, s.Config.Leader.LeaseTTL/3, s.electLeader)
+	return err
+}
+
+// ReleaseLeadership - give up the scheduler lease once the jobs are stopped, so another instance takes over
+// without waiting for it to expire
+func (s *Service) ReleaseLeadership() error {
+	leadershipMu.Lock()
+	held := leadership != nil && leadership.IsLeader
+	if leadership != nil {
+		leadership.IsLeader = false
+	}
+	leadershipMu.Unlock()
+
+	if !held {
+		return nil
+	}
+	metrics.SchedulerLeader.Set(0)
+	return s.Leases.Release(leaderLease, s.Config.Leader.InstanceID)
+}
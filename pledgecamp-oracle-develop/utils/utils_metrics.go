@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/metrics"
+)
+
+// RefreshMetrics - set the gauges that are read from the store, called before the metrics are scraped
+func (s *Service) RefreshMetrics() error {
+	counts, err := s.Projects.CountByStatus()
+	if err != nil {
+		return errs.Store(err, "Could not count projects")
+	}
+	for _, status := range constants.ProjectStatuses {
+		metrics.Projects.Set(float64(counts[status]), status.String())
+	}
+	for status, count := range counts {
+		metrics.Projects.Set(float64(count), status.String())
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+)
+
+// The milestone timers turn every milestoneTick through milestoneSlots slots, so a turn takes a minute. Every
+// turn the milestones due within milestoneHorizon are loaded again, to pick up those moved by another replica
+const (
+	milestoneTick    = time.Second
+	milestoneSlots   = 60
+	milestoneHorizon = 2 * milestoneSlots * milestoneTick
+)
+
+// timerWheel - hashed timer wheel of project milestones. A project waits in the slot of the first tick at or
+// after its milestone and fires once the wheel turns to that slot. Milestones more than a turn away stay in
+// their slot for the turns in between
+type timerWheel struct {
+	mu    sync.Mutex
+	tick  time.Duration
+	slots []map[int]time.Time
+	// due - milestone of each project in the wheel, to find its slot when it moves
+	due map[int]time.Time
+	// turnedTo - last tick the wheel turned to
+	turnedTo int64
+}
+
+// newTimerWheel - wheel of size slots of tick each, turned to now
+func newTimerWheel(tick time.Duration, size int, now time.Time) *timerWheel {
+	w := &timerWheel{tick: tick, slots: make([]map[int]time.Time, size), due: map[int]time.Time{}}
+	for i := range w.slots {
+		w.slots[i] = map[int]time.Time{}
+	}
+	w.turnedTo = w.tickOf(now)
+	return w
+}
+
+// tickOf - tick at or before at
+func (w *timerWheel) tickOf(at time.Time) int64 {
+	return at.UnixNano() / int64(w.tick)
+}
+
+// slotOf - slot of a tick
+func (w *timerWheel) slotOf(tick int64) map[int]time.Time {
+	return w.slots[tick%int64(len(w.slots))]
+}
+
+// schedule - fire projectId at due, in place of its previous milestone. A milestone the wheel already turned
+// past fires on the next tick
+func (w *timerWheel) schedule(projectId int, due time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.remove(projectId)
+	tick := w.tickOf(due)
+	if due.After(time.Unix(0, tick*int64(w.tick))) {
+		tick++
+	}
+	if tick <= w.turnedTo {
+		tick = w.turnedTo + 1
+	}
+	w.slotOf(tick)[projectId] = due
+	w.due[projectId] = due
+}
+
+// cancel - forget the milestone of projectId
+func (w *timerWheel) cancel(projectId int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.remove(projectId)
+}
+
+// remove - take projectId out of its slot, which may not be the one of its milestone when it was overdue
+func (w *timerWheel) remove(projectId int) {
+	if _, ok := w.due[projectId]; !ok {
+		return
+	}
+	for _, slot := range w.slots {
+		delete(slot, projectId)
+	}
+	delete(w.due, projectId)
+}
+
+// advance - turn the wheel to now and take out the projects whose milestone is at or before now, earliest
+// first. A wheel that was not turned for a full turn or more looks at every slot once
+func (w *timerWheel) advance(now time.Time) []int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	to := w.tickOf(now)
+	from := w.turnedTo + 1
+	if to-from >= int64(len(w.slots)) {
+		from = to - int64(len(w.slots)) + 1
+	}
+
+	var fired []int
+	for tick := from; tick <= to; tick++ {
+		slot := w.slotOf(tick)
+		for projectId, due := range slot {
+			if !due.After(now) {
+				fired = append(fired, projectId)
+				delete(slot, projectId)
+				delete(w.due, projectId)
+			}
+		}
+	}
+	if to > w.turnedTo {
+		w.turnedTo = to
+	}
+	sort.Ints(fired)
+	return fired
+}
+
+// size - number of projects in the wheel
+func (w *timerWheel) size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.due)
+}
+
+var (
+	milestoneTimers = newTimerWheel(milestoneTick, milestoneSlots, time.Now())
+
+	milestoneRefreshMu sync.Mutex
+	// milestoneRefreshedAt - when the milestones within the horizon were last loaded
+	milestoneRefreshedAt time.Time
+)
+
+// timeMilestone - time the next milestone of a project in the milestone phase, or drop its timer once it left
+// the phase
+func timeMilestone(project Project) {
+	if project.Status == constants.ProjectMilestonePhase {
+		milestoneTimers.schedule(project.Id, project.NextActivityDate)
+		return
+	}
+	milestoneTimers.cancel(project.Id)
+}
+
+// retimeMilestone - time the milestone of projectId again after a postback changed the project
+func (s *Service) retimeMilestone(projectId int) {
+	project, err := s.Projects.FetchById(projectId)
+	if err != nil {
+		logger.Error(s.context(), "Could not time the next milestone of project ", projectId, ": ", err)
+		return
+	}
+	timeMilestone(project)
+}
+
+// RebuildMilestoneTimers - time the milestones due within the horizon from the database, the later ones are
+// loaded on the turns before they are due
+func (s *Service) RebuildMilestoneTimers() error {
+	now := time.Now()
+	projects, err := s.Projects.FetchMilestonesDue(now.Add(milestoneHorizon))
+	if err != nil {
+		logger.Error(s.context(), "Could not load the milestones: ", err)
+		return err
+	}
+	for _, project := range projects {
+		timeMilestone(project)
+	}
+	milestoneRefreshMu.Lock()
+	milestoneRefreshedAt = now
+	milestoneRefreshMu.Unlock()
+	logger.Debugf(s.context(), "Timing %d milestones", milestoneTimers.size())
+	return nil
+}
+
+// tickMilestones - check the milestones the wheel turned past, loading the milestones within the horizon
+// once a turn
+func (s *Service) tickMilestones(ctx context.Context) (failed error) {
+	now := time.Now()
+	milestoneRefreshMu.Lock()
+	refresh := now.Sub(milestoneRefreshedAt) >= milestoneSlots*milestoneTick
+	milestoneRefreshMu.Unlock()
+	if refresh {
+		if err := s.RebuildMilestoneTimers(); err != nil {
+			failed = err
+		}
+	}
+
+	for _, projectId := range milestoneTimers.advance(now) {
+		if ctx.Err() != nil {
+			// Timed again on the next turn
+			return failed
+		}
+		project, err := s.Projects.FetchById(projectId)
+		if err != nil {
+			logger.Error(s.context(), "Could not find project ", projectId, ": ", err)
+			failed = err
+			continue
+		}
+		if _, err := s.checkDueMilestone(project); err != nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+// StartMilestoneTimers - time the milestones from the database and check each one within a tick of it being
+// reached, until StopJobs
+func (s *Service) StartMilestoneTimers() error {
+	err := s.RebuildMilestoneTimers()
+	startJob(jobMilestoneTimers, milestoneTick, s.tickMilestones)
+	return err
+}
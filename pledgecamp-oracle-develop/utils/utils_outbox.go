@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/config"
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/models"
+)
+
+type OutboxEvent = models.OutboxEvent
+
+// OutboxConfig - schedule of the outbox dispatcher
+type OutboxConfig = config.Outbox
+
+// projectEvents - ordering key of the backend events of a project
+func projectEvents(projectId int) string {
+	return "project:" + strconv.Itoa(projectId)
+}
+
+// csEvents - ordering key of the backend events of a CampShares holder
+func csEvents(userId int) string {
+	return "cs:" + strconv.Itoa(userId)
+}
+
+// userEvents - ordering key of the backend events of a user account
+func userEvents(userId int) string {
+	return "user:" + strconv.Itoa(userId)
+}
+
+// enqueueBackend - record a backend event in the outbox. Called with the tx Store it is only delivered
+// if the surrounding unit of work commits. The event keeps the ID of the request being served
+func (s *Service) enqueueBackend(tx models.Store, orderingKey string, uri string, parameters RequestParameters) error {
+	now := time.Now()
+	_, err := tx.Outbox.Insert(OutboxEvent{
+		OrderingKey:   orderingKey,
+		URI:           uri,
+		Payload:       models.JSONMap(parameters),
+		Status:        constants.OutboxPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		ModifiedAt:    now,
+		RequestId:     logger.RequestID(s.context()),
+	})
+	return err
+}
+
+// outboxBackoff - delay before the next delivery attempt, doubling from BaseDelay up to MaxDelay
+func outboxBackoff(config OutboxConfig, attempts int) time.Duration {
+	delay := config.BaseDelay
+	for i := 1; i < attempts && delay < config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > config.MaxDelay {
+		delay = config.MaxDelay
+	}
+	return delay
+}
+
+// deliverOutboxEvent - post one event to the backend and record the outcome
+func (s *Service) deliverOutboxEvent(config OutboxConfig, event OutboxEvent) (OutboxEvent, error) {
+	ctx := logger.WithFields(logger.WithRequestID(s.context(), event.RequestId), logger.Fields{"outbox_id": event.Id})
+	_, err := s.postBackend(ctx, RequestParameters(event.Payload), event.URI)
+
+	event.Attempts++
+	event.ModifiedAt = time.Now()
+	if err == nil {
+		event.Status = constants.OutboxDelivered
+		event.LastError = sql.NullString{}
+	} else {
+		event.LastError = sql.NullString{String: err.Error(), Valid: true}
+		if event.Attempts >= config.MaxAttempts {
+			event.Status = constants.OutboxDeadLetter
+			logger.Errorf(ctx, "Outbox event %d dead-lettered after %d attempts: %v", event.Id, event.Attempts, err)
+		} else {
+			event.NextAttemptAt = event.ModifiedAt.Add(outboxBackoff(config, event.Attempts))
+		}
+	}
+
+	return s.Outbox.UpdateFields(event)
+}
+
+// DispatchOutbox - deliver due outbox events until none are left, returning how many were delivered.
+// Events of the same ordering key are delivered one after the other in the order they were recorded
+func (s *Service) DispatchOutbox(config OutboxConfig) (int, error) {
+	return s.dispatchOutbox(context.Background(), config)
+}
+
+// dispatchOutbox - DispatchOutbox that stops between batches once ctx is cancelled
+func (s *Service) dispatchOutbox(ctx context.Context, config OutboxConfig) (int, error) {
+	delivered := 0
+	for ctx.Err() == nil {
+		events, err := s.Outbox.Due(time.Now(), config.BatchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		// Delivered and dead-lettered events let the next event of their ordering key through
+		progressed := false
+		for _, event := range events {
+			event, err = s.deliverOutboxEvent(config, event)
+			if err != nil {
+				return delivered, err
+			}
+			if event.Status == constants.OutboxDelivered {
+				delivered++
+			}
+			if event.Status != constants.OutboxPending {
+				progressed = true
+			}
+		}
+
+		// Anything left is waiting for its next attempt
+		if !progressed {
+			return delivered, nil
+		}
+	}
+	return delivered, nil
+}
+
+// StartOutboxDispatcher - deliver the outbox in the background every config.Interval until StopJobs
+func (s *Service) StartOutboxDispatcher(config OutboxConfig) {
+	startJob(jobOutbox, config.Interval, func(ctx context.Context) error {
+		_, err := s.dispatchOutbox(ctx, config)
+		if err != nil {
+			logger.Error(s.context(), "Could not dispatch outbox: ", err)
+		}
+		return err
+	})
+}
+
+// DeadLetters - outbox events that ran out of delivery attempts
+func (s *Service) DeadLetters() ([]OutboxEvent, error) {
+	events, err := s.Outbox.DeadLettered()
+	if err != nil {
+		return events, errs.Store(err, "Could not get dead-lettered events")
+	}
+	return events, nil
+}
+
+// RedriveOutboxEvent - queue a dead-lettered event for delivery again. It goes back ahead of the pending
+// events recorded after it for the same ordering key
+func (s *Service) RedriveOutboxEvent(eventId int) (OutboxEvent, error) {
+	event, err := s.Outbox.FetchById(eventId)
+	if err != nil {
+		return event, errs.Store(err, "Could not find outbox event %d", eventId)
+	}
+	if event.Status != constants.OutboxDeadLetter {
+		return event, errs.New(errs.Conflict, "Outbox event %d is not dead-lettered", eventId)
+	}
+
+	event.Status = constants.OutboxPending
+	event.Attempts = 0
+	event.NextAttemptAt = time.Now()
+	event.ModifiedAt = event.NextAttemptAt
+	event, err = s.Outbox.UpdateFields(event)
+	if err != nil {
+		return event, errs.Store(err, "Could not redrive outbox event %d", eventId)
+	}
+	return event, nil
+}
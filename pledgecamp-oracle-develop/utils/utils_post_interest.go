@@ -3,23 +3,23 @@ package utils
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/imroc/req"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 // PostInterest() - post interest payment for CS holders
-func PostInterest(postInterestRequest RequestPostInterest) (CampShares, error) {
+func (s *Service) PostInterest(postInterestRequest RequestPostInterest) (CampShares, error) {
 	activityReference := string(constants.PostInterest)
 
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/cs/0/callback/" + activityReference
-	nodeServerURL := "/cs/" + activityReference
+	oracleCallbackURL := s.Config.App.Domain + "/cs/0/callback/" + activityReference
 
 	cs := models.CampShares{
 		Amount: postInterestRequest.Amount,
@@ -31,9 +31,9 @@ func PostInterest(postInterestRequest RequestPostInterest) (CampShares, error) {
 	inrec, _ := json.Marshal(cs)
 	json.Unmarshal(inrec, &inInterface)
 
-	latestCs, err := models.GetlatestCSId()
+	latestCs, err := s.CampShares.GetLatest()
 	if err != nil {
-		log.Println(err)
+		logger.Error(s.context(), err)
 	}
 	csId := latestCs.CSId + 1
 	if csId < 1 {
@@ -42,29 +42,32 @@ func PostInterest(postInterestRequest RequestPostInterest) (CampShares, error) {
 
 	cs.CSId = csId
 
-	// Input CS transaction into CampShare model
-	cs, err = models.CSInsert(cs)
-	if err != nil {
-		log.Fatal(err)
-	}
+	var csActivity models.CSActivity
+	err = s.Atomic(func(tx models.Store) error {
+		// Input CS transaction into CampShare model
+		var err error
+		cs, err = tx.CampShares.Insert(cs)
+		if err != nil {
+			return err
+		}
 
-	// Create cs activity for tracking purposes
-	csActivity, err := models.SetCSActivity(csId, constants.PostInterest)
+		// Create cs activity for tracking purposes
+		csActivity, err = models.SetCSActivity(tx.CSActivities, csId, constants.PostInterest)
+		return err
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return cs, err
 	}
+	s = s.withFields(logger.Fields{"activity_id": csActivity.Id})
 
 	// Send request to collect interest to Nodeserver
-	requestParameters := req.Param{
-		"transaction_type": activityReference,
-		"activity_id":      csActivity.Id,
-		"amount":           postInterestRequest.Amount,
-		"url_callback":     oracleCallbackURL,
-	}
-
-	_, err = PostNodeServer(requestParameters, nodeServerURL)
+	_, err = s.NodeServer.PostInterest(s.context(), nodeserver.PostInterestRequest{
+		Amount:   postInterestRequest.Amount,
+		Callback: nodeserver.Callback{ActivityId: csActivity.Id, URL: oracleCallbackURL},
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return cs, err
 	}
 
@@ -72,57 +75,60 @@ func PostInterest(postInterestRequest RequestPostInterest) (CampShares, error) {
 }
 
 // Follows default callback behavior in handlers_projects
-func PostInterestCallback(transactionResponse NodeServerModel, csActivity models.CSActivity) error {
+func (s *Service) PostInterestCallback(transactionResponse NodeServerModel, csActivity models.CSActivity) error {
 
 	// Only process if Nodeserver postback response successful
-	log.Printf("Project status: %v", transactionResponse.Status)
+	logger.Infof(s.context(), "Project status: %v", transactionResponse.Status)
 
 	if transactionResponse.Status == structs.Complete {
 
 		// Get the interest amount from event from Nodeserver
 		var interestAmount int
 		interestResultInterface := transactionResponse.TransactionEvents.([]interface{})
-		log.Println("Post interest result")
-		log.Println(interestResultInterface)
+		logger.Debug(s.context(), "Post interest result")
+		logger.Debug(s.context(), interestResultInterface)
 
 		for _, interests := range interestResultInterface {
 			interestItem := interests.(string)
 			interestAmount, _ = strconv.Atoi(interestItem)
 		}
 
-		// Update Activity status to success
-		csActivity.Status = constants.ActivitySuccess
-		csActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.CSActivityUpdateFields(csActivity)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Update project status & completed activity
-		interestCS, err := models.CSSearchCSId(csActivity.CsId)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Amount reflects interest in PLG posted
-		interestCS.Amount = interestAmount
-
-		_, err = models.CSUpdateFields(interestCS)
-		if err != nil {
-			log.Println(err)
-		}
-
-		// Send response back to backend if activities required are completed
-		backendURL := "/events/blockchain/cs/" + string(constants.PostInterestEvent) + "/"
-		requestParameters := req.Param{
-			"event_type":      constants.PostInterestEvent,
-			"activity_id":     csActivity.Id,
-			"status":          true,
-			"interest_amount": interestAmount,
-		}
-		_, err = PostBackend(requestParameters, backendURL)
+		var interestCS CampShares
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity status to success
+			csActivity.Status = constants.ActivitySuccess
+			csActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.CSActivities.UpdateFields(csActivity)
+			if err != nil {
+				return err
+			}
+
+			// Update project status & completed activity
+			interestCS, err = tx.CampShares.SearchCSId(csActivity.CsId)
+			if err != nil {
+				return errs.Store(err, "Could not find CS entry %d", csActivity.CsId)
+			}
+
+			// Amount reflects interest in PLG posted
+			interestCS.Amount = interestAmount
+
+			_, err = tx.CampShares.UpdateFields(interestCS)
+			if err != nil {
+				return err
+			}
+
+			// Queue the backend event with the changes
+			backendURL := "/events/blockchain/cs/" + string(constants.PostInterestEvent) + "/"
+			requestParameters := req.Param{
+				"event_type":      constants.PostInterestEvent,
+				"activity_id":     csActivity.Id,
+				"status":          true,
+				"interest_amount": interestAmount,
+			}
+			return s.enqueueBackend(tx, csEvents(interestCS.UserId), backendURL, requestParameters)
+		})
 		if err != nil {
-			log.Fatal(err)
+			logger.Error(s.context(), err)
 			return err
 		}
 	}
@@ -3,34 +3,40 @@ package utils
 import (
 	"database/sql"
 	"encoding/json"
-	"errors"
-	"fmt"
-	"log"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/imroc/req"
 	"github.com/lib/pq"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 // ProjectCreate()
-func ProjectCreate(projectRequest RequestProjectCreate) (Project, error) {
+func (s *Service) ProjectCreate(projectRequest RequestProjectCreate) (Project, error) {
+	s = s.withFields(logger.Fields{"project_id": projectRequest.ProjectId})
 
 	// Check whether the Project already exists
-	existingProject, _ := models.ProjectFetchById(projectRequest.ProjectId)
-	if existingProject.Id != 0 {
-		return existingProject, errors.New("Project already exists")
+	existingProject, err := s.Projects.FetchById(projectRequest.ProjectId)
+	if err == nil {
+		return existingProject, errs.New(errs.Conflict, "Project already exists")
+	} else if !errs.Is(err, errs.NotFound) {
+		logger.Error(s.context(), err)
+		return existingProject, errs.Store(err, "Could not check for project %d", projectRequest.ProjectId)
+	}
+
+	if len(projectRequest.Milestones) == 0 {
+		return existingProject, errs.New(errs.Validation, "At least one milestone is required")
 	}
 
 	// Activity Definitions
 	projectId := strconv.Itoa(projectRequest.ProjectId)
 	activityReference := string(constants.ProjectDeploy)
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/projects/" + projectId + "/callback/" + activityReference
-	nodeServerURL := "/projects/" + projectId
+	oracleCallbackURL := s.Config.App.Domain + "/projects/" + projectId + "/callback/" + activityReference
 
 	// Prepare project parameters with information from incoming request
 	var projectParams = ProjectParameters{
@@ -51,34 +57,33 @@ func ProjectCreate(projectRequest RequestProjectCreate) (Project, error) {
 		ProjectParameters: projectParamsInterface,
 	}
 
-	// Insert model into the project table for the new request
-	projectEntity, err := models.ProjectInsert(project)
-	if err != nil {
-		log.Fatal(err)
-		return project, err
-	}
-
-	fmt.Printf("Inserting project: %v", projectEntity)
+	var projectActivity ProjectActivity
+	err = s.Atomic(func(tx models.Store) error {
+		// Insert model into the project table for the new request
+		if _, err := tx.Projects.Insert(project); err != nil {
+			return err
+		}
+		logger.Debug(s.context(), "Inserted project")
 
-	// Create project activity for tracking deployment
-	projectActivity, err := models.SetProjectActivity(projectRequest.ProjectId, constants.ProjectDeploy)
+		// Create project activity for tracking deployment
+		projectActivity, err = models.SetProjectActivity(tx.ProjectActivities, projectRequest.ProjectId, constants.ProjectDeploy)
+		return err
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return project, err
 	}
+	s = s.withFields(logger.Fields{"activity_id": projectActivity.Id})
 
 	// Pass incoming request to Nodeserver
-	requestParameters := req.Param{
-		"transaction_type": constants.ProjectDeploy,
-		"project_id":       projectRequest.ProjectId,
-		"activity_id":      projectActivity.Id,
-		"milestone_times":  projectRequest.Milestones,
-		"release_percents": projectRequest.ReleasePercents,
-		"url_callback":     oracleCallbackURL,
-	}
-
-	_, err = PostNodeServer(requestParameters, nodeServerURL)
+	_, err = s.NodeServer.DeployProject(s.context(), nodeserver.DeployProjectRequest{
+		ProjectId:       projectRequest.ProjectId,
+		Milestones:      projectRequest.Milestones,
+		ReleasePercents: projectRequest.ReleasePercents,
+		Callback:        nodeserver.Callback{ActivityId: projectActivity.Id, URL: oracleCallbackURL},
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return project, err
 	}
 
@@ -88,59 +93,64 @@ func ProjectCreate(projectRequest RequestProjectCreate) (Project, error) {
 }
 
 // ProjectCreateCallback()
-func ProjectCreateCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
+func (s *Service) ProjectCreateCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
 	//transactionStatus := string(transactionResponse.TransactionStatus)
 
 	// Only process if Nodeserver postback response successful
-	log.Printf("Project status: %v", transactionResponse.Status)
+	logger.Infof(s.context(), "Project status: %v", transactionResponse.Status)
 
 	if transactionResponse.Status == structs.Complete {
 
 		// Update new contract address from Nodeserver
 		newProjectAddress := ""
 		contractInterface := transactionResponse.TransactionEvents.([]interface{})
-		log.Println("Contract result")
-		log.Println(contractInterface)
+		logger.Debug(s.context(), "Contract result")
+		logger.Debug(s.context(), contractInterface)
 
 		for _, address := range contractInterface {
 			newProjectAddress = address.(string)
 		}
 
-		log.Printf("Project deployed: %s", newProjectAddress)
-
-		// Update Activity status to success
-		projectActivity.Status = constants.ActivitySuccess
-		projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.ProjectActivityUpdateFields(projectActivity)
-
-		// Update project status, created contract address, & completed activity
-		project, err := models.ProjectFetchById(projectActivity.ProjectId)
-		if err != nil {
-			log.Fatal(err)
-		}
-		project.Status = constants.ProjectDeployed
-		project.ContractAddress = newProjectAddress
-		project.ActivitiesCompleted = append(project.ActivitiesCompleted, string(constants.ProjectDeploy))
-		project, err = models.ProjectUpdateFields(project)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Send response back to backend if activities required are completed
-		projectId := strconv.Itoa(project.Id)
-		backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.ProjectCreate)
-		requestParameters := req.Param{
-			"event_type":       constants.ProjectCreate,
-			"project_id":       project.Id,
-			"project_contract": project.ContractAddress,
-			"status":           true,
-		}
-		_, err = PostBackend(requestParameters, backendURL)
+		logger.Infof(s.context(), "Project deployed: %s", newProjectAddress)
+
+		var project Project
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity status to success
+			projectActivity.Status = constants.ActivitySuccess
+			projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.ProjectActivities.UpdateFields(projectActivity)
+			if err != nil {
+				return err
+			}
+
+			// Update project status, created contract address, & completed activity
+			project, err = tx.Projects.FetchById(projectActivity.ProjectId)
+			if err != nil {
+				return errs.Store(err, "Could not find project %d", projectActivity.ProjectId)
+			}
+			project.Status = constants.ProjectDeployed
+			project.ContractAddress = newProjectAddress
+			project.ActivitiesCompleted = append(project.ActivitiesCompleted, string(constants.ProjectDeploy))
+			project, err = tx.Projects.UpdateFields(project)
+			if err != nil {
+				return err
+			}
+
+			// Queue the backend event with the changes
+			projectId := strconv.Itoa(project.Id)
+			backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.ProjectCreate)
+			requestParameters := req.Param{
+				"event_type":       constants.ProjectCreate,
+				"project_id":       project.Id,
+				"project_contract": project.ContractAddress,
+				"status":           true,
+			}
+			return s.enqueueBackend(tx, projectEvents(project.Id), backendURL, requestParameters)
+		})
 		if err != nil {
-			log.Fatal(err)
+			logger.Error(s.context(), err)
 			return err
 		}
-
 	}
 
 	return nil
@@ -1,22 +1,23 @@
 package utils
 
 import (
-	"log"
-
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
 )
 
 // ProjectGetState()
-func ProjectGetState(projectRequest RequestProjectState) (ProjectStateResponse, error) {
+func (s *Service) ProjectGetState(projectRequest RequestProjectState) (ProjectStateResponse, error) {
+	s = s.withFields(logger.Fields{"project_id": projectRequest.ProjectId})
 
 	var projectState models.ProjectStateResponse
 	projectState.ProjectId = projectRequest.ProjectId
 
 	// Check whether the Project already exists
-	project, err := models.ProjectFetchById(projectRequest.ProjectId)
+	project, err := s.Projects.FetchById(projectRequest.ProjectId)
 	if err != nil {
-		log.Fatal(err)
-		return projectState, err
+		logger.Error(s.context(), err)
+		return projectState, errs.Store(err, "Could not find project %d", projectRequest.ProjectId)
 	}
 
 	projectState.ContractAddress = project.ContractAddress
@@ -26,9 +27,9 @@ func ProjectGetState(projectRequest RequestProjectState) (ProjectStateResponse,
 	projectState.ActivitiesCompleted = project.ActivitiesCompleted
 
 	// var projectActivitiesList models.ProjectActivitiesList
-	projectActivitiesList, err := models.ProjectActivitySearchProjectID(project.Id)
+	projectActivitiesList, err := s.ProjectActivities.SearchProjectID(project.Id)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return projectState, err
 	}
 	projectState.ProjectActivitiesList = projectActivitiesList
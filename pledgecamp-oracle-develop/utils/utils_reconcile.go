@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/metrics"
+	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
+	"github.com/pledgecamp/pledgecamp-oracle/structs"
+)
+
+// reconciliationJob - settle the activities still pending ReconcileAfter after they were created, whose postback
+// was lost, from the transaction Nodeserver has for them. The last error is returned once every activity has
+// been handled. Returns the number of activities settled
+func (s *Service) reconciliationJob(ctx context.Context) (settled int, failed error) {
+	createdBefore := time.Now().Add(-s.Config.Jobs.ReconcileAfter)
+	projectActivities, err := s.ProjectActivities.PendingProject(createdBefore)
+	if err != nil {
+		logger.Error(s.context(), "Could not get pending project activities: ", err)
+		return 0, err
+	}
+	csActivities, err := s.CSActivities.Pending(createdBefore)
+	if err != nil {
+		logger.Error(s.context(), "Could not get pending CS activities: ", err)
+		return 0, err
+	}
+	logger.Info(s.context(), "~~~~~~~~~~Reconciling pending activities~~~~~~~~~~~~~~~~~")
+
+	for _, activity := range projectActivities {
+		if ctx.Err() != nil {
+			logger.Info(s.context(), "Reconciliation stopped before project activity ", activity.Id)
+			return settled, failed
+		}
+		done, err := s.reconcileProjectActivity(activity)
+		if err != nil {
+			failed = err
+			continue
+		}
+		if done {
+			settled++
+		}
+	}
+	for _, csActivity := range csActivities {
+		if ctx.Err() != nil {
+			logger.Info(s.context(), "Reconciliation stopped before CS activity ", csActivity.Id)
+			return settled, failed
+		}
+		done, err := s.reconcileCSActivity(csActivity)
+		if err != nil {
+			failed = err
+			continue
+		}
+		if done {
+			settled++
+		}
+	}
+	return settled, failed
+}
+
+// findTransaction - transaction Nodeserver has for an activity, false when it has none
+func (s *Service) findTransaction(activityId int, activityType constants.ActivityReference) (NodeServerModel, bool, error) {
+	transaction, err := s.NodeServer.FindTransaction(s.context(), nodeserver.TransactionRequest{ActivityId: activityId, Type: activityType})
+	if errs.Is(err, errs.NotFound) {
+		logger.Warnf(s.context(), "Nodeserver has no %s transaction for activity %d", activityType, activityId)
+		return transaction, false, nil
+	}
+	if err != nil {
+		logger.Error(s.context(), err)
+		return transaction, false, err
+	}
+	transaction.ParentID = activityId
+	if transaction.Type == "" {
+		transaction.Type = string(activityType)
+	}
+	return transaction, true, nil
+}
+
+// timedOut - failed postback standing for a transaction Nodeserver did not settle in time
+func timedOut(transaction NodeServerModel, activityId int, activityType constants.ActivityReference) NodeServerModel {
+	transaction.ParentID = activityId
+	transaction.Type = string(activityType)
+	transaction.Status = structs.FailedTimeout
+	return transaction
+}
+
+// reconcileProjectActivity - apply the transaction Nodeserver has for a pending project activity through the
+// postback handling, and time the activity out once it is still pending after ActivityTimeout. A postback
+// received after the timeout is still applied. Returns whether the activity was settled
+func (s *Service) reconcileProjectActivity(projectActivity ProjectActivity) (bool, error) {
+	s = s.withFields(logger.Fields{"activity_id": projectActivity.Id, "project_id": projectActivity.ProjectId})
+	transaction, found, err := s.findTransaction(projectActivity.Id, projectActivity.Type)
+	if err != nil {
+		return false, err
+	}
+	if found {
+		if err := s.ProjectCallback(transaction); err != nil {
+			return false, err
+		}
+		reloaded, err := s.ProjectActivities.SearchActivityID(projectActivity.Id)
+		if err != nil {
+			logger.Error(s.context(), err)
+			return false, errs.Store(err, "Could not find project activity %d", projectActivity.Id)
+		}
+		if reloaded.Status != constants.ActivityPending {
+			logger.Infof(s.context(), "Reconciled %s activity %d as %s", reloaded.Type, reloaded.Id, reloaded.Status)
+			return true, nil
+		}
+	}
+	if time.Since(projectActivity.CreatedAt) < s.Config.Jobs.ActivityTimeout {
+		return false, nil
+	}
+
+	failed := timedOut(transaction, projectActivity.Id, projectActivity.Type)
+	if failed.ContractAddress == "" {
+		if project, err := s.Projects.FetchById(projectActivity.ProjectId); err == nil {
+			failed.ContractAddress = project.ContractAddress
+		}
+	}
+	logger.Warnf(s.context(), "%s activity %d timed out after %v", projectActivity.Type, projectActivity.Id, s.Config.Jobs.ActivityTimeout)
+	if err := s.projectCallbackFailed(failed, projectActivity); err != nil {
+		return false, err
+	}
+	metrics.Activities.Inc(string(projectActivity.Type), constants.ActivityTimeout.String())
+	return true, nil
+}
+
+// reconcileCSActivity - apply the transaction Nodeserver has for a pending CS activity through the postback
+// handling, and time the activity out once it is still pending after ActivityTimeout. A postback received
+// after the timeout is still applied. Returns whether the activity was settled
+func (s *Service) reconcileCSActivity(csActivity models.CSActivity) (bool, error) {
+	s = s.withFields(logger.Fields{"activity_id": csActivity.Id, "cs_id": csActivity.CsId})
+	transaction, found, err := s.findTransaction(csActivity.Id, csActivity.Type)
+	if err != nil {
+		return false, err
+	}
+	if found {
+		if err := s.CsCallback(transaction); err != nil {
+			return false, err
+		}
+		reloaded, err := s.CSActivities.SearchActivityID(csActivity.Id)
+		if err != nil {
+			logger.Error(s.context(), err)
+			return false, errs.Store(err, "Could not find CS activity %d", csActivity.Id)
+		}
+		if reloaded.Status != constants.ActivityPending {
+			logger.Infof(s.context(), "Reconciled %s activity %d as %s", reloaded.Type, reloaded.Id, reloaded.Status)
+			return true, nil
+		}
+	}
+	if time.Since(csActivity.CreatedAt) < s.Config.Jobs.ActivityTimeout {
+		return false, nil
+	}
+
+	logger.Warnf(s.context(), "%s activity %d timed out after %v", csActivity.Type, csActivity.Id, s.Config.Jobs.ActivityTimeout)
+	if err := s.csCallbackFailed(timedOut(transaction, csActivity.Id, csActivity.Type), csActivity); err != nil {
+		return false, err
+	}
+	metrics.Activities.Inc(string(csActivity.Type), constants.ActivityTimeout.String())
+	return true, nil
+}
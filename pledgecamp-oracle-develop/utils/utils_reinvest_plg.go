@@ -3,24 +3,25 @@ package utils
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/imroc/req"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 // ReinvestPLG() - reinvest and stake interest from holding CS
-func ReinvestPLG(reinvestRequest RequestReinvestPLG) (CampShares, error) {
+func (s *Service) ReinvestPLG(reinvestRequest RequestReinvestPLG) (CampShares, error) {
+	s = s.withFields(logger.Fields{"user_id": reinvestRequest.UserId})
 	userId := strconv.Itoa(reinvestRequest.UserId)
 	activityReference := string(constants.ReinvestPLG)
 
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/cs/" + userId + "/callback/" + activityReference
-	nodeServerURL := "/manager/cs/" + userId + "/" + activityReference
+	oracleCallbackURL := s.Config.App.Domain + "/cs/" + userId + "/callback/" + activityReference
 
 	cs := models.CampShares{
 		UserId: reinvestRequest.UserId,
@@ -32,9 +33,9 @@ func ReinvestPLG(reinvestRequest RequestReinvestPLG) (CampShares, error) {
 	inrec, _ := json.Marshal(cs)
 	json.Unmarshal(inrec, &inInterface)
 
-	latestCs, err := models.GetlatestCSId()
+	latestCs, err := s.CampShares.GetLatest()
 	if err != nil {
-		log.Println(err)
+		logger.Error(s.context(), err)
 	}
 	csId := latestCs.CSId + 1
 	if csId < 1 {
@@ -43,29 +44,32 @@ func ReinvestPLG(reinvestRequest RequestReinvestPLG) (CampShares, error) {
 
 	cs.CSId = csId
 
-	// Input CS transaction into CampShare model
-	cs, err = models.CSInsert(cs)
-	if err != nil {
-		log.Fatal(err)
-	}
+	var csActivity models.CSActivity
+	err = s.Atomic(func(tx models.Store) error {
+		// Input CS transaction into CampShare model
+		var err error
+		cs, err = tx.CampShares.Insert(cs)
+		if err != nil {
+			return err
+		}
 
-	// Create cs activity for tracking purposes
-	csActivity, err := models.SetCSActivity(csId, constants.ReinvestPLG)
+		// Create cs activity for tracking purposes
+		csActivity, err = models.SetCSActivity(tx.CSActivities, csId, constants.ReinvestPLG)
+		return err
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return cs, err
 	}
+	s = s.withFields(logger.Fields{"activity_id": csActivity.Id})
 
 	// Send request to collect interest to Nodeserver
-	requestParameters := req.Param{
-		"transaction_type": activityReference,
-		"user_id":          cs.UserId,
-		"activity_id":      csActivity.Id,
-		"url_callback":     oracleCallbackURL,
-	}
-
-	_, err = PostNodeServer(requestParameters, nodeServerURL)
+	_, err = s.NodeServer.ReinvestPLG(s.context(), nodeserver.UserRequest{
+		UserId:   cs.UserId,
+		Callback: nodeserver.Callback{ActivityId: csActivity.Id, URL: oracleCallbackURL},
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return cs, err
 	}
 
@@ -73,18 +77,18 @@ func ReinvestPLG(reinvestRequest RequestReinvestPLG) (CampShares, error) {
 }
 
 // Follows default callback behavior in handlers_projects
-func ReinvestPLGCallback(transactionResponse NodeServerModel, csActivity models.CSActivity) error {
+func (s *Service) ReinvestPLGCallback(transactionResponse NodeServerModel, csActivity models.CSActivity) error {
 
 	// Only process if Nodeserver postback response successful
-	log.Printf("Project status: %v", transactionResponse.Status)
+	logger.Infof(s.context(), "Project status: %v", transactionResponse.Status)
 
 	if transactionResponse.Status == structs.Complete {
 
 		// Get the interest amount from event from Nodeserver
 		var interestAmount int
 		reinvestResultInterface := transactionResponse.TransactionEvents.([]interface{})
-		log.Println("Reinvest interest result")
-		log.Println(reinvestResultInterface)
+		logger.Debug(s.context(), "Reinvest interest result")
+		logger.Debug(s.context(), reinvestResultInterface)
 
 		for index, interests := range reinvestResultInterface {
 			interestItem := interests.([]interface{})
@@ -93,43 +97,46 @@ func ReinvestPLGCallback(transactionResponse NodeServerModel, csActivity models.
 			}
 		}
 
-		// Update Activity status to success
-		csActivity.Status = constants.ActivitySuccess
-		csActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.CSActivityUpdateFields(csActivity)
-		if err != nil {
-			log.Fatal(err)
-		}
+		var reinvestCS CampShares
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity status to success
+			csActivity.Status = constants.ActivitySuccess
+			csActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.CSActivities.UpdateFields(csActivity)
+			if err != nil {
+				return err
+			}
 
-		// Update project status & completed activity
-		reinvestCS, err := models.CSSearchCSId(csActivity.CsId)
-		if err != nil {
-			log.Fatal(err)
-		}
+			// Update project status & completed activity
+			reinvestCS, err = tx.CampShares.SearchCSId(csActivity.CsId)
+			if err != nil {
+				return errs.Store(err, "Could not find CS entry %d", csActivity.CsId)
+			}
 
-		// Amount reflects interest in PLG received
-		reinvestCS.Amount = interestAmount
-		// BalanceMovement reflects the amount of interest received
-		reinvestCS.BalanceMovement = interestAmount
+			// Amount reflects interest in PLG received
+			reinvestCS.Amount = interestAmount
+			// BalanceMovement reflects the amount of interest received
+			reinvestCS.BalanceMovement = interestAmount
 
-		_, err = models.CSUpdateFields(reinvestCS)
-		if err != nil {
-			log.Println(err)
-		}
+			_, err = tx.CampShares.UpdateFields(reinvestCS)
+			if err != nil {
+				return err
+			}
 
-		// Send response back to backend if activities required are completed
-		userId := strconv.Itoa(reinvestCS.UserId)
-		backendURL := "/events/blockchain/cs/" + userId + "/" + string(constants.ReinvestPLGEvent)
-		requestParameters := req.Param{
-			"event_type":  constants.ReinvestPLGEvent,
-			"activity_id": csActivity.Id,
-			"user_id":     reinvestCS.UserId,
-			"status":      true,
-			"amount":      interestAmount,
-		}
-		_, err = PostBackend(requestParameters, backendURL)
+			// Queue the backend event with the changes
+			userId := strconv.Itoa(reinvestCS.UserId)
+			backendURL := "/events/blockchain/cs/" + userId + "/" + string(constants.ReinvestPLGEvent)
+			requestParameters := req.Param{
+				"event_type":  constants.ReinvestPLGEvent,
+				"activity_id": csActivity.Id,
+				"user_id":     reinvestCS.UserId,
+				"status":      true,
+				"amount":      interestAmount,
+			}
+			return s.enqueueBackend(tx, csEvents(reinvestCS.UserId), backendURL, requestParameters)
+		})
 		if err != nil {
-			log.Fatal(err)
+			logger.Error(s.context(), err)
 			return err
 		}
 	}
@@ -2,18 +2,20 @@ package utils
 
 import (
 	"database/sql"
-	"log"
-	"os"
 	"strconv"
 
 	"github.com/imroc/req"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 // ReleaseFunds
-func ReleaseFunds(releaseRequest RequestReleaseFunds) error {
+func (s *Service) ReleaseFunds(releaseRequest RequestReleaseFunds) error {
+	s = s.withFields(logger.Fields{"project_id": releaseRequest.FkProjectId, "user_id": releaseRequest.UserId})
 
 	// Activity Definitions
 	projectId := strconv.Itoa(releaseRequest.FkProjectId)
@@ -22,36 +24,41 @@ func ReleaseFunds(releaseRequest RequestReleaseFunds) error {
 		activityType = constants.WithdrawFunds
 	} else if releaseRequest.TransactionType == string(constants.RequestRefund) {
 		activityType = constants.RequestRefund
+	} else {
+		return errs.New(errs.Validation, "Unsupported transaction type %q", releaseRequest.TransactionType)
 	}
 
 	activityReference := string(activityType)
-	nodeServerURL := "/manager/projects/" + projectId + "/" + activityReference
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/projects/" + projectId + "/callback/" + activityReference
+	oracleCallbackURL := s.Config.App.Domain + "/projects/" + projectId + "/callback/" + activityReference
 
 	// Get project information
-	project, _ := models.ProjectFetchById(releaseRequest.FkProjectId)
+	project, err := s.Projects.FetchById(releaseRequest.FkProjectId)
 	if err != nil {
-		log.Fatal(err)
-		return err
+		logger.Error(s.context(), err)
+		return errs.Store(err, "Could not find project %d", releaseRequest.FkProjectId)
 	}
 
-	projectActivity, err := models.SetProjectActivity(releaseRequest.FkProjectId, activityType)
+	projectActivity, err := models.SetProjectActivity(s.ProjectActivities, releaseRequest.FkProjectId, activityType)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return err
 	}
+	s = s.withFields(logger.Fields{"activity_id": projectActivity.Id})
 
 	// Get parameters from the above structs
-	requestParameters := req.Param{
-		"transaction_type": activityReference,
-		"contract_address": project.ContractAddress,
-		"user_id":          releaseRequest.UserId,
-		"activity_id":      projectActivity.Id,
-		"url_callback":     oracleCallbackURL,
+	fundsRequest := nodeserver.ReleaseFundsRequest{
+		ProjectId:       releaseRequest.FkProjectId,
+		ContractAddress: project.ContractAddress,
+		UserId:          releaseRequest.UserId,
+		Callback:        nodeserver.Callback{ActivityId: projectActivity.Id, URL: oracleCallbackURL},
+	}
+	if activityType == constants.WithdrawFunds {
+		_, err = s.NodeServer.WithdrawFunds(s.context(), fundsRequest)
+	} else {
+		_, err = s.NodeServer.RequestRefund(s.context(), fundsRequest)
 	}
-
-	_, err = PostNodeServer(requestParameters, nodeServerURL)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return err
 	}
 
@@ -59,63 +66,76 @@ func ReleaseFunds(releaseRequest RequestReleaseFunds) error {
 }
 
 // WithdrawFundsCallback()
-func WithdrawFundsCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
+func (s *Service) WithdrawFundsCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
 
 	// Only process if Nodeserver postback response successful
-	log.Printf("Project status: %v", transactionResponse.Status)
+	logger.Infof(s.context(), "Project status: %v", transactionResponse.Status)
 
 	if transactionResponse.Status == structs.Complete {
 
 		// Update withdrawal amount from Nodeserver
 		withdrawalAmount := 0
 		withdrawalInterface := transactionResponse.TransactionEvents.([]interface{})
-		log.Println("Withdrawal result")
-		log.Println(withdrawalInterface)
+		logger.Debug(s.context(), "Withdrawal result")
+		logger.Debug(s.context(), withdrawalInterface)
 
 		for _, funds := range withdrawalInterface {
-			withdrawalAmount := funds.(string)
-			log.Println(withdrawalAmount)
-		}
-
-		// Update Activity status to success
-		projectActivity.Status = constants.ActivitySuccess
-		projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.ProjectActivityUpdateFields(projectActivity)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Update project completed activity
-		project, err := models.ProjectFetchById(projectActivity.ProjectId)
-		if err != nil {
-			log.Fatal(err)
+			fundItem := funds.([]interface{})
+			logger.Debug(s.context(), funds)
+			withdrawalAmount, _ = strconv.Atoi(fundItem[0].(string))
+			logger.Debug(s.context(), withdrawalAmount)
 		}
 
-		// Send response back to backend if activities required are completed
-		if project.Status == constants.ProjectMilestoneSuccess {
-
-			project.Status = constants.ProjectEnded
-			project, err = models.ProjectUpdateFields(project)
+		var project Project
+		projectEnded := false
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity status to success
+			projectActivity.Status = constants.ActivitySuccess
+			projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.ProjectActivities.UpdateFields(projectActivity)
 			if err != nil {
-				log.Fatal(err)
 				return err
 			}
 
-			projectId := strconv.Itoa(project.Id)
-			backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.FundWithdrawal)
-			// TODO: Funds released
-			requestParameters := req.Param{
-				"event_type":       constants.FundWithdrawal,
-				"project_id":       project.Id,
-				"project_contract": project.ContractAddress,
-				"status":           true,
-				"funds_released":   withdrawalAmount,
+			// Update project completed activity
+			project, err = tx.Projects.FetchById(projectActivity.ProjectId)
+			if err != nil {
+				return errs.Store(err, "Could not find project %d", projectActivity.ProjectId)
+			}
+			project.ActivitiesCompleted = append(project.ActivitiesCompleted, string(constants.WithdrawFunds))
+
+			// The project ends once the funds of its final milestone are withdrawn
+			if project.Status == constants.ProjectMilestoneSuccess {
+				project.Status = constants.ProjectEnded
+				projectEnded = true
 			}
-			_, err = PostBackend(requestParameters, backendURL)
+			project, err = tx.Projects.UpdateFields(project)
 			if err != nil {
-				log.Fatal(err)
 				return err
 			}
+
+			// Queue the backend event with the changes
+			if projectEnded {
+				projectId := strconv.Itoa(project.Id)
+				backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.FundWithdrawal)
+				// TODO: Funds released
+				requestParameters := req.Param{
+					"event_type":       constants.FundWithdrawal,
+					"project_id":       project.Id,
+					"project_contract": project.ContractAddress,
+					"status":           true,
+					"funds_released":   withdrawalAmount,
+				}
+				err = s.enqueueBackend(tx, projectEvents(project.Id), backendURL, requestParameters)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Error(s.context(), err)
+			return err
 		}
 
 	}
@@ -124,7 +144,7 @@ func WithdrawFundsCallback(transactionResponse NodeServerModel, projectActivity
 }
 
 // RequestRefundCallback()
-func RequestRefundCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
+func (s *Service) RequestRefundCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
 
 	// TODO Use constants map
 	// Only process if Nodeserver postback response successful
@@ -133,55 +153,66 @@ func RequestRefundCallback(transactionResponse NodeServerModel, projectActivity
 		// Update refund amount from Nodeserver
 		refundAmount := 0
 		refundInterface := transactionResponse.TransactionEvents.([]interface{})
-		log.Println("Refund result")
-		log.Println(refundInterface)
+		logger.Debug(s.context(), "Refund result")
+		logger.Debug(s.context(), refundInterface)
 
 		for _, funds := range refundInterface {
 			fundItem := funds.([]interface{})
-			log.Println(funds)
+			logger.Debug(s.context(), funds)
 			refundAmount, _ = strconv.Atoi(fundItem[0].(string))
-			log.Println(refundAmount)
-		}
-
-		// Update Activity status to success
-		projectActivity.Status = constants.ActivitySuccess
-		projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.ProjectActivityUpdateFields(projectActivity)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Update project completed activity
-		project, err := models.ProjectFetchById(projectActivity.ProjectId)
-		if err != nil {
-			log.Fatal(err)
+			logger.Debug(s.context(), refundAmount)
 		}
 
-		// Send response back to backend if activities required are completed
-		if project.Status == constants.ProjectMilestoneFailed {
-
-			project.Status = constants.ProjectFailed
-			project, err = models.ProjectUpdateFields(project)
+		var project Project
+		projectFailed := false
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity status to success
+			projectActivity.Status = constants.ActivitySuccess
+			projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.ProjectActivities.UpdateFields(projectActivity)
 			if err != nil {
-				log.Fatal(err)
 				return err
 			}
 
-			projectId := strconv.Itoa(project.Id)
-			backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.FundWithdrawal)
-			// TODO: Funds released
-			requestParameters := req.Param{
-				"event_type":       constants.FundWithdrawal,
-				"project_id":       project.Id,
-				"project_contract": project.ContractAddress,
-				"status":           true,
-				"funds_released":   refundAmount,
+			// Update project completed activity
+			project, err = tx.Projects.FetchById(projectActivity.ProjectId)
+			if err != nil {
+				return errs.Store(err, "Could not find project %d", projectActivity.ProjectId)
+			}
+			project.ActivitiesCompleted = append(project.ActivitiesCompleted, string(constants.RequestRefund))
+
+			// The project fails once refunds start after a failed milestone
+			if project.Status == constants.ProjectMilestoneFailed {
+				project.Status = constants.ProjectFailed
+				projectFailed = true
 			}
-			_, err = PostBackend(requestParameters, backendURL)
+			project, err = tx.Projects.UpdateFields(project)
 			if err != nil {
-				log.Fatal(err)
 				return err
 			}
+
+			// Queue the backend event with the changes
+			if projectFailed {
+				projectId := strconv.Itoa(project.Id)
+				backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.FundWithdrawal)
+				// TODO: Funds released
+				requestParameters := req.Param{
+					"event_type":       constants.FundWithdrawal,
+					"project_id":       project.Id,
+					"project_contract": project.ContractAddress,
+					"status":           true,
+					"funds_released":   refundAmount,
+				}
+				err = s.enqueueBackend(tx, projectEvents(project.Id), backendURL, requestParameters)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Error(s.context(), err)
+			return err
 		}
 	}
 
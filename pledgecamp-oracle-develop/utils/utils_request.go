@@ -1,11 +1,13 @@
 package utils
 
 import (
-	"errors"
-	"log"
-	"os"
+	"context"
+	"time"
 
 	"github.com/imroc/req"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/metrics"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
@@ -14,68 +16,38 @@ type RequestParameters = req.Param
 type Response = *req.Resp
 type NodeServerModel = structs.NodeServerModel
 
-func PostNodeServer(requestParameters RequestParameters, uri string) (NodeServerModel, error) {
-
-	header := req.Header{
-		"Accept":        "application/json",
-		"Authorization": "Bearer " + os.Getenv("NODESERVER_AUTH_ACCESS_TOKEN"),
-	}
-
-	var fullUrl string
-	fullUrl = os.Getenv("NODESERVER_URL") + uri
-	log.Printf("Making a request to %s", fullUrl)
-	response, err := req.Post(fullUrl, header, requestParameters)
-	var NewResponse NodeServerModel
-	if err != nil {
-		log.Fatal(err)
-		return NewResponse, err
-	} else if response.Response().StatusCode > 201 {
-		err := errors.New("Server response failed")
-		log.Fatal(err)
-		return NewResponse, err
-	}
-	response.ToJSON(&NewResponse)
-	return NewResponse, nil
+func (s *Service) PostBackend(requestParameters RequestParameters, uri string) (Response, error) {
+	return s.postBackend(context.Background(), requestParameters, uri)
 }
 
-func GetNodeServer(requestParameters RequestParameters, uri string) (*req.Resp, error) {
-
-	header := req.Header{
-		"Accept":        "application/json",
-		"Authorization": "Bearer " + os.Getenv("NODESERVER_AUTH_ACCESS_TOKEN"),
-	}
-
-	var fullUrl string
-	fullUrl = os.Getenv("NODESERVER_URL") + uri
-	response, err := req.Get(fullUrl, header, requestParameters)
-	if err != nil {
-		return response, err
-	}
-	return response, nil
-}
-
-func PostBackend(requestParameters RequestParameters, uri string) (Response, error) {
+// postBackend - post to the backend with the request ID of ctx, so its logs can be matched with the Oracle's
+func (s *Service) postBackend(ctx context.Context, requestParameters RequestParameters, uri string) (Response, error) {
 
 	//TODO: Enable basic auth
 	header := req.Header{
 		"Accept":        "application/json",
 		"Content-Type":  "application/json",
-		"Authorization": "Bearer " + os.Getenv("BACKEND_AUTH_ACCESS_TOKEN"),
+		"Authorization": "Bearer " + s.Config.Backend.AccessToken,
+	}
+	if requestId := logger.RequestID(ctx); requestId != "" {
+		header[logger.RequestIDHeader] = requestId
 	}
 
 	var fullUrl string
-	fullUrl = os.Getenv("BACKEND_URL") + uri
+	fullUrl = s.Config.Backend.URL + uri
 
 	// TODO: Check backend validation of types
+	start := time.Now()
 	response, err := req.Post(fullUrl, header, requestParameters)
+	if err == nil && response.Response().StatusCode > 201 {
+		err = errs.New(errs.Backend, "Backend responded with status %d", response.Response().StatusCode)
+	} else if err != nil {
+		err = errs.Wrap(errs.Backend, err, "Backend request failed")
+	}
+	metrics.BackendRequestDuration.Observe(metrics.Since(start), metrics.Outcome(err))
 	if err != nil {
-		log.Fatal(err)
-		return response, err
-	} else if response.Response().StatusCode > 201 {
-		err := errors.New("Server response failed")
-		log.Fatal(err)
-		return response, err
+		logger.Error(ctx, err)
 	}
 
-	return response, nil
+	return response, err
 }
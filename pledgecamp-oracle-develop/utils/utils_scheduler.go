@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/metrics"
+)
+
+// Scheduler jobs, as labelled in the metrics
+const (
+	jobMilestone       = "milestone"
+	jobRecovery        = "recovery"
+	jobCancellation    = "cancellation"
+	jobInterest        = "interest"
+	jobReconciliation  = "reconciliation"
+	jobIdempotency     = "idempotency"
+	jobOutbox          = "outbox"
+	jobLeader          = "leader"
+	jobWorker          = "jobs"
+	jobMilestoneTimers = "milestone_timers"
+)
+
+// SchedulerJobs - names of the jobs RunJobOnce can run
+var SchedulerJobs = []string{jobMilestone, jobRecovery, jobCancellation, jobInterest, jobReconciliation, jobIdempotency, jobOutbox}
+
+// jobRun - single run of a scheduler job, returning how many projects, transactions or events it handled
+type jobRun func(ctx context.Context) (int, error)
+
+// A scheduler job that has not beaten for missedBeats intervals plus heartbeatGrace is considered dead, the
+// grace lets a run that is slower than its interval finish
+const (
+	missedBeats    = 2
+	heartbeatGrace = 30 * time.Second
+)
+
+// heartbeat - interval of a scheduler job and when its goroutine last ticked
+type heartbeat struct {
+	Interval string    `json:"interval"`
+	BeatAt   time.Time `json:"beat_at"`
+	interval time.Duration
+}
+
+// scheduledJob - interval started by startJob. Cancelling ctx asks a running job to stop at the next point
+// where it can, and the job is stopped once its interval took the clear
+type scheduledJob struct {
+	name    string
+	clear   chan bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+}
+
+var (
+	heartbeatsMu sync.Mutex
+	heartbeats   = map[string]heartbeat{}
+
+	jobsMu sync.Mutex
+	jobs   []*scheduledJob
+)
+
+// beat - record that the goroutine of job is ticking every interval
+func beat(job string, interval time.Duration) {
+	heartbeatsMu.Lock()
+	defer heartbeatsMu.Unlock()
+	heartbeats[job] = heartbeat{Interval: interval.String(), BeatAt: time.Now(), interval: interval}
+}
+
+// runJob - run a scheduler job and record how long it took and when it last succeeded
+func runJob(job string, run func() error) {
+	start := time.Now()
+	err := run()
+	metrics.SchedulerRunDuration.Observe(metrics.Since(start), job, metrics.Outcome(err))
+	if err == nil {
+		metrics.SchedulerLastSuccess.Set(float64(time.Now().Unix()), job)
+	}
+}
+
+// startJob - run job every interval on its own goroutine until StopJobs, beating before and after each run so
+// readiness can tell a stuck or stopped job. The context given to run is cancelled when the job should stop,
+// runs check it between items and never abandon a Nodeserver or backend request already sent. Only the
+// scheduler leader runs its jobs, the other instances keep beating and skip them
+func startJob(job string, interval time.Duration, run func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduled := &scheduledJob{name: job, ctx: ctx, cancel: cancel, stopped: make(chan struct{})}
+
+	beat(job, interval)
+	scheduled.clear = SetInterval(func() {
+		if ctx.Err() != nil {
+			return
+		}
+		beat(job, interval)
+		if job != jobLeader && !leading(time.Now()) {
+			return
+		}
+		runJob(job, func() error { return run(ctx) })
+		beat(job, interval)
+	}, int(interval/time.Millisecond), false)
+
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	jobs = append(jobs, scheduled)
+}
+
+// schedulerJobs - a single run of each scheduler job
+func (s *Service) schedulerJobs() map[string]jobRun {
+	return map[string]jobRun{
+		jobMilestone:      s.milestoneJob,
+		jobRecovery:       s.recoveryJob,
+		jobCancellation:   s.cancellationJob,
+		jobInterest:       s.interestJob,
+		jobReconciliation: s.reconciliationJob,
+		jobIdempotency:    s.idempotencyJob,
+		jobOutbox: func(ctx context.Context) (int, error) {
+			return s.dispatchOutbox(ctx, s.Config.Outbox)
+		},
+	}
+}
+
+// RunJobOnce - run job now, outside of its interval, recording the run like a scheduled one. Cancelling
+// ctx stops the run at the next point where it can
+func (s *Service) RunJobOnce(ctx context.Context, job string) error {
+	run, ok := s.schedulerJobs()[job]
+	if !ok {
+		return errs.New(errs.Validation, "Unknown scheduler job %q, expected one of %s", job, strings.Join(SchedulerJobs, ", "))
+	}
+	var err error
+	runJob(job, func() error {
+		_, err = run(ctx)
+		return err
+	})
+	return err
+}
+
+// StopJobs - stop every job started so far. Running jobs are asked to stop and waited for until ctx is done,
+// the jobs still running then are returned in the error
+func StopJobs(ctx context.Context) error {
+	jobsMu.Lock()
+	stopping := jobs
+	jobs = nil
+	jobsMu.Unlock()
+
+	for _, job := range stopping {
+		job.cancel()
+		go func(job *scheduledJob) {
+			// Taken by the interval goroutine once the current run is over
+			job.clear <- true
+			close(job.stopped)
+		}(job)
+	}
+
+	var running []string
+	for _, job := range stopping {
+		select {
+		case <-job.stopped:
+		case <-ctx.Done():
+			select {
+			case <-job.stopped:
+			default:
+				running = append(running, job.name)
+			}
+		}
+	}
+	if len(running) > 0 {
+		return errs.Wrap(errs.Internal, ctx.Err(), "Scheduler jobs still running: %s", strings.Join(running, ", "))
+	}
+	return nil
+}
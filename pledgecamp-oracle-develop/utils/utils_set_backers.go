@@ -3,26 +3,27 @@ package utils
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
-	"os"
 	"strconv"
 
 	"github.com/imroc/req"
 	"github.com/lib/pq"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 // SetBackers()
 // TODO Add comments
-func SetBackers(setBackersRequest RequestSetBackers) error {
+func (s *Service) SetBackers(setBackersRequest RequestSetBackers) error {
+	s = s.withFields(logger.Fields{"project_id": setBackersRequest.FkProjectId})
 
 	// Activity Definitions
 	projectId := strconv.Itoa(setBackersRequest.FkProjectId)
 	activityReference := string(constants.SetBackers)
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/projects/" + projectId + "/callback/" + activityReference
-	nodeServerURL := "/manager/projects/" + projectId + "/" + activityReference
+	oracleCallbackURL := s.Config.App.Domain + "/projects/" + projectId + "/callback/" + activityReference
 
 	// Prepare project parameters with information from incoming request
 	var projectParams = ProjectParameters{
@@ -34,33 +35,33 @@ func SetBackers(setBackersRequest RequestSetBackers) error {
 	inrec, _ := json.Marshal(projectParams)
 	json.Unmarshal(inrec, &inInterface)
 
-	// Create project activity for tracking deployment
-	projectActivity, err := models.SetProjectActivity(setBackersRequest.FkProjectId, constants.SetBackers)
+	// Create the base project
+	project, err := s.Projects.FetchById(setBackersRequest.FkProjectId)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return errs.Store(err, "Could not find project %d", setBackersRequest.FkProjectId)
 	}
 
-	// Create the base project
-	project, _ := models.ProjectFetchById(setBackersRequest.FkProjectId)
+	// Create project activity for tracking deployment
+	projectActivity, err := models.SetProjectActivity(s.ProjectActivities, setBackersRequest.FkProjectId, constants.SetBackers)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return err
 	}
+	s = s.withFields(logger.Fields{"activity_id": projectActivity.Id})
 
 	// Pass incoming request to Nodeserver
-	requestParameters := req.Param{
-		"transaction_type": activityReference,
-		"beneficiaries":    projectParams.Backers,
-		"amounts":          projectParams.Amounts,
-		"funding_complete": projectParams.FundingComplete,
-		"total_amount":     setBackersRequest.TotalAmount,
-		"contract_address": project.ContractAddress,
-		"activity_id":      projectActivity.Id,
-		"url_callback":     oracleCallbackURL,
-	}
-	_, err = PostNodeServer(requestParameters, nodeServerURL)
+	_, err = s.NodeServer.SetBackers(s.context(), nodeserver.SetBackersRequest{
+		ProjectId:       setBackersRequest.FkProjectId,
+		ContractAddress: project.ContractAddress,
+		Beneficiaries:   projectParams.Backers,
+		Amounts:         projectParams.Amounts,
+		FundingComplete: projectParams.FundingComplete,
+		TotalAmount:     setBackersRequest.TotalAmount,
+		Callback:        nodeserver.Callback{ActivityId: projectActivity.Id, URL: oracleCallbackURL},
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return err
 	}
 
@@ -69,62 +70,69 @@ func SetBackers(setBackersRequest RequestSetBackers) error {
 
 // TODO Add comments
 // SetBackersCallback()
-func SetBackersCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
+func (s *Service) SetBackersCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
 
 	// Only process if Nodeserver postback response successful
-	log.Printf("Project status: %v", transactionResponse.Status)
+	logger.Infof(s.context(), "Project status: %v", transactionResponse.Status)
 
 	if transactionResponse.Status == structs.Complete {
 
-		// Update Activity status to success
-		projectActivity.Status = constants.ActivitySuccess
-		projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.ProjectActivityUpdateFields(projectActivity)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Update project status & completed activity
-		project, err := models.ProjectFetchById(projectActivity.ProjectId)
-		if err != nil {
-			log.Fatal(err)
-		}
-		project.Status = constants.ProjectMilestonePhase
-		project.ActivitiesCompleted = append(project.ActivitiesCompleted, string(constants.SetBackers))
-		project, err = models.ProjectUpdateFields(project)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Create list of required activities to mark PROJECT_CREATE process as complete
-		// TODO Copy this postback across all the subsequent PROJECT_CREATE events, they may complete out of order
-		activitiesToComplete := [3]constants.ActivityReference{}
-		activitiesToComplete[0] = constants.ProjectDeploy
-		activitiesToComplete[1] = constants.SetBackers
-		activitiesToComplete[2] = constants.SetProjectInfo
-		activitiesCompleted := true
-		for _, targetActivity := range activitiesToComplete {
-			activitiesCompleted = models.CheckCompletedActivity(targetActivity, project.ActivitiesCompleted)
-			if activitiesCompleted == false {
-				break
+		var project Project
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity status to success
+			projectActivity.Status = constants.ActivitySuccess
+			projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.ProjectActivities.UpdateFields(projectActivity)
+			if err != nil {
+				return err
 			}
-		}
 
-		// Send response back to backend if activities required are completed
-		if activitiesCompleted {
-			projectId := strconv.Itoa(project.Id)
-			backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.ProjectComplete)
-			requestParameters := req.Param{
-				"event_type":       constants.ProjectComplete,
-				"project_id":       project.Id,
-				"project_contract": project.ContractAddress,
-				"status":           true,
+			// Update project status & completed activity
+			project, err = tx.Projects.FetchById(projectActivity.ProjectId)
+			if err != nil {
+				return errs.Store(err, "Could not find project %d", projectActivity.ProjectId)
 			}
-			_, err = PostBackend(requestParameters, backendURL)
+			project.Status = constants.ProjectMilestonePhase
+			project.ActivitiesCompleted = append(project.ActivitiesCompleted, string(constants.SetBackers))
+			project, err = tx.Projects.UpdateFields(project)
 			if err != nil {
-				log.Fatal(err)
 				return err
 			}
+
+			// Create list of required activities to mark PROJECT_CREATE process as complete
+			// TODO Copy this postback across all the subsequent PROJECT_CREATE events, they may complete out of order
+			activitiesToComplete := [3]constants.ActivityReference{}
+			activitiesToComplete[0] = constants.ProjectDeploy
+			activitiesToComplete[1] = constants.SetBackers
+			activitiesToComplete[2] = constants.SetProjectInfo
+			activitiesCompleted := true
+			for _, targetActivity := range activitiesToComplete {
+				activitiesCompleted = models.CheckCompletedActivity(targetActivity, project.ActivitiesCompleted)
+				if activitiesCompleted == false {
+					break
+				}
+			}
+
+			// Queue the backend event with the changes if activities required are completed
+			if activitiesCompleted {
+				projectId := strconv.Itoa(project.Id)
+				backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.ProjectComplete)
+				requestParameters := req.Param{
+					"event_type":       constants.ProjectComplete,
+					"project_id":       project.Id,
+					"project_contract": project.ContractAddress,
+					"status":           true,
+				}
+				err = s.enqueueBackend(tx, projectEvents(project.Id), backendURL, requestParameters)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Error(s.context(), err)
+			return err
 		}
 
 	}
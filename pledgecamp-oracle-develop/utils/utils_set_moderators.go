@@ -2,50 +2,50 @@ package utils
 
 import (
 	"database/sql"
-	"log"
-	"os"
 	"strconv"
 
 	"github.com/imroc/req"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 // SetModerators - set moderators for moderation votes
-func SetModerators(moderatorRequest RequestSetModerators) error {
+func (s *Service) SetModerators(moderatorRequest RequestSetModerators) error {
+	s = s.withFields(logger.Fields{"project_id": moderatorRequest.FkProjectId})
 
 	// Activity Definitions
 	projectId := strconv.Itoa(moderatorRequest.FkProjectId)
 	activityReference := string(constants.SetModerators)
-	nodeServerURL := "/cs/projects/" + projectId + "/" + activityReference
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/projects/" + projectId + "/callback/" + activityReference
+	oracleCallbackURL := s.Config.App.Domain + "/projects/" + projectId + "/callback/" + activityReference
 
-	projectActivity, err := models.SetProjectActivity(moderatorRequest.FkProjectId, constants.SetModerators)
+	// Create the base project
+	project, err := s.Projects.FetchById(moderatorRequest.FkProjectId)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return errs.Store(err, "Could not find project %d", moderatorRequest.FkProjectId)
 	}
 
-	// Create the base project
-	project, _ := models.ProjectFetchById(moderatorRequest.FkProjectId)
+	projectActivity, err := models.SetProjectActivity(s.ProjectActivities, moderatorRequest.FkProjectId, constants.SetModerators)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return err
 	}
+	s = s.withFields(logger.Fields{"activity_id": projectActivity.Id})
 
 	// Send request to stake PLG for CS to Nodeserver
-	requestParameters := req.Param{
-		"transaction_type":    activityReference,
-		"contract_address":    project.ContractAddress,
-		"moderators":          moderatorRequest.Moderators,
-		"moderation_end_time": moderatorRequest.ModerationEndTime,
-		"activity_id":         projectActivity.Id,
-		"url_callback":        oracleCallbackURL,
-	}
-
-	_, err = PostNodeServer(requestParameters, nodeServerURL)
+	_, err = s.NodeServer.SetModerators(s.context(), nodeserver.SetModeratorsRequest{
+		ProjectId:         moderatorRequest.FkProjectId,
+		ContractAddress:   project.ContractAddress,
+		Moderators:        moderatorRequest.Moderators,
+		ModerationEndTime: moderatorRequest.ModerationEndTime,
+		Callback:          nodeserver.Callback{ActivityId: projectActivity.Id, URL: oracleCallbackURL},
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return err
 	}
 
@@ -53,45 +53,52 @@ func SetModerators(moderatorRequest RequestSetModerators) error {
 }
 
 // SetModeratorsCallback()
-func SetModeratorsCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
+func (s *Service) SetModeratorsCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
 
 	// Only process if Nodeserver postback response successful
-	log.Printf("Project status: %v", transactionResponse.Status)
+	logger.Infof(s.context(), "Project status: %v", transactionResponse.Status)
 
 	if transactionResponse.Status == structs.Complete {
 
-		// Update Activity status to success
-		projectActivity.Status = constants.ActivitySuccess
-		projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.ProjectActivityUpdateFields(projectActivity)
-
-		// Update project status & completed activity
-		project, err := models.ProjectFetchById(projectActivity.ProjectId)
-		if err != nil {
-			log.Fatal(err)
-		}
-		project.Status = constants.ProjectModerationPhase
-		project, err = models.ProjectUpdateFields(project)
+		var project Project
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity status to success
+			projectActivity.Status = constants.ActivitySuccess
+			projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.ProjectActivities.UpdateFields(projectActivity)
+			if err != nil {
+				return err
+			}
+
+			// Update project status & completed activity
+			project, err = tx.Projects.FetchById(projectActivity.ProjectId)
+			if err != nil {
+				return errs.Store(err, "Could not find project %d", projectActivity.ProjectId)
+			}
+			project.Status = constants.ProjectModerationPhase
+			project, err = tx.Projects.UpdateFields(project)
+			if err != nil {
+				return err
+			}
+
+			// Queue the backend event with the changes
+			projectId := strconv.Itoa(project.Id)
+			backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.StartModeration)
+			requestParameters := req.Param{
+				"event_type":       constants.StartModeration,
+				"project_id":       project.Id,
+				"project_contract": project.ContractAddress,
+				"status":           true,
+			}
+			return s.enqueueBackend(tx, projectEvents(project.Id), backendURL, requestParameters)
+		})
 		if err != nil {
-			log.Fatal(err)
-		}
-
-		log.Println("The project moderators have been set.")
-
-		projectId := strconv.Itoa(project.Id)
-		backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.StartModeration)
-		requestParameters := req.Param{
-			"event_type":       constants.StartModeration,
-			"project_id":       project.Id,
-			"project_contract": project.ContractAddress,
-			"status":           true,
-		}
-		_, err = PostBackend(requestParameters, backendURL)
-		if err != nil {
-			log.Fatal(err)
+			logger.Error(s.context(), err)
 			return err
 		}
 
+		logger.Info(s.context(), "The project moderators have been set.")
+
 	}
 
 	return nil
@@ -3,31 +3,32 @@ package utils
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
-	"os"
 	"reflect"
 	"strconv"
 
 	"github.com/imroc/req"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 // SetProjectInfo()
-func SetProjectInfo(setInfoRequest RequestSetProjectInfo) error {
+func (s *Service) SetProjectInfo(setInfoRequest RequestSetProjectInfo) error {
+	s = s.withFields(logger.Fields{"project_id": setInfoRequest.FkProjectId})
 
 	// Activity Definitions
 	projectId := strconv.Itoa(setInfoRequest.FkProjectId)
 	activityReference := string(constants.SetProjectInfo)
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/projects/" + projectId + "/callback/" + activityReference
-	nodeServerURL := "/admin/projects/" + projectId + "/" + activityReference
+	oracleCallbackURL := s.Config.App.Domain + "/projects/" + projectId + "/callback/" + activityReference
 
 	// Create the base project
-	project, _ := models.ProjectFetchById(setInfoRequest.FkProjectId)
+	project, err := s.Projects.FetchById(setInfoRequest.FkProjectId)
 	if err != nil {
-		log.Fatal(err)
-		return err
+		logger.Error(s.context(), err)
+		return errs.Store(err, "Could not find project %d", setInfoRequest.FkProjectId)
 	}
 	// Prepare project parameters with information from incoming request
 	var projectParams = ProjectParameters{
@@ -39,7 +40,7 @@ func SetProjectInfo(setInfoRequest RequestSetProjectInfo) error {
 		TotalAmount:     setInfoRequest.TotalAmount,
 	}
 	milestones := project.ProjectParameters["milestones"]
-	log.Println("Milestones", reflect.TypeOf(milestones))
+	logger.Debug(s.context(), "Milestones", reflect.TypeOf(milestones))
 	convertedMilestones := make([]int64, len(milestones.([]interface{})))
 	for i := range milestones.([]interface{}) {
 		if reflect.TypeOf(milestones.([]interface{})[i]).String() == "string" {
@@ -50,9 +51,9 @@ func SetProjectInfo(setInfoRequest RequestSetProjectInfo) error {
 		}
 	}
 	projectParams.Milestones = convertedMilestones
-	log.Println("ReleasePercents")
+	logger.Debug(s.context(), "ReleasePercents")
 	releasePercents := project.ProjectParameters["release_percents"]
-	log.Println("ReleasePercents", reflect.TypeOf(releasePercents))
+	logger.Debug(s.context(), "ReleasePercents", reflect.TypeOf(releasePercents))
 	convertedRP := make([]int64, len(releasePercents.([]interface{})))
 	for i := range releasePercents.([]interface{}) {
 		convertedRP[i] = int64(releasePercents.([]interface{})[i].(float64))
@@ -61,7 +62,9 @@ func SetProjectInfo(setInfoRequest RequestSetProjectInfo) error {
 	if project.ProjectParameters["creator"] != nil {
 		projectParams.Creator = int64(project.ProjectParameters["creator"].(float64))
 	} else {
-		log.Fatal("Creator was not defined")
+		err := errs.New(errs.Conflict, "Creator was not defined for project %d", project.Id)
+		logger.Error(s.context(), err)
+		return err
 	}
 	var projectParamsInterface map[string]interface{}
 	inrec, _ := json.Marshal(projectParams)
@@ -69,29 +72,32 @@ func SetProjectInfo(setInfoRequest RequestSetProjectInfo) error {
 	project.Status = constants.ProjectDeployed
 	project.ProjectParameters = projectParamsInterface
 
-	// Insert model into the project table for the new request
-	_, err := models.ProjectUpdateFields(project)
-	if err != nil {
-		log.Fatal(err)
-		return err
-	}
+	var projectActivity ProjectActivity
+	err = s.Atomic(func(tx models.Store) error {
+		// Insert model into the project table for the new request
+		_, err := tx.Projects.UpdateFields(project)
+		if err != nil {
+			return err
+		}
 
-	projectActivity, err := models.SetProjectActivity(setInfoRequest.FkProjectId, constants.SetProjectInfo)
+		projectActivity, err = models.SetProjectActivity(tx.ProjectActivities, setInfoRequest.FkProjectId, constants.SetProjectInfo)
+		return err
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return err
 	}
+	s = s.withFields(logger.Fields{"activity_id": projectActivity.Id})
 
 	// Pass incoming request to Nodeserver
-	requestParameters := req.Param{
-		"transaction_type": activityReference,
-		"contract_address": project.ContractAddress,
-		"listing_fee":      setInfoRequest.ListingFee,
-		"activity_id":      projectActivity.Id,
-		"url_callback":     oracleCallbackURL,
-	}
-	_, err = PostNodeServer(requestParameters, nodeServerURL)
+	_, err = s.NodeServer.SetProjectInfo(s.context(), nodeserver.SetProjectInfoRequest{
+		ProjectId:       setInfoRequest.FkProjectId,
+		ContractAddress: project.ContractAddress,
+		ListingFee:      setInfoRequest.ListingFee,
+		Callback:        nodeserver.Callback{ActivityId: projectActivity.Id, URL: oracleCallbackURL},
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return err
 	}
 
@@ -99,45 +105,69 @@ func SetProjectInfo(setInfoRequest RequestSetProjectInfo) error {
 }
 
 // SetProjectInfoCallback()
-func SetProjectInfoCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
+func (s *Service) SetProjectInfoCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
 
 	// Only process if Nodeserver postback response successful
-	log.Printf("Project status: %v", transactionResponse.Status)
+	logger.Infof(s.context(), "Project status: %v", transactionResponse.Status)
 
 	if transactionResponse.Status == structs.Complete {
-		log.Println("Inside the Set Project Info callback")
+		logger.Debug(s.context(), "Inside the Set Project Info callback")
+
+		var project Project
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity record to complete
+			projectActivity.Status = constants.ActivitySuccess
+			projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.ProjectActivities.UpdateFields(projectActivity)
+			if err != nil {
+				return err
+			}
 
-		// Update Activity record to complete
-		projectActivity.Status = constants.ActivitySuccess
-		projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.ProjectActivityUpdateFields(projectActivity)
+			// Update project completed activity
+			project, err = tx.Projects.FetchById(projectActivity.ProjectId)
+			if err != nil {
+				return errs.Store(err, "Could not find project %d", projectActivity.ProjectId)
+			}
+			project.ActivitiesCompleted = append(project.ActivitiesCompleted, string(constants.SetProjectInfo))
+			project, err = tx.Projects.UpdateFields(project)
+			if err != nil {
+				return err
+			}
 
-		// Update project completed activity
-		project, err := models.ProjectFetchById(projectActivity.ProjectId)
-		if err != nil {
-			log.Fatal(err)
-		}
-		project.ActivitiesCompleted = append(project.ActivitiesCompleted, string(constants.SetProjectInfo))
-		project, err = models.ProjectUpdateFields(project)
-		if err != nil {
-			log.Fatal(err)
-		}
+			// TODO Add the activitiesCompleted Check
+			// Create list of required activities to mark PROJECT_CREATE process as complete
+			activitiesToComplete := [3]constants.ActivityReference{}
+			activitiesToComplete[0] = constants.ProjectDeploy
+			activitiesToComplete[1] = constants.SetBackers
+			activitiesToComplete[2] = constants.SetProjectInfo
+			activitiesCompleted := true
+			for _, targetActivity := range activitiesToComplete {
+				activitiesCompleted = models.CheckCompletedActivity(targetActivity, project.ActivitiesCompleted)
+				if activitiesCompleted == false {
+					break
+				}
+			}
 
-		// TODO Add the activitiesCompleted Check
-		// Create list of required activities to mark PROJECT_CREATE process as complete
-		activitiesToComplete := [3]constants.ActivityReference{}
-		activitiesToComplete[0] = constants.ProjectDeploy
-		activitiesToComplete[1] = constants.SetBackers
-		activitiesToComplete[2] = constants.SetProjectInfo
-		activitiesCompleted := true
-		for _, targetActivity := range activitiesToComplete {
-			activitiesCompleted = models.CheckCompletedActivity(targetActivity, project.ActivitiesCompleted)
-			if activitiesCompleted == false {
-				break
+			// Queue the backend event with the changes if activities required are completed
+			if activitiesCompleted {
+				projectId := strconv.Itoa(project.Id)
+				backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.ProjectComplete)
+				requestParameters := req.Param{
+					"event_type":       constants.ProjectComplete,
+					"project_id":       project.Id,
+					"project_contract": project.ContractAddress,
+					"status":           true,
+				}
+				return s.enqueueBackend(tx, projectEvents(project.Id), backendURL, requestParameters)
 			}
+			return nil
+		})
+		if err != nil {
+			logger.Error(s.context(), err)
+			return err
 		}
 
-		// Create request and initiate SetBackers()
+		// Create request and initiate SetBackers() once the changes are committed
 		var sbReq RequestSetBackers
 		sbReq.FkProjectId = project.Id
 		backers := project.ProjectParameters["backers"]
@@ -154,29 +184,14 @@ func SetProjectInfoCallback(transactionResponse NodeServerModel, projectActivity
 		sbReq.Amounts = convertedAmounts
 		sbReq.FundingComplete = project.ProjectParameters["funding_complete"].(bool)
 		sbReq.TotalAmount = int64(project.ProjectParameters["total_amount"].(float64))
-		log.Printf("Setting Backers: %s", project.ProjectParameters["backers"])
-		err = SetBackers(sbReq)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Send response back to backend if activities required are completed
-		if activitiesCompleted {
-			projectId := strconv.Itoa(project.Id)
-			backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.ProjectComplete)
-			requestParameters := req.Param{
-				"event_type":       constants.ProjectComplete,
-				"project_id":       project.Id,
-				"project_contract": project.ContractAddress,
-				"status":           true,
-			}
-			_, err = PostBackend(requestParameters, backendURL)
+		logger.Debugf(s.context(), "Setting Backers: %s", project.ProjectParameters["backers"])
+		return s.afterCommit(func(s *Service) error {
+			err := s.SetBackers(sbReq)
 			if err != nil {
-				log.Fatal(err)
-				return err
+				logger.Error(s.context(), err)
 			}
-		}
-
+			return err
+		})
 	}
 
 	return nil
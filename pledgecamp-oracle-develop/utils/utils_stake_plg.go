@@ -3,25 +3,24 @@ package utils
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/imroc/req"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 // StakePLG() - submit both milestone and moderation votes
-func StakePLG(stakeRequest RequestStakePLG) (CampShares, error) {
-	// fmt.Printf("%+v\n", project)
+func (s *Service) StakePLG(stakeRequest RequestStakePLG) (CampShares, error) {
+	s = s.withFields(logger.Fields{"user_id": stakeRequest.UserId})
 	userId := strconv.Itoa(stakeRequest.UserId)
 	activityReference := string(constants.StakePLG)
 
-	nodeServerURL := "/manager/cs/" + userId + "/" + activityReference
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/cs/" + userId + "/callback/" + activityReference
+	oracleCallbackURL := s.Config.App.Domain + "/cs/" + userId + "/callback/" + activityReference
 
 	cs := models.CampShares{
 		UserId:              stakeRequest.UserId,
@@ -36,9 +35,9 @@ func StakePLG(stakeRequest RequestStakePLG) (CampShares, error) {
 	inrec, _ := json.Marshal(cs)
 	json.Unmarshal(inrec, &inInterface)
 
-	latestCs, err := models.GetlatestCSId()
+	latestCs, err := s.CampShares.GetLatest()
 	if err != nil {
-		log.Println(err)
+		logger.Error(s.context(), err)
 	}
 
 	csId := latestCs.CSId + 1
@@ -48,30 +47,33 @@ func StakePLG(stakeRequest RequestStakePLG) (CampShares, error) {
 
 	cs.CSId = csId
 
-	// Input CS transaction into CampShare model
-	cs, err = models.CSInsert(cs)
-	if err != nil {
-		log.Fatal(err)
-	}
+	var csActivity models.CSActivity
+	err = s.Atomic(func(tx models.Store) error {
+		// Input CS transaction into CampShare model
+		var err error
+		cs, err = tx.CampShares.Insert(cs)
+		if err != nil {
+			return err
+		}
 
-	// Create cs activity for tracking purposes
-	csActivity, err := models.SetCSActivity(csId, constants.StakePLG)
+		// Create cs activity for tracking purposes
+		csActivity, err = models.SetCSActivity(tx.CSActivities, csId, constants.StakePLG)
+		return err
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return cs, err
 	}
+	s = s.withFields(logger.Fields{"activity_id": csActivity.Id})
 
 	// Send request to stake PLG for CS to Nodeserver
-	requestParameters := req.Param{
-		"transaction_type": activityReference,
-		"user_id":          cs.UserId,
-		"amount":           cs.Amount,
-		"activity_id":      csActivity.Id,
-		"url_callback":     oracleCallbackURL,
-	}
-
-	_, err = PostNodeServer(requestParameters, nodeServerURL)
+	_, err = s.NodeServer.StakePLG(s.context(), nodeserver.StakeRequest{
+		UserId:   cs.UserId,
+		Amount:   cs.Amount,
+		Callback: nodeserver.Callback{ActivityId: csActivity.Id, URL: oracleCallbackURL},
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return cs, err
 	}
 
@@ -79,55 +81,58 @@ func StakePLG(stakeRequest RequestStakePLG) (CampShares, error) {
 }
 
 // Follows default callback behavior in handlers_projects
-func StakePLGCallback(transactionResponse NodeServerModel, csActivity models.CSActivity) error {
+func (s *Service) StakePLGCallback(transactionResponse NodeServerModel, csActivity models.CSActivity) error {
 
 	// Only process if Nodeserver postback response successful
-	log.Printf("Project status: %v", transactionResponse.Status)
+	logger.Infof(s.context(), "Project status: %v", transactionResponse.Status)
 
 	if transactionResponse.Status == structs.Complete {
 
 		// Get the stake amount from event from Nodeserver
 		var stakeAmount int
 		stakeResultInterface := transactionResponse.TransactionEvents.([]interface{})
-		log.Println("Stake result")
-		log.Println(stakeResultInterface)
+		logger.Debug(s.context(), "Stake result")
+		logger.Debug(s.context(), stakeResultInterface)
 
 		for _, stakes := range stakeResultInterface {
 			stakeItem := stakes.([]interface{})
 			stakeAmount, _ = strconv.Atoi(stakeItem[1].(string))
 		}
 
-		// Update Activity status to success
-		csActivity.Status = constants.ActivitySuccess
-		csActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.CSActivityUpdateFields(csActivity)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Update project status & completed activity
-		cs, err := models.CSSearchCSId(csActivity.CsId)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		if stakeAmount != cs.Amount {
-			log.Printf("Error: Stake Amount of %v did not match stake amount from blockchain: %v \n", stakeAmount, cs.Amount)
-		}
-
-		// Send response back to backend if activities required are completed
-		userId := strconv.Itoa(cs.UserId)
-		backendURL := "/events/blockchain/cs/" + userId + "/" + string(constants.StakePLGEvent)
-		requestParameters := req.Param{
-			"event_type":  constants.StakePLGEvent,
-			"activity_id": csActivity.Id,
-			"user_id":     cs.UserId,
-			"status":      true,
-			"amount":      cs.Amount,
-		}
-		_, err = PostBackend(requestParameters, backendURL)
+		var cs CampShares
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity status to success
+			csActivity.Status = constants.ActivitySuccess
+			csActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.CSActivities.UpdateFields(csActivity)
+			if err != nil {
+				return err
+			}
+
+			// Update project status & completed activity
+			cs, err = tx.CampShares.SearchCSId(csActivity.CsId)
+			if err != nil {
+				return err
+			}
+
+			if stakeAmount != cs.Amount {
+				logger.Errorf(s.context(), "Error: Stake Amount of %v did not match stake amount from blockchain: %v \n", stakeAmount, cs.Amount)
+			}
+
+			// Queue the backend event with the changes
+			userId := strconv.Itoa(cs.UserId)
+			backendURL := "/events/blockchain/cs/" + userId + "/" + string(constants.StakePLGEvent)
+			requestParameters := req.Param{
+				"event_type":  constants.StakePLGEvent,
+				"activity_id": csActivity.Id,
+				"user_id":     cs.UserId,
+				"status":      true,
+				"amount":      cs.Amount,
+			}
+			return s.enqueueBackend(tx, csEvents(cs.UserId), backendURL, requestParameters)
+		})
 		if err != nil {
-			log.Fatal(err)
+			logger.Error(s.context(), err)
 			return err
 		}
 
@@ -4,23 +4,24 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
-	"log"
 	"math/big"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/imroc/req"
 	solsha3 "github.com/miguelmota/go-solidity-sha3"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 // TODO Split this vote in to moderation vote / milestone vote workflows but, have vote encryption as utility
 // SubmitVote() - submit both milestone and moderation votes
-func SubmitVote(votingRequest RequestVote) (Vote, error) {
+func (s *Service) SubmitVote(votingRequest RequestVote) (Vote, error) {
+	s = s.withFields(logger.Fields{"project_id": votingRequest.FkProjectId, "user_id": votingRequest.UserId})
 
 	// Activity Definitions
 	var activityType constants.ActivityReference
@@ -35,28 +36,29 @@ func SubmitVote(votingRequest RequestVote) (Vote, error) {
 		activityType = constants.ModerationVote
 		activityReference = string(constants.ModerationVote)
 	default:
-		log.Fatal(err)
+		err := errs.New(errs.Validation, "Invalid vote type %d", votingRequest.VoteType)
+		logger.Error(s.context(), err)
 		return vote, err
 	}
 
 	projectId := strconv.Itoa(votingRequest.FkProjectId)
 	userId := strconv.Itoa(votingRequest.UserId)
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/projects/" + projectId + "/callback/" + activityReference
-	nodeServerUrl := "/manager/projects/" + projectId + "/" + activityReference + "/" + userId
+	oracleCallbackURL := s.Config.App.Domain + "/projects/" + projectId + "/callback/" + activityReference
 
-	// Create project activity for tracking purposes
-	projectActivity, err := models.SetProjectActivity(votingRequest.FkProjectId, activityType)
+	// Create the base project
+	project, err := s.Projects.FetchById(votingRequest.FkProjectId)
 	if err != nil {
-		log.Fatal(err)
-		return vote, err
+		logger.Error(s.context(), err)
+		return vote, errs.Store(err, "Could not find project %d", votingRequest.FkProjectId)
 	}
 
-	// Create the base project
-	project, err := models.ProjectFetchById(votingRequest.FkProjectId)
+	// Create project activity for tracking purposes
+	projectActivity, err := models.SetProjectActivity(s.ProjectActivities, votingRequest.FkProjectId, activityType)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return vote, err
 	}
+	s = s.withFields(logger.Fields{"activity_id": projectActivity.Id})
 
 	var votingParams VotingParameters
 	var encryptedVote string
@@ -82,9 +84,9 @@ func SubmitVote(votingRequest RequestVote) (Vote, error) {
 				fourthParam,
 			},
 		)
-		log.Println("Encryption complete")
+		logger.Debug(s.context(), "Encryption complete")
 		encryptedVote = "0x" + hex.EncodeToString(argCombined)
-		log.Println(encryptedVote)
+		logger.Debug(s.context(), encryptedVote)
 		votingParams.EncryptedVote = encryptedVote
 
 		// Create the base project
@@ -102,11 +104,11 @@ func SubmitVote(votingRequest RequestVote) (Vote, error) {
 		vote.VoteParameters = inInterface
 
 		// Save the model
-		vote, err = models.VoteInsert(vote)
-		log.Print("Inside utils_submit_vote")
-		log.Print(vote)
+		vote, err = s.Votes.Insert(vote)
+		logger.Debug(s.context(), "Inside utils_submit_vote")
+		logger.Debug(s.context(), vote)
 		if err != nil {
-			log.Fatal(err)
+			logger.Error(s.context(), err)
 			return vote, err
 		}
 
@@ -115,40 +117,29 @@ func SubmitVote(votingRequest RequestVote) (Vote, error) {
 	// Detect path based on the VoteType
 	switch votingRequest.VoteType {
 	case 0: // Milestone Votes
-		requestParameters := req.Param{
-			"transaction_type": activityReference,
-			"contract_address": project.ContractAddress,
-			"user_id":          votingRequest.UserId,
-			"vote":             votingRequest.Vote,
-			"activity_id":      projectActivity.Id,
-			"url_callback":     oracleCallbackURL,
-		}
-
-		_, err = PostNodeServer(requestParameters, nodeServerUrl)
+		_, err = s.NodeServer.MilestoneVote(s.context(), nodeserver.MilestoneVoteRequest{
+			ProjectId:       votingRequest.FkProjectId,
+			ContractAddress: project.ContractAddress,
+			UserId:          votingRequest.UserId,
+			Vote:            votingRequest.Vote,
+			Callback:        nodeserver.Callback{ActivityId: projectActivity.Id, URL: oracleCallbackURL},
+		})
 		if err != nil {
-			log.Fatal(err)
+			logger.Error(s.context(), err)
 			return vote, err
 		}
 	case 1: // Cancellation Votes
-
-		requestParameters := req.Param{
-			"transaction_type": activityReference,
-			"contract_address": project.ContractAddress,
-			"user_id":          votingRequest.UserId,
-			"encrypted_vote":   encryptedVote,
-			"activity_id":      projectActivity.Id,
-			"url_callback":     oracleCallbackURL,
-		}
-
-		_, err = PostNodeServer(requestParameters, nodeServerUrl)
+		_, err = s.NodeServer.ModerationVote(s.context(), nodeserver.ModerationVoteRequest{
+			ProjectId:       votingRequest.FkProjectId,
+			ContractAddress: project.ContractAddress,
+			UserId:          votingRequest.UserId,
+			EncryptedVote:   encryptedVote,
+			Callback:        nodeserver.Callback{ActivityId: projectActivity.Id, URL: oracleCallbackURL},
+		})
 		if err != nil {
-			log.Fatal(err)
+			logger.Error(s.context(), err)
 			return vote, err
 		}
-	default:
-		err = errors.New("Error relating to vote type")
-		log.Fatal(err)
-		return vote, err
 	}
 
 	// Create project activity for tracking deployment
@@ -163,61 +154,51 @@ func SubmitVote(votingRequest RequestVote) (Vote, error) {
 		projectActivity.CreatedAt = time.Now()
 		projectActivity.ModifiedAt = time.Now()
 		projectActivity.Type = constants.ModerationVote
-	default:
-		log.Fatal(err)
 	}
 
-	projectActivity, err = models.ProjectActivityInsert(projectActivity)
+	projectActivity, err = s.ProjectActivities.Insert(projectActivity)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return vote, err
 	}
 
 	return vote, nil
 }
 
-func VoteCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
+func (s *Service) VoteCallback(transactionResponse NodeServerModel, projectActivity ProjectActivity) error {
 
 	// Only process if Nodeserver postback response successful
-	log.Printf("Project status: %v", transactionResponse.Status)
+	logger.Infof(s.context(), "Project status: %v", transactionResponse.Status)
 
 	if transactionResponse.Status == structs.Complete {
 
-		// Update Activity status to success
-		projectActivity.Status = constants.ActivitySuccess
-		projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.ProjectActivityUpdateFields(projectActivity)
-		if err != nil {
-			log.Fatal(err)
-			return err
-		}
-
 		var beneficiary int
 		var voteBool bool
 		var voteEncrypted string
 
 		if projectActivity.Type == constants.MilestoneVote {
-			log.Println("Milestone Vote Transaction Events:")
+			logger.Debug(s.context(), "Milestone Vote Transaction Events:")
 			voteInterface := transactionResponse.TransactionEvents.([]interface{})
-			log.Println(voteInterface)
+			logger.Debug(s.context(), voteInterface)
 			for _, vote := range voteInterface {
 				voteItem := vote.([]interface{})
-				log.Println(vote)
+				logger.Debug(s.context(), vote)
 				beneficiary, _ = strconv.Atoi(voteItem[0].(string))
-				log.Println(beneficiary)
+				logger.Debug(s.context(), beneficiary)
 				voteBool, _ = voteItem[1].(bool)
-				log.Println(voteBool)
+				logger.Debug(s.context(), voteBool)
 			}
 		} else if projectActivity.Type == constants.ModerationVote {
-			log.Println("Moderation Vote Transaction Events:")
+			logger.Debug(s.context(), "Moderation Vote Transaction Events:")
 			voteInterface := transactionResponse.TransactionEvents.([]interface{})
-			log.Println(voteInterface)
+			logger.Debug(s.context(), voteInterface)
 			for _, vote := range voteInterface {
 				voteItem := vote.([]interface{})
-				log.Println(vote)
+				logger.Debug(s.context(), vote)
 				beneficiary, _ = strconv.Atoi(voteItem[1].(string))
-				log.Println(beneficiary)
+				logger.Debug(s.context(), beneficiary)
 				voteEncrypted, _ = voteItem[2].(string)
-				log.Println(voteEncrypted)
+				logger.Debug(s.context(), voteEncrypted)
 			}
 		}
 
@@ -241,30 +222,34 @@ func VoteCallback(transactionResponse NodeServerModel, projectActivity ProjectAc
 		voteInfo.FkProjectId = projectActivity.ProjectId
 		voteInfo.VoteParameters = voteParamsInterface
 
-		_, err = models.VoteInsert(voteInfo)
-		if err != nil {
-			log.Fatal(err)
-			return err
-		}
-
-		projectId := strconv.Itoa(voteInfo.FkProjectId)
-
-		if projectActivity.Type == constants.MilestoneVote {
-			backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.MilestoneVoteEvent)
-			requestParameters := req.Param{
-				"event_type":       constants.MilestoneVoteEvent,
-				"project_id":       voteInfo.FkProjectId,
-				"user_id":          voteInfo.UserId,
-				"project_contract": voteInfo.ContractAddress,
-				"status":           true,
-				"vote":             voteBool,
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity status to success
+			projectActivity.Status = constants.ActivitySuccess
+			projectActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.ProjectActivities.UpdateFields(projectActivity)
+			if err != nil {
+				return err
 			}
-			_, err = PostBackend(requestParameters, backendURL)
+
+			_, err = tx.Votes.Insert(voteInfo)
 			if err != nil {
-				log.Fatal(err)
 				return err
 			}
-		} else {
+
+			// Queue the backend event with the changes
+			projectId := strconv.Itoa(voteInfo.FkProjectId)
+			if projectActivity.Type == constants.MilestoneVote {
+				backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.MilestoneVoteEvent)
+				requestParameters := req.Param{
+					"event_type":       constants.MilestoneVoteEvent,
+					"project_id":       voteInfo.FkProjectId,
+					"user_id":          voteInfo.UserId,
+					"project_contract": voteInfo.ContractAddress,
+					"status":           true,
+					"vote":             voteBool,
+				}
+				return s.enqueueBackend(tx, projectEvents(voteInfo.FkProjectId), backendURL, requestParameters)
+			}
 			backendURL := "/events/blockchain/projects/" + projectId + "/" + string(constants.ModerationVoteEvent)
 			requestParameters := req.Param{
 				"event_type":       constants.ModerationVoteEvent,
@@ -274,18 +259,18 @@ func VoteCallback(transactionResponse NodeServerModel, projectActivity ProjectAc
 				"status":           true,
 				"vote":             voteEncrypted,
 			}
-			_, err = PostBackend(requestParameters, backendURL)
-			if err != nil {
-				log.Fatal(err)
-				return err
-			}
+			return s.enqueueBackend(tx, projectEvents(voteInfo.FkProjectId), backendURL, requestParameters)
+		})
+		if err != nil {
+			logger.Error(s.context(), err)
+			return err
 		}
 
 		if projectActivity.Type == constants.ModerationVote {
 
-			votes, err := models.VoteSearchProjectIdVoteType(voteInfo.FkProjectId, 1)
+			votes, err := s.Votes.SearchProjectIdVoteType(voteInfo.FkProjectId, 1)
 			if err != nil {
-				log.Fatal(err)
+				logger.Error(s.context(), err)
 				return err
 			}
 
@@ -295,20 +280,23 @@ func VoteCallback(transactionResponse NodeServerModel, projectActivity ProjectAc
 
 			if len(votes) >= 7 {
 				for _, vote := range votes {
-					log.Println(vote.VoteParameters["vote_type"].(float64))
+					logger.Debug(s.context(), vote.VoteParameters["vote_type"].(float64))
 					encryptedVotes = append(encryptedVotes, vote.VoteParameters["encrypted_vote"].(string))
 					decryptionKey = append(decryptionKey, vote.VoteParameters["decryption_key"].(string))
-					log.Println(requestCommitVotes)
+					logger.Debug(s.context(), requestCommitVotes)
 				}
 
 				requestCommitVotes.FkProjectId = voteInfo.FkProjectId
 				requestCommitVotes.EncryptedVotes = encryptedVotes
 				requestCommitVotes.DecryptionKeys = decryptionKey
 
-				err := CommitModerationVotes(requestCommitVotes)
-				if err != nil {
-					log.Printf("An error was returned: %d", err)
-				}
+				s.afterCommit(func(s *Service) error {
+					err := s.CommitModerationVotes(requestCommitVotes)
+					if err != nil {
+						logger.Debugf(s.context(), "An error was returned: %d", err)
+					}
+					return nil
+				})
 
 			}
 
@@ -1,29 +1,35 @@
 package utils
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/imroc/req"
 	"github.com/joho/godotenv"
 	"github.com/lib/pq"
-	"github.com/pledgecamp/pledgecamp-oracle/connect"
+	"github.com/pledgecamp/pledgecamp-oracle/config"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
+	"github.com/pledgecamp/pledgecamp-oracle/metrics"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
+	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 const (
-	projectTable    = "project"
-	voteTable       = "votes"
-	csTable         = "campshare"
-	csActivityTable = "cs_activity"
-	activityTable   = "project_activity"
-	layout          = "2006-01-02T15:04:05"
+	layout = "2006-01-02T15:04:05"
 )
 
 var server *httptest.Server
@@ -36,52 +42,50 @@ var testActivityId int
 var testProjectId int
 var testCSId int
 
+// Utils tests run against the in-memory stores, so no database is required
+var testService *Service
+var testConfig config.Config
+
+// testEnv - settings used unless configured otherwise, the requests go to the local stub servers
+var testEnv = map[string]string{
+	"APP_DOMAIN":                   "http://localhost:4010",
+	"APP_PORT":                     "4010",
+	"BACKEND_AUTH_ACCESS_TOKEN":    "test_backend",
+	"BACKEND_URL":                  "http://localhost:5010",
+	"CORS_ALLOWED_ORIGINS":         "*",
+	"CS_UNSTAKE_PERIOD":            "90",
+	"DB_HOST":                      "127.0.0.1",
+	"DB_NAME":                      "pledgecamp_oracle_test",
+	"DB_PASS":                      "test",
+	"DB_PORT":                      "6012",
+	"DB_USER":                      "pledgecamp_oracle",
+	"ENV_MODE":                     "test",
+	"INTERVALS_CHECK_MILESTONE":    "500000",
+	"INTERVALS_FUND_RECOVERY":      "100000",
+	"NODESERVER_AUTH_ACCESS_TOKEN": "test_internal",
+	"NODESERVER_URL":               "http://localhost:3010/api",
+}
+
 func init() {
-	err := godotenv.Load("../.env")
+	godotenv.Load("../.env")
+
+	for key, value := range testEnv {
+		if os.Getenv(key) == "" {
+			os.Setenv(key, value)
+		}
+	}
+	testConfig, err = config.Load()
 	if err != nil {
-		log.Fatal("Models Test - No .env file found")
+		log.Fatal(err)
 	}
 
-	env := connect.LookupEnvOrExit("ENV_MODE")
-
-	if strings.ToLower(env) != "test" {
-		log.Fatal("Please set ENV_MODE to 'test' in .env file, change all URLs to 'http' and update 'DB_NAME' in .env file  & rerun DB migrations for testing DB.")
-	}
+	testService = NewService(testConfig, models.NewMemoryStore(), nodeserver.NewClient(testConfig.NodeServer))
 }
 
 func TestMain(m *testing.M) {
 
 	log.Println("********************************* TestMain() **************************************")
 
-	// Truncate table before beginning
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
-	query := dbConnection.DeleteFrom(voteTable)
-	_, err = query.Exec()
-	if err != nil {
-		log.Fatalf("DeleteFrom(): %q\n", err)
-	}
-	query = dbConnection.DeleteFrom(csActivityTable)
-	_, err = query.Exec()
-	if err != nil {
-		log.Fatalf("DeleteFrom(): %q\n", err)
-	}
-	query = dbConnection.DeleteFrom(csTable)
-	_, err = query.Exec()
-	if err != nil {
-		log.Fatalf("DeleteFrom(): %q\n", err)
-	}
-	query = dbConnection.DeleteFrom(activityTable)
-	_, err = query.Exec()
-	if err != nil {
-		log.Fatalf("DeleteFrom(): %q\n", err)
-	}
-	query = dbConnection.DeleteFrom(projectTable)
-	_, err = query.Exec()
-	if err != nil {
-		log.Fatalf("DeleteFrom(): %q\n", err)
-	}
-
 	// Prepare entry for milestones
 	milestoneTest.Id = 100123
 
@@ -109,7 +113,7 @@ func TestMain(m *testing.M) {
 		"funding_complete": false,
 		"release_percents": []int{50, 50},
 	}
-	project, err := models.ProjectInsert(milestoneTest)
+	project, err := testService.Projects.Insert(milestoneTest)
 	if err != nil {
 		log.Printf("Could not insert project %v \n", milestoneTest.Id)
 	}
@@ -142,7 +146,7 @@ func TestMain(m *testing.M) {
 		"funding_complete": false,
 		"release_percents": []int{50, 50},
 	}
-	project, err = models.ProjectInsert(cancelTest)
+	project, err = testService.Projects.Insert(cancelTest)
 	if err != nil {
 		log.Printf("Could not insert project %v \n", cancelTest.Id)
 	}
@@ -178,7 +182,7 @@ func TestMain(m *testing.M) {
 		"funding_complete": false,
 		"release_percents": []int{50, 50},
 	}
-	project, err = models.ProjectInsert(failedFundTest)
+	project, err = testService.Projects.Insert(failedFundTest)
 	if err != nil {
 		log.Printf("Could not insert project %v \n", failedFundTest.Id)
 	}
@@ -204,29 +208,86 @@ func TestSetInterval(t *testing.T) {
 	log.Println("********************************* End TestSetInterval() **************************************")
 }
 
-// Tests for utils_request.go
-func TestPostNodeServer(t *testing.T) {
-	log.Println("********************************* TestNodeserver() **************************************")
-	requestParameters := req.Param{
-		"Status": 0,
-	}
-	_, err := PostNodeServer(requestParameters, "/projects/123")
+func TestNodeServerFake(t *testing.T) {
+	log.Println("********************************* TestNodeServerFake() **************************************")
+	fake := nodeserver.NewFake()
+	service := NewService(testConfig, models.NewMemoryStore(), fake)
+
+	var createReq RequestProjectCreate
+	createReq.ProjectId = 4242
+	createReq.Milestones = []int64{1617539309000}
+	createReq.ReleasePercents = []int64{100}
+	_, err := service.ProjectCreate(createReq)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+
+	call, ok := fake.LastCall()
+	if !ok || call.Operation != "DeployProject" {
+		t.Fatalf("Expected a DeployProject call, got %v", call)
+	}
+	deployReq := call.Request.(nodeserver.DeployProjectRequest)
+	if deployReq.ProjectId != 4242 || deployReq.ActivityId == 0 {
+		t.Errorf("Unexpected DeployProject request %+v", deployReq)
 	}
-	log.Println("********************************* End TestNodeserver() **************************************")
+
+	fake.Err = errs.New(errs.NodeServer, "Nodeserver unavailable")
+	var cancelReq RequestCancelProject
+	cancelReq.FkProjectId = 4242
+	err = service.CancelProject(cancelReq)
+	if !errs.Is(err, errs.NodeServer) {
+		t.Errorf("Expected a nodeserver error, got %v", err)
+	}
+	log.Println("********************************* End TestNodeServerFake() **************************************")
 }
 
-func TestGetPostNodeServer(t *testing.T) {
-	log.Println("********************************* TestGetNodeserver() **************************************")
-	testReq := req.Param{
-		"user_id": 1,
+// Tests for utils_outbox.go
+func TestDispatchOutbox(t *testing.T) {
+	log.Println("********************************* TestDispatchOutbox() **************************************")
+	service := NewService(testConfig, models.NewMemoryStore(), nodeserver.NewFake())
+	config := OutboxConfig{BaseDelay: time.Hour, MaxDelay: time.Hour, MaxAttempts: 2, BatchSize: 10}
+
+	// The backend stub rejects unknown events
+	service.enqueueBackend(service.Store, projectEvents(1), "/events/blockchain/projects/1/UNKNOWN_EVENT", RequestParameters{"project_id": 1})
+	service.enqueueBackend(service.Store, projectEvents(1), "/events/blockchain/projects/1/PROJECT_CREATE", RequestParameters{"project_id": 1})
+	service.enqueueBackend(service.Store, projectEvents(2), "/events/blockchain/projects/2/PROJECT_CREATE", RequestParameters{"project_id": 2})
+
+	// Project 1 waits behind its failing event while project 2 is delivered
+	delivered, err := service.DispatchOutbox(config)
+	if err != nil || delivered != 1 {
+		t.Fatalf("Expected 1 delivered event, got %d: %v", delivered, err)
 	}
-	_, err := GetNodeServer(testReq, "/projects/123")
-	if err != nil {
-		t.Error(err)
+	failing, _ := service.Outbox.FetchById(1)
+	if failing.Status != constants.OutboxPending || failing.Attempts != 1 || !failing.NextAttemptAt.After(time.Now()) {
+		t.Errorf("Expected the failing event to be rescheduled, got %+v", failing)
+	}
+
+	// The last attempt dead-letters it and lets the next project 1 event through
+	failing.NextAttemptAt = time.Now()
+	service.Outbox.UpdateFields(failing)
+	delivered, err = service.DispatchOutbox(config)
+	if err != nil || delivered != 1 {
+		t.Fatalf("Expected 1 delivered event, got %d: %v", delivered, err)
+	}
+
+	deadLetters, err := service.DeadLetters()
+	if err != nil || len(deadLetters) != 1 || deadLetters[0].Id != 1 || !deadLetters[0].LastError.Valid {
+		t.Fatalf("Expected event 1 to be dead-lettered, got %v: %v", deadLetters, err)
+	}
+
+	redriven, err := service.RedriveOutboxEvent(1)
+	if err != nil || redriven.Status != constants.OutboxPending || redriven.Attempts != 0 {
+		t.Errorf("Expected event 1 to be pending again, got %+v: %v", redriven, err)
 	}
-	log.Println("********************************* End TestGetNodeserver() **************************************")
+	_, err = service.RedriveOutboxEvent(1)
+	if !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected a conflict error, got %v", err)
+	}
+	_, err = service.RedriveOutboxEvent(9999)
+	if !errs.Is(err, errs.NotFound) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+	log.Println("********************************* End TestDispatchOutbox() **************************************")
 }
 
 func TestPostBackend(t *testing.T) {
@@ -234,24 +295,694 @@ func TestPostBackend(t *testing.T) {
 	requestParameters := req.Param{
 		"Status": 0,
 	}
-	_, err := PostBackend(requestParameters, "/events/blockchain/projects/432/PROJECT_CREATE")
+	_, err := testService.PostBackend(requestParameters, "/events/blockchain/projects/432/PROJECT_CREATE")
 	if err != nil {
 		t.Error(err)
 	}
 	log.Println("********************************* End TestBackend() **************************************")
 }
 
+func TestOutboxRequestID(t *testing.T) {
+	log.Println("********************************* TestOutboxRequestID() **************************************")
+	received := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(logger.RequestIDHeader)
+	}))
+	defer backend.Close()
+
+	// The request ID of the request that queued an event is sent along to the backend
+	backendConfig := testConfig
+	backendConfig.Backend.URL = backend.URL
+	ctx := logger.WithRequestID(context.Background(), "request-1")
+	service := NewService(backendConfig, models.NewMemoryStore(), nodeserver.NewFake()).WithContext(ctx)
+	service.enqueueBackend(service.Store, projectEvents(7), "/events/blockchain/projects/7/PROJECT_CREATE", RequestParameters{"project_id": 7})
+	if delivered, err := NewService(backendConfig, service.Store, nodeserver.NewFake()).DispatchOutbox(OutboxConfig{BaseDelay: time.Hour, MaxDelay: time.Hour, MaxAttempts: 1, BatchSize: 10}); err != nil || delivered != 1 {
+		t.Fatalf("Expected the event to be delivered, got %d: %v", delivered, err)
+	}
+	if requestId := <-received; requestId != "request-1" {
+		t.Errorf("Expected the backend to get the request ID, got %q", requestId)
+	}
+	log.Println("********************************* End TestOutboxRequestID() **************************************")
+}
+
+// Tests for utils_idempotency.go
+func TestIdempotencyKeys(t *testing.T) {
+	log.Println("********************************* TestIdempotencyKeys() **************************************")
+	store := models.NewMemoryStore()
+	service := NewService(testConfig, store, nodeserver.NewFake())
+	ttl := time.Hour
+	lease := time.Minute
+
+	record, replay, err := service.ClaimIdempotencyKey("backend", "stake-1", "fingerprint", ttl, lease)
+	if err != nil || replay {
+		t.Fatalf("Expected the first request to claim the key, got replay %v and %v", replay, err)
+	}
+
+	// A repeat while the first request is running is a conflict
+	_, _, err = service.ClaimIdempotencyKey("backend", "stake-1", "fingerprint", ttl, lease)
+	if !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected a conflict for a request in progress, got %v", err)
+	}
+
+	// Another caller has keys of its own
+	_, replay, err = service.ClaimIdempotencyKey("jwt:user-1", "stake-1", "other fingerprint", ttl, lease)
+	if err != nil || replay {
+		t.Errorf("Expected another caller to claim the same key, got replay %v and %v", replay, err)
+	}
+
+	if err := service.CompleteIdempotencyKey(record, http.StatusAccepted, `{"msg":"ok"}`); err != nil {
+		t.Fatal(err)
+	}
+	stored, replay, err := service.ClaimIdempotencyKey("backend", "stake-1", "fingerprint", ttl, lease)
+	if err != nil || !replay || stored.StatusCode != http.StatusAccepted || stored.ResponseBody != `{"msg":"ok"}` {
+		t.Errorf("Expected the stored response to be replayed, got %+v, replay %v and %v", stored, replay, err)
+	}
+
+	// The same key with a different request is rejected
+	_, _, err = service.ClaimIdempotencyKey("backend", "stake-1", "other fingerprint", ttl, lease)
+	if !errs.Is(err, errs.Validation) {
+		t.Errorf("Expected a validation error for a different request, got %v", err)
+	}
+
+	// Keys are forgotten after ttl
+	_, replay, err = service.ClaimIdempotencyKey("backend", "stake-1", "other fingerprint", 0, lease)
+	if err != nil || replay {
+		t.Errorf("Expected an expired key to be claimed again, got replay %v and %v", replay, err)
+	}
+
+	// A request that stopped renewing its claim leaves its key to a repeat once its lease is over
+	stalled, _, err := service.ClaimIdempotencyKey("backend", "unstake-1", "fingerprint", ttl, lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.RenewIdempotencyKey(stalled); err != nil {
+		t.Errorf("Expected the claim to be renewed, got %v", err)
+	}
+	if _, _, err := service.ClaimIdempotencyKey("backend", "unstake-1", "other fingerprint", ttl, 0); !errs.Is(err, errs.Validation) {
+		t.Errorf("Expected a different request not to take over the key, got %v", err)
+	}
+	repeat, replay, err := service.ClaimIdempotencyKey("backend", "unstake-1", "fingerprint", ttl, 0)
+	if err != nil || replay {
+		t.Errorf("Expected the repeat to take over the key, got replay %v and %v", replay, err)
+	}
+	if _, _, err := service.ClaimIdempotencyKey("backend", "unstake-1", "fingerprint", ttl, lease); !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected the key to be held by the repeat, got %v", err)
+	}
+
+	// The request that lost its key can no longer renew, complete or release it
+	if err := service.RenewIdempotencyKey(stalled); !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected the lost claim not to be renewed, got %v", err)
+	}
+	if err := service.CompleteIdempotencyKey(stalled, http.StatusBadRequest, `{"error":"late"}`); !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected the late response not to be stored, got %v", err)
+	}
+	if err := service.CompleteIdempotencyKey(stalled, http.StatusBadGateway, `{"error":"late"}`); !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected the late failure not to release the key, got %v", err)
+	}
+	if err := service.CompleteIdempotencyKey(repeat, http.StatusAccepted, `{"msg":"ok"}`); err != nil {
+		t.Fatal(err)
+	}
+	stored, replay, err = service.ClaimIdempotencyKey("backend", "unstake-1", "fingerprint", ttl, lease)
+	if err != nil || !replay || stored.ResponseBody != `{"msg":"ok"}` {
+		t.Errorf("Expected the response of the repeat to be replayed, got %+v, replay %v and %v", stored, replay, err)
+	}
+
+	// A server error is not replayed, the repeat runs again
+	record, _, _ = service.ClaimIdempotencyKey("backend", "stake-2", "fingerprint", ttl, lease)
+	if err := service.CompleteIdempotencyKey(record, http.StatusServiceUnavailable, `{"error":"nodeserver"}`); err != nil {
+		t.Fatal(err)
+	}
+	_, replay, err = service.ClaimIdempotencyKey("backend", "stake-2", "fingerprint", ttl, lease)
+	if err != nil || replay {
+		t.Errorf("Expected the key of a server error to be claimed again, got replay %v and %v", replay, err)
+	}
+
+	// The idempotency job forgets the keys recorded more than IDEMPOTENCY_KEY_TTL ago
+	service.Config.App.IdempotencyTTL = ttl
+	if _, err := service.idempotencyJob(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Idempotency.FetchByKey(idempotencyRecordKey("backend", "stake-2")); err != nil {
+		t.Errorf("Expected a recent key to be kept, got %v", err)
+	}
+	service.Config.App.IdempotencyTTL = 0
+	if _, err := service.idempotencyJob(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Idempotency.FetchByKey(idempotencyRecordKey("backend", "stake-2")); err == nil {
+		t.Error("Expected an expired key to be forgotten")
+	}
+	log.Println("********************************* End TestIdempotencyKeys() **************************************")
+}
+
+// Tests for utils_credentials.go
+func TestCredentials(t *testing.T) {
+	log.Println("********************************* TestCredentials() **************************************")
+	service := NewService(testConfig, models.NewMemoryStore(), nodeserver.NewFake())
+
+	credential, token, err := service.CreateCredential(RequestCreateCredential{Name: "backend", Role: string(constants.RoleBackend)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credential.TokenHash != HashToken(token) || !credential.Allows(constants.RouteTreasury) || credential.Allows(constants.RouteAdmin) {
+		t.Errorf("Unexpected backend credential: %+v", credential)
+	}
+
+	caller, err := service.Authenticate(token)
+	if err != nil || caller.Id != credential.Id {
+		t.Errorf("Expected the token to authenticate as %d, got %+v and %v", credential.Id, caller, err)
+	}
+	if _, err := service.Authenticate("not a token"); !errs.Is(err, errs.Unauthorized) {
+		t.Errorf("Expected an unknown token to be unauthorized, got %v", err)
+	}
+
+	// Credentials can be narrowed to some of the route groups of their role
+	narrowed, _, err := service.CreateCredential(RequestCreateCredential{Name: "cs-only", Role: string(constants.RoleBackend), RouteGroups: []string{"cs"}})
+	if err != nil || narrowed.Allows(constants.RouteTreasury) || !narrowed.Allows(constants.RouteCampShares) {
+		t.Errorf("Unexpected narrowed credential: %+v, %v", narrowed, err)
+	}
+	if _, _, err := service.CreateCredential(RequestCreateCredential{Name: "reader", Role: string(constants.RoleReadOnly), RouteGroups: []string{"admin"}}); !errs.Is(err, errs.Validation) {
+		t.Errorf("Expected a validation error for a group outside the role, got %v", err)
+	}
+	if _, _, err := service.CreateCredential(RequestCreateCredential{Name: "backend", Role: string(constants.RoleBackend)}); !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected a conflict for a name in use, got %v", err)
+	}
+
+	revoked, err := service.RevokeCredential(credential.Id)
+	if err != nil || !revoked.RevokedAt.Valid {
+		t.Fatalf("Expected the credential to be revoked, got %+v and %v", revoked, err)
+	}
+	if _, err := service.Authenticate(token); !errs.Is(err, errs.Unauthorized) {
+		t.Errorf("Expected a revoked token to be unauthorized, got %v", err)
+	}
+	if _, err := service.RevokeCredential(credential.Id); !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected a conflict revoking twice, got %v", err)
+	}
+	if _, err := service.RevokeCredential(1000); !errs.Is(err, errs.NotFound) {
+		t.Errorf("Expected an unknown credential to be not found, got %v", err)
+	}
+	log.Println("********************************* End TestCredentials() **************************************")
+}
+
+// Tests for utils_metrics.go
+
+// metricValue - value of the series written as name in the metrics output, 0 when there is none yet
+func metricValue(t *testing.T, series string) float64 {
+	var output bytes.Buffer
+	if err := metrics.Write(&output); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(output.String(), "\n") {
+		if strings.HasPrefix(line, series+" ") {
+			value, err := strconv.ParseFloat(strings.TrimPrefix(line, series+" "), 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return value
+		}
+	}
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	log.Println("********************************* TestMetrics() **************************************")
+	service := NewService(testConfig, models.NewMemoryStore(), nodeserver.NewFake())
+	for id, status := range map[int]constants.ProjectStatus{4401: constants.ProjectDeployed, 4402: constants.ProjectDeployed, 4403: constants.ProjectEnded} {
+		if _, err := service.Projects.Insert(models.Project{Id: id, CreatedAt: time.Now(), Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := service.RefreshMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	if value := metricValue(t, `oracle_projects{status="deployed"}`); value != 2 {
+		t.Errorf("Expected 2 deployed projects, got %v", value)
+	}
+	if value := metricValue(t, `oracle_projects{status="cancelled"}`); value != 0 {
+		t.Errorf("Expected no cancelled projects, got %v", value)
+	}
+
+	// A final postback counts the activity, its repeat only counts the postback
+	activity, err := service.ProjectActivities.Insert(ProjectActivity{
+		ProjectId:  4401,
+		CreatedAt:  time.Now(),
+		ModifiedAt: time.Now(),
+		Type:       constants.CheckMilestone,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	activities := `oracle_activities_total{activity_reference="CHECK_MILESTONE",activity_status="timeout"}`
+	applied := `oracle_callbacks_total{status="FAILED_TIMEOUT",outcome="applied"}`
+	duplicate := `oracle_callbacks_total{status="FAILED_TIMEOUT",outcome="duplicate"}`
+	before := []float64{metricValue(t, activities), metricValue(t, applied), metricValue(t, duplicate)}
+
+	var response NodeServerModel
+	response.UUID = "5d3c2b1a-0000-4000-8000-000000000099"
+	response.ParentID = activity.Id
+	response.Type = string(constants.CheckMilestone)
+	response.Status = structs.FailedTimeout
+	for i := 0; i < 2; i++ {
+		if err := service.ProjectCallback(response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	after := []float64{metricValue(t, activities), metricValue(t, applied), metricValue(t, duplicate)}
+	for i, series := range []string{activities, applied, duplicate} {
+		if after[i]-before[i] != 1 {
+			t.Errorf("Expected %s to go up by 1, went from %v to %v", series, before[i], after[i])
+		}
+	}
+	log.Println("********************************* End TestMetrics() **************************************")
+}
+
+// Tests for utils_health.go
+func TestReadiness(t *testing.T) {
+	log.Println("********************************* TestReadiness() **************************************")
+	readinessConfig := testConfig
+	readinessConfig.Backend.URL = "http://127.0.0.1:8080"
+	fake := nodeserver.NewFake()
+	service := NewService(readinessConfig, models.NewMemoryStore(), fake)
+
+	// The memory store has no Postgres to check
+	startJob("readiness-test", time.Hour, func(ctx context.Context) error { return nil })
+	defer StopJobs(context.Background())
+	readiness := service.Readiness(time.Second)
+	if !readiness.Ready {
+		t.Errorf("Expected the Oracle to be ready, got %+v", readiness.Checks)
+	}
+	for name, status := range map[string]string{"database": CheckSkipped, "migrations": CheckSkipped, "nodeserver": CheckOk, "backend": CheckOk, "scheduler": CheckOk} {
+		if readiness.Checks[name].Status != status {
+			t.Errorf("Expected the %s check to be %s, got %+v", name, status, readiness.Checks[name])
+		}
+	}
+
+	// A failing dependency is reported on its own
+	fake.Err = errs.New(errs.NodeServer, "Nodeserver responded with status 503")
+	readiness = service.Readiness(time.Second)
+	if readiness.Ready || readiness.Checks["nodeserver"].Status != CheckFailing || readiness.Checks["backend"].Status != CheckOk {
+		t.Errorf("Expected only Nodeserver to fail, got %+v", readiness.Checks)
+	}
+	fake.Err = nil
+
+	// A scheduler job that stopped beating is dead
+	if _, err := checkSchedulers(time.Now().Add(3 * time.Hour)); err == nil {
+		t.Error("Expected a job without a heartbeat for 3 intervals to be reported")
+	}
+	log.Println("********************************* End TestReadiness() **************************************")
+}
+
+// Tests for utils_scheduler.go
+func TestStopJobs(t *testing.T) {
+	log.Println("********************************* TestStopJobs() **************************************")
+	// A running job is asked to stop and waited for
+	started := make(chan bool, 1)
+	var finished int32
+	startJob("stop-test", 10*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case started <- true:
+		default:
+		}
+		<-ctx.Done()
+		atomic.StoreInt32(&finished, 1)
+		return nil
+	})
+	<-started
+	if err := StopJobs(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Error("Expected the running job to finish before StopJobs returned")
+	}
+
+	// A job that does not stop in time is reported
+	release := make(chan bool)
+	startJob("stuck-test", 10*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case started <- true:
+		default:
+		}
+		<-release
+		return nil
+	})
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := StopJobs(ctx); err == nil || !strings.Contains(err.Error(), "stuck-test") {
+		t.Errorf("Expected the stuck job to be reported, got %v", err)
+	}
+	close(release)
+
+	// Outbox delivery stops between batches
+	stopped, stop := context.WithCancel(context.Background())
+	stop()
+	service := NewService(testConfig, models.NewMemoryStore(), nodeserver.NewFake())
+	service.enqueueBackend(service.Store, projectEvents(7), "/events/blockchain/projects/7/PROJECT_CREATE", RequestParameters{"project_id": 7})
+	if delivered, err := service.dispatchOutbox(stopped, OutboxConfig{BaseDelay: time.Hour, MaxDelay: time.Hour, MaxAttempts: 1, BatchSize: 10}); err != nil || delivered != 0 {
+		t.Errorf("Expected nothing to be delivered once stopped, got %d: %v", delivered, err)
+	}
+	log.Println("********************************* End TestStopJobs() **************************************")
+}
+
+func TestRunJobOnce(t *testing.T) {
+	log.Println("********************************* TestRunJobOnce() **************************************")
+	service := NewService(testConfig, models.NewMemoryStore(), nodeserver.NewFake())
+	if err := service.RunJobOnce(context.Background(), "unknown"); !errs.Is(err, errs.Validation) {
+		t.Errorf("Expected an unknown job to be rejected, got %v", err)
+	}
+	for _, job := range SchedulerJobs {
+		if err := service.RunJobOnce(context.Background(), job); err != nil {
+			t.Errorf("Expected job %s to run, got %v", job, err)
+		}
+	}
+	series := `oracle_scheduler_run_duration_seconds_count{job="outbox",outcome="success"}`
+	count := metricValue(t, series)
+	service.RunJobOnce(context.Background(), jobOutbox)
+	if value := metricValue(t, series); value != count+1 {
+		t.Errorf("Expected the run to be recorded, got %v after %v", value, count)
+	}
+	if value := metricValue(t, `oracle_scheduler_last_success_timestamp_seconds{job="outbox"}`); value < float64(time.Now().Add(-time.Minute).Unix()) {
+		t.Errorf("Expected a recent last success of the outbox job, got %v", value)
+	}
+	failures := `oracle_scheduler_run_duration_seconds_count{job="milestone",outcome="error"}`
+	count = metricValue(t, failures)
+	runJob(jobMilestone, func() error { return errors.New("Could not get active projects") })
+	if value := metricValue(t, failures); value != count+1 {
+		t.Errorf("Expected the failed run to be counted, got %v after %v", value, count)
+	}
+	log.Println("********************************* End TestRunJobOnce() **************************************")
+}
+
+// Tests for utils_jobs.go
+func TestJobWorker(t *testing.T) {
+	log.Println("********************************* TestJobWorker() **************************************")
+	jobsConfig := testConfig
+	jobsConfig.Leader.InstanceID = "oracle-1"
+	jobsConfig.Jobs.RetryBaseDelay = time.Minute
+	jobsConfig.Jobs.RetryMaxDelay = time.Hour
+	store := models.NewMemoryStore()
+	service := NewService(jobsConfig, store, nodeserver.NewFake())
+
+	// New jobs are due at once and run by the worker
+	if err := service.RegisterJobs(); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.runJobs(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := service.ListJobs()
+	if err != nil || len(jobs) != len(TableJobs) {
+		t.Fatalf("Expected every job to be registered, got %+v: %v", jobs, err)
+	}
+	for _, job := range jobs {
+		if !job.LastSuccessAt.Valid || job.Attempts != 0 || job.Running(time.Now()) || !job.NextRunAt.After(time.Now()) {
+			t.Errorf("Expected job %s to have run and be due on its schedule, got %+v", job.Name, job)
+		}
+	}
+
+	// Registering again keeps the job, which is not due yet
+	service.RegisterJobs()
+	if _, claimed, _ := store.Jobs.Claim(jobMilestone, "oracle-2", time.Now(), time.Minute); claimed {
+		t.Error("Expected a job that is not due to be left alone")
+	}
+
+	// A triggered job is due now and held by a single worker
+	if _, err := service.TriggerJob(jobInterest); err != nil {
+		t.Fatal(err)
+	}
+	job, claimed, _ := store.Jobs.Claim(jobInterest, "oracle-1", time.Now(), time.Minute)
+	if !claimed {
+		t.Fatal("Expected the triggered job to be claimed")
+	}
+	if _, claimed, _ := store.Jobs.Claim(jobInterest, "oracle-2", time.Now(), time.Minute); claimed {
+		t.Error("Expected a running job not to be claimed twice")
+	}
+	if _, err := service.TriggerJob(jobInterest); !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected a running job not to be triggered, got %v", err)
+	}
+
+	// A failed run is retried after the backoff, before its next scheduled run
+	err = service.runClaimedJob(context.Background(), job, func(ctx context.Context) (int, error) {
+		return 0, errs.New(errs.NodeServer, "Nodeserver responded with status 503")
+	})
+	job, _ = store.Jobs.FetchByName(jobInterest)
+	if err == nil || job.Attempts != 1 || !job.LastError.Valid || job.Running(time.Now()) {
+		t.Errorf("Expected the failed run to be recorded, got %+v: %v", job, err)
+	}
+	if retry := time.Until(job.NextRunAt); retry <= 0 || retry > time.Minute {
+		t.Errorf("Expected a retry within a minute, got %v", retry)
+	}
+
+	// A paused job does not run until it is resumed
+	if _, err := service.PauseJob(jobInterest); err != nil {
+		t.Fatal(err)
+	}
+	if _, claimed, _ := store.Jobs.Claim(jobInterest, "oracle-1", time.Now().Add(time.Hour), time.Minute); claimed {
+		t.Error("Expected a paused job not to be claimed")
+	}
+	service.ResumeJob(jobInterest)
+	if _, claimed, _ := store.Jobs.Claim(jobInterest, "oracle-1", time.Now().Add(time.Hour), time.Minute); !claimed {
+		t.Error("Expected the resumed job to be claimed")
+	}
+	if _, err := service.PauseJob("unknown"); !errs.Is(err, errs.NotFound) {
+		t.Errorf("Expected an unknown job not to be found, got %v", err)
+	}
+	log.Println("********************************* End TestJobWorker() **************************************")
+}
+
+// Tests for utils_milestone_timers.go
+func TestTimerWheel(t *testing.T) {
+	log.Println("********************************* TestTimerWheel() **************************************")
+	start := time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC)
+	wheel := newTimerWheel(time.Second, 4, start)
+
+	wheel.schedule(1, start.Add(1500*time.Millisecond))
+	wheel.schedule(2, start.Add(10*time.Second))
+	wheel.schedule(3, start.Add(-time.Hour))
+	if wheel.size() != 3 {
+		t.Fatalf("Expected 3 timers, got %d", wheel.size())
+	}
+
+	// An overdue milestone fires on the next tick, one within the tick waits for it
+	if fired := wheel.advance(start.Add(time.Second)); len(fired) != 1 || fired[0] != 3 {
+		t.Errorf("Expected the overdue project to fire, got %v", fired)
+	}
+	if fired := wheel.advance(start.Add(2 * time.Second)); len(fired) != 1 || fired[0] != 1 {
+		t.Errorf("Expected project 1 to fire once its milestone passed, got %v", fired)
+	}
+
+	// A milestone more than a turn away stays through the turns before it
+	if fired := wheel.advance(start.Add(6 * time.Second)); len(fired) != 0 {
+		t.Errorf("Expected project 2 to wait for its turn, got %v", fired)
+	}
+
+	// Moving a milestone replaces its timer and a cancelled one never fires
+	wheel.schedule(2, start.Add(8*time.Second))
+	wheel.schedule(4, start.Add(7*time.Second))
+	wheel.cancel(4)
+	if fired := wheel.advance(start.Add(8 * time.Second)); len(fired) != 1 || fired[0] != 2 {
+		t.Errorf("Expected only the moved project 2 to fire, got %v", fired)
+	}
+	if fired := wheel.advance(start.Add(time.Minute)); len(fired) != 0 || wheel.size() != 0 {
+		t.Errorf("Expected every timer to have fired once, got %v and %d left", fired, wheel.size())
+	}
+	log.Println("********************************* End TestTimerWheel() **************************************")
+}
+
+func TestMilestoneTimers(t *testing.T) {
+	log.Println("********************************* TestMilestoneTimers() **************************************")
+	fake := nodeserver.NewFake()
+	service := NewService(testConfig, models.NewMemoryStore(), fake)
+	// Forget the milestones timed by the postbacks of other tests, turned a tick back to fire without waiting
+	milestoneTimers = newTimerWheel(milestoneTick, milestoneSlots, time.Now().Add(-milestoneTick))
+	now := time.Now()
+	milestones := []interface{}{float64(now.Add(-time.Second).Unix()), float64(now.Add(time.Hour).Unix())}
+	due := Project{Id: 1, Status: constants.ProjectMilestonePhase, NextActivityDate: now.Add(-time.Second),
+		ProjectParameters: map[string]interface{}{"milestones": milestones}}
+	later := Project{Id: 2, Status: constants.ProjectMilestonePhase, NextActivityDate: now.Add(time.Hour),
+		ProjectParameters: map[string]interface{}{"milestones": milestones}}
+	for _, project := range []Project{due, later} {
+		if _, err := service.Projects.Insert(project); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The reached milestone is checked on the next tick, the later one is not loaded yet
+	if err := service.RebuildMilestoneTimers(); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.tickMilestones(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Operation != "CheckMilestone" || calls[0].Request.(nodeserver.ProjectRequest).ProjectId != 1 {
+		t.Fatalf("Expected CHECK_MILESTONE for project 1, got %+v", calls)
+	}
+
+	// The check waiting for Nodeserver is not sent again
+	milestoneTimers.schedule(1, due.NextActivityDate)
+	if err := service.tickMilestones(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if checked, err := service.milestoneJob(context.Background()); err != nil || checked != 0 {
+		t.Errorf("Expected the pending check not to be sent again, got %d: %v", checked, err)
+	}
+	if len(fake.Calls()) != 1 {
+		t.Errorf("Expected a single CHECK_MILESTONE, got %+v", fake.Calls())
+	}
+
+	// A project that left the milestone phase drops its timer
+	later.Status = constants.ProjectEnded
+	service.Projects.UpdateFields(later)
+	milestoneTimers.schedule(2, later.NextActivityDate)
+	service.retimeMilestone(2)
+	milestoneTimers.cancel(1)
+	if size := milestoneTimers.size(); size != 0 {
+		t.Errorf("Expected no milestone timed, got %d", size)
+	}
+	log.Println("********************************* End TestMilestoneTimers() **************************************")
+}
+
+// Tests for utils_reconcile.go
+func TestReconciliation(t *testing.T) {
+	log.Println("********************************* TestReconciliation() **************************************")
+	reconcileConfig := testConfig
+	reconcileConfig.Jobs.ReconcileAfter = 10 * time.Minute
+	reconcileConfig.Jobs.ActivityTimeout = time.Hour
+	fake := nodeserver.NewFake()
+	service := NewService(reconcileConfig, models.NewMemoryStore(), fake)
+	now := time.Now()
+	service.Projects.Insert(Project{Id: 5151, ContractAddress: "0x5151"})
+	service.CampShares.Insert(models.CampShares{CSId: 5252, UserId: 5353})
+
+	activity := func(projectId int, activityType constants.ActivityReference, age time.Duration) ProjectActivity {
+		inserted, err := service.ProjectActivities.Insert(ProjectActivity{ProjectId: projectId, CreatedAt: now.Add(-age), ModifiedAt: now.Add(-age), Type: activityType})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return inserted
+	}
+	// Postback lost, Nodeserver reports the transaction failed
+	lost := activity(5151, constants.CheckMilestone, 20*time.Minute)
+	fake.Transactions[nodeserver.TransactionRequest{ActivityId: lost.Id, Type: constants.CheckMilestone}] = NodeServerModel{
+		UUID: "lost", Type: string(constants.CheckMilestone), Status: structs.FailedGas, Hash: "0xlost"}
+	// Never reached Nodeserver
+	unknown := activity(5152, constants.CancelProject, 2*time.Hour)
+	// Too recent to ask about
+	recent := activity(5151, constants.CheckMilestone, time.Minute)
+	// Still in flight after the timeout
+	csActivity, _ := service.CSActivities.Insert(models.CSActivity{CsId: 5252, CreatedAt: now.Add(-2 * time.Hour), Type: constants.StakePLG})
+	fake.Transactions[nodeserver.TransactionRequest{ActivityId: csActivity.Id, Type: constants.StakePLG}] = NodeServerModel{
+		UUID: "stuck", Type: string(constants.StakePLG), Status: structs.Pending, Hash: "0xstuck"}
+
+	settled, err := service.reconciliationJob(context.Background())
+	if err != nil || settled != 3 {
+		t.Fatalf("Expected 3 activities to be settled, got %d: %v", settled, err)
+	}
+	if lost, _ = service.ProjectActivities.SearchActivityID(lost.Id); lost.Status != constants.ActivityGasError || lost.TransactionHash.String != "0xlost" {
+		t.Errorf("Expected the lost postback to be applied, got %+v", lost)
+	}
+	if unknown, _ = service.ProjectActivities.SearchActivityID(unknown.Id); unknown.Status != constants.ActivityTimeout {
+		t.Errorf("Expected the unknown transaction to time out, got %+v", unknown)
+	}
+	if recent, _ = service.ProjectActivities.SearchActivityID(recent.Id); recent.Status != constants.ActivityPending {
+		t.Errorf("Expected the recent activity to be left pending, got %+v", recent)
+	}
+	if csActivity, _ = service.CSActivities.SearchActivityID(csActivity.Id); csActivity.Status != constants.ActivityTimeout || csActivity.TransactionHash.String != "0xstuck" {
+		t.Errorf("Expected the stuck CS transaction to time out, got %+v", csActivity)
+	}
+	for _, call := range fake.Calls() {
+		if request, ok := call.Request.(nodeserver.TransactionRequest); ok && request.ActivityId == recent.Id && request.Type == constants.CheckMilestone {
+			t.Error("Expected Nodeserver not to be asked about the recent activity")
+		}
+	}
+
+	// The backend hears about each failure
+	events, _ := service.Outbox.Due(time.Now(), 10)
+	reported := map[string]bool{}
+	for _, event := range events {
+		if event.Payload["status"] == false {
+			reported[event.OrderingKey] = true
+		}
+	}
+	if !reported[projectEvents(5151)] || !reported[projectEvents(5152)] || !reported[csEvents(5353)] {
+		t.Errorf("Expected a failure event for each settled activity, got %+v", events)
+	}
+
+	// Nodeserver being down leaves the activities pending
+	fake.Err = errs.New(errs.NodeServer, "Nodeserver responded with status 503")
+	service.Config.Jobs.ReconcileAfter = 0
+	if settled, err := service.reconciliationJob(context.Background()); err == nil || settled != 0 {
+		t.Errorf("Expected the Nodeserver failure to be returned, got %d: %v", settled, err)
+	}
+	if recent, _ = service.ProjectActivities.SearchActivityID(recent.Id); recent.Status != constants.ActivityPending {
+		t.Errorf("Expected the activity to stay pending while Nodeserver is down, got %+v", recent)
+	}
+	log.Println("********************************* End TestReconciliation() **************************************")
+}
+
+// Tests for utils_leader.go
+func TestLeaderElection(t *testing.T) {
+	log.Println("********************************* TestLeaderElection() **************************************")
+	store := models.NewMemoryStore()
+	leaderConfig := testConfig
+	leaderConfig.Leader = config.Leader{InstanceID: "oracle-1", LeaseTTL: time.Minute}
+	leader := NewService(leaderConfig, store, nodeserver.NewFake())
+
+	leadershipMu.Lock()
+	leadership = &Leadership{Instance: "oracle-1"}
+	leadershipMu.Unlock()
+	defer func() {
+		leadershipMu.Lock()
+		leadership = nil
+		leadershipMu.Unlock()
+	}()
+
+	if err := leader.electLeader(context.Background()); err != nil || !leading(time.Now()) {
+		t.Fatalf("Expected the first instance to lead, got %v", err)
+	}
+	current, _ := CurrentLeadership()
+	if current.Leader != "oracle-1" {
+		t.Errorf("Expected oracle-1 to be reported as leader, got %+v", current)
+	}
+
+	// oracle-2 competes through the shared store, as another replica would
+	lease, _ := store.Leases.Acquire(leaderLease, "oracle-2", time.Now(), time.Minute)
+	if lease.Holder != "oracle-1" {
+		t.Errorf("Expected oracle-1 to keep the lease, got %+v", lease)
+	}
+
+	// Once the leader stops renewing the lease expires and the follower takes over
+	lease, _ = store.Leases.Acquire(leaderLease, "oracle-2", time.Now().Add(2*time.Minute), time.Minute)
+	if lease.Holder != "oracle-2" {
+		t.Errorf("Expected oracle-2 to take over the expired lease, got %+v", lease)
+	}
+	if leading(time.Now().Add(2 * time.Minute)) {
+		t.Error("Expected a leader to stop running jobs once its lease expired")
+	}
+	leader.electLeader(context.Background())
+	if current, _ := CurrentLeadership(); current.IsLeader || current.Leader != "oracle-2" {
+		t.Errorf("Expected oracle-1 to follow oracle-2, got %+v", current)
+	}
+
+	// A leader shutting down hands the lease over right away
+	store.Leases.Release(leaderLease, "oracle-2")
+	leader.electLeader(context.Background())
+	if err := leader.ReleaseLeadership(); err != nil || leading(time.Now()) {
+		t.Errorf("Expected oracle-1 to stop leading, got %v", err)
+	}
+	lease, _ = store.Leases.Acquire(leaderLease, "oracle-2", time.Now(), time.Minute)
+	if lease.Holder != "oracle-2" {
+		t.Errorf("Expected oracle-2 to take the released lease, got %+v", lease)
+	}
+	log.Println("********************************* End TestLeaderElection() **************************************")
+}
+
 // Tests for utils_project_create.go
 func TestProjectCreateSuccess(t *testing.T) {
 	log.Println("********************************* TestProjectCreateSuccess() **************************************")
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
-	var lastProject Project
-	projectCollection := dbConnection.SelectFrom(projectTable)
-	projectCollection.OrderBy("-id").One(&lastProject)
-	counter := lastProject.Id
 	var testReq RequestProjectCreate
-	testReq.ProjectId = counter + 1
+	testReq.ProjectId = failedFundTest.Id + 1
 	testReq.Milestones = []int64{time.Now().Add(time.Second * 1).Unix(), time.Now().Add(time.Second * 5).Unix()}
 	testReq.ReleasePercents = []int64{50, 50}
 	testReq.Creator = 231
@@ -259,13 +990,13 @@ func TestProjectCreateSuccess(t *testing.T) {
 	log.Print(pq.Array(testReq.ReleasePercents))
 
 	log.Println("Creating project")
-	project, err := ProjectCreate(testReq)
+	project, err := testService.ProjectCreate(testReq)
 	fmt.Println(project)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	newProject, err := models.ProjectFetchById(project.Id)
+	newProject, err := testService.Projects.FetchById(project.Id)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -276,7 +1007,7 @@ func TestProjectCreateSuccess(t *testing.T) {
 
 	testProjectId = testReq.ProjectId
 	transactionType := "PROJECT_DEPLOY"
-	activity, _ := models.ProjectActivitySearchProjectIDTransType(testProjectId, transactionType)
+	activity, _ := testService.ProjectActivities.SearchProjectIDTransType(testProjectId, transactionType)
 	testActivityId = activity[0].Id
 	if activity[0].ProjectId != project.Id {
 		t.Error("Project activity was not created")
@@ -287,26 +1018,61 @@ func TestProjectCreateSuccess(t *testing.T) {
 
 func TestProjectCreateFailure(t *testing.T) {
 	log.Println("********************************* TestProjectCreateFailure() **************************************")
-	dbConnection := connect.Postgres()
-	defer dbConnection.Close()
-	var lastProject Project
-	projectCollection := dbConnection.SelectFrom(projectTable)
-	projectCollection.OrderBy("-id").One(&lastProject)
-	counter := lastProject.Id
 	var testReq RequestProjectCreate
-	testReq.ProjectId = counter
+	testReq.ProjectId = testProjectId
 	testReq.Milestones = []int64{time.Now().Round(time.Millisecond).UnixNano() / 1e6, time.Now().Round(time.Millisecond).UnixNano() / 1e6}
 	testReq.ReleasePercents = []int64{50, 50}
 	testReq.Creator = 231
-	_, err := ProjectCreate(testReq)
+	_, err := testService.ProjectCreate(testReq)
 	fmt.Println("Error: ", err)
 	if err == nil {
 		t.Errorf("Should have failed")
 	}
+	if !errs.Is(err, errs.Conflict) {
+		t.Errorf("Expected a conflict error, got: %v", err)
+	}
 
 	log.Println("********************************* End TestProjectCreateFailure() **************************************")
 }
 
+func TestProjectActionNotFound(t *testing.T) {
+	log.Println("********************************* TestProjectActionNotFound() **************************************")
+	var testReq RequestCancelProject
+	testReq.FkProjectId = testProjectId + 1000
+	err := testService.CancelProject(testReq)
+	if !errs.Is(err, errs.NotFound) {
+		t.Errorf("Expected a not found error, got: %v", err)
+	}
+
+	log.Println("********************************* End TestProjectActionNotFound() **************************************")
+}
+
+func TestProjectCallbackMalformed(t *testing.T) {
+	log.Println("********************************* TestProjectCallbackMalformed() **************************************")
+	activity, err := models.SetProjectActivity(testService.ProjectActivities, testProjectId, constants.CheckMilestone)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var malformedResponse NodeServerModel
+	malformedResponse.ParentID = activity.Id
+	malformedResponse.Type = string(constants.CheckMilestone)
+	malformedResponse.Status = 2
+	malformedResponse.TransactionEvents = "not a list of events"
+	err = testService.ProjectCallback(malformedResponse)
+	if !errs.Is(err, errs.Validation) {
+		t.Errorf("Expected a validation error, got: %v", err)
+	}
+
+	malformedResponse.ParentID = activity.Id + 1000
+	err = testService.ProjectCallback(malformedResponse)
+	if !errs.Is(err, errs.NotFound) {
+		t.Errorf("Expected a not found error, got: %v", err)
+	}
+
+	log.Println("********************************* End TestProjectCallbackMalformed() **************************************")
+}
+
 func TestProjectCreateCallback(t *testing.T) {
 
 	log.Println("********************************* TestProjectCreateCallback() **************************************")
@@ -314,19 +1080,20 @@ func TestProjectCreateCallback(t *testing.T) {
 	transactionResponse.Status = 2
 	transactionResponse.To = "0x60e3ee943f7045f7fb7348841aa710c129c58667"
 	transactionResponse.ContractAddress = "0x60e3ee943f7045f7fb7348841aa710c129c58667"
+	transactionResponse.TransactionEvents = []interface{}{"0x60e3ee943f7045f7fb7348841aa710c129c58667"}
 
 	projectActivityId := transactionResponse.ParentID
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = ProjectCreateCallback(transactionResponse, targetActivity)
+	err = testService.ProjectCreateCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	project, err := models.ProjectFetchById(targetActivity.ProjectId)
+	project, err := testService.Projects.FetchById(targetActivity.ProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -346,13 +1113,13 @@ func TestSubmitMilestoneVoteSuccess(t *testing.T) {
 	testReq.Vote = true
 	testReq.VoteType = 0
 	testReq.FkProjectId = testProjectId
-	_, err := SubmitVote(testReq)
+	_, err := testService.SubmitVote(testReq)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
 	transactionType := "MILESTONE_VOTE"
-	activity, err := models.ProjectActivitySearchProjectIDTransType(testReq.FkProjectId, transactionType)
+	activity, err := testService.ProjectActivities.SearchProjectIDTransType(testReq.FkProjectId, transactionType)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -375,18 +1142,18 @@ func TestMilestoneVoteCallback(t *testing.T) {
 	transactionEventsArray = append(transactionEventsArray, []interface{}{"123", true, "100"})
 	transactionResponse.TransactionEvents = transactionEventsArray
 	projectActivityId := transactionResponse.ParentID
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 	log.Println(targetActivity)
 
-	err = VoteCallback(transactionResponse, targetActivity)
+	err = testService.VoteCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	votes, err := models.VoteSearchProjectId(testProjectId)
+	votes, err := testService.Votes.SearchProjectId(testProjectId)
 	if err != nil {
 		t.Errorf("An error was returned when extracting votes: %d", err)
 	}
@@ -403,7 +1170,7 @@ func TestMilestoneVoteCallback(t *testing.T) {
 		t.Errorf("An error was returned when extracting votes")
 	}
 
-	targetActivity, err = models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err = testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -423,13 +1190,13 @@ func TestSubmitModerationVoteSuccess(t *testing.T) {
 	testReq.DecryptionKey = "0x8f4a0d1940bbb011db54926c65572b03fd379cfc3c2da3d5765043dd682dc353"
 	testReq.VoteType = 1
 	testReq.FkProjectId = testProjectId
-	vote, err := SubmitVote(testReq)
+	vote, err := testService.SubmitVote(testReq)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
 	transactionType := "MODERATION_VOTE"
-	activity, err := models.ProjectActivitySearchProjectIDTransType(testReq.FkProjectId, transactionType)
+	activity, err := testService.ProjectActivities.SearchProjectIDTransType(testReq.FkProjectId, transactionType)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -438,7 +1205,7 @@ func TestSubmitModerationVoteSuccess(t *testing.T) {
 	}
 	testActivityId = activity[0].Id
 
-	extractedVote, err := models.VoteSearchVoteId(vote.VoteId)
+	extractedVote, err := testService.Votes.SearchVoteId(vote.VoteId)
 	if err != nil {
 		t.Errorf("An error was returned when extracting votes: %d", err)
 	} else if extractedVote.FkProjectId != testReq.FkProjectId {
@@ -460,17 +1227,17 @@ func TestModerationVoteCallback(t *testing.T) {
 	transactionEventsArray = append(transactionEventsArray, []interface{}{"0x60e3ee943f7045f7fb7348841aa710c129c58667", "423", "0x6ba79b6be13a20011b8f5bceca9feaabcd8995cb51d0e0b448a0006123afaafc"})
 	transactionResponse.TransactionEvents = transactionEventsArray
 	projectActivityId := transactionResponse.ParentID
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = VoteCallback(transactionResponse, targetActivity)
+	err = testService.VoteCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	votes, err := models.VoteSearchProjectId(testProjectId)
+	votes, err := testService.Votes.SearchProjectId(testProjectId)
 	if err != nil {
 		t.Errorf("An error was returned when extracting votes: %d", err)
 	}
@@ -479,7 +1246,7 @@ func TestModerationVoteCallback(t *testing.T) {
 		t.Error("Incorrect vote type")
 	}
 
-	targetActivity, err = models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err = testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -502,16 +1269,16 @@ func TestSetProjectInfo(t *testing.T) {
 	testReq.Amounts = []int64{100, 50}
 	testReq.FundingComplete = true
 	testReq.TotalAmount = 150
-	err := SetProjectInfo(testReq)
+	err := testService.SetProjectInfo(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	transactionType := "SET_PROJECT_INFO"
-	activity, _ := models.ProjectActivitySearchProjectIDTransType(testReq.FkProjectId, transactionType)
+	activity, _ := testService.ProjectActivities.SearchProjectIDTransType(testReq.FkProjectId, transactionType)
 	testActivityId = activity[0].Id
 
-	project, err := models.ProjectFetchById(testProjectId)
+	project, err := testService.Projects.FetchById(testProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -531,22 +1298,22 @@ func TestSetProjectInfoCallback(t *testing.T) {
 	transactionResponse.ContractAddress = "0x60e3ee943f7045f7fb7348841aa710c129c58667"
 	transactionResponse.Hash = "0xeef10fc5170f669b86c4cd0444882a96087221325f8bf2f55d6188633aa7be7c"
 	projectActivityId := transactionResponse.ParentID
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = SetProjectInfoCallback(transactionResponse, targetActivity)
+	err = testService.SetProjectInfoCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	targetActivity, err = models.ProjectActivitySearchActivityID(testActivityId)
+	targetActivity, err = testService.ProjectActivities.SearchActivityID(testActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	project, err := models.ProjectFetchById(testProjectId)
+	project, err := testService.Projects.FetchById(testProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -562,7 +1329,7 @@ func TestSetProjectInfoCallback(t *testing.T) {
 
 	time.Sleep(2 * time.Second)
 
-	targetActivities, err := models.ProjectActivitySearchProjectID(testProjectId)
+	targetActivities, err := testService.ProjectActivities.SearchProjectID(testProjectId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -582,16 +1349,16 @@ func TestSetBackers(t *testing.T) {
 	testReq.FundingComplete = true
 	testReq.FkProjectId = testProjectId
 	testReq.TotalAmount = 150
-	err := SetBackers(testReq)
+	err := testService.SetBackers(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	transactionType := string(constants.SetBackers)
-	activity, _ := models.ProjectActivitySearchProjectIDTransType(testReq.FkProjectId, transactionType)
+	activity, _ := testService.ProjectActivities.SearchProjectIDTransType(testReq.FkProjectId, transactionType)
 	testActivityId = activity[0].Id
 
-	project, err := models.ProjectFetchById(testProjectId)
+	project, err := testService.Projects.FetchById(testProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -611,17 +1378,17 @@ func TestSetBackersCallback(t *testing.T) {
 	transactionResponse.ContractAddress = "0x60e3ee943f7045f7fb7348841aa710c129c58667"
 	transactionResponse.Hash = "0xeef10fc5170f669b86c4cd0444882a96087221325f8bf2f55d6188633aa7be7c"
 	projectActivityId := transactionResponse.ParentID
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = SetBackersCallback(transactionResponse, targetActivity)
+	err = testService.SetBackersCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	project, err := models.ProjectFetchById(testProjectId)
+	project, err := testService.Projects.FetchById(testProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -638,18 +1405,260 @@ func TestSetBackersCallback(t *testing.T) {
 	log.Println("********************************* End TestSetBackersCallback() **************************************")
 }
 
+func TestSetBackersCallbackRollback(t *testing.T) {
+	log.Println("********************************* TestSetBackersCallbackRollback() **************************************")
+
+	// Activity pointing at a project that does not exist, so the callback fails after updating the activity
+	orphanActivity, err := testService.ProjectActivities.Insert(ProjectActivity{
+		ProjectId:  testProjectId + 1000,
+		CreatedAt:  time.Now(),
+		ModifiedAt: time.Now(),
+		Type:       constants.SetBackers,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	transactionResponse.ParentID = orphanActivity.Id
+	transactionResponse.Status = 2
+	transactionResponse.Hash = "0xeef10fc5170f669b86c4cd0444882a96087221325f8bf2f55d6188633aa7be7c"
+	err = testService.SetBackersCallback(transactionResponse, orphanActivity)
+	if err == nil {
+		t.Error("Expected an error for a missing project")
+	}
+
+	storedActivity, err := testService.ProjectActivities.SearchActivityID(orphanActivity.Id)
+	if err != nil {
+		t.Errorf("An error was returned: %d", err)
+	}
+	if storedActivity.Status != constants.ActivityPending || storedActivity.TransactionHash.Valid {
+		t.Errorf("Activity update was not rolled back: %v", storedActivity)
+	}
+
+	log.Println("********************************* End TestSetBackersCallbackRollback() **************************************")
+}
+
+func TestCallbackOutbox(t *testing.T) {
+	log.Println("********************************* TestCallbackOutbox() **************************************")
+	service := NewService(testConfig, models.NewMemoryStore(), nodeserver.NewFake())
+
+	activity, err := service.ProjectActivities.Insert(ProjectActivity{
+		ProjectId:  4343,
+		CreatedAt:  time.Now(),
+		ModifiedAt: time.Now(),
+		Type:       constants.CheckMilestone,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The failure event is queued with the activity update instead of being posted to the backend
+	var response NodeServerModel
+	response.ParentID = activity.Id
+	response.Type = string(constants.CheckMilestone)
+	response.Status = structs.FailedGas
+	err = service.ProjectCallback(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := service.Outbox.Due(time.Now(), 10)
+	if len(events) != 1 || events[0].OrderingKey != projectEvents(4343) || events[0].Payload["status"] != false {
+		t.Errorf("Expected one queued failure event, got %v", events)
+	}
+
+	// Nothing is queued when the unit of work rolls back
+	orphanActivity, _ := service.ProjectActivities.Insert(ProjectActivity{
+		ProjectId:  4344,
+		CreatedAt:  time.Now(),
+		ModifiedAt: time.Now(),
+		Type:       constants.SetBackers,
+	})
+	response.ParentID = orphanActivity.Id
+	response.Status = structs.Complete
+	err = service.SetBackersCallback(response, orphanActivity)
+	if err == nil {
+		t.Error("Expected an error for a missing project")
+	}
+	events, _ = service.Outbox.Due(time.Now(), 10)
+	if len(events) != 1 {
+		t.Errorf("Expected the rolled back callback to queue nothing, got %v", events)
+	}
+	log.Println("********************************* End TestCallbackOutbox() **************************************")
+}
+
+func TestCallbackDeduplication(t *testing.T) {
+	log.Println("********************************* TestCallbackDeduplication() **************************************")
+	service := NewService(testConfig, models.NewMemoryStore(), nodeserver.NewFake())
+
+	activity, err := service.ProjectActivities.Insert(ProjectActivity{
+		ProjectId:  4345,
+		CreatedAt:  time.Now(),
+		ModifiedAt: time.Now(),
+		Type:       constants.CheckMilestone,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response NodeServerModel
+	response.UUID = "5d3c2b1a-0000-4000-8000-000000000001"
+	response.ParentID = activity.Id
+	response.Type = string(constants.CheckMilestone)
+	response.Status = structs.FailedGas
+	response.Hash = "0x01"
+	if err := service.ProjectCallback(response); err != nil {
+		t.Fatal(err)
+	}
+
+	// A Nodeserver retry of the final postback succeeds without queueing the backend event again
+	if err := service.ProjectCallback(response); err != nil {
+		t.Errorf("Expected the repeated postback to succeed, got %v", err)
+	}
+	events, _ := service.Outbox.Due(time.Now(), 10)
+	if len(events) != 1 {
+		t.Errorf("Expected one queued event, got %v", events)
+	}
+
+	// A postback moving the transaction backwards is ignored
+	response.Status = structs.Pending
+	response.Hash = "0x02"
+	if err := service.ProjectCallback(response); err != nil {
+		t.Errorf("Expected the stale postback to succeed, got %v", err)
+	}
+	activity, _ = service.ProjectActivities.SearchActivityID(activity.Id)
+	if activity.Status != constants.ActivityGasError || activity.TransactionHash.String != "0x01" {
+		t.Errorf("Stale postback changed the activity: %+v", activity)
+	}
+
+	records, _ := service.Callbacks.SearchUUID(response.UUID)
+	outcomes := []constants.CallbackOutcome{constants.CallbackApplied, constants.CallbackDuplicate, constants.CallbackStale}
+	if len(records) != len(outcomes) {
+		t.Fatalf("Expected %d recorded postbacks, got %v", len(outcomes), records)
+	}
+	for i, record := range records {
+		if record.Outcome != outcomes[i] {
+			t.Errorf("Postback %d recorded as %d, expected %d", i, record.Outcome, outcomes[i])
+		}
+	}
+	log.Println("********************************* End TestCallbackDeduplication() **************************************")
+}
+
+// failedMilestonePostback - project in the milestone phase with backers and the postback of its failed
+// CHECK_MILESTONE
+func failedMilestonePostback(t *testing.T, service *Service, projectId int, backers int) NodeServerModel {
+	backerIds := make([]interface{}, backers)
+	for i := range backerIds {
+		backerIds[i] = float64(i + 1)
+	}
+	_, err := service.Projects.Insert(Project{
+		Id:                projectId,
+		ContractAddress:   "0x" + strconv.Itoa(projectId),
+		Status:            constants.ProjectMilestonePhase,
+		ProjectParameters: map[string]interface{}{"backers": backerIds},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	activity, err := models.SetProjectActivity(service.ProjectActivities, projectId, constants.CheckMilestone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NodeServerModel{
+		UUID:              "milestone-" + strconv.Itoa(projectId),
+		ParentID:          activity.Id,
+		Type:              string(constants.CheckMilestone),
+		Status:            structs.Complete,
+		TransactionEvents: []interface{}{[]interface{}{"milestone_result", false}},
+	}
+}
+
+func TestCallbackFollowUpConfig(t *testing.T) {
+	log.Println("********************************* TestCallbackFollowUpConfig() **************************************")
+	domainConfig := testConfig
+	domainConfig.App.Domain = "https://oracle.example"
+	fake := nodeserver.NewFake()
+	service := NewService(domainConfig, models.NewMemoryStore(), fake)
+
+	// The refunds sent while applying the postback call back the configured domain
+	if err := service.ProjectCallback(failedMilestonePostback(t, service, 4346, 2)); err != nil {
+		t.Fatal(err)
+	}
+	refunds := 0
+	for _, call := range fake.Calls() {
+		if call.Operation != "RequestRefund" {
+			continue
+		}
+		refunds++
+		if url := call.Request.(nodeserver.ReleaseFundsRequest).URL; url != "https://oracle.example/projects/4346/callback/REQUEST_REFUND" {
+			t.Errorf("Expected the refund to call back the configured domain, got %q", url)
+		}
+	}
+	if refunds != 2 {
+		t.Errorf("Expected a refund for each backer, got %+v", fake.Calls())
+	}
+	log.Println("********************************* End TestCallbackFollowUpConfig() **************************************")
+}
+
+// failingRefunds - Nodeserver failing the refund requests numbered in fail, counted from 1
+type failingRefunds struct {
+	*nodeserver.Fake
+	fail    map[int]bool
+	refunds int
+}
+
+func (f *failingRefunds) RequestRefund(ctx context.Context, request nodeserver.ReleaseFundsRequest) (nodeserver.Transaction, error) {
+	f.refunds++
+	transaction, err := f.Fake.RequestRefund(ctx, request)
+	if f.fail[f.refunds] {
+		return transaction, errs.New(errs.NodeServer, "Nodeserver responded with status 500")
+	}
+	return transaction, err
+}
+
+func TestCallbackFollowUpAfterCommit(t *testing.T) {
+	log.Println("********************************* TestCallbackFollowUpAfterCommit() **************************************")
+	client := &failingRefunds{Fake: nodeserver.NewFake(), fail: map[int]bool{2: true}}
+	service := NewService(testConfig, models.NewMemoryStore(), client)
+	postback := failedMilestonePostback(t, service, 4347, 3)
+
+	// The failed refund does not roll back the postback, the other backers are still refunded
+	if err := service.ProjectCallback(postback); err == nil {
+		t.Error("Expected the failed refund to be reported")
+	}
+	activity, _ := service.ProjectActivities.SearchActivityID(postback.ParentID)
+	project, _ := service.Projects.FetchById(4347)
+	if activity.Status != constants.ActivitySuccess || project.Status != constants.ProjectMilestoneFailed {
+		t.Errorf("Expected the postback to be committed, got %+v and %+v", activity, project)
+	}
+	refunds, _ := service.ProjectActivities.SearchProjectIDTransType(4347, string(constants.RequestRefund))
+	if len(refunds) != 3 {
+		t.Errorf("Expected a refund activity for each backer, got %+v", refunds)
+	}
+
+	// A Nodeserver retry of the postback is a duplicate and refunds nobody again
+	if err := service.ProjectCallback(postback); err != nil {
+		t.Errorf("Expected the repeated postback to succeed, got %v", err)
+	}
+	if client.refunds != 3 {
+		t.Errorf("Expected 3 refund requests, got %d", client.refunds)
+	}
+
+	log.Println("********************************* End TestCallbackFollowUpAfterCommit() **************************************")
+}
+
 // Tests for utils_cancel_project.go
 func TestCancelProject(t *testing.T) {
 	log.Println("********************************* TestCancelProject() **************************************")
 	var testReq RequestCancelProject
 	testReq.FkProjectId = testProjectId
-	err := CancelProject(testReq)
+	err := testService.CancelProject(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	transactionType := "CANCEL_PROJECT"
-	activity, _ := models.ProjectActivitySearchProjectIDTransType(testReq.FkProjectId, transactionType)
+	activity, _ := testService.ProjectActivities.SearchProjectIDTransType(testReq.FkProjectId, transactionType)
 	testActivityId = activity[0].Id
 
 	log.Println("********************************* End TestCancelProject() **************************************")
@@ -665,17 +1674,17 @@ func TestCancelProjectCallbackCancel(t *testing.T) {
 	transactionEventsArray := []interface{}{"0x60e3ee943f7045f7fb7348841aa710c129c58667", true}
 	transactionResponse.TransactionEvents = transactionEventsArray
 	projectActivityId := transactionResponse.ParentID
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = CancelProjectCallback(transactionResponse, targetActivity)
+	err = testService.CancelProjectCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	project, err := models.ProjectFetchById(testProjectId)
+	project, err := testService.Projects.FetchById(testProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -696,22 +1705,22 @@ func TestCancelProjectCallbackNoCancel(t *testing.T) {
 	transactionEventsArray := []interface{}{"0x60e3ee943f7045f7fb7348841aa710c129c58667", false}
 	transactionResponse.TransactionEvents = transactionEventsArray
 	projectActivityId := transactionResponse.ParentID
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = CancelProjectCallback(transactionResponse, targetActivity)
+	err = testService.CancelProjectCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	project, err := models.ProjectFetchById(testProjectId)
+	project, err := testService.Projects.FetchById(testProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	targetActivities, err := models.ProjectActivitySearchProjectID(testProjectId)
+	targetActivities, err := testService.ProjectActivities.SearchProjectID(testProjectId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -732,13 +1741,13 @@ func TestCheckMilestone(t *testing.T) {
 	log.Println("********************************* TestCheckMilestone() **************************************")
 	var testReq RequestCheckMilestones
 	testReq.FkProjectId = testProjectId
-	err := CheckMilestones(testReq)
+	err := testService.CheckMilestones(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	transactionType := "CHECK_MILESTONE"
-	activity, _ := models.ProjectActivitySearchProjectIDTransType(testReq.FkProjectId, transactionType)
+	activity, _ := testService.ProjectActivities.SearchProjectIDTransType(testReq.FkProjectId, transactionType)
 	testActivityId = activity[0].Id
 	log.Println("********************************* End TestCheckMilestone() **************************************")
 }
@@ -754,24 +1763,24 @@ func TestCheckMilestoneCallbackPass(t *testing.T) {
 	var transactionEventsArray []interface{}
 	transactionEventsArray = append(transactionEventsArray, []interface{}{"2000", true})
 	transactionResponse.TransactionEvents = transactionEventsArray
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = CheckMilestoneCallback(transactionResponse, targetActivity)
+	err = testService.CheckMilestoneCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	project, err := models.ProjectFetchById(testProjectId)
+	project, err := testService.Projects.FetchById(testProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
 	time.Sleep(3 * time.Second)
 
-	targetActivities, err := models.ProjectActivitySearchProjectID(testProjectId)
+	targetActivities, err := testService.ProjectActivities.SearchProjectID(testProjectId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -798,22 +1807,22 @@ func TestCheckMilestoneCallbackNoPass(t *testing.T) {
 	var transactionEventsArray []interface{}
 	transactionEventsArray = append(transactionEventsArray, []interface{}{"0", false})
 	transactionResponse.TransactionEvents = transactionEventsArray
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = CheckMilestoneCallback(transactionResponse, targetActivity)
+	err = testService.CheckMilestoneCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	project, err := models.ProjectFetchById(targetActivity.ProjectId)
+	project, err := testService.Projects.FetchById(targetActivity.ProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	targetActivities, err := models.ProjectActivitySearchProjectID(testProjectId)
+	targetActivities, err := testService.ProjectActivities.SearchProjectID(testProjectId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -836,13 +1845,13 @@ func TestCheckMilestoneLastMilestone(t *testing.T) {
 
 	var testReq RequestCheckMilestones
 	testReq.FkProjectId = testProjectId
-	err = CheckMilestones(testReq)
+	err = testService.CheckMilestones(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	transactionType := "CHECK_MILESTONE"
-	activity, _ := models.ProjectActivitySearchProjectIDTransType(testReq.FkProjectId, transactionType)
+	activity, _ := testService.ProjectActivities.SearchProjectIDTransType(testReq.FkProjectId, transactionType)
 	testActivityId = activity[0].Id
 	log.Println("********************************* End TestCheckMilestoneLastMilestone() **************************************")
 }
@@ -858,17 +1867,17 @@ func TestCheckMilestoneCallbackLastMilestone(t *testing.T) {
 	var transactionEventsArray []interface{}
 	transactionEventsArray = append(transactionEventsArray, []interface{}{"2000", true})
 	transactionResponse.TransactionEvents = transactionEventsArray
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = CheckMilestoneCallback(transactionResponse, targetActivity)
+	err = testService.CheckMilestoneCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	project, err := models.ProjectFetchById(targetActivity.ProjectId)
+	project, err := testService.Projects.FetchById(targetActivity.ProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -876,7 +1885,7 @@ func TestCheckMilestoneCallbackLastMilestone(t *testing.T) {
 
 	time.Sleep(3 * time.Second)
 
-	targetActivities, err := models.ProjectActivitySearchProjectID(testProjectId)
+	targetActivities, err := testService.ProjectActivities.SearchProjectID(testProjectId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -885,6 +1894,17 @@ func TestCheckMilestoneCallbackLastMilestone(t *testing.T) {
 		t.Error("WITHDRAW_FUNDS should have been run")
 	}
 
+	// The project ends once the final milestone funds have been withdrawn
+	err = testService.WithdrawFundsCallback(transactionResponse, targetActivities[length])
+	if err != nil {
+		t.Errorf("An error was returned: %d", err)
+	}
+
+	project, err = testService.Projects.FetchById(targetActivity.ProjectId)
+	if err != nil {
+		t.Errorf("An error was returned: %d", err)
+	}
+
 	if project.Status != constants.ProjectEnded {
 		t.Errorf("Incorrect project status: %d", project.Status)
 	}
@@ -897,13 +1917,13 @@ func TestFailedFundRecovery(t *testing.T) {
 	log.Println("********************************* TestFailedFundRecovery() **************************************")
 	var testReq RequestFailedFundRecovery
 	testReq.FkProjectId = testProjectId
-	err := FailedFundRecovery(testReq)
+	err := testService.FailedFundRecovery(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	transactionType := "FAILED_FUND_RECOVERY"
-	activity, _ := models.ProjectActivitySearchProjectIDTransType(testReq.FkProjectId, transactionType)
+	activity, _ := testService.ProjectActivities.SearchProjectIDTransType(testReq.FkProjectId, transactionType)
 	testActivityId = activity[0].Id
 	log.Println("********************************* End TestFailedFundRecovery() **************************************")
 }
@@ -916,22 +1936,22 @@ func TestFailedFundRecoveryCallback(t *testing.T) {
 	transactionResponse.ContractAddress = "0x60e3ee943f7045f7fb7348841aa710c129c58667"
 	transactionResponse.Hash = "0xeef10fc5170f669b86c4cd0444882a96087221325f8bf2f55d6188633aa7be7c"
 	projectActivityId := transactionResponse.ParentID
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = FailedFundRecoveryCallback(transactionResponse, targetActivity)
+	err = testService.FailedFundRecoveryCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	project, err := models.ProjectFetchById(targetActivity.ProjectId)
+	project, err := testService.Projects.FetchById(targetActivity.ProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	targetActivities, err := models.ProjectActivitySearchProjectID(testProjectId)
+	targetActivities, err := testService.ProjectActivities.SearchProjectID(testProjectId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -969,13 +1989,13 @@ func TestCommitModerationVotes(t *testing.T) {
 		"0x8f4a0d1940bbb011db54926c65572b03fd379cfc3c2da3d5765043dd682dc353",
 		"0x8f4a0d1940bbb011db54926c65572b03fd379cfc3c2da3d5765043dd682dc353",
 	}
-	err := CommitModerationVotes(testReq)
+	err := testService.CommitModerationVotes(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	transactionType := "COMMIT_MODERATION_VOTES"
-	activity, _ := models.ProjectActivitySearchProjectIDTransType(testReq.FkProjectId, transactionType)
+	activity, _ := testService.ProjectActivities.SearchProjectIDTransType(testReq.FkProjectId, transactionType)
 	testActivityId = activity[0].Id
 	log.Println("********************************* End TestCommitModerationVotes() **************************************")
 }
@@ -988,24 +2008,24 @@ func TestCommitModerationVoteCallback(t *testing.T) {
 	transactionResponse.ContractAddress = "0x60e3ee943f7045f7fb7348841aa710c129c58667"
 	transactionResponse.Hash = "0xeef10fc5170f669b86c4cd0444882a96087221325f8bf2f55d6188633aa7be7c"
 	projectActivityId := transactionResponse.ParentID
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = CommitModerationVotesCallback(transactionResponse, targetActivity)
+	err = testService.CommitModerationVotesCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	project, err := models.ProjectFetchById(targetActivity.ProjectId)
+	project, err := testService.Projects.FetchById(targetActivity.ProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
 	time.Sleep(2 * time.Second)
 
-	targetActivities, err := models.ProjectActivitySearchProjectID(testProjectId)
+	targetActivities, err := testService.ProjectActivities.SearchProjectID(testProjectId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -1027,13 +2047,13 @@ func TestRequestRefund(t *testing.T) {
 	testReq.UserId = 321
 	testReq.FkProjectId = testProjectId
 	testReq.TransactionType = "REQUEST_REFUND"
-	err := ReleaseFunds(testReq)
+	err := testService.ReleaseFunds(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	transactionType := testReq.TransactionType
-	activity, _ := models.ProjectActivitySearchProjectIDTransType(testReq.FkProjectId, transactionType)
+	activity, _ := testService.ProjectActivities.SearchProjectIDTransType(testReq.FkProjectId, transactionType)
 	testActivityId = activity[0].Id
 	log.Println("********************************* End TestRequestRefund() **************************************")
 }
@@ -1049,17 +2069,17 @@ func TestRequestRefundCallback(t *testing.T) {
 	var transactionEventsArray []interface{}
 	transactionEventsArray = append(transactionEventsArray, []interface{}{"2000", true})
 	transactionResponse.TransactionEvents = transactionEventsArray
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = RequestRefundCallback(transactionResponse, targetActivity)
+	err = testService.RequestRefundCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	project, err := models.ProjectFetchById(testProjectId)
+	project, err := testService.Projects.FetchById(testProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -1085,13 +2105,13 @@ func TestWithdrawFunds(t *testing.T) {
 	testReq.UserId = 123
 	testReq.FkProjectId = testProjectId
 	testReq.TransactionType = "WITHDRAW_FUNDS"
-	err := ReleaseFunds(testReq)
+	err := testService.ReleaseFunds(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	transactionType := testReq.TransactionType
-	activity, _ := models.ProjectActivitySearchProjectIDTransType(testReq.FkProjectId, transactionType)
+	activity, _ := testService.ProjectActivities.SearchProjectIDTransType(testReq.FkProjectId, transactionType)
 	testActivityId = activity[0].Id
 	log.Println("********************************* End TestWithdrawFunds() **************************************")
 }
@@ -1107,17 +2127,17 @@ func TestWithdrawFundsCallback(t *testing.T) {
 	var transactionEventsArray []interface{}
 	transactionEventsArray = append(transactionEventsArray, []interface{}{"2000", true})
 	transactionResponse.TransactionEvents = transactionEventsArray
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = WithdrawFundsCallback(transactionResponse, targetActivity)
+	err = testService.WithdrawFundsCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	project, err := models.ProjectFetchById(testProjectId)
+	project, err := testService.Projects.FetchById(testProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -1142,13 +2162,13 @@ func TestStakePLG(t *testing.T) {
 	var testReq RequestStakePLG
 	testReq.UserId = 231
 	testReq.Amount = 100
-	csModel, err := StakePLG(testReq)
+	csModel, err := testService.StakePLG(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	transactionType := string(constants.StakePLG)
-	activity, _ := models.CSActivitySearchCsIDTransType(csModel.CSId, transactionType)
+	activity, _ := testService.CSActivities.SearchCsIDTransType(csModel.CSId, transactionType)
 	testActivityId = activity[0].Id
 	testCSId = csModel.CSId
 	log.Println("********************************* End TestStakePLG() **************************************")
@@ -1165,17 +2185,17 @@ func TestStakePLGCallback(t *testing.T) {
 	transactionEventsArray = append(transactionEventsArray, []interface{}{"231", "200"})
 	transactionResponse.TransactionEvents = transactionEventsArray
 	csActivityId := transactionResponse.ParentID
-	targetActivity, err := models.CSActivitySearchActivityID(csActivityId)
+	targetActivity, err := testService.CSActivities.SearchActivityID(csActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = StakePLGCallback(transactionResponse, targetActivity)
+	err = testService.StakePLGCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	targetActivity, err = models.CSActivitySearchActivityID(csActivityId)
+	targetActivity, err = testService.CSActivities.SearchActivityID(csActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -1191,7 +2211,7 @@ func TestStakePLGCallback(t *testing.T) {
 		t.Error("CS activity was not created")
 	}
 
-	returnedCS, _ := models.CSSearchCSId(testCSId)
+	returnedCS, _ := testService.CampShares.SearchCSId(testCSId)
 	if returnedCS.Amount <= 0 {
 		t.Error("Amount was not updated")
 	}
@@ -1207,13 +2227,13 @@ func TestUntakePLG(t *testing.T) {
 	log.Println("********************************* TestUnstakePLG() **************************************")
 	var testReq RequestUnstakePLG
 	testReq.UserId = 231
-	csModel, err := UnstakePLG(testReq)
+	csModel, err := testService.UnstakePLG(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	transactionType := string(constants.UnstakePLG)
-	activity, _ := models.CSActivitySearchCsIDTransType(csModel.CSId, transactionType)
+	activity, _ := testService.CSActivities.SearchCsIDTransType(csModel.CSId, transactionType)
 	testActivityId = activity[0].Id
 	testCSId = csModel.CSId
 	log.Println("********************************* End TestUnstakePLG() **************************************")
@@ -1230,17 +2250,17 @@ func TestUnstakePLGCallback(t *testing.T) {
 	transactionEventsArray = append(transactionEventsArray, []interface{}{"231", "100"})
 	transactionResponse.TransactionEvents = transactionEventsArray
 	csActivityId := transactionResponse.ParentID
-	targetActivity, err := models.CSActivitySearchActivityID(csActivityId)
+	targetActivity, err := testService.CSActivities.SearchActivityID(csActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = UnstakePLGCallback(transactionResponse, targetActivity)
+	err = testService.UnstakePLGCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	targetActivity, err = models.CSActivitySearchActivityID(csActivityId)
+	targetActivity, err = testService.CSActivities.SearchActivityID(csActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -1256,7 +2276,7 @@ func TestUnstakePLGCallback(t *testing.T) {
 		t.Error("CS activity was not created")
 	}
 
-	returnedCS, _ := models.CSSearchCSId(testCSId)
+	returnedCS, _ := testService.CampShares.SearchCSId(testCSId)
 	if returnedCS.Amount <= 0 {
 		t.Error("Amount was not updated")
 	}
@@ -1271,13 +2291,13 @@ func TestWithdrawPLG(t *testing.T) {
 	log.Println("********************************* RequestWithdrawInterest() **************************************")
 	var testReq RequestWithdrawInterest
 	testReq.UserId = 231
-	csModel, err := WithdrawInterest(testReq)
+	csModel, err := testService.WithdrawInterest(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	transactionType := string(constants.WithdrawInterest)
-	activity, _ := models.CSActivitySearchCsIDTransType(csModel.CSId, transactionType)
+	activity, _ := testService.CSActivities.SearchCsIDTransType(csModel.CSId, transactionType)
 	testActivityId = activity[0].Id
 	testCSId = csModel.CSId
 	log.Println("********************************* End RequestWithdrawInterest() **************************************")
@@ -1294,17 +2314,17 @@ func TestWithdrawInterestCallback(t *testing.T) {
 	var transactionEventsArray []interface{}
 	transactionEventsArray = append(transactionEventsArray, []interface{}{"231", "100"})
 	transactionResponse.TransactionEvents = transactionEventsArray
-	targetActivity, err := models.CSActivitySearchActivityID(csActivityId)
+	targetActivity, err := testService.CSActivities.SearchActivityID(csActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = WithdrawInterestCallback(transactionResponse, targetActivity)
+	err = testService.WithdrawInterestCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	targetActivity, err = models.CSActivitySearchActivityID(csActivityId)
+	targetActivity, err = testService.CSActivities.SearchActivityID(csActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -1316,7 +2336,7 @@ func TestWithdrawInterestCallback(t *testing.T) {
 		t.Errorf("Incorrect activity status: %d", targetActivity.Status)
 	}
 
-	returnedCS, _ := models.CSSearchCSId(testCSId)
+	returnedCS, _ := testService.CampShares.SearchCSId(testCSId)
 	if returnedCS.Amount <= 0 {
 		t.Error("Amount was not updated")
 	}
@@ -1328,12 +2348,12 @@ func TestReinvestPLG(t *testing.T) {
 	log.Println("********************************* TestReinvestPLG() **************************************")
 	var testReq RequestReinvestPLG
 	testReq.UserId = 231
-	csModel, err := ReinvestPLG(testReq)
+	csModel, err := testService.ReinvestPLG(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 	transactionType := string(constants.ReinvestPLG)
-	activity, _ := models.CSActivitySearchCsIDTransType(csModel.CSId, transactionType)
+	activity, _ := testService.CSActivities.SearchCsIDTransType(csModel.CSId, transactionType)
 	testActivityId = activity[0].Id
 	testCSId = csModel.CSId
 	log.Println("********************************* End TestReinvestPLG() **************************************")
@@ -1351,17 +2371,17 @@ func TestReinvestPLGCallback(t *testing.T) {
 	transactionEventsArray = append(transactionEventsArray, []interface{}{"231", "744"})
 	transactionResponse.TransactionEvents = transactionEventsArray
 	csActivityId := transactionResponse.ParentID
-	targetActivity, err := models.CSActivitySearchActivityID(csActivityId)
+	targetActivity, err := testService.CSActivities.SearchActivityID(csActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = ReinvestPLGCallback(transactionResponse, targetActivity)
+	err = testService.ReinvestPLGCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	targetActivity, err = models.CSActivitySearchActivityID(csActivityId)
+	targetActivity, err = testService.CSActivities.SearchActivityID(csActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -1373,7 +2393,7 @@ func TestReinvestPLGCallback(t *testing.T) {
 		t.Errorf("Incorrect activity status: %d", targetActivity.Status)
 	}
 
-	returnedCS, _ := models.CSSearchCSId(testCSId)
+	returnedCS, _ := testService.CampShares.SearchCSId(testCSId)
 	if returnedCS.Amount <= 0 {
 		t.Error("Amount was not updated")
 	}
@@ -1388,12 +2408,12 @@ func TestPostInterest(t *testing.T) {
 	log.Println("********************************* TestPostInterest() **************************************")
 	var testReq RequestPostInterest
 	testReq.Amount = 2000
-	csModel, err := PostInterest(testReq)
+	csModel, err := testService.PostInterest(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 	transactionType := string(constants.PostInterest)
-	activity, _ := models.CSActivitySearchCsIDTransType(csModel.CSId, transactionType)
+	activity, _ := testService.CSActivities.SearchCsIDTransType(csModel.CSId, transactionType)
 	testActivityId = activity[0].Id
 	testCSId = csModel.CSId
 	log.Println("********************************* End TestPostInterest() **************************************")
@@ -1410,17 +2430,17 @@ func TestPostInterestCallback(t *testing.T) {
 	transactionEventsArray = append(transactionEventsArray, "2000")
 	transactionResponse.TransactionEvents = transactionEventsArray
 	csActivityId := transactionResponse.ParentID
-	targetActivity, err := models.CSActivitySearchActivityID(csActivityId)
+	targetActivity, err := testService.CSActivities.SearchActivityID(csActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = PostInterestCallback(transactionResponse, targetActivity)
+	err = testService.PostInterestCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	targetActivity, err = models.CSActivitySearchActivityID(csActivityId)
+	targetActivity, err = testService.CSActivities.SearchActivityID(csActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -1432,7 +2452,7 @@ func TestPostInterestCallback(t *testing.T) {
 		t.Errorf("Incorrect activity status: %d", targetActivity.Status)
 	}
 
-	returnedCS, _ := models.CSSearchCSId(testCSId)
+	returnedCS, _ := testService.CampShares.SearchCSId(testCSId)
 	if returnedCS.Amount <= 0 {
 		t.Error("Amount was not updated")
 	}
@@ -1447,13 +2467,13 @@ func TestSetModerators(t *testing.T) {
 	testReq.Moderators = []int64{12345678, 87654321}
 	testReq.ModerationEndTime = 1719810543
 	testReq.FkProjectId = testProjectId
-	err := SetModerators(testReq)
+	err := testService.SetModerators(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	transactionType := "START_MODERATION"
-	activity, _ := models.ProjectActivitySearchProjectIDTransType(testReq.FkProjectId, transactionType)
+	activity, _ := testService.ProjectActivities.SearchProjectIDTransType(testReq.FkProjectId, transactionType)
 	testActivityId = activity[0].Id
 	log.Println("********************************* End TestSetModerators() **************************************")
 }
@@ -1466,17 +2486,17 @@ func TestSetModeratorsCallback(t *testing.T) {
 	transactionResponse.ContractAddress = "0x60e3ee943f7045f7fb7348841aa710c129c58667"
 	transactionResponse.Hash = "0xeef10fc5170f669b86c4cd0444882a96087221325f8bf2f55d6188633aa7be7c"
 	projectActivityId := transactionResponse.ParentID
-	targetActivity, err := models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err := testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = SetModeratorsCallback(transactionResponse, targetActivity)
+	err = testService.SetModeratorsCallback(transactionResponse, targetActivity)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
 
-	project, err := models.ProjectFetchById(targetActivity.ProjectId)
+	project, err := testService.Projects.FetchById(targetActivity.ProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -1485,7 +2505,7 @@ func TestSetModeratorsCallback(t *testing.T) {
 		t.Errorf("Incorrect project status: %d", project.Status)
 	}
 
-	targetActivity, err = models.ProjectActivitySearchActivityID(projectActivityId)
+	targetActivity, err = testService.ProjectActivities.SearchActivityID(projectActivityId)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -1503,7 +2523,7 @@ func TestGetBalance(t *testing.T) {
 	log.Println("********************************* TestGetBalance() **************************************")
 	var testReq RequestUserBalance
 	testReq.UserId = 231
-	_, err := GetBalance(testReq)
+	_, err := testService.GetBalance(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
@@ -1516,7 +2536,7 @@ func TestGetGains(t *testing.T) {
 	log.Println("********************************* TestGetGains() **************************************")
 	var testReq RequestCsGains
 	testReq.UserId = 231
-	_, err := CsGains(testReq)
+	_, err := testService.CsGains(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
@@ -1529,12 +2549,12 @@ func TestProjectGetState(t *testing.T) {
 	log.Println("********************************* TestProjectGetState() **************************************")
 	var testReq RequestProjectState
 	testReq.ProjectId = testProjectId
-	_, err := ProjectGetState(testReq)
+	_, err := testService.ProjectGetState(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
-	_, err = models.ProjectFetchById(testReq.ProjectId)
+	_, err = testService.Projects.FetchById(testReq.ProjectId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -1547,14 +2567,14 @@ func TestCsGetState(t *testing.T) {
 	log.Println("********************************* TestCsGetState() **************************************")
 	var testReq RequestCsState
 	testReq.UserId = 231
-	csState, err := CsGetState(testReq)
+	csState, err := testService.CsGetState(testReq)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
 
 	var expectedBalance int
 
-	csList, err := models.GetCSByUserId(testReq.UserId)
+	csList, err := testService.CampShares.GetByUserId(testReq.UserId)
 	if err != nil {
 		log.Printf("An error was returned: %d", err)
 	}
@@ -1568,7 +2588,7 @@ func TestCsGetState(t *testing.T) {
 		t.Errorf("An error occurred in the calculation of current_balance.  Expected: %v, Retrieved: %v", expectedBalance, csState.CurrentBalance)
 	}
 
-	_, err = models.GetCSByUserId(testReq.UserId)
+	_, err = testService.CampShares.GetByUserId(testReq.UserId)
 	if err != nil {
 		t.Errorf("An error was returned: %d", err)
 	}
@@ -3,25 +3,24 @@ package utils
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
-	"log"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/imroc/req"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
-func UnstakePLG(unstakeRequest RequestUnstakePLG) (CampShares, error) {
-	// fmt.Printf("%+v\n", project)
+func (s *Service) UnstakePLG(unstakeRequest RequestUnstakePLG) (CampShares, error) {
+	s = s.withFields(logger.Fields{"user_id": unstakeRequest.UserId})
 	userId := strconv.Itoa(unstakeRequest.UserId)
 	activityReference := string(constants.UnstakePLG)
 
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/cs/" + userId + "/callback/" + activityReference
-	nodeServerURL := "/manager/cs/" + userId + "/" + activityReference
+	oracleCallbackURL := s.Config.App.Domain + "/cs/" + userId + "/callback/" + activityReference
 
 	cs := models.CampShares{
 		UserId:          unstakeRequest.UserId,
@@ -34,9 +33,9 @@ func UnstakePLG(unstakeRequest RequestUnstakePLG) (CampShares, error) {
 	inrec, _ := json.Marshal(cs)
 	json.Unmarshal(inrec, &inInterface)
 
-	latestCs, err := models.GetlatestCSId()
+	latestCs, err := s.CampShares.GetLatest()
 	if err != nil {
-		log.Println(err)
+		logger.Error(s.context(), err)
 	}
 	csId := latestCs.CSId + 1
 	if csId < 1 {
@@ -46,96 +45,97 @@ func UnstakePLG(unstakeRequest RequestUnstakePLG) (CampShares, error) {
 	cs.CSId = csId
 
 	// Set unstake complete date
-	unstakePeriod := os.Getenv("CS_UNSTAKE_PERIOD")
-	unstakePeriodInt, err := strconv.Atoi(unstakePeriod)
-	if err != nil {
-		fmt.Println("Error occurred with converting Interest Interval: " + unstakePeriod)
-	}
-	cs.UnstakeCompleteDate = time.Now().Add(time.Second * time.Duration(unstakePeriodInt))
+	cs.UnstakeCompleteDate = time.Now().Add(s.Config.CampShares.UnstakePeriod)
 
-	// Input CS transaction into CampShare model
-	_, err = models.CSInsert(cs)
-	if err != nil {
-		log.Fatal(err)
-	}
+	var csActivity models.CSActivity
+	err = s.Atomic(func(tx models.Store) error {
+		// Input CS transaction into CampShare model
+		var err error
+		_, err = tx.CampShares.Insert(cs)
+		if err != nil {
+			return err
+		}
 
-	// Create cs activity for tracking purposes
-	csActivity, err := models.SetCSActivity(csId, constants.UnstakePLG)
+		// Create cs activity for tracking purposes
+		csActivity, err = models.SetCSActivity(tx.CSActivities, csId, constants.UnstakePLG)
+		return err
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return cs, err
 	}
+	s = s.withFields(logger.Fields{"activity_id": csActivity.Id})
 
 	// Send request to unstake PLG for CS to Nodeserver
-	requestParameters := req.Param{
-		"transaction_type": activityReference,
-		"user_id":          cs.UserId,
-		"activity_id":      csActivity.Id,
-		"url_callback":     oracleCallbackURL,
-	}
-
-	_, err = PostNodeServer(requestParameters, nodeServerURL)
+	_, err = s.NodeServer.UnstakePLG(s.context(), nodeserver.UserRequest{
+		UserId:   cs.UserId,
+		Callback: nodeserver.Callback{ActivityId: csActivity.Id, URL: oracleCallbackURL},
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return cs, err
 	}
 
 	return cs, nil
 }
 
-func UnstakePLGCallback(transactionResponse NodeServerModel, csActivity models.CSActivity) error {
+func (s *Service) UnstakePLGCallback(transactionResponse NodeServerModel, csActivity models.CSActivity) error {
 
 	// Only process if Nodeserver postback response successful
-	log.Printf("Project status: %v", transactionResponse.Status)
+	logger.Infof(s.context(), "Project status: %v", transactionResponse.Status)
 
 	if transactionResponse.Status == structs.Complete {
 
 		// Get the unstake amount from event from Nodeserver
 		var unstakeAmount int
 		unstakeResultInterface := transactionResponse.TransactionEvents.([]interface{})
-		log.Println("Unstake result")
-		log.Println(unstakeResultInterface)
+		logger.Debug(s.context(), "Unstake result")
+		logger.Debug(s.context(), unstakeResultInterface)
 
 		for _, unstakes := range unstakeResultInterface {
 			unstakeItem := unstakes.([]interface{})
 			unstakeAmount, _ = strconv.Atoi(unstakeItem[1].(string))
 		}
 
-		// Update Activity status to success
-		csActivity.Status = constants.ActivitySuccess
-		csActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.CSActivityUpdateFields(csActivity)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Update project status & completed activity
-		cs, err := models.CSSearchCSId(csActivity.CsId)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Amount reflects unstake movement in DB table
-		cs.Amount = unstakeAmount
-		cs.BalanceMovement = -unstakeAmount
-
-		_, err = models.CSUpdateFields(cs)
-		if err != nil {
-			log.Println(err)
-		}
-
-		// Send response back to backend if activities required are completed
-		userId := strconv.Itoa(cs.UserId)
-		backendURL := "/events/blockchain/cs/" + userId + "/" + string(constants.UnstakePLGEvent)
-		requestParameters := req.Param{
-			"event_type":  constants.UnstakePLGEvent,
-			"activity_id": csActivity.Id,
-			"user_id":     cs.UserId,
-			"status":      true,
-			"amount":      cs.Amount,
-		}
-		_, err = PostBackend(requestParameters, backendURL)
+		var cs CampShares
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity status to success
+			csActivity.Status = constants.ActivitySuccess
+			csActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.CSActivities.UpdateFields(csActivity)
+			if err != nil {
+				return err
+			}
+
+			// Update project status & completed activity
+			cs, err = tx.CampShares.SearchCSId(csActivity.CsId)
+			if err != nil {
+				return errs.Store(err, "Could not find CS entry %d", csActivity.CsId)
+			}
+
+			// Amount reflects unstake movement in DB table
+			cs.Amount = unstakeAmount
+			cs.BalanceMovement = -unstakeAmount
+
+			_, err = tx.CampShares.UpdateFields(cs)
+			if err != nil {
+				return err
+			}
+
+			// Queue the backend event with the changes
+			userId := strconv.Itoa(cs.UserId)
+			backendURL := "/events/blockchain/cs/" + userId + "/" + string(constants.UnstakePLGEvent)
+			requestParameters := req.Param{
+				"event_type":  constants.UnstakePLGEvent,
+				"activity_id": csActivity.Id,
+				"user_id":     cs.UserId,
+				"status":      true,
+				"amount":      cs.Amount,
+			}
+			return s.enqueueBackend(tx, csEvents(cs.UserId), backendURL, requestParameters)
+		})
 		if err != nil {
-			log.Fatal(err)
+			logger.Error(s.context(), err)
 			return err
 		}
 
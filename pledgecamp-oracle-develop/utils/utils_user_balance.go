@@ -1,47 +1,36 @@
 package utils
 
 import (
-	"log"
 	"strconv"
 
 	"github.com/imroc/req"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 )
 
 // GetBalance()
-func GetBalance(balanceRequest RequestUserBalance) (int, error) {
+func (s *Service) GetBalance(balanceRequest RequestUserBalance) (int, error) {
+	s = s.withFields(logger.Fields{"user_id": balanceRequest.UserId})
 	userId := strconv.Itoa(balanceRequest.UserId)
-	activityReference := string(constants.GetBalance)
 
-	nodeServerURL := "/manager/users/" + userId + "/" + activityReference
-
-	// Send request to get balance to Nodeserver
-	requestParameters := req.Param{
-		"user_id": balanceRequest.UserId,
-	}
-
-	resp, err := GetNodeServer(requestParameters, nodeServerURL)
+	// Request the balance from Nodeserver
+	responseValue, err := s.NodeServer.GetBalance(s.context(), balanceRequest.UserId)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return 0, err
 	}
 
-	responseValue, err := strconv.Atoi(resp.String())
-	if err != nil {
-		return responseValue, err
-	}
-
-	// Send response back to backend if activities required are completed
+	// Queue the result for the backend
 	backendURL := "/events/blockchain/users/" + userId + "/" + string(constants.GetBalanceEvent) + "/"
-	requestParameters = req.Param{
+	requestParameters := req.Param{
 		"event_type": constants.GetBalanceEvent,
 		"user_id":    balanceRequest.UserId,
 		"status":     true,
 		"balance":    responseValue,
 	}
-	_, err = PostBackend(requestParameters, backendURL)
+	err = s.enqueueBackend(s.Store, userEvents(balanceRequest.UserId), backendURL, requestParameters)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return responseValue, err
 	}
 
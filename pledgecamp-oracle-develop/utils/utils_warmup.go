@@ -1,14 +1,11 @@
 package utils
 
 import (
-	"errors"
-	"fmt"
-	"log"
-	"os"
-	"strconv"
+	"context"
 	"time"
 
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
 )
 
@@ -44,115 +41,138 @@ func SetInterval(testFunc func(), milliseconds int, async bool) chan bool {
 
 }
 
-func milestoneInterval(projects []models.Project) {
-	log.Println("~~~~~~~~~~Checking for milestones~~~~~~~~~~~~~~~~~")
+// checkDueMilestone - send CHECK_MILESTONE for a project in the milestone phase once it reached its next
+// milestone, unless a check is already waiting for Nodeserver. A milestone still to come is timed instead.
+// Returns whether the check was sent
+func (s *Service) checkDueMilestone(project Project) (bool, error) {
+	if project.Status != constants.ProjectMilestonePhase {
+		milestoneTimers.cancel(project.Id)
+		return false, nil
+	}
+
+	// Initial setup of NextActivityDate
+	if project.NextActivityDate.Year() <= 1970 {
+		milestones := project.ProjectParameters["milestones"]
+		convertedMilestones := make([]int64, len(milestones.([]interface{})))
+		for i := range milestones.([]interface{}) {
+			convertedMilestones[i] = int64(milestones.([]interface{})[i].(float64))
+		}
+		project.NextActivityDate = time.Unix(convertedMilestones[0], 0)
+		_, err := s.Projects.UpdateFields(project)
+		if err != nil {
+			logger.Error(s.context(), err)
+			return false, err
+		}
+	}
+	if project.NextActivityDate.After(time.Now()) {
+		timeMilestone(project)
+		return false, nil
+	}
+
+	activities, err := s.ProjectActivities.SearchProjectIDTransType(project.Id, string(constants.CheckMilestone))
+	if err != nil {
+		logger.Error(s.context(), err)
+		return false, err
+	}
+	if activityPending(activities) {
+		logger.Debugf(s.context(), "Milestone check of project %v is waiting for Nodeserver", project.Id)
+		return false, nil
+	}
+
+	logger.Debugf(s.context(), "Checking milestone for project: %v, %v", project.Id, project.ContractAddress)
+	err = s.CheckMilestones(RequestCheckMilestones{FkProjectId: project.Id})
+	if err != nil {
+		logger.Error(s.context(), err)
+		return false, err
+	}
+	return true, nil
+}
+
+// milestoneInterval - check the milestones of the projects that reached them, the last error is returned
+// once every project has been checked. Projects left when ctx is cancelled are checked on the next run
+func (s *Service) milestoneInterval(ctx context.Context, projects []models.Project) (checked int, failed error) {
+	logger.Info(s.context(), "~~~~~~~~~~Checking for milestones~~~~~~~~~~~~~~~~~")
 
 	for _, project := range projects {
-		// Check projects only in the milestone phase that have passed their nextActivityDate
-		switch project.Status {
-		case constants.ProjectMilestonePhase:
-			// Initial setup of NextActivityDate
-			if project.NextActivityDate.Year() <= 1970 {
-				milestones := project.ProjectParameters["milestones"]
-				convertedMilestones := make([]int64, len(milestones.([]interface{})))
-				for i := range milestones.([]interface{}) {
-					convertedMilestones[i] = int64(milestones.([]interface{})[i].(float64))
-				}
-				project.NextActivityDate = time.Unix(convertedMilestones[0], 0)
-				_, err = models.ProjectUpdateFields(project)
-				if err != nil {
-					log.Fatal(err)
-				}
-			}
-			if time.Now().Format("2006-01-02 15:04:05") >= project.NextActivityDate.Format("2006-01-02 15:04:05") && project.Status != constants.ProjectModerationPhase {
-				var milestoneRequest RequestCheckMilestones
-				milestoneRequest.FkProjectId = project.Id
-				log.Printf("Checking milestone for project: %v, %v", project.Id, project.ContractAddress)
-				CheckMilestones(milestoneRequest)
-			}
+		if ctx.Err() != nil {
+			logger.Info(s.context(), "Milestone check stopped before project ", project.Id)
+			return checked, failed
+		}
+		sent, err := s.checkDueMilestone(project)
+		if err != nil {
+			failed = err
+			continue
+		}
+		if sent {
+			checked++
 		}
 	}
+	return checked, failed
 }
 
-func recoveryInterval(projects []models.Project) {
-	log.Println("~~~~~~~~~~Recovery of funds from projects~~~~~~~~~~~~~~~~~")
+// recoveryInterval - recover the funds left in projects completed over 90 days ago, the last error is
+// returned once every project has been handled. Projects left when ctx is cancelled are handled on the next run
+func (s *Service) recoveryInterval(ctx context.Context, projects []models.Project) (recovered int, failed error) {
+	logger.Info(s.context(), "~~~~~~~~~~Recovery of funds from projects~~~~~~~~~~~~~~~~~")
 
 	for _, project := range projects {
-		log.Println(project.Id, project.CompletedAt, project.NextActivityDate)
+		if ctx.Err() != nil {
+			logger.Info(s.context(), "Fund recovery stopped before project ", project.Id)
+			return recovered, failed
+		}
+		logger.Debug(s.context(), project.Id, project.CompletedAt, project.NextActivityDate)
 		dateDiff := project.CompletedAt.Sub(project.NextActivityDate)
-		log.Println(dateDiff)
+		logger.Debug(s.context(), dateDiff)
 		diffDays := dateDiff.Minutes() / 24
-		log.Println(diffDays)
+		logger.Debug(s.context(), diffDays)
 		if project.Status != constants.ProjectFundsRecovered && diffDays > 90 {
-			log.Printf("Retrieving leftover funds for project %v", project.Id)
+			logger.Infof(s.context(), "Retrieving leftover funds for project %v", project.Id)
 			var recoveryRequest RequestFailedFundRecovery
 			recoveryRequest.FkProjectId = project.Id
-			err = FailedFundRecovery(recoveryRequest)
+			err := s.FailedFundRecovery(recoveryRequest)
 			if err != nil {
-				log.Fatal(err)
+				logger.Error(s.context(), err)
+				failed = err
+				continue
 			}
+			recovered++
 		}
 	}
+	return recovered, failed
 }
 
-// Warmup function to implement interval checks
-func Warmup() error {
-
-	initialRun := true
-
-	/*
-		Milestone Interval
-	*/
-
-	intervalMS := os.Getenv("INTERVALS_CHECK_MILESTONE")
-	intervalMSNum, err := strconv.Atoi(intervalMS)
+// milestoneJob - check the milestones that were reached and not checked yet, those the milestone timers
+// missed while no instance was leading or Nodeserver failed
+func (s *Service) milestoneJob(ctx context.Context) (int, error) {
+	dueProjects, err := s.Projects.FetchMilestonesDue(time.Now())
 	if err != nil {
-		fmt.Printf("Error occurred with converting Milestone Interval: %v", intervalMS)
+		logger.Error(s.context(), "Could not get projects due for a milestone check: ", err)
+		return 0, err
 	}
+	return s.milestoneInterval(ctx, dueProjects)
+}
 
-	// Interval function to run checkMilestone for projects reaching milestone date
-	SetInterval(func() {
-		activeProjects, err := models.ProjectFetchActive()
-		if err != nil {
-			log.Fatal("Could not get active projects")
-		}
-
-		milestoneInterval(activeProjects)
-	}, intervalMSNum, false)
-
-	/*
-		Failed Funds Recovery Interval
-	*/
-
-	intervalRecov := os.Getenv("INTERVALS_FUND_RECOVERY")
-	intervalRecovNum, err := strconv.Atoi(intervalRecov)
+// recoveryJob - recover the funds left in the completed projects
+func (s *Service) recoveryJob(ctx context.Context) (int, error) {
+	completedProjects, err := s.Projects.FetchCompleted()
 	if err != nil {
-		fmt.Printf("Error occurred with converting Failed Funds Recovery Interval: %v", intervalRecov)
+		logger.Error(s.context(), "Could not get completed projects: ", err)
+		return 0, err
 	}
-	completedProjects, err := models.ProjectFetchCompleted()
-	if err != nil {
-		return errors.New("Could not get completed projects")
-	}
-
-	// Interval function to get remaining funds from projects after 90 days
-	SetInterval(func() {
-		recoveryInterval(completedProjects)
-		log.Println(intervalRecovNum)
-	}, intervalRecovNum, false)
-
-	if initialRun {
-
-		activeProjects, err := models.ProjectFetchActive()
-		if err != nil {
-			return errors.New("Could not get active projects")
-		}
-
-		milestoneInterval(activeProjects)
-
-		recoveryInterval(completedProjects)
+	return s.recoveryInterval(ctx, completedProjects)
+}
 
-		initialRun = false
+// Warmup - register the jobs of the jobs table and start the worker that runs them. The scheduler leader
+// runs the due jobs at once, without waiting for the first poll
+func (s *Service) Warmup() error {
+	if err := s.RegisterJobs(); err != nil {
+		logger.Error(s.context(), err)
+		return err
 	}
+	s.StartJobWorker()
 
-	return nil
+	if !leading(time.Now()) {
+		return nil
+	}
+	return s.runJobs(context.Background())
 }
@@ -3,24 +3,25 @@ package utils
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/imroc/req"
 	"github.com/pledgecamp/pledgecamp-oracle/constants"
+	"github.com/pledgecamp/pledgecamp-oracle/errs"
+	"github.com/pledgecamp/pledgecamp-oracle/logger"
 	"github.com/pledgecamp/pledgecamp-oracle/models"
+	"github.com/pledgecamp/pledgecamp-oracle/nodeserver"
 	"github.com/pledgecamp/pledgecamp-oracle/structs"
 )
 
 // WithdrawInterest() - Withdraw accrued interest in PLG
-func WithdrawInterest(withdrawRequest RequestWithdrawInterest) (CampShares, error) {
+func (s *Service) WithdrawInterest(withdrawRequest RequestWithdrawInterest) (CampShares, error) {
+	s = s.withFields(logger.Fields{"user_id": withdrawRequest.UserId})
 	userId := strconv.Itoa(withdrawRequest.UserId)
 	activityReference := string(constants.WithdrawInterest)
 
-	nodeServerURL := "/manager/cs/" + userId + "/" + activityReference
-	oracleCallbackURL := os.Getenv("APP_DOMAIN") + "/cs/" + userId + "/callback/" + activityReference
+	oracleCallbackURL := s.Config.App.Domain + "/cs/" + userId + "/callback/" + activityReference
 
 	cs := models.CampShares{
 		UserId:              withdrawRequest.UserId,
@@ -33,9 +34,9 @@ func WithdrawInterest(withdrawRequest RequestWithdrawInterest) (CampShares, erro
 	inrec, _ := json.Marshal(cs)
 	json.Unmarshal(inrec, &inInterface)
 
-	latestCs, err := models.GetlatestCSId()
+	latestCs, err := s.CampShares.GetLatest()
 	if err != nil {
-		log.Println(err)
+		logger.Error(s.context(), err)
 	}
 	csId := latestCs.CSId + 1
 	if csId < 1 {
@@ -44,88 +45,94 @@ func WithdrawInterest(withdrawRequest RequestWithdrawInterest) (CampShares, erro
 
 	cs.CSId = csId
 
-	// Input CS transaction into CampShare model
-	cs, err = models.CSInsert(cs)
-	if err != nil {
-		log.Fatal(err)
-	}
+	var csActivity models.CSActivity
+	err = s.Atomic(func(tx models.Store) error {
+		// Input CS transaction into CampShare model
+		var err error
+		cs, err = tx.CampShares.Insert(cs)
+		if err != nil {
+			return err
+		}
 
-	// Create cs activity for tracking purposes
-	csActivity, err := models.SetCSActivity(csId, constants.WithdrawInterest)
+		// Create cs activity for tracking purposes
+		csActivity, err = models.SetCSActivity(tx.CSActivities, csId, constants.WithdrawInterest)
+		return err
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
+		return cs, err
 	}
+	s = s.withFields(logger.Fields{"activity_id": csActivity.Id})
 
 	// Send request to withdraw PLG interest to Nodeserver
-	requestParameters := req.Param{
-		"transaction_type": activityReference,
-		"user_id":          cs.UserId,
-		"activity_id":      csActivity.Id,
-		"url_callback":     oracleCallbackURL,
-	}
-
-	_, err = PostNodeServer(requestParameters, nodeServerURL)
+	_, err = s.NodeServer.WithdrawInterest(s.context(), nodeserver.UserRequest{
+		UserId:   cs.UserId,
+		Callback: nodeserver.Callback{ActivityId: csActivity.Id, URL: oracleCallbackURL},
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(s.context(), err)
 		return cs, err
 	}
 
 	return cs, nil
 }
 
-func WithdrawInterestCallback(transactionResponse NodeServerModel, csActivity models.CSActivity) error {
+func (s *Service) WithdrawInterestCallback(transactionResponse NodeServerModel, csActivity models.CSActivity) error {
 
 	// Only process if Nodeserver postback response successful
-	log.Printf("Project status: %v", transactionResponse.Status)
+	logger.Infof(s.context(), "Project status: %v", transactionResponse.Status)
 
 	if transactionResponse.Status == structs.Complete {
 
 		// Get the withdrawal amount from event from Nodeserver
 		var withdrawalAmount int
 		withdrawResultInterface := transactionResponse.TransactionEvents.([]interface{})
-		log.Println("Withdraw result")
-		log.Println(withdrawResultInterface)
+		logger.Debug(s.context(), "Withdraw result")
+		logger.Debug(s.context(), withdrawResultInterface)
 
 		for _, withdrawals := range withdrawResultInterface {
 			withdrawal := withdrawals.([]interface{})
 			withdrawalAmount, _ = strconv.Atoi(withdrawal[1].(string))
 		}
 
-		// Update Activity status to success
-		csActivity.Status = constants.ActivitySuccess
-		csActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
-		_, err := models.CSActivityUpdateFields(csActivity)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Update project status & completed activity
-		withdrawalCS, err := models.CSSearchCSId(csActivity.CsId)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// Amount reflects interest in PLG received
-		withdrawalCS.Amount = withdrawalAmount
-
-		_, err = models.CSUpdateFields(withdrawalCS)
-		if err != nil {
-			log.Println(err)
-		}
-
-		// Send response back to backend if activities required are completed
-		userId := strconv.Itoa(withdrawalCS.UserId)
-		backendURL := "/events/blockchain/cs/" + userId + "/" + string(constants.WithdrawInterestEvent) + "/"
-		requestParameters := req.Param{
-			"event_type":  constants.WithdrawInterestEvent,
-			"activity_id": csActivity.Id,
-			"user_id":     withdrawalCS.UserId,
-			"status":      true,
-			"amount":      withdrawalAmount,
-		}
-		_, err = PostBackend(requestParameters, backendURL)
+		var withdrawalCS CampShares
+		err := s.Atomic(func(tx models.Store) error {
+			// Update Activity status to success
+			csActivity.Status = constants.ActivitySuccess
+			csActivity.TransactionHash = sql.NullString{String: transactionResponse.Hash, Valid: true}
+			_, err := tx.CSActivities.UpdateFields(csActivity)
+			if err != nil {
+				return err
+			}
+
+			// Update project status & completed activity
+			withdrawalCS, err = tx.CampShares.SearchCSId(csActivity.CsId)
+			if err != nil {
+				return errs.Store(err, "Could not find CS entry %d", csActivity.CsId)
+			}
+
+			// Amount reflects interest in PLG received
+			withdrawalCS.Amount = withdrawalAmount
+
+			_, err = tx.CampShares.UpdateFields(withdrawalCS)
+			if err != nil {
+				return err
+			}
+
+			// Queue the backend event with the changes
+			userId := strconv.Itoa(withdrawalCS.UserId)
+			backendURL := "/events/blockchain/cs/" + userId + "/" + string(constants.WithdrawInterestEvent) + "/"
+			requestParameters := req.Param{
+				"event_type":  constants.WithdrawInterestEvent,
+				"activity_id": csActivity.Id,
+				"user_id":     withdrawalCS.UserId,
+				"status":      true,
+				"amount":      withdrawalAmount,
+			}
+			return s.enqueueBackend(tx, csEvents(withdrawalCS.UserId), backendURL, requestParameters)
+		})
 		if err != nil {
-			log.Fatal(err)
+			logger.Error(s.context(), err)
 			return err
 		}
 	}